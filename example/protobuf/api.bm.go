@@ -30,7 +30,7 @@ var UserSvc UserBMServer
 
 func userInfo(c *bm.Context) {
 	p := new(UserReq)
-	if err := c.BindWith(p, binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type"))); err != nil {
+	if err := c.BindWith(p, binding.WithHeader(binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type")))); err != nil {
 		return
 	}
 	resp, err := UserSvc.Info(c, p)
@@ -39,7 +39,7 @@ func userInfo(c *bm.Context) {
 
 func userCard(c *bm.Context) {
 	p := new(UserReq)
-	if err := c.BindWith(p, binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type"))); err != nil {
+	if err := c.BindWith(p, binding.WithHeader(binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type")))); err != nil {
 		return
 	}
 	resp, err := UserSvc.Card(c, p)
@@ -52,3 +52,37 @@ func RegisterUserBMServer(e *bm.Engine, server UserBMServer) {
 	e.GET("/user.api.User/Info", userInfo)
 	e.GET("/user.api.User/Card", userCard)
 }
+
+// UserBMClient is the blademaster client API for User service.
+type UserBMClient interface {
+	Info(ctx context.Context, req *UserReq) (resp *InfoReply, err error)
+
+	Card(ctx context.Context, req *UserReq) (resp *google_protobuf1.Empty, err error)
+}
+
+type userBMClient struct {
+	client *bm.Client
+	host   string
+}
+
+// NewUserBMClient new a blademaster client of User service, host is like http://127.0.0.1:8000.
+// The path of each method is the key of bm.ClientConfig.URL to tune the timeout and breaker.
+func NewUserBMClient(client *bm.Client, host string) UserBMClient {
+	return &userBMClient{client: client, host: host}
+}
+
+func (c *userBMClient) Info(ctx context.Context, req *UserReq) (resp *InfoReply, err error) {
+	out := new(InfoReply)
+	if err = c.client.Call(ctx, "GET", c.host, "/user.api.User/Info", req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userBMClient) Card(ctx context.Context, req *UserReq) (resp *google_protobuf1.Empty, err error) {
+	out := new(google_protobuf1.Empty)
+	if err = c.client.Call(ctx, "GET", c.host, "/user.api.User/Card", req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
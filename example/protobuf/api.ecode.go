@@ -12,6 +12,6 @@ var _ ecode.Codes
 
 // UserErrCode ecode
 var (
-	UserNotExist         = ecode.New(-404)
+	UserNotExist         = ecode.New(10001)
 	UserUpdateNameFailed = ecode.New(10000)
 )
@@ -28,19 +28,19 @@ type UserErrCode int32
 
 const (
 	UserErrCode_OK                   UserErrCode = 0
-	UserErrCode_UserNotExist         UserErrCode = -404
+	UserErrCode_UserNotExist         UserErrCode = 10001
 	UserErrCode_UserUpdateNameFailed UserErrCode = 10000
 )
 
 var UserErrCode_name = map[int32]string{
 	0:     "OK",
-	-404:  "UserNotExist",
 	10000: "UserUpdateNameFailed",
+	10001: "UserNotExist",
 }
 
 var UserErrCode_value = map[string]int32{
 	"OK":                   0,
-	"UserNotExist":         -404,
+	"UserNotExist":         10001,
 	"UserUpdateNameFailed": 10000,
 }
 
@@ -133,6 +133,7 @@ func (m *Info) GetSign() string {
 
 type UserReq struct {
 	Mid                  int64    `protobuf:"varint,1,opt,name=mid,proto3" json:"mid,omitempty" validate:"gt=0,required"`
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty" header:"X-Token"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -178,6 +179,13 @@ func (m *UserReq) GetMid() int64 {
 	return 0
 }
 
+func (m *UserReq) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
 type InfoReply struct {
 	Info                 *Info    `protobuf:"bytes,1,opt,name=info,proto3" json:"info,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -235,30 +243,32 @@ func init() {
 func init() { proto.RegisterFile("api.proto", fileDescriptor_00212fb1f9d3bf1c) }
 
 var fileDescriptor_00212fb1f9d3bf1c = []byte{
-	// 366 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x51, 0x4d, 0x4b, 0xeb, 0x40,
-	0x14, 0xed, 0x34, 0x79, 0xfd, 0x98, 0x3e, 0x1e, 0x7d, 0xf3, 0x9e, 0x90, 0x96, 0x92, 0x96, 0xac,
-	0x8a, 0x68, 0x2a, 0x15, 0x04, 0x05, 0x37, 0x95, 0x0a, 0x52, 0xa8, 0x10, 0xe8, 0xc6, 0xdd, 0xb4,
-	0x99, 0xc4, 0x81, 0x26, 0x93, 0xe6, 0x43, 0xea, 0xbf, 0x70, 0x25, 0xfe, 0x24, 0x97, 0xfe, 0x82,
-	0x22, 0x75, 0x57, 0x5c, 0xb9, 0x16, 0x94, 0x7b, 0x5b, 0xa9, 0x38, 0x8b, 0xc3, 0x9c, 0x39, 0xf7,
-	0x70, 0xcf, 0xbd, 0x43, 0xcb, 0x3c, 0x92, 0x76, 0x14, 0xab, 0x54, 0xb1, 0x52, 0x96, 0x88, 0xd8,
-	0xe6, 0x91, 0xac, 0xef, 0xfb, 0x32, 0xbd, 0xce, 0xc6, 0xf6, 0x44, 0x05, 0x1d, 0x5f, 0xf9, 0xaa,
-	0x83, 0x05, 0xe3, 0xcc, 0x43, 0x86, 0x04, 0x6f, 0x6b, 0xa3, 0x75, 0x4f, 0xa8, 0x7e, 0x11, 0x7a,
-	0x8a, 0xd5, 0xa8, 0x16, 0x48, 0xd7, 0x20, 0x2d, 0xd2, 0xd6, 0x7a, 0xc5, 0xd5, 0xa2, 0x09, 0xd4,
-	0x01, 0x60, 0x0d, 0xaa, 0x87, 0x3c, 0x10, 0x46, 0xbe, 0x45, 0xda, 0xe5, 0x5e, 0x69, 0xb5, 0x68,
-	0x22, 0x77, 0x10, 0xc1, 0x98, 0x88, 0xb9, 0xa1, 0xa1, 0x88, 0xc6, 0x44, 0xcc, 0x1d, 0x00, 0x30,
-	0x7a, 0x7c, 0x22, 0x0c, 0x7d, 0x6b, 0x04, 0xee, 0x20, 0x82, 0x9a, 0x48, 0x3f, 0x34, 0x7e, 0x6d,
-	0x55, 0xe0, 0x0e, 0xa2, 0x75, 0x4c, 0x8b, 0xa3, 0x44, 0xc4, 0x8e, 0x98, 0x31, 0xfb, 0x7b, 0xb4,
-	0xc6, 0xdb, 0xa2, 0x69, 0xdc, 0xf0, 0xa9, 0x74, 0x79, 0x2a, 0x4e, 0x2c, 0x3f, 0x3d, 0x3d, 0xd8,
-	0x8b, 0xc5, 0x2c, 0x93, 0xb1, 0x70, 0x2d, 0xcc, 0x6b, 0x75, 0x68, 0x19, 0x46, 0x72, 0x44, 0x34,
-	0xbd, 0x65, 0x16, 0xd5, 0x65, 0xe8, 0x29, 0x74, 0x57, 0xba, 0x7f, 0xec, 0xaf, 0x45, 0xd9, 0x58,
-	0x82, 0xda, 0xee, 0x80, 0x56, 0xa0, 0x57, 0x3f, 0x8e, 0xcf, 0x94, 0x2b, 0x58, 0x81, 0xe6, 0x2f,
-	0x07, 0xd5, 0x1c, 0xab, 0xd1, 0xdf, 0xf0, 0x3c, 0x54, 0x69, 0x7f, 0x2e, 0x93, 0xb4, 0xfa, 0xfa,
-	0xfe, 0xb1, 0x3e, 0x84, 0xd5, 0xe8, 0x7f, 0x90, 0x46, 0x11, 0xa4, 0x18, 0xf2, 0x40, 0x9c, 0x73,
-	0x39, 0x15, 0x6e, 0xf5, 0x6e, 0xd8, 0x3d, 0xa2, 0x3a, 0x48, 0xcc, 0xde, 0x2c, 0xf6, 0xef, 0xb6,
-	0xe5, 0x66, 0xa0, 0xfa, 0xbf, 0x1f, 0x29, 0x20, 0x68, 0x6f, 0xe7, 0x71, 0x69, 0x92, 0xa7, 0xa5,
-	0x49, 0x9e, 0x97, 0x26, 0x79, 0x78, 0x31, 0x73, 0x57, 0x1a, 0x8f, 0xe4, 0xb8, 0x80, 0xff, 0x74,
-	0xf8, 0x19, 0x00, 0x00, 0xff, 0xff, 0x9d, 0xa7, 0xb7, 0x22, 0xed, 0x01, 0x00, 0x00,
+	// 387 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x51, 0x5d, 0x8b, 0xd3, 0x40,
+	0x14, 0xdd, 0x6c, 0xb2, 0x1f, 0x9d, 0x15, 0xc9, 0x8e, 0x2b, 0x64, 0x97, 0x25, 0x29, 0xf3, 0x54,
+	0x8a, 0x4d, 0xa5, 0x82, 0x0f, 0x05, 0x5f, 0x5a, 0x2a, 0x88, 0x10, 0x61, 0xb0, 0x20, 0xbe, 0x4d,
+	0x9b, 0x9b, 0x74, 0xb0, 0xc9, 0xa4, 0x93, 0x44, 0xea, 0xbf, 0xd0, 0x17, 0xf1, 0x27, 0xf9, 0xe8,
+	0x2f, 0x08, 0x52, 0xdf, 0xfa, 0xd8, 0x5f, 0x20, 0x77, 0x5a, 0xa9, 0xec, 0xcb, 0x61, 0xce, 0x3d,
+	0xf7, 0x70, 0xcf, 0xbd, 0x43, 0x5a, 0xa2, 0x90, 0x61, 0xa1, 0x55, 0xa5, 0xe8, 0x65, 0x5d, 0x82,
+	0x0e, 0x45, 0x21, 0xef, 0x7a, 0xa9, 0xac, 0x16, 0xf5, 0x2c, 0x9c, 0xab, 0xac, 0x9f, 0xaa, 0x54,
+	0xf5, 0x4d, 0xc3, 0xac, 0x4e, 0x0c, 0x33, 0xc4, 0xbc, 0xf6, 0x46, 0xf6, 0xdd, 0x22, 0xce, 0x9b,
+	0x3c, 0x51, 0xf4, 0x96, 0xd8, 0x99, 0x8c, 0x3d, 0xab, 0x6d, 0x75, 0xec, 0xd1, 0xc5, 0xb6, 0x09,
+	0x90, 0x72, 0x04, 0x7a, 0x4f, 0x9c, 0x5c, 0x64, 0xe0, 0x9d, 0xb6, 0xad, 0x4e, 0x6b, 0x74, 0xb9,
+	0x6d, 0x02, 0xc3, 0xb9, 0x41, 0x34, 0x96, 0xb0, 0xf6, 0x6c, 0x23, 0x1a, 0x63, 0x09, 0x6b, 0x8e,
+	0x80, 0xc6, 0x44, 0xcc, 0xc1, 0x73, 0x8e, 0x46, 0xe4, 0xdc, 0x20, 0xaa, 0xa5, 0x4c, 0x73, 0xef,
+	0xec, 0xa8, 0x22, 0xe7, 0x06, 0x19, 0x90, 0x8b, 0x69, 0x09, 0x9a, 0xc3, 0x8a, 0x86, 0xff, 0x47,
+	0xbb, 0xdf, 0x35, 0x81, 0xf7, 0x59, 0x2c, 0x65, 0x2c, 0x2a, 0x18, 0xb2, 0xb4, 0x7a, 0xf5, 0xfc,
+	0x99, 0x86, 0x55, 0x2d, 0x35, 0xc4, 0x6c, 0x9f, 0xb7, 0x4b, 0xce, 0x2a, 0xf5, 0x09, 0xf2, 0x43,
+	0xe0, 0x9b, 0x5d, 0x13, 0xb8, 0x0b, 0x10, 0x31, 0xe8, 0x21, 0xfb, 0xd0, 0x7b, 0x8f, 0x12, 0xe3,
+	0xfb, 0x16, 0xd6, 0x27, 0x2d, 0x5c, 0x9f, 0x43, 0xb1, 0xfc, 0x42, 0x19, 0x71, 0x64, 0x9e, 0x28,
+	0x33, 0xe9, 0x6a, 0xf0, 0x38, 0xfc, 0x77, 0xd4, 0xd0, 0xb4, 0x18, 0xad, 0x3b, 0x26, 0x57, 0x98,
+	0x6b, 0xa2, 0xf5, 0x58, 0xc5, 0x40, 0xcf, 0xc9, 0xe9, 0xbb, 0xb7, 0xee, 0x09, 0xbd, 0x26, 0x8f,
+	0xb0, 0x1c, 0xa9, 0x6a, 0xb2, 0x96, 0x65, 0xe5, 0x7e, 0x8b, 0xe8, 0x2d, 0xb9, 0xc1, 0xd2, 0xb4,
+	0xc0, 0xa4, 0x91, 0xc8, 0xe0, 0xb5, 0x90, 0x4b, 0x88, 0xdd, 0xaf, 0xd1, 0xe0, 0x25, 0x71, 0x50,
+	0xa2, 0xe1, 0xe1, 0xf8, 0xd7, 0xc7, 0x51, 0x87, 0xa5, 0xef, 0x9e, 0x3c, 0x98, 0x8e, 0x01, 0x47,
+	0x4f, 0x7f, 0x6e, 0x7c, 0xeb, 0xd7, 0xc6, 0xb7, 0x7e, 0x6f, 0x7c, 0xeb, 0xc7, 0x1f, 0xff, 0xe4,
+	0xa3, 0x2d, 0x0a, 0x39, 0x3b, 0x37, 0x7f, 0xf9, 0xe2, 0xef, 0x00, 0xb3, 0x9b, 0x29, 0x4b, 0x11,
+	0x02, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -403,6 +413,12 @@ func (m *UserReq) MarshalTo(dAtA []byte) (int, error) {
 		i++
 		i = encodeVarintApi(dAtA, i, uint64(m.Mid))
 	}
+	if len(m.Token) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintApi(dAtA, i, uint64(len(m.Token)))
+		i += copy(dAtA[i:], m.Token)
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -489,6 +505,10 @@ func (m *UserReq) Size() (n int) {
 	if m.Mid != 0 {
 		n += 1 + sovApi(uint64(m.Mid))
 	}
+	l = len(m.Token)
+	if l > 0 {
+		n += 1 + l + sovApi(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -773,6 +793,38 @@ func (m *UserReq) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Token", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowApi
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthApi
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Token = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipApi(dAtA[iNdEx:])
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	google_protobuf1 "github.com/golang/protobuf/ptypes/empty"
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/netutil/breaker"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userService struct{}
+
+func (userService) Info(ctx context.Context, req *UserReq) (*InfoReply, error) {
+	c := ctx.(*bm.Context)
+	// the header field is sent as the header only.
+	if c.Request.URL.Query().Get("token") != "" || c.Request.Header.Get("X-Token") != req.Token {
+		return nil, ecode.RequestErr
+	}
+	if req.Mid == 404 {
+		return nil, UserNotExist
+	}
+	return &InfoReply{Info: &Info{Mid: req.Mid, Name: req.Token}}, nil
+}
+
+func (userService) Card(ctx context.Context, req *UserReq) (*google_protobuf1.Empty, error) {
+	return &google_protobuf1.Empty{}, nil
+}
+
+func TestUserBM(t *testing.T) {
+	engine := bm.NewServer(&bm.ServerConfig{Timeout: xtime.Duration(time.Second)})
+	RegisterUserBMServer(engine, userService{})
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+
+	client := NewUserBMClient(bm.NewClient(&bm.ClientConfig{
+		Dial:    xtime.Duration(time.Second),
+		Timeout: xtime.Duration(time.Second),
+		Breaker: &breaker.Config{
+			Window:  xtime.Duration(time.Second),
+			Bucket:  10,
+			K:       1.5,
+			Request: 100,
+		},
+	}), srv.URL)
+	ctx := context.Background()
+
+	reply, err := client.Info(ctx, &UserReq{Mid: 1, Token: "secret"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(1), reply.Info.Mid)
+	assert.Equal(t, "secret", reply.Info.Name)
+	_, err = client.Info(ctx, &UserReq{Mid: 404})
+	assert.Equal(t, UserNotExist.Code(), ecode.Cause(err).Code())
+	_, err = client.Info(ctx, &UserReq{Token: "secret"})
+	assert.Equal(t, ecode.RequestErr.Code(), ecode.Cause(err).Code())
+	_, err = client.Card(ctx, &UserReq{Mid: 1})
+	assert.NoError(t, err)
+
+	// the header field is encoded by protobuf as well.
+	bs, err := proto.Marshal(&UserReq{Mid: 1, Token: "secret"})
+	assert.NoError(t, err)
+	req := new(UserReq)
+	assert.NoError(t, proto.Unmarshal(bs, req))
+	assert.Equal(t, "secret", req.Token)
+}
@@ -0,0 +1,186 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+)
+
+var (
+	// the backoff of resubscribing the channel.
+	_subMinBackoff = 100 * time.Millisecond
+	_subMaxBackoff = 5 * time.Second
+)
+
+// InvalidationConfig is the config of the invalidation across the instances.
+type InvalidationConfig struct {
+	Redis *redis.Config
+	// Channel is the channel of the invalidated keys, "local_cache:" and the
+	// cache name by default.
+	Channel string
+}
+
+type invalidation struct {
+	ID   string   `json:"id"`
+	Keys []string `json:"keys"`
+}
+
+// invalidator publishes the invalidated keys, and deletes the keys published
+// by the other instances from the cache. The cache is purged if the
+// subscription is broken, since the messages may be missed.
+type invalidator struct {
+	c     *InvalidationConfig
+	id    string
+	cache *Cache
+	pub   *redis.Redis
+
+	mu     sync.Mutex
+	sub    redis.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newInvalidator(c *Config, cache *Cache) *invalidator {
+	ic := c.Invalidation
+	if ic.Redis == nil {
+		panic("local cache: must config the redis of invalidation")
+	}
+	if ic.Channel == "" {
+		ic.Channel = "local_cache:" + c.Name
+	}
+	host, _ := os.Hostname()
+	inv := &invalidator{
+		c:      ic,
+		id:     fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		cache:  cache,
+		pub:    redis.NewRedis(ic.Redis),
+		closed: make(chan struct{}),
+	}
+	go inv.watch()
+	return inv
+}
+
+// Invalidate deletes the keys from the local cache, and from the caches of the
+// other instances if Invalidation is set.
+func (c *Cache) Invalidate(ctx context.Context, keys ...string) error {
+	c.Delete(keys...)
+	return c.broadcast(ctx, keys...)
+}
+
+// broadcast deletes the keys from the caches of the other instances only.
+func (c *Cache) broadcast(ctx context.Context, keys ...string) error {
+	if c.inv == nil || len(keys) == 0 {
+		return nil
+	}
+	return c.inv.publish(ctx, keys)
+}
+
+func (inv *invalidator) publish(ctx context.Context, keys []string) error {
+	msg, err := json.Marshal(&invalidation{ID: inv.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+	_, err = inv.pub.Do(ctx, "PUBLISH", inv.c.Channel, msg)
+	return err
+}
+
+func (inv *invalidator) watch() {
+	backoff := _subMinBackoff
+	for {
+		subscribed, err := inv.subscribe()
+		select {
+		case <-inv.closed:
+			return
+		default:
+		}
+		log.Error("local cache: subscribe channel(%s) error(%v)", inv.c.Channel, err)
+		if subscribed {
+			// NOTE: the messages may be missed until resubscribed.
+			inv.cache.Purge()
+			backoff = _subMinBackoff
+		}
+		select {
+		case <-time.After(backoff):
+		case <-inv.closed:
+			return
+		}
+		if backoff *= 2; backoff > _subMaxBackoff {
+			backoff = _subMaxBackoff
+		}
+	}
+}
+
+func (inv *invalidator) subscribe() (subscribed bool, err error) {
+	rc := inv.c.Redis
+	// NOTE: no read timeout for waiting the messages.
+	c, err := redis.Dial(rc.Proto, rc.Addr,
+		redis.DialConnectTimeout(time.Duration(rc.DialTimeout)),
+		redis.DialWriteTimeout(time.Duration(rc.WriteTimeout)),
+		redis.DialPassword(rc.Auth))
+	if err != nil {
+		return
+	}
+	inv.mu.Lock()
+	select {
+	case <-inv.closed:
+		inv.mu.Unlock()
+		c.Close()
+		return
+	default:
+	}
+	inv.sub = c
+	inv.mu.Unlock()
+	defer func() {
+		inv.mu.Lock()
+		inv.sub = nil
+		inv.mu.Unlock()
+		c.Close()
+	}()
+	psc := redis.PubSubConn{Conn: c}
+	if err = psc.Subscribe(inv.c.Channel); err != nil {
+		return
+	}
+	for {
+		switch m := psc.Receive().(type) {
+		case redis.Subscription:
+			if m.Kind == "subscribe" {
+				subscribed = true
+				// catch up the messages missed before subscribing.
+				inv.cache.Purge()
+			}
+		case redis.Message:
+			inv.handle(m.Data)
+		case error:
+			return subscribed, m
+		}
+	}
+}
+
+func (inv *invalidator) handle(data []byte) {
+	msg := new(invalidation)
+	if err := json.Unmarshal(data, msg); err != nil {
+		log.Warn("local cache: bad invalidation(%s) of channel(%s) error(%v)", data, inv.c.Channel, err)
+		return
+	}
+	if msg.ID != inv.id {
+		inv.cache.Delete(msg.Keys...)
+	}
+}
+
+func (inv *invalidator) Close() error {
+	inv.once.Do(func() {
+		inv.mu.Lock()
+		close(inv.closed)
+		if inv.sub != nil {
+			inv.sub.Close()
+		}
+		inv.mu.Unlock()
+	})
+	return inv.pub.Close()
+}
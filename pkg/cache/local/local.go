@@ -0,0 +1,349 @@
+// Package local provides a sharded and size bounded in process cache, which
+// can be used as the first level cache in front of memcache or redis.
+package local
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+)
+
+const (
+	// PolicyLRU evicts the least recently used entry.
+	PolicyLRU = "lru"
+	// PolicyTinyLFU evicts the least recently used entry too, but the new
+	// entry is only admitted if it's accessed more frequently than the victim.
+	PolicyTinyLFU = "tinylfu"
+)
+
+// Config is the config of the local cache.
+type Config struct {
+	Name   string // the cache name, for metrics
+	Shards int    // the number of the shards, 16 by default
+	Size   int    // the max number of the entries, 10000 by default
+	Policy string // the eviction policy, tinylfu by default
+	// Expire is the time to live of the entries, they never expire if 0,
+	// which is required by the two level caches.
+	// A random jitter in [0, Jitter) is added to every entry, so that the
+	// entries set together don't expire at once.
+	Expire xtime.Duration
+	Jitter xtime.Duration
+
+	// Invalidation broadcasts the invalidated keys to the other instances by
+	// the redis pub/sub, which is required by the two level caches if there
+	// are multiple instances.
+	Invalidation *InvalidationConfig
+}
+
+// Cache is a local cache, the keys are distributed to the shards which are
+// locked separately.
+type Cache struct {
+	c      *Config
+	shards []*shard
+	mask   uint64
+	inv    *invalidator
+}
+
+// New creates a local cache.
+func New(c *Config) *Cache {
+	if c.Shards <= 0 {
+		c.Shards = 16
+	}
+	if c.Size <= 0 {
+		c.Size = 10000
+	}
+	if c.Policy == "" {
+		c.Policy = PolicyTinyLFU
+	}
+	c.Policy = strings.ToLower(c.Policy)
+	if c.Policy != PolicyLRU && c.Policy != PolicyTinyLFU {
+		panic("local cache: unknown policy " + c.Policy)
+	}
+	// NOTE: the number of the shards is a power of two for masking the hash.
+	n := 1
+	for n < c.Shards {
+		n <<= 1
+	}
+	size := (c.Size + n - 1) / n
+	cache := &Cache{c: c, mask: uint64(n - 1)}
+	for i := 0; i < n; i++ {
+		cache.shards = append(cache.shards, newShard(c.Name, size, c.Policy == PolicyTinyLFU))
+	}
+	if c.Invalidation != nil {
+		cache.inv = newInvalidator(c, cache)
+	}
+	return cache
+}
+
+// fnv-1a
+func hash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func (c *Cache) shard(h uint64) *shard {
+	return c.shards[h&c.mask]
+}
+
+// Get returns the value of the key, ok is false if the key is absent or
+// expired.
+func (c *Cache) Get(key string) (value interface{}, ok bool) {
+	h := hash(key)
+	return c.shard(h).get(key, h)
+}
+
+// Set sets the value of the key with the expire of the config, it returns
+// false if the key isn't admitted by the tinylfu policy.
+func (c *Cache) Set(key string, value interface{}) bool {
+	return c.SetWithExpire(key, value, time.Duration(c.c.Expire))
+}
+
+// SetWithExpire sets the value of the key with the expire, the key never
+// expires if the expire is 0. The jitter of the config is added.
+func (c *Cache) SetWithExpire(key string, value interface{}, expire time.Duration) bool {
+	h := hash(key)
+	return c.shard(h).set(key, h, value, c.deadline(expire, 0))
+}
+
+// deadline returns the deadline of the expire with the jitter, which is
+// capped at the limit if the limit is positive.
+func (c *Cache) deadline(expire, limit time.Duration) (deadline time.Time) {
+	if expire > 0 && c.c.Jitter > 0 {
+		expire += time.Duration(rand.Int63n(int64(c.c.Jitter)))
+	}
+	if limit > 0 && (expire <= 0 || limit < expire) {
+		expire = limit
+	}
+	if expire > 0 {
+		deadline = time.Now().Add(expire)
+	}
+	return
+}
+
+// fill loads the value of the key by load and sets it with the expire of the
+// config capped at the limit returned by load. The value isn't set if load
+// fails, or the key is deleted or the cache is purged while loading, so that
+// the stale value loaded before an invalidation isn't cached.
+func (c *Cache) fill(key string, load func() (value interface{}, limit time.Duration, err error)) error {
+	h := hash(key)
+	s := c.shard(h)
+	epoch := s.startFill(key)
+	value, limit, err := load()
+	s.endFill(key, h, epoch, value, c.deadline(time.Duration(c.c.Expire), limit), err == nil)
+	return err
+}
+
+// Delete deletes the keys from the local cache only, use Invalidate for
+// deleting the keys from the other instances too.
+func (c *Cache) Delete(keys ...string) {
+	for _, key := range keys {
+		h := hash(key)
+		c.shard(h).delete(key)
+	}
+}
+
+// Purge deletes all the keys from the local cache.
+func (c *Cache) Purge() {
+	for _, s := range c.shards {
+		s.purge()
+	}
+}
+
+// Len returns the number of the entries, including the expired ones which
+// are not evicted yet.
+func (c *Cache) Len() (n int) {
+	for _, s := range c.shards {
+		n += s.len()
+	}
+	return
+}
+
+// Close stops receiving the invalidated keys of the other instances.
+func (c *Cache) Close() error {
+	if c.inv != nil {
+		return c.inv.Close()
+	}
+	return nil
+}
+
+type shard struct {
+	name string
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// the doubly linked list of the entries, the most recently used first.
+	head, tail *entry
+	sketch     *sketch
+	// the keys being filled, which are dirty if deleted while filling, the
+	// epoch is increased by purging.
+	fills map[string]int
+	dirty map[string]struct{}
+	epoch uint64
+}
+
+type entry struct {
+	key        string
+	hash       uint64
+	value      interface{}
+	deadline   time.Time
+	prev, next *entry
+}
+
+func newShard(name string, size int, tinylfu bool) *shard {
+	s := &shard{
+		name:    name,
+		size:    size,
+		entries: make(map[string]*entry, size),
+		fills:   make(map[string]int),
+		dirty:   make(map[string]struct{}),
+	}
+	if tinylfu {
+		s.sketch = newSketch(size)
+	}
+	return s
+}
+
+func (s *shard) get(key string, h uint64) (interface{}, bool) {
+	s.mu.Lock()
+	if s.sketch != nil {
+		s.sketch.increment(h)
+	}
+	e, ok := s.entries[key]
+	if ok && !e.deadline.IsZero() && time.Now().After(e.deadline) {
+		s.remove(e)
+		s.mu.Unlock()
+		_metricEvictions.Inc(s.name, "expire")
+		_metricMisses.Inc(s.name)
+		return nil, false
+	}
+	if !ok {
+		s.mu.Unlock()
+		_metricMisses.Inc(s.name)
+		return nil, false
+	}
+	s.moveToFront(e)
+	value := e.value
+	s.mu.Unlock()
+	_metricHits.Inc(s.name)
+	return value, true
+}
+
+func (s *shard) set(key string, h uint64, value interface{}, deadline time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setLocked(key, h, value, deadline)
+}
+
+func (s *shard) setLocked(key string, h uint64, value interface{}, deadline time.Time) bool {
+	if e, ok := s.entries[key]; ok {
+		e.value, e.deadline = value, deadline
+		s.moveToFront(e)
+		return true
+	}
+	if len(s.entries) >= s.size {
+		victim := s.tail
+		if !victim.deadline.IsZero() && time.Now().After(victim.deadline) {
+			_metricEvictions.Inc(s.name, "expire")
+		} else if s.sketch != nil && s.sketch.estimate(h) <= s.sketch.estimate(victim.hash) {
+			// NOTE: the victim is more valuable than the new one.
+			return false
+		} else {
+			_metricEvictions.Inc(s.name, "size")
+		}
+		s.remove(victim)
+	}
+	e := &entry{key: key, hash: h, value: value, deadline: deadline}
+	s.entries[key] = e
+	s.pushFront(e)
+	return true
+}
+
+func (s *shard) startFill(key string) (epoch uint64) {
+	s.mu.Lock()
+	s.fills[key]++
+	epoch = s.epoch
+	s.mu.Unlock()
+	return
+}
+
+func (s *shard) endFill(key string, h uint64, epoch uint64, value interface{}, deadline time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dirty := s.dirty[key]; ok && !dirty && epoch == s.epoch {
+		s.setLocked(key, h, value, deadline)
+	}
+	if s.fills[key]--; s.fills[key] == 0 {
+		delete(s.fills, key)
+		delete(s.dirty, key)
+	}
+}
+
+func (s *shard) delete(key string) {
+	s.mu.Lock()
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+	if _, ok := s.fills[key]; ok {
+		s.dirty[key] = struct{}{}
+	}
+	s.mu.Unlock()
+}
+
+func (s *shard) purge() {
+	s.mu.Lock()
+	s.epoch++
+	s.entries = make(map[string]*entry, s.size)
+	s.head, s.tail = nil, nil
+	s.mu.Unlock()
+}
+
+func (s *shard) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *shard) pushFront(e *entry) {
+	e.prev, e.next = nil, s.head
+	if s.head != nil {
+		s.head.prev = e
+	}
+	s.head = e
+	if s.tail == nil {
+		s.tail = e
+	}
+}
+
+func (s *shard) unlink(e *entry) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		s.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		s.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
+
+func (s *shard) moveToFront(e *entry) {
+	if s.head != e {
+		s.unlink(e)
+		s.pushFront(e)
+	}
+}
+
+func (s *shard) remove(e *entry) {
+	s.unlink(e)
+	delete(s.entries, e.key)
+}
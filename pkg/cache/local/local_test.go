@@ -0,0 +1,312 @@
+package local
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/memcache"
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	c := New(&Config{Name: "test", Shards: 1, Size: 2, Policy: PolicyLRU})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	// b is the least recently used one.
+	assert.True(t, c.Set("c", 3))
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	c.Set("a", 10)
+	v, _ = c.Get("a")
+	assert.Equal(t, 10, v)
+	c.Delete("a", "absent")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestTinyLFU(t *testing.T) {
+	c := New(&Config{Name: "test", Shards: 1, Size: 2})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	for i := 0; i < 5; i++ {
+		c.Get("a")
+		c.Get("b")
+	}
+	// the one hit wonder isn't admitted.
+	assert.False(t, c.Set("c", 3))
+	_, ok := c.Get("b")
+	assert.True(t, ok)
+	// the frequently accessed key evicts the victim.
+	for i := 0; i < 10; i++ {
+		c.Get("c")
+	}
+	assert.True(t, c.Set("c", 3))
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(8)
+	h := hash("a")
+	for i := 0; i < 10; i++ {
+		s.increment(h)
+	}
+	assert.Equal(t, uint8(10), s.estimate(h))
+	assert.Equal(t, uint8(0), s.estimate(hash("b")))
+	// the counters are halved periodically.
+	for i := 0; i < 70; i++ {
+		s.increment(hash("x" + strconv.Itoa(i)))
+	}
+	assert.True(t, s.estimate(h) >= 5 && s.estimate(h) < 10, s.estimate(h))
+}
+
+func TestExpire(t *testing.T) {
+	c := New(&Config{
+		Name:   "test",
+		Shards: 4,
+		Expire: xtime.Duration(50 * time.Millisecond),
+		Jitter: xtime.Duration(50 * time.Millisecond),
+	})
+	c.Set("a", 1)
+	c.SetWithExpire("b", 2, 0)
+	s := c.shard(hash("a"))
+	s.mu.Lock()
+	ttl := time.Until(s.entries["a"].deadline)
+	s.mu.Unlock()
+	assert.True(t, ttl > 0 && ttl <= 100*time.Millisecond, ttl)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	time.Sleep(110 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestConcurrent(t *testing.T) {
+	c := New(&Config{Name: "test", Size: 100})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa((i * j) % 300)
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, j)
+				}
+				if j%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.True(t, c.Len() <= 112, c.Len())
+}
+
+func TestInvalidationHandle(t *testing.T) {
+	c := New(&Config{Name: "test"})
+	inv := &invalidator{c: &InvalidationConfig{Channel: "test"}, id: "self", cache: c}
+	c.Set("a", 1)
+	c.Set("b", 2)
+	inv.handle([]byte(`{"id":"self","keys":["a"]}`))
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	inv.handle([]byte(`{"id":"other","keys":["a","b"]}`))
+	inv.handle([]byte(`bad`))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestDecode(t *testing.T) {
+	var b []byte
+	item := &memcache.Item{Key: "a", Value: []byte("raw")}
+	assert.Nil(t, decode(item, &b))
+	b[0] = 'R'
+	assert.Equal(t, "raw", string(item.Value))
+
+	var v struct{ Name string }
+	assert.Nil(t, decode(&memcache.Item{Key: "b", Value: []byte(`{"Name":"n"}`), Flags: memcache.FlagJSON}, &v))
+	assert.Equal(t, "n", v.Name)
+}
+
+func TestFill(t *testing.T) {
+	c := New(&Config{Name: "test", Expire: xtime.Duration(time.Minute)})
+	deadline := func(key string) time.Time {
+		s := c.shard(hash(key))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.entries[key].deadline
+	}
+
+	// the expire is capped at the limit.
+	assert.Nil(t, c.fill("a", func() (interface{}, time.Duration, error) { return 1, time.Second, nil }))
+	assert.True(t, time.Until(deadline("a")) <= time.Second)
+	assert.Nil(t, c.fill("b", func() (interface{}, time.Duration, error) { return 2, 0, nil }))
+	assert.True(t, time.Until(deadline("b")) > time.Second)
+
+	// the values loaded before the invalidation aren't cached.
+	assert.Nil(t, c.fill("c", func() (interface{}, time.Duration, error) {
+		c.Delete("c")
+		return 3, 0, nil
+	}))
+	_, ok := c.Get("c")
+	assert.False(t, ok)
+	assert.Nil(t, c.fill("c", func() (interface{}, time.Duration, error) {
+		c.Purge()
+		return 3, 0, nil
+	}))
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+	assert.NotNil(t, c.fill("c", func() (interface{}, time.Duration, error) { return 3, 0, io.EOF }))
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+	assert.Nil(t, c.fill("c", func() (interface{}, time.Duration, error) { return 3, 0, nil }))
+	v, _ := c.Get("c")
+	assert.Equal(t, 3, v)
+	s := c.shard(hash("c"))
+	assert.Len(t, s.fills, 0)
+	assert.Len(t, s.dirty, 0)
+}
+
+func TestExpiration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), expiration(0))
+	assert.Equal(t, time.Minute, expiration(60))
+	d := expiration(int32(time.Now().Add(time.Hour * 24 * 31).Unix()))
+	assert.True(t, d > 30*24*time.Hour && d <= 31*24*time.Hour, d)
+	assert.Equal(t, time.Nanosecond, expiration(-1))
+}
+
+// runRedis runs a redis server of GET and PTTL, the hook is called before
+// replying GET.
+func runRedis(t *testing.T, data map[string]string, ttls map[string]int64, hook func(key string)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			var n int
+			if _, err := fmt.Fscanf(br, "*%d\r\n", &n); err != nil {
+				return
+			}
+			args := make([]string, n)
+			for i := range args {
+				var l int
+				if _, err := fmt.Fscanf(br, "$%d\r\n", &l); err != nil {
+					return
+				}
+				b := make([]byte, l+2)
+				if _, err := io.ReadFull(br, b); err != nil {
+					return
+				}
+				args[i] = string(b[:l])
+			}
+			switch args[0] {
+			case "GET":
+				hook(args[1])
+				if v, ok := data[args[1]]; ok {
+					fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+				} else {
+					conn.Write([]byte("$-1\r\n"))
+				}
+			case "PTTL":
+				ttl, ok := ttls[args[1]]
+				if _, exist := data[args[1]]; !exist {
+					ttl = -2
+				} else if !ok {
+					ttl = -1
+				}
+				fmt.Fprintf(conn, ":%d\r\n", ttl)
+			default:
+				conn.Write([]byte("-ERR unknown command\r\n"))
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedis(t *testing.T) {
+	assert.Panics(t, func() { NewRedis(&Config{Name: "test"}, nil) })
+
+	var r *Redis
+	addr := runRedis(t, map[string]string{"a": "1", "b": "2", "race": "old"}, map[string]int64{"b": 1000}, func(key string) {
+		if key == "race" {
+			// the key is invalidated while filling.
+			r.Local().Delete(key)
+		}
+	})
+	rc := redis.NewRedis(&redis.Config{
+		Config:       &pool.Config{Active: 10, Idle: 2, IdleTimeout: xtime.Duration(time.Minute)},
+		Name:         "test",
+		Proto:        "tcp",
+		Addr:         addr,
+		DialTimeout:  xtime.Duration(time.Second),
+		ReadTimeout:  xtime.Duration(time.Second),
+		WriteTimeout: xtime.Duration(time.Second),
+	})
+	defer rc.Close()
+	r = NewRedis(&Config{Name: "test", Expire: xtime.Duration(time.Minute)}, rc)
+	defer r.Close()
+	ctx := context.Background()
+	ttl := func(key string) time.Duration {
+		s := r.local.shard(hash(key))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		e, ok := s.entries[key]
+		if !ok {
+			return -1
+		}
+		return time.Until(e.deadline)
+	}
+
+	for _, key := range []string{"a", "b", "absent", "race"} {
+		_, err := r.Get(ctx, key)
+		assert.Nil(t, err)
+	}
+	// the expire is capped at the ttl of the key, and the nil replies expire.
+	assert.True(t, ttl("a") > 59*time.Second, ttl("a"))
+	assert.True(t, ttl("b") > 0 && ttl("b") <= time.Second, ttl("b"))
+	assert.True(t, ttl("absent") > 59*time.Second, ttl("absent"))
+	assert.Equal(t, time.Duration(-1), ttl("race"))
+
+	v, err := redis.String(r.Get(ctx, "a"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1", v)
+	_, err = redis.String(r.Get(ctx, "absent"))
+	assert.Equal(t, redis.ErrNil, err)
+}
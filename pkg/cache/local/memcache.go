@@ -0,0 +1,116 @@
+package local
+
+import (
+	"context"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/memcache"
+)
+
+// Memcache is a two level cache, the items got from memcache are cached in
+// the local cache. Memcache doesn't return the expiration of the got items,
+// so they are cached with the expire of the config, which should be shorter
+// than the expirations of the items. The raw items written by Set are cached
+// with the expire capped at their expirations.
+type Memcache struct {
+	local *Cache
+	mc    *memcache.Memcache
+}
+
+// NewMemcache creates a two level cache of the local cache and memcache, the
+// expire of the config is required.
+func NewMemcache(c *Config, mc *memcache.Memcache) *Memcache {
+	if c.Expire <= 0 {
+		panic("local cache: must config the expire of the two level cache")
+	}
+	return &Memcache{local: New(c), mc: mc}
+}
+
+// Local returns the local cache.
+func (m *Memcache) Local() *Cache {
+	return m.local
+}
+
+// Get gets the item of the key from the local cache or memcache, and converts
+// the value like memcache.Reply.Scan.
+func (m *Memcache) Get(ctx context.Context, key string, v interface{}) (err error) {
+	if value, ok := m.local.Get(key); ok {
+		return decode(value.(*memcache.Item), v)
+	}
+	return m.local.fill(key, func() (interface{}, time.Duration, error) {
+		reply := m.mc.Get(ctx, key)
+		if err := reply.Scan(v); err != nil {
+			return nil, 0, err
+		}
+		item := reply.Item()
+		return &memcache.Item{Key: item.Key, Value: append([]byte(nil), item.Value...), Flags: item.Flags}, 0, nil
+	})
+}
+
+func decode(item *memcache.Item, v interface{}) (err error) {
+	if err = memcache.Decode(item, v); err != nil {
+		return
+	}
+	// NOTE: the raw value shares the bytes of the cached item.
+	if b, ok := v.(*[]byte); ok {
+		*b = append([]byte(nil), *b...)
+	}
+	return
+}
+
+// Set writes the item to memcache, and invalidates the key of the local
+// caches. The raw item is cached unless the key is invalidated by the other
+// instances while writing.
+func (m *Memcache) Set(ctx context.Context, item *memcache.Item) (err error) {
+	if item.Object != nil {
+		if err = m.mc.Set(ctx, item); err != nil {
+			return
+		}
+		return m.local.Invalidate(ctx, item.Key)
+	}
+	err = m.local.fill(item.Key, func() (interface{}, time.Duration, error) {
+		if err := m.mc.Set(ctx, item); err != nil {
+			return nil, 0, err
+		}
+		cached := &memcache.Item{Key: item.Key, Value: append([]byte(nil), item.Value...), Flags: item.Flags}
+		// NOTE: the local entry is replaced by the fill, it's not deleted,
+		// which would make the fill dirty.
+		return cached, expiration(item.Expiration), m.local.broadcast(ctx, item.Key)
+	})
+	if err != nil {
+		m.local.Delete(item.Key)
+	}
+	return
+}
+
+// expiration converts the expiration of memcache to the time to live, which
+// is the relative seconds, or the absolute unix time if it's larger than 30
+// days.
+func expiration(exp int32) time.Duration {
+	if exp < 0 {
+		return time.Nanosecond
+	}
+	if exp > 60*60*24*30 {
+		if d := time.Until(time.Unix(int64(exp), 0)); d > 0 {
+			return d
+		}
+		return time.Nanosecond
+	}
+	return time.Duration(exp) * time.Second
+}
+
+// Delete deletes the key from memcache and the local caches.
+func (m *Memcache) Delete(ctx context.Context, key string) (err error) {
+	if err = m.mc.Delete(ctx, key); err != nil && err != memcache.ErrNotFound {
+		return
+	}
+	if e := m.local.Invalidate(ctx, key); e != nil {
+		return e
+	}
+	return
+}
+
+// Close closes the local cache, memcache is closed by the caller.
+func (m *Memcache) Close() error {
+	return m.local.Close()
+}
@@ -0,0 +1,29 @@
+package local
+
+import "github.com/mapgoo-lab/atreus/pkg/stat/metric"
+
+const namespace = "cache"
+
+var (
+	_metricHits = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "local",
+		Name:      "hits_total",
+		Help:      "local cache hits total.",
+		Labels:    []string{"name"},
+	})
+	_metricMisses = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "local",
+		Name:      "misses_total",
+		Help:      "local cache misses total.",
+		Labels:    []string{"name"},
+	})
+	_metricEvictions = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "local",
+		Name:      "evictions_total",
+		Help:      "local cache evictions total.",
+		Labels:    []string{"name", "reason"},
+	})
+)
@@ -0,0 +1,101 @@
+package local
+
+import (
+	"context"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+)
+
+// Redis is a two level cache, the replies of GET are cached in the local
+// cache, including the nil replies of the absent keys. The expire of the
+// config is capped at the ttl of the key.
+type Redis struct {
+	local *Cache
+	r     *redis.Redis
+}
+
+// NewRedis creates a two level cache of the local cache and redis, the
+// expire of the config is required.
+func NewRedis(c *Config, r *redis.Redis) *Redis {
+	if c.Expire <= 0 {
+		panic("local cache: must config the expire of the two level cache")
+	}
+	return &Redis{local: New(c), r: r}
+}
+
+// Local returns the local cache.
+func (r *Redis) Local() *Cache {
+	return r.local
+}
+
+// Get gets the value of the key from the local cache or redis, the reply can
+// be converted by redis.String, redis.Bytes etc.
+func (r *Redis) Get(ctx context.Context, key string) (reply interface{}, err error) {
+	if value, ok := r.local.Get(key); ok {
+		if b, ok := value.([]byte); ok {
+			return append([]byte(nil), b...), nil
+		}
+		return value, nil
+	}
+	err = r.local.fill(key, func() (value interface{}, ttl time.Duration, err error) {
+		if reply, ttl, err = r.get(ctx, key); err != nil {
+			return
+		}
+		if b, ok := reply.([]byte); ok {
+			value = append([]byte(nil), b...)
+		}
+		return
+	})
+	return
+}
+
+// get gets the value and the ttl of the key by the pipelined GET and PTTL,
+// ttl is 0 if the key is absent or has no ttl.
+func (r *Redis) get(ctx context.Context, key string) (reply interface{}, ttl time.Duration, err error) {
+	conn := r.r.Conn(ctx)
+	defer conn.Close()
+	if err = conn.Send("GET", key); err != nil {
+		return
+	}
+	if err = conn.Send("PTTL", key); err != nil {
+		return
+	}
+	if err = conn.Flush(); err != nil {
+		return
+	}
+	if reply, err = conn.Receive(); err != nil {
+		return
+	}
+	ms, err := redis.Int64(conn.Receive())
+	if err == nil && ms > 0 {
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+	return
+}
+
+// Set sets the value of the key by SET with the args, e.g. "EX", 60, and
+// invalidates the key of the local caches.
+func (r *Redis) Set(ctx context.Context, key string, value interface{}, args ...interface{}) (err error) {
+	if _, err = r.r.Do(ctx, "SET", append([]interface{}{key, value}, args...)...); err != nil {
+		return
+	}
+	return r.local.Invalidate(ctx, key)
+}
+
+// Delete deletes the keys from redis and the local caches.
+func (r *Redis) Delete(ctx context.Context, keys ...string) (err error) {
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	if _, err = r.r.Do(ctx, "DEL", args...); err != nil {
+		return
+	}
+	return r.local.Invalidate(ctx, keys...)
+}
+
+// Close closes the local cache, redis is closed by the caller.
+func (r *Redis) Close() error {
+	return r.local.Close()
+}
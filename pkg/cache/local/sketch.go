@@ -0,0 +1,60 @@
+package local
+
+const _sketchDepth = 4
+
+// sketch is a count-min sketch of the access frequencies for the tinylfu
+// admission, the counters are halved periodically so that the old accesses
+// are forgotten.
+type sketch struct {
+	rows      [_sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newSketch(size int) *sketch {
+	width := 16
+	for width < size*2 {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), resetAt: size * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index returns the counter of the row by the double hashing.
+func (s *sketch) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+func (s *sketch) increment(h uint64) {
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < 255 {
+			*c++
+		}
+	}
+	if s.additions++; s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *sketch) estimate(h uint64) uint8 {
+	min := uint8(255)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"io"
+	"sync"
 
 	"github.com/gogo/protobuf/proto"
+	pkgerr "github.com/pkg/errors"
 )
 
 type reader struct {
@@ -49,6 +51,17 @@ func newEncodeDecoder() *encodeDecode {
 	return ed
 }
 
+var _decoders = sync.Pool{New: func() interface{} { return newEncodeDecoder() }}
+
+// Decode converts the value of the item got from memcache by the flags, which
+// is the same as Reply.Scan without the connection, e.g. for the items cached
+// in process.
+func Decode(item *Item, v interface{}) error {
+	ed := _decoders.Get().(*encodeDecode)
+	defer _decoders.Put(ed)
+	return pkgerr.WithStack(ed.decode(item, v))
+}
+
 func (ed *encodeDecode) encode(item *Item) (data []byte, err error) {
 	if (item.Flags | _flagEncoding) == _flagEncoding {
 		if item.Value == nil {
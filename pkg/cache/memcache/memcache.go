@@ -192,11 +192,22 @@ type Config struct {
 	DialTimeout  xtime.Duration
 	ReadTimeout  xtime.Duration
 	WriteTimeout xtime.Duration
+
+	// Addrs shards the keys over the nodes by ketama consistent hashing
+	// instead of Addr, which is only supported by Memcache.
+	Addrs []string
+	// EjectFailures ejects the node after the consecutive failures of the
+	// commands or the probes, which are counted separately, 3 by default.
+	// The failing and ejected nodes are probed every ProbeInterval,
+	// 1s by default, and the ejected node is re-added once the probe succeeds.
+	EjectFailures int
+	ProbeInterval xtime.Duration
 }
 
 // Memcache memcache client
 type Memcache struct {
 	pool *Pool
+	ring *ring
 }
 
 // Reply is the result of Get
@@ -218,22 +229,32 @@ type Replies struct {
 
 // New get a memcache client
 func New(cfg *Config) *Memcache {
+	if len(cfg.Addrs) > 0 {
+		return &Memcache{ring: newRing(cfg)}
+	}
 	return &Memcache{pool: NewPool(cfg)}
 }
 
 // Close close connection pool
 func (mc *Memcache) Close() error {
+	if mc.ring != nil {
+		return mc.ring.Close()
+	}
 	return mc.pool.Close()
 }
 
-// Conn direct get a connection
+// Conn direct get a connection, the commands are routed to the nodes by the
+// keys if Addrs is set.
 func (mc *Memcache) Conn(ctx context.Context) Conn {
+	if mc.ring != nil {
+		return mc.ring.Get(ctx)
+	}
 	return mc.pool.Get(ctx)
 }
 
 // Set writes the given item, unconditionally.
 func (mc *Memcache) Set(ctx context.Context, item *Item) (err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	err = conn.SetContext(ctx, item)
 	conn.Close()
 	return
@@ -242,7 +263,7 @@ func (mc *Memcache) Set(ctx context.Context, item *Item) (err error) {
 // Add writes the given item, if no value already exists for its key.
 // ErrNotStored is returned if that condition is not met.
 func (mc *Memcache) Add(ctx context.Context, item *Item) (err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	err = conn.AddContext(ctx, item)
 	conn.Close()
 	return
@@ -250,7 +271,7 @@ func (mc *Memcache) Add(ctx context.Context, item *Item) (err error) {
 
 // Replace writes the given item, but only if the server *does* already hold data for this key.
 func (mc *Memcache) Replace(ctx context.Context, item *Item) (err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	err = conn.ReplaceContext(ctx, item)
 	conn.Close()
 	return
@@ -258,7 +279,7 @@ func (mc *Memcache) Replace(ctx context.Context, item *Item) (err error) {
 
 // CompareAndSwap writes the given item that was previously returned by Get
 func (mc *Memcache) CompareAndSwap(ctx context.Context, item *Item) (err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	err = conn.CompareAndSwapContext(ctx, item)
 	conn.Close()
 	return
@@ -266,7 +287,7 @@ func (mc *Memcache) CompareAndSwap(ctx context.Context, item *Item) (err error)
 
 // Get sends a command to the server for gets data.
 func (mc *Memcache) Get(ctx context.Context, key string) *Reply {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	item, err := conn.GetContext(ctx, key)
 	if err != nil {
 		conn.Close()
@@ -292,9 +313,10 @@ func (r *Reply) Scan(v interface{}) (err error) {
 	return
 }
 
-// GetMulti is a batch version of Get
+// GetMulti is a batch version of Get, the keys of the nodes are got in
+// parallel if Addrs is set.
 func (mc *Memcache) GetMulti(ctx context.Context, keys []string) (*Replies, error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	items, err := conn.GetMultiContext(ctx, keys)
 	rs := &Replies{err: err, items: items, conn: conn, usedItems: make(map[string]struct{}, len(keys))}
 	if (err != nil) || (len(items) == 0) {
@@ -346,7 +368,7 @@ func (rs *Replies) Keys() (keys []string) {
 
 // Touch updates the expiry for the given key.
 func (mc *Memcache) Touch(ctx context.Context, key string, timeout int32) (err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	err = conn.TouchContext(ctx, key, timeout)
 	conn.Close()
 	return
@@ -354,7 +376,7 @@ func (mc *Memcache) Touch(ctx context.Context, key string, timeout int32) (err e
 
 // Delete deletes the item with the provided key.
 func (mc *Memcache) Delete(ctx context.Context, key string) (err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	err = conn.DeleteContext(ctx, key)
 	conn.Close()
 	return
@@ -362,7 +384,7 @@ func (mc *Memcache) Delete(ctx context.Context, key string) (err error) {
 
 // Increment atomically increments key by delta.
 func (mc *Memcache) Increment(ctx context.Context, key string, delta uint64) (newValue uint64, err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	newValue, err = conn.IncrementContext(ctx, key, delta)
 	conn.Close()
 	return
@@ -370,7 +392,7 @@ func (mc *Memcache) Increment(ctx context.Context, key string, delta uint64) (ne
 
 // Decrement atomically decrements key by delta.
 func (mc *Memcache) Decrement(ctx context.Context, key string, delta uint64) (newValue uint64, err error) {
-	conn := mc.pool.Get(ctx)
+	conn := mc.Conn(ctx)
 	newValue, err = conn.DecrementContext(ctx, key, delta)
 	conn.Close()
 	return
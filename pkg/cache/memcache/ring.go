@@ -0,0 +1,477 @@
+package memcache
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	pkgerr "github.com/pkg/errors"
+)
+
+// ErrNoServers is returned if all the nodes are ejected.
+var ErrNoServers = errors.New("memcache: no servers available")
+
+const (
+	// the virtual points of a node on the continuum, which are compatible
+	// with the weighted ketama of libmemcached with the equal weights.
+	_ketamaPoints = 160
+	// the port whose node is hashed by the host only like libmemcached.
+	_defaultPort = "11211"
+	// the key for probing the nodes.
+	_probeKey = "atreus_memcache_probe"
+)
+
+type point struct {
+	hash uint32
+	node *node
+}
+
+type node struct {
+	addr string
+	pool *Pool
+	// the consecutive failures of the commands and the probes, the node is
+	// ejected if either reaches EjectFailures.
+	failures int32
+	probes   int32
+	ejected  bool
+}
+
+// ring shards the keys over the nodes by ketama consistent hashing, the
+// failing nodes are ejected temporarily and re-added once the probe succeeds.
+type ring struct {
+	c     *Config
+	nodes []*node
+
+	mu     sync.RWMutex
+	points []point
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newRing(c *Config) *ring {
+	if c.EjectFailures <= 0 {
+		c.EjectFailures = 3
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = xtime.Duration(time.Second)
+	}
+	r := &ring{c: c, closed: make(chan struct{})}
+	for _, addr := range c.Addrs {
+		nc := *c
+		nc.Addr = addr
+		r.nodes = append(r.nodes, &node{addr: addr, pool: NewPool(&nc)})
+	}
+	r.rebuild()
+	go r.probe()
+	return r
+}
+
+// ketamaHost returns the host of the virtual points of the node like
+// libmemcached, which is host for the default port and host:port otherwise.
+func ketamaHost(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port != _defaultPort {
+		return addr
+	}
+	return host
+}
+
+func ketamaHash(key string) uint32 {
+	digest := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(digest[:4])
+}
+
+// rebuild rebuilds the continuum of the nodes which are not ejected, the
+// caller must hold the lock or own the ring exclusively.
+func (r *ring) rebuild() {
+	points := make([]point, 0, len(r.nodes)*_ketamaPoints)
+	for _, n := range r.nodes {
+		if n.ejected {
+			continue
+		}
+		host := ketamaHost(n.addr)
+		for i := 0; i < _ketamaPoints/4; i++ {
+			digest := md5.Sum([]byte(host + "-" + strconv.Itoa(i)))
+			for j := 0; j < 4; j++ {
+				points = append(points, point{hash: binary.LittleEndian.Uint32(digest[j*4:]), node: n})
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	r.points = points
+}
+
+// pick returns the node of the key, or nil if all the nodes are ejected.
+func (r *ring) pick(key string) *node {
+	h := ketamaHash(key)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 {
+		return nil
+	}
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// report records the result of the command of the node.
+func (r *ring) report(n *node, conn Conn) {
+	if !failed(conn.Err()) {
+		atomic.StoreInt32(&n.failures, 0)
+		return
+	}
+	if atomic.AddInt32(&n.failures, 1) >= int32(r.c.EjectFailures) {
+		r.eject(n)
+	}
+}
+
+// failed reports whether the error is caused by the node, not the local pool
+// or the canceled context.
+func failed(err error) bool {
+	switch pkgerr.Cause(err) {
+	case nil, pool.ErrPoolExhausted, pool.ErrPoolClosed, ErrConnClosed, context.Canceled, context.DeadlineExceeded:
+		return false
+	}
+	return true
+}
+
+func (r *ring) eject(n *node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n.ejected {
+		return
+	}
+	log.Warn("memcache: node(%s) of %s ejected after %d command failures and %d probe failures",
+		n.addr, r.c.Name, atomic.LoadInt32(&n.failures), atomic.LoadInt32(&n.probes))
+	n.ejected = true
+	r.rebuild()
+}
+
+func (r *ring) readd(n *node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !n.ejected {
+		return
+	}
+	log.Info("memcache: node(%s) of %s re-added", n.addr, r.c.Name)
+	atomic.StoreInt32(&n.failures, 0)
+	n.ejected = false
+	r.rebuild()
+}
+
+// probe checks the failing and ejected nodes periodically.
+func (r *ring) probe() {
+	ticker := time.NewTicker(time.Duration(r.c.ProbeInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.closed:
+			return
+		}
+		r.check()
+	}
+}
+
+// check pings the failing and ejected nodes, the node is ejected after
+// EjectFailures consecutive probe failures and re-added once a probe succeeds.
+func (r *ring) check() {
+	for _, n := range r.nodes {
+		r.mu.RLock()
+		ejected := n.ejected
+		r.mu.RUnlock()
+		if !ejected && atomic.LoadInt32(&n.failures) == 0 {
+			atomic.StoreInt32(&n.probes, 0)
+			continue
+		}
+		if err := r.ping(n.addr); failed(err) {
+			log.Warn("memcache: probe node(%s) of %s error(%v)", n.addr, r.c.Name, err)
+			if atomic.AddInt32(&n.probes, 1) >= int32(r.c.EjectFailures) && !ejected {
+				r.eject(n)
+			}
+			continue
+		}
+		atomic.StoreInt32(&n.probes, 0)
+		if ejected {
+			r.readd(n)
+		}
+	}
+}
+
+func (r *ring) ping(addr string) error {
+	conn, err := Dial(r.c.Proto, addr,
+		DialConnectTimeout(time.Duration(r.c.DialTimeout)),
+		DialReadTimeout(time.Duration(r.c.ReadTimeout)),
+		DialWriteTimeout(time.Duration(r.c.WriteTimeout)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.Get(_probeKey); err == ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+func (r *ring) Get(ctx context.Context) Conn {
+	return &ringConn{r: r, ctx: ctx, conns: make(map[*node]Conn), ed: newEncodeDecoder()}
+}
+
+func (r *ring) Close() (err error) {
+	r.once.Do(func() { close(r.closed) })
+	for _, n := range r.nodes {
+		if e := n.pool.Close(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// ringConn routes the commands to the connections of the nodes by the keys,
+// the connections are got lazily and released by Close.
+type ringConn struct {
+	r   *ring
+	ctx context.Context
+	ed  *encodeDecode
+
+	mu     sync.Mutex
+	conns  map[*node]Conn
+	closed bool
+}
+
+func (rc *ringConn) conn(n *node) Conn {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		return errConn{ErrConnClosed}
+	}
+	c, ok := rc.conns[n]
+	if !ok {
+		c = n.pool.Get(rc.ctx)
+		rc.conns[n] = c
+	}
+	return c
+}
+
+// get returns the node and the connection of the key.
+func (rc *ringConn) get(key string) (*node, Conn) {
+	n := rc.r.pick(key)
+	if n == nil {
+		return nil, errConn{ErrNoServers}
+	}
+	return n, rc.conn(n)
+}
+
+// done reports the result of the connection of the node, the broken
+// connection is released so that the next command gets a new one.
+func (rc *ringConn) done(n *node, c Conn) {
+	if n == nil {
+		return
+	}
+	rc.r.report(n, c)
+	if c.Err() == nil {
+		return
+	}
+	rc.mu.Lock()
+	if rc.conns[n] == c {
+		delete(rc.conns, n)
+		c.Close()
+	}
+	rc.mu.Unlock()
+}
+
+func (rc *ringConn) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		return nil
+	}
+	rc.closed = true
+	for _, c := range rc.conns {
+		c.Close()
+	}
+	rc.conns = nil
+	return nil
+}
+
+func (rc *ringConn) Err() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		return ErrConnClosed
+	}
+	for _, c := range rc.conns {
+		if err := c.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rc *ringConn) Add(item *Item) error {
+	return rc.AddContext(rc.ctx, item)
+}
+
+func (rc *ringConn) Set(item *Item) error {
+	return rc.SetContext(rc.ctx, item)
+}
+
+func (rc *ringConn) Replace(item *Item) error {
+	return rc.ReplaceContext(rc.ctx, item)
+}
+
+func (rc *ringConn) Get(key string) (*Item, error) {
+	return rc.GetContext(rc.ctx, key)
+}
+
+func (rc *ringConn) GetMulti(keys []string) (map[string]*Item, error) {
+	return rc.GetMultiContext(rc.ctx, keys)
+}
+
+func (rc *ringConn) Delete(key string) error {
+	return rc.DeleteContext(rc.ctx, key)
+}
+
+func (rc *ringConn) Increment(key string, delta uint64) (uint64, error) {
+	return rc.IncrementContext(rc.ctx, key, delta)
+}
+
+func (rc *ringConn) Decrement(key string, delta uint64) (uint64, error) {
+	return rc.DecrementContext(rc.ctx, key, delta)
+}
+
+func (rc *ringConn) CompareAndSwap(item *Item) error {
+	return rc.CompareAndSwapContext(rc.ctx, item)
+}
+
+func (rc *ringConn) Touch(key string, seconds int32) error {
+	return rc.TouchContext(rc.ctx, key, seconds)
+}
+
+func (rc *ringConn) Scan(item *Item, v interface{}) error {
+	return pkgerr.WithStack(rc.ed.decode(item, v))
+}
+
+func (rc *ringConn) AddContext(ctx context.Context, item *Item) error {
+	n, c := rc.get(item.Key)
+	err := c.AddContext(ctx, item)
+	rc.done(n, c)
+	return err
+}
+
+func (rc *ringConn) SetContext(ctx context.Context, item *Item) error {
+	n, c := rc.get(item.Key)
+	err := c.SetContext(ctx, item)
+	rc.done(n, c)
+	return err
+}
+
+func (rc *ringConn) ReplaceContext(ctx context.Context, item *Item) error {
+	n, c := rc.get(item.Key)
+	err := c.ReplaceContext(ctx, item)
+	rc.done(n, c)
+	return err
+}
+
+func (rc *ringConn) GetContext(ctx context.Context, key string) (*Item, error) {
+	n, c := rc.get(key)
+	item, err := c.GetContext(ctx, key)
+	rc.done(n, c)
+	return item, err
+}
+
+// GetMultiContext gets the keys of the nodes in parallel and merges the items,
+// the first error is returned if any node fails.
+func (rc *ringConn) GetMultiContext(ctx context.Context, keys []string) (map[string]*Item, error) {
+	groups := make(map[*node][]string)
+	for _, key := range keys {
+		n := rc.r.pick(key)
+		if n == nil {
+			return nil, ErrNoServers
+		}
+		groups[n] = append(groups[n], key)
+	}
+	if len(groups) <= 1 {
+		for n, keys := range groups {
+			c := rc.conn(n)
+			items, err := c.GetMultiContext(ctx, keys)
+			rc.done(n, c)
+			return items, err
+		}
+		return make(map[string]*Item), nil
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		res   = make(map[string]*Item, len(keys))
+		first error
+	)
+	for n, keys := range groups {
+		wg.Add(1)
+		go func(n *node, keys []string) {
+			defer wg.Done()
+			c := rc.conn(n)
+			items, err := c.GetMultiContext(ctx, keys)
+			rc.done(n, c)
+			mu.Lock()
+			for key, item := range items {
+				res[key] = item
+			}
+			if err != nil && first == nil {
+				first = err
+			}
+			mu.Unlock()
+		}(n, keys)
+	}
+	wg.Wait()
+	return res, first
+}
+
+func (rc *ringConn) DeleteContext(ctx context.Context, key string) error {
+	n, c := rc.get(key)
+	err := c.DeleteContext(ctx, key)
+	rc.done(n, c)
+	return err
+}
+
+func (rc *ringConn) IncrementContext(ctx context.Context, key string, delta uint64) (uint64, error) {
+	n, c := rc.get(key)
+	newValue, err := c.IncrementContext(ctx, key, delta)
+	rc.done(n, c)
+	return newValue, err
+}
+
+func (rc *ringConn) DecrementContext(ctx context.Context, key string, delta uint64) (uint64, error) {
+	n, c := rc.get(key)
+	newValue, err := c.DecrementContext(ctx, key, delta)
+	rc.done(n, c)
+	return newValue, err
+}
+
+func (rc *ringConn) CompareAndSwapContext(ctx context.Context, item *Item) error {
+	n, c := rc.get(item.Key)
+	err := c.CompareAndSwapContext(ctx, item)
+	rc.done(n, c)
+	return err
+}
+
+func (rc *ringConn) TouchContext(ctx context.Context, key string, seconds int32) error {
+	n, c := rc.get(key)
+	err := c.TouchContext(ctx, key, seconds)
+	rc.done(n, c)
+	return err
+}
@@ -0,0 +1,303 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testServer is a memcached server of the get, gets, set and delete commands.
+type testServer struct {
+	addr string
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+	data  map[string][]byte
+	flags map[string]string
+	gets  int
+}
+
+func runTestServer(t *testing.T, addr string) *testServer {
+	s := &testServer{addr: addr, data: make(map[string][]byte), flags: make(map[string]string)}
+	s.start(t)
+	return s
+}
+
+func (s *testServer) start(t *testing.T) {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.ln, s.addr, s.conns = ln, ln.Addr().String(), make(map[net.Conn]struct{})
+	s.mu.Unlock()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+}
+
+func (s *testServer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ln.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return
+		}
+		s.mu.Lock()
+		switch fields[0] {
+		case "get", "gets":
+			s.gets++
+			for _, key := range fields[1:] {
+				if v, ok := s.data[key]; ok {
+					fmt.Fprintf(rw, "VALUE %s %s %d 1\r\n%s\r\n", key, s.flags[key], len(v), v)
+				}
+			}
+			rw.WriteString("END\r\n")
+		case "set":
+			n, _ := strconv.Atoi(fields[4])
+			v := make([]byte, n+2)
+			if _, err = io.ReadFull(rw, v); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			s.data[fields[1]], s.flags[fields[1]] = v[:n], fields[2]
+			rw.WriteString("STORED\r\n")
+		case "delete":
+			if _, ok := s.data[fields[1]]; ok {
+				delete(s.data, fields[1])
+				rw.WriteString("DELETED\r\n")
+			} else {
+				rw.WriteString("NOT_FOUND\r\n")
+			}
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+		s.mu.Unlock()
+		if rw.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *testServer) getCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func (s *testServer) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data[key]
+	return ok
+}
+
+func newTestRing(addrs ...string) *Memcache {
+	return New(&Config{
+		Config: &pool.Config{
+			Active:      10,
+			Idle:        5,
+			IdleTimeout: xtime.Duration(90 * time.Second),
+		},
+		Name:          "test",
+		Proto:         "tcp",
+		DialTimeout:   xtime.Duration(time.Second),
+		ReadTimeout:   xtime.Duration(time.Second),
+		WriteTimeout:  xtime.Duration(time.Second),
+		Addrs:         addrs,
+		EjectFailures: 2,
+		ProbeInterval: xtime.Duration(50 * time.Millisecond),
+	})
+}
+
+func TestKetama(t *testing.T) {
+	addrs := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	r := &ring{c: &Config{}}
+	for _, addr := range addrs {
+		r.nodes = append(r.nodes, &node{addr: addr})
+	}
+	r.rebuild()
+	assert.Len(t, r.points, len(addrs)*_ketamaPoints)
+
+	// the points are hashed by host-i for the default port and host:port-i
+	// otherwise like libmemcached.
+	assert.Equal(t, "10.0.0.1", ketamaHost("10.0.0.1:11211"))
+	assert.Equal(t, "10.0.0.1:11212", ketamaHost("10.0.0.1:11212"))
+	assert.Equal(t, "/tmp/memcached.sock", ketamaHost("/tmp/memcached.sock"))
+	digest := md5.Sum([]byte("10.0.0.1-39"))
+	var found bool
+	for _, p := range r.points {
+		if p.hash == binary.LittleEndian.Uint32(digest[12:]) {
+			found = p.node == r.nodes[0]
+		}
+	}
+	assert.True(t, found)
+
+	owners := make(map[string]*node)
+	counts := make(map[*node]int)
+	for i := 0; i < 3000; i++ {
+		key := "key" + strconv.Itoa(i)
+		n := r.pick(key)
+		owners[key] = n
+		counts[n]++
+	}
+	for _, n := range r.nodes {
+		assert.InDelta(t, 1000, counts[n], 250, n.addr)
+	}
+
+	// only the keys of the ejected node are moved.
+	r.nodes[1].ejected = true
+	r.rebuild()
+	for key, owner := range owners {
+		if n := r.pick(key); owner != r.nodes[1] {
+			assert.Equal(t, owner, n)
+		} else {
+			assert.NotEqual(t, r.nodes[1], n)
+		}
+	}
+	for _, n := range r.nodes {
+		n.ejected = true
+	}
+	r.rebuild()
+	assert.Nil(t, r.pick("key"))
+}
+
+func TestRing(t *testing.T) {
+	s1 := runTestServer(t, "127.0.0.1:0")
+	defer s1.stop()
+	s2 := runTestServer(t, "127.0.0.1:0")
+	defer s2.stop()
+	mc := newTestRing(s1.addr, s2.addr)
+	defer mc.Close()
+	ctx := context.Background()
+
+	var keys []string
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i)
+		keys = append(keys, key)
+		assert.Nil(t, mc.Set(ctx, &Item{Key: key, Value: []byte(key)}))
+		assert.True(t, s1.has(key) != s2.has(key), key)
+		var v string
+		assert.Nil(t, mc.Get(ctx, key).Scan(&v))
+		assert.Equal(t, key, v)
+	}
+
+	// the keys are got from the both nodes and merged.
+	gets1, gets2 := s1.getCount(), s2.getCount()
+	rs, err := mc.GetMulti(ctx, append(keys, "absent"))
+	assert.Nil(t, err)
+	assert.Len(t, rs.Keys(), len(keys))
+	for _, key := range keys {
+		var v string
+		assert.Nil(t, rs.Scan(key, &v))
+		assert.Equal(t, key, v)
+	}
+	assert.Equal(t, gets1+1, s1.getCount())
+	assert.Equal(t, gets2+1, s2.getCount())
+
+	assert.Nil(t, mc.Delete(ctx, keys[0]))
+	assert.Equal(t, ErrNotFound, mc.Get(ctx, keys[0]).Scan(new(string)))
+}
+
+func TestRingEject(t *testing.T) {
+	s1 := runTestServer(t, "127.0.0.1:0")
+	defer s1.stop()
+	s2 := runTestServer(t, "127.0.0.1:0")
+	defer s2.stop()
+	mc := newTestRing(s1.addr, s2.addr)
+	defer mc.Close()
+	ctx := context.Background()
+	key := "key0"
+	for i := 1; mc.ring.pick(key) != mc.ring.nodes[1]; i++ {
+		key = "key" + strconv.Itoa(i)
+	}
+
+	// the failing node is ejected, and the keys are moved to the others.
+	s2.stop()
+	for i := 0; i < 2; i++ {
+		assert.NotNil(t, mc.Set(ctx, &Item{Key: key, Value: []byte("v")}))
+	}
+	assert.Equal(t, mc.ring.nodes[0], mc.ring.pick(key))
+	assert.Nil(t, mc.Set(ctx, &Item{Key: key, Value: []byte("v")}))
+	assert.True(t, s1.has(key))
+
+	// the node is re-added once the probe succeeds.
+	s2.start(t)
+	deadline := time.Now().Add(5 * time.Second)
+	for mc.ring.pick(key) != mc.ring.nodes[1] {
+		if time.Now().After(deadline) {
+			t.Fatal("wait re-adding timeout")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, ErrNotFound, mc.Get(ctx, key).Scan(new(string)))
+}
+
+func TestRingProbeFailures(t *testing.T) {
+	s1 := runTestServer(t, "127.0.0.1:0")
+	defer s1.stop()
+	s2 := runTestServer(t, "127.0.0.1:0")
+	defer s2.stop()
+	mc := newTestRing(s1.addr, s2.addr)
+	defer mc.Close()
+	// the probe loop is driven by check in the test.
+	mc.ring.once.Do(func() { close(mc.ring.closed) })
+	ctx := context.Background()
+	key := "key0"
+	for i := 1; mc.ring.pick(key) != mc.ring.nodes[1]; i++ {
+		key = "key" + strconv.Itoa(i)
+	}
+
+	// a command failure and a probe failure don't add up to EjectFailures.
+	s2.stop()
+	assert.NotNil(t, mc.Set(ctx, &Item{Key: key, Value: []byte("v")}))
+	mc.ring.check()
+	assert.Equal(t, mc.ring.nodes[1], mc.ring.pick(key))
+	mc.ring.check()
+	assert.Equal(t, mc.ring.nodes[0], mc.ring.pick(key))
+
+	// the probe failures are reset once the node is re-added.
+	s2.start(t)
+	mc.ring.check()
+	assert.Equal(t, mc.ring.nodes[1], mc.ring.pick(key))
+	assert.Equal(t, int32(0), mc.ring.nodes[1].failures)
+	assert.Equal(t, int32(0), mc.ring.nodes[1].probes)
+}
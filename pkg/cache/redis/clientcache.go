@@ -0,0 +1,439 @@
+package redis
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+)
+
+var (
+	// the backoff of reconnecting the invalidation connection.
+	_cacheMinBackoff = 100 * time.Millisecond
+	_cacheMaxBackoff = 5 * time.Second
+	// the invalidation connection is pinged every interval, it's broken if
+	// nothing is received in the interval and the timeout.
+	_cachePingInterval = 5 * time.Second
+	_cachePingTimeout  = 3 * time.Second
+)
+
+var _cacheableCommands = make(map[string]struct{})
+
+func init() {
+	// NOTE: the single key read only commands of the deterministic replies,
+	// e.g. not TTL or SRANDMEMBER.
+	for _, cmd := range []string{
+		"GET", "GETRANGE", "STRLEN", "EXISTS", "TYPE", "GETBIT", "BITCOUNT",
+		"HGET", "HMGET", "HGETALL", "HKEYS", "HVALS", "HLEN", "HEXISTS", "HSTRLEN",
+		"LRANGE", "LLEN", "LINDEX",
+		"SMEMBERS", "SISMEMBER", "SMISMEMBER", "SCARD",
+		"ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE", "ZREVRANGEBYSCORE", "ZRANGEBYLEX", "ZREVRANGEBYLEX",
+		"ZSCORE", "ZMSCORE", "ZCARD", "ZCOUNT", "ZLEXCOUNT", "ZRANK", "ZREVRANK",
+	} {
+		_cacheableCommands[cmd] = struct{}{}
+	}
+}
+
+// ClientCacheConfig is the config of the client side caching, the replies of
+// the read only commands are cached in process and invalidated by the server
+// with CLIENT TRACKING.
+type ClientCacheConfig struct {
+	Size   int            // the max number of the cached keys, 10000 by default
+	Expire xtime.Duration // the max age of the cached replies, no limit if 0
+	// Prefixes tracks the keys of the prefixes in the broadcasting mode, only
+	// the keys of the prefixes are cached. All the keys are tracked in the
+	// default mode, which the server remembers the keys read by the client.
+	Prefixes []string
+}
+
+type cacheEntry struct {
+	key     string
+	replies map[string]interface{} // the replies of the commands of the key
+	expire  time.Time
+}
+
+// clientCache caches the replies in a LRU keyed by the redis keys, the
+// connections of the pool redirect the invalidation messages to a RESP3
+// connection which is watched by the cache.
+type clientCache struct {
+	c   *Config
+	cc  *ClientCacheConfig
+	ops []DialOption
+	// the ping interval and timeout of the invalidation connection.
+	pingInterval, pingTimeout time.Duration
+
+	mu       sync.Mutex
+	pool     *Pool
+	inv      Conn
+	tracking bool
+	entries  map[string]*list.Element
+	lru      *list.List
+	// the keys being filled, which are dirty if invalidated while filling.
+	fills map[string]int
+	dirty map[string]struct{}
+	epoch uint64
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newClientCache(c *Config, ops []DialOption) *clientCache {
+	if c.Sentinel != nil || c.Cluster != nil {
+		panic("redis client cache doesn't support sentinel or cluster")
+	}
+	if c.ClientCache.Size <= 0 {
+		c.ClientCache.Size = 10000
+	}
+	cc := &clientCache{
+		c:       c,
+		cc:      c.ClientCache,
+		ops:     ops,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		fills:   make(map[string]int),
+		dirty:   make(map[string]struct{}),
+		closed:  make(chan struct{}),
+	}
+	// NOTE: the vars are read once as they're changed by the tests.
+	cc.pingInterval, cc.pingTimeout = _cachePingInterval, _cachePingTimeout
+	// NOTE: the commands are executed without caching until connected.
+	cc.pool = newPool(c, ops, cc.addr)
+	if err := cc.connect(); err != nil {
+		log.Error("redis: client cache(%s) connect error(%v)", c.Addr, err)
+	}
+	go cc.watch()
+	return cc
+}
+
+func (cc *clientCache) addr() (string, error) {
+	return cc.c.Addr, nil
+}
+
+// connect dials the invalidation connection, and the connections of the pool
+// are redialed to redirect the invalidation messages.
+func (cc *clientCache) connect() (err error) {
+	ops := append(append([]DialOption(nil), cc.ops...), DialReadTimeout(cc.pingInterval+cc.pingTimeout), DialProtocol(3))
+	inv, err := Dial(cc.c.Proto, cc.c.Addr, ops...)
+	if err != nil {
+		return
+	}
+	id, err := Int64(inv.Do("CLIENT", "ID"))
+	if err != nil {
+		inv.Close()
+		return
+	}
+	pool := cc.pool
+	if len(cc.cc.Prefixes) > 0 {
+		args := []interface{}{"TRACKING", "on", "BCAST"}
+		for _, prefix := range cc.cc.Prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+		if _, err = inv.Do("CLIENT", args...); err != nil {
+			inv.Close()
+			return
+		}
+	} else {
+		ops := append(append([]DialOption(nil), cc.ops...), dialCommand("CLIENT", "TRACKING", "on", "REDIRECT", id))
+		pool = newPool(cc.c, ops, cc.addr)
+	}
+	cc.mu.Lock()
+	select {
+	case <-cc.closed:
+		cc.mu.Unlock()
+		inv.Close()
+		if pool != cc.pool {
+			pool.Close()
+		}
+		return
+	default:
+	}
+	old := cc.pool
+	cc.pool, cc.inv, cc.tracking = pool, inv, true
+	cc.flush()
+	cc.mu.Unlock()
+	if old != pool {
+		old.Close()
+	}
+	go cc.ping(inv)
+	return
+}
+
+// ping pings the invalidation connection until it's replaced, the replies
+// are received by watch, which breaks the connection by the read timeout if
+// the server doesn't reply, e.g. the connection is half open.
+func (cc *clientCache) ping(inv Conn) {
+	ticker := time.NewTicker(cc.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-cc.closed:
+			return
+		}
+		cc.mu.Lock()
+		current := cc.inv == inv
+		cc.mu.Unlock()
+		if !current {
+			return
+		}
+		err := inv.Send("PING")
+		if err == nil {
+			err = inv.Flush()
+		}
+		if err != nil {
+			log.Error("redis: client cache(%s) ping error(%v)", cc.c.Addr, err)
+			inv.Close()
+			return
+		}
+	}
+}
+
+// watch receives the invalidation messages, the cache is flushed and the
+// connection is redialed if the connection is broken.
+func (cc *clientCache) watch() {
+	backoff := _cacheMinBackoff
+	for {
+		cc.mu.Lock()
+		inv := cc.inv
+		cc.mu.Unlock()
+		if inv != nil {
+			err := cc.receive(inv)
+			select {
+			case <-cc.closed:
+				return
+			default:
+			}
+			log.Error("redis: client cache(%s) receive invalidation error(%v)", cc.c.Addr, err)
+			cc.mu.Lock()
+			cc.inv, cc.tracking = nil, false
+			cc.flush()
+			cc.mu.Unlock()
+			inv.Close()
+			backoff = _cacheMinBackoff
+		}
+		select {
+		case <-time.After(backoff):
+		case <-cc.closed:
+			return
+		}
+		if err := cc.connect(); err != nil {
+			log.Error("redis: client cache(%s) connect error(%v)", cc.c.Addr, err)
+			if backoff *= 2; backoff > _cacheMaxBackoff {
+				backoff = _cacheMaxBackoff
+			}
+		}
+	}
+}
+
+func (cc *clientCache) receive(inv Conn) error {
+	for {
+		reply, err := inv.Receive()
+		if err != nil {
+			return err
+		}
+		p, ok := reply.(Push)
+		if !ok || len(p) != 2 {
+			continue
+		}
+		if kind, _ := String(p[0], nil); kind != "invalidate" {
+			continue
+		}
+		cc.mu.Lock()
+		if p[1] == nil {
+			// NOTE: all the keys are invalidated by FLUSHALL or FLUSHDB.
+			cc.flush()
+		} else if keys, err := Strings(p[1], nil); err == nil {
+			cc.invalidate(keys...)
+		}
+		cc.mu.Unlock()
+	}
+}
+
+func (cc *clientCache) cacheable(commandName string, args []interface{}) (key string, ok bool) {
+	if _, ok = _cacheableCommands[strings.ToUpper(commandName)]; !ok || len(args) == 0 {
+		return "", false
+	}
+	if key = keyString(args[0]); len(cc.cc.Prefixes) == 0 {
+		return
+	}
+	for _, prefix := range cc.cc.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func (cc *clientCache) do(ctx context.Context, commandName string, args []interface{}) (reply interface{}, err error) {
+	key, ok := cc.cacheable(commandName, args)
+	if !ok {
+		if reply, err = cc.exec(ctx, commandName, args); !isReadOnly(commandName) {
+			// NOTE: read your own writes before the invalidation message.
+			cc.mu.Lock()
+			cc.invalidate(writeKeys(commandName, args)...)
+			cc.mu.Unlock()
+		}
+		return
+	}
+	sig := signature(commandName, args)
+	cc.mu.Lock()
+	if !cc.tracking {
+		cc.mu.Unlock()
+		return cc.exec(ctx, commandName, args)
+	}
+	if reply, ok = cc.get(key, sig); ok {
+		cc.mu.Unlock()
+		_metricCacheHits.Inc(cc.c.Name, cc.c.Addr)
+		return
+	}
+	cc.fills[key]++
+	epoch := cc.epoch
+	cc.mu.Unlock()
+	_metricCacheMisses.Inc(cc.c.Name, cc.c.Addr)
+	reply, err = cc.exec(ctx, commandName, args)
+	cc.mu.Lock()
+	_, dirty := cc.dirty[key]
+	if err == nil && !dirty && epoch == cc.epoch {
+		cc.set(key, sig, reply)
+	}
+	if cc.fills[key]--; cc.fills[key] == 0 {
+		delete(cc.fills, key)
+		delete(cc.dirty, key)
+	}
+	cc.mu.Unlock()
+	return
+}
+
+func (cc *clientCache) exec(ctx context.Context, commandName string, args []interface{}) (interface{}, error) {
+	cc.mu.Lock()
+	pool := cc.pool
+	cc.mu.Unlock()
+	conn := pool.Get(ctx)
+	defer conn.Close()
+	return conn.Do(commandName, args...)
+}
+
+func (cc *clientCache) get(key, sig string) (reply interface{}, ok bool) {
+	e, ok := cc.entries[key]
+	if !ok {
+		return
+	}
+	entry := e.Value.(*cacheEntry)
+	if !entry.expire.IsZero() && time.Now().After(entry.expire) {
+		cc.remove(e)
+		return nil, false
+	}
+	if reply, ok = entry.replies[sig]; ok {
+		cc.lru.MoveToFront(e)
+		reply = cloneReply(reply)
+	}
+	return
+}
+
+func (cc *clientCache) set(key, sig string, reply interface{}) {
+	e, ok := cc.entries[key]
+	if !ok {
+		entry := &cacheEntry{key: key, replies: make(map[string]interface{})}
+		if cc.cc.Expire > 0 {
+			entry.expire = time.Now().Add(time.Duration(cc.cc.Expire))
+		}
+		e = cc.lru.PushFront(entry)
+		cc.entries[key] = e
+		for cc.lru.Len() > cc.cc.Size {
+			cc.remove(cc.lru.Back())
+		}
+		_metricCacheSize.Set(float64(cc.lru.Len()), cc.c.Name, cc.c.Addr)
+	} else {
+		cc.lru.MoveToFront(e)
+	}
+	e.Value.(*cacheEntry).replies[sig] = cloneReply(reply)
+}
+
+func (cc *clientCache) remove(e *list.Element) {
+	cc.lru.Remove(e)
+	delete(cc.entries, e.Value.(*cacheEntry).key)
+}
+
+func (cc *clientCache) invalidate(keys ...string) {
+	for _, key := range keys {
+		if e, ok := cc.entries[key]; ok {
+			cc.remove(e)
+		}
+		if _, ok := cc.fills[key]; ok {
+			cc.dirty[key] = struct{}{}
+		}
+	}
+	_metricCacheSize.Set(float64(cc.lru.Len()), cc.c.Name, cc.c.Addr)
+}
+
+// flush removes all the keys, and the keys being filled are discarded.
+func (cc *clientCache) flush() {
+	cc.entries = make(map[string]*list.Element)
+	cc.lru.Init()
+	cc.epoch++
+	_metricCacheSize.Set(0, cc.c.Name, cc.c.Addr)
+}
+
+func (cc *clientCache) Close() error {
+	cc.once.Do(func() {
+		cc.mu.Lock()
+		close(cc.closed)
+		cc.tracking = false
+		if cc.inv != nil {
+			cc.inv.Close()
+		}
+		cc.mu.Unlock()
+	})
+	return cc.pool.Close()
+}
+
+// writeKeys returns the keys written by the command, which are the first
+// argument or all the keys of the multi-key commands.
+func writeKeys(commandName string, args []interface{}) (keys []string) {
+	var step int
+	switch strings.ToUpper(commandName) {
+	case "DEL", "UNLINK", "TOUCH":
+		step = 1
+	case "MSET", "MSETNX":
+		step = 2
+	default:
+		if len(args) > 0 {
+			keys = append(keys, keyString(args[0]))
+		}
+		return
+	}
+	for i := 0; i < len(args); i += step {
+		keys = append(keys, keyString(args[i]))
+	}
+	return
+}
+
+// cloneReply returns a deep copy of the reply, the cached replies are
+// copied so that the callers can modify the returned replies.
+func cloneReply(reply interface{}) interface{} {
+	switch r := reply.(type) {
+	case []byte:
+		return append([]byte(nil), r...)
+	case []interface{}:
+		c := make([]interface{}, len(r))
+		for i := range r {
+			c[i] = cloneReply(r[i])
+		}
+		return c
+	}
+	return reply
+}
+
+func signature(commandName string, args []interface{}) string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(commandName))
+	for _, arg := range args {
+		b.WriteByte(0)
+		b.WriteString(keyString(arg))
+	}
+	return b.String()
+}
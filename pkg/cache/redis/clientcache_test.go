@@ -0,0 +1,209 @@
+package redis
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis/internal/mockserver"
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCacheRedis(addr string, cc *ClientCacheConfig) *Redis {
+	return NewRedis(&Config{
+		Config: &pool.Config{
+			Active:      10,
+			Idle:        2,
+			IdleTimeout: xtime.Duration(90 * time.Second),
+		},
+		Name:         "test",
+		Proto:        "tcp",
+		Addr:         addr,
+		DialTimeout:  xtime.Duration(time.Second),
+		ReadTimeout:  xtime.Duration(time.Second),
+		WriteTimeout: xtime.Duration(time.Second),
+		ClientCache:  cc,
+	})
+}
+
+func TestClientCache(t *testing.T) {
+	m := mockserver.Run()
+	defer m.Close()
+	r := newTestCacheRedis(m.Addr(), &ClientCacheConfig{Size: 2})
+	defer r.Close()
+	ctx := context.Background()
+	m.Set("a", "1")
+	m.Set("b", "2")
+	m.Set("c", "3")
+
+	// the second GET is a hit.
+	for i := 0; i < 2; i++ {
+		v, err := String(r.Do(ctx, "GET", "a"))
+		assert.Nil(t, err)
+		assert.Equal(t, "1", v)
+	}
+	assert.Equal(t, 1, m.Commands("GET"))
+	_, err := String(r.Do(ctx, "GET", "absent"))
+	assert.Equal(t, ErrNil, err)
+	_, err = String(r.Do(ctx, "GET", "absent"))
+	assert.Equal(t, ErrNil, err)
+	assert.Equal(t, 2, m.Commands("GET"))
+
+	// the writes of the other clients are invalidated by the server.
+	conn, err := Dial("tcp", m.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Do("SET", "a", "10")
+	assert.Nil(t, err)
+	waitUntil(t, func() bool {
+		v, _ := String(r.Do(ctx, "GET", "a"))
+		return v == "10"
+	})
+
+	// the own writes are read at once.
+	_, err = r.Do(ctx, "SET", "a", "11")
+	assert.Nil(t, err)
+	v, err := String(r.Do(ctx, "GET", "a"))
+	assert.Nil(t, err)
+	assert.Equal(t, "11", v)
+
+	// the least recently used key is evicted.
+	n := m.Commands("GET")
+	for _, key := range []string{"b", "c", "a"} {
+		r.Do(ctx, "GET", key)
+	}
+	assert.Equal(t, n+3, m.Commands("GET"))
+	r.cache.mu.Lock()
+	assert.Equal(t, 2, r.cache.lru.Len())
+	r.cache.mu.Unlock()
+
+	// the cache is flushed by FLUSHALL.
+	_, err = conn.Do("FLUSHALL")
+	assert.Nil(t, err)
+	waitUntil(t, func() bool {
+		_, err := String(r.Do(ctx, "GET", "a"))
+		return err == ErrNil
+	})
+}
+
+func TestClientCacheReconnect(t *testing.T) {
+	m := mockserver.Run()
+	defer m.Close()
+	r := newTestCacheRedis(m.Addr(), &ClientCacheConfig{})
+	defer r.Close()
+	ctx := context.Background()
+	m.Set("a", "1")
+
+	_, err := r.Do(ctx, "GET", "a")
+	assert.Nil(t, err)
+	// the cache is flushed if the invalidation connection is broken, the key
+	// may be changed without the invalidation message.
+	m.CloseClients()
+	m.Set("a", "2")
+	waitUntil(t, func() bool {
+		r.cache.mu.Lock()
+		defer r.cache.mu.Unlock()
+		return r.cache.tracking && r.cache.lru.Len() == 0
+	})
+	v, err := String(r.Do(ctx, "GET", "a"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2", v)
+	n := m.Commands("GET")
+	_, err = r.Do(ctx, "GET", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, n, m.Commands("GET"))
+}
+
+func TestClientCacheBroadcast(t *testing.T) {
+	m := mockserver.Run()
+	defer m.Close()
+	r := newTestCacheRedis(m.Addr(), &ClientCacheConfig{Prefixes: []string{"conf:"}})
+	defer r.Close()
+	ctx := context.Background()
+	m.Set("conf:a", "1")
+	m.Set("b", "2")
+
+	for i := 0; i < 2; i++ {
+		r.Do(ctx, "GET", "conf:a")
+		r.Do(ctx, "GET", "b")
+	}
+	// only the keys of the prefixes are cached.
+	assert.Equal(t, 3, m.Commands("GET"))
+
+	conn, err := Dial("tcp", m.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Do("SET", "conf:a", "10")
+	assert.Nil(t, err)
+	waitUntil(t, func() bool {
+		v, _ := String(r.Do(ctx, "GET", "conf:a"))
+		return v == "10"
+	})
+}
+
+func TestClientCacheCopy(t *testing.T) {
+	m := mockserver.Run()
+	defer m.Close()
+	r := newTestCacheRedis(m.Addr(), &ClientCacheConfig{})
+	defer r.Close()
+	ctx := context.Background()
+	m.Set("a", "1")
+
+	// the cached replies aren't modified by the callers.
+	for i := 0; i < 2; i++ {
+		b, err := Bytes(r.Do(ctx, "GET", "a"))
+		assert.Nil(t, err)
+		assert.Equal(t, "1", string(b))
+		b[0] = '2'
+	}
+	assert.Equal(t, 1, m.Commands("GET"))
+}
+
+func TestClientCachePing(t *testing.T) {
+	interval, timeout := _cachePingInterval, _cachePingTimeout
+	_cachePingInterval, _cachePingTimeout = 50*time.Millisecond, 50*time.Millisecond
+	defer func() { _cachePingInterval, _cachePingTimeout = interval, timeout }()
+	m := mockserver.Run()
+	defer m.Close()
+	var hang int32
+	release := make(chan struct{})
+	defer close(release)
+	m.Hook(func(req *mockserver.Request) (interface{}, bool) {
+		if req.Command == "PING" && atomic.LoadInt32(&hang) == 1 {
+			// NOTE: the server doesn't reply like the connection is half open.
+			<-release
+		}
+		return nil, false
+	})
+	r := newTestCacheRedis(m.Addr(), &ClientCacheConfig{})
+	defer r.Close()
+	ctx := context.Background()
+	m.Set("a", "1")
+	r.Do(ctx, "GET", "a")
+
+	// the connection is kept if the pings are replied.
+	time.Sleep(200 * time.Millisecond)
+	r.cache.mu.Lock()
+	epoch := r.cache.epoch
+	assert.Equal(t, 1, r.cache.lru.Len())
+	r.cache.mu.Unlock()
+	assert.True(t, m.Commands("PING") > 0)
+
+	// the cache is flushed and reconnected if the pings time out.
+	atomic.StoreInt32(&hang, 1)
+	waitUntil(t, func() bool {
+		r.cache.mu.Lock()
+		defer r.cache.mu.Unlock()
+		return r.cache.epoch > epoch && r.cache.lru.Len() == 0
+	})
+	atomic.StoreInt32(&hang, 0)
+	waitUntil(t, func() bool {
+		r.cache.mu.Lock()
+		defer r.cache.mu.Unlock()
+		return r.cache.tracking
+	})
+}
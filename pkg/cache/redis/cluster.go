@@ -0,0 +1,744 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+)
+
+const _clusterSlots = 16384
+
+// ErrClusterUnsupported is returned by the transaction and pub/sub commands
+// of the redis cluster.
+var ErrClusterUnsupported = errors.New("redis: command is not supported by cluster")
+
+var (
+	// the backoff of retrying the TRYAGAIN and CLUSTERDOWN errors.
+	_clusterRetryBackoff = 50 * time.Millisecond
+	// the min interval of refreshing the slots.
+	_clusterRefreshInterval = 100 * time.Millisecond
+)
+
+// the multi-key commands which are split by the slots, the value is the
+// number of the arguments of each key.
+var _clusterMultiKeys = map[string]int{
+	"MGET":   1,
+	"MSET":   2,
+	"DEL":    1,
+	"UNLINK": 1,
+	"EXISTS": 1,
+	"TOUCH":  1,
+}
+
+// the commands without keys, which are sent to a random node.
+var _clusterKeyless = map[string]struct{}{
+	"PING": {}, "ECHO": {}, "INFO": {}, "TIME": {}, "DBSIZE": {}, "RANDOMKEY": {}, "KEYS": {}, "SCAN": {},
+	"FLUSHDB": {}, "FLUSHALL": {}, "SCRIPT": {}, "CONFIG": {}, "CLUSTER": {}, "CLIENT": {}, "COMMAND": {},
+	"SLOWLOG": {}, "LASTSAVE": {}, "ROLE": {}, "PUBLISH": {},
+}
+
+// ClusterConfig is the config of the redis cluster.
+type ClusterConfig struct {
+	Addrs []string // the seed nodes of the cluster
+	// MaxRedirects is the max times of following the MOVED and ASK
+	// redirects or retrying the TRYAGAIN errors of a command, default 3.
+	MaxRedirects int
+}
+
+type cluster struct {
+	c   *Config
+	ops []DialOption
+
+	mu    sync.RWMutex
+	slots []string // the master of each slot
+	nodes []string
+	pools map[string]*Pool
+
+	refreshCh chan struct{}
+	closed    chan struct{}
+	once      sync.Once
+}
+
+func newCluster(c *Config, options ...DialOption) *cluster {
+	if len(c.Cluster.Addrs) == 0 {
+		panic("must config redis cluster addrs")
+	}
+	if c.Cluster.MaxRedirects <= 0 {
+		c.Cluster.MaxRedirects = 3
+	}
+	cc := &cluster{
+		c:         c,
+		ops:       buildDialOptions(c, options),
+		slots:     make([]string, _clusterSlots),
+		pools:     make(map[string]*Pool),
+		refreshCh: make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+	}
+	if err := cc.refresh(); err != nil {
+		log.Error("redis: cluster(%v) refresh slots error(%v)", c.Cluster.Addrs, err)
+	}
+	go cc.proc()
+	return cc
+}
+
+// Slot returns the hash slot of the key in the redis cluster, only the hash
+// tag is hashed if the key contains a non-empty {...}.
+func Slot(key string) int {
+	if s := strings.IndexByte(key, '{'); s >= 0 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			key = key[s+1 : s+1+e]
+		}
+	}
+	return int(crc16(key) % _clusterSlots)
+}
+
+// crc16 is the CRC16-XMODEM checksum used by the redis cluster.
+func crc16(s string) (crc uint16) {
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return
+}
+
+func keyString(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+// commandSlot returns the slot of the first key of the command, or -1 if the
+// command has no keys.
+func commandSlot(commandName string, args []interface{}) int {
+	i := 0
+	switch name := strings.ToUpper(commandName); name {
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 {
+			return -1
+		}
+		if n, err := strconv.Atoi(keyString(args[1])); err != nil || n <= 0 {
+			return -1
+		}
+		i = 2
+	case "BITOP", "OBJECT", "MEMORY", "XINFO":
+		i = 1
+	case "XREAD", "XREADGROUP":
+		i = -1
+		for j, arg := range args {
+			if strings.EqualFold(keyString(arg), "STREAMS") {
+				i = j + 1
+				break
+			}
+		}
+	default:
+		if _, ok := _clusterKeyless[name]; ok {
+			return -1
+		}
+	}
+	if i < 0 || i >= len(args) {
+		return -1
+	}
+	return Slot(keyString(args[i]))
+}
+
+type slotGroup struct {
+	args []interface{}
+	keys []int // the indexes of the keys in the whole command
+}
+
+// splitKeys splits the arguments of the multi-key command by the slots.
+func splitKeys(args []interface{}, step int) (groups []*slotGroup) {
+	slots := make(map[int]*slotGroup)
+	for i := 0; i+step <= len(args); i += step {
+		slot := Slot(keyString(args[i]))
+		g, ok := slots[slot]
+		if !ok {
+			g = &slotGroup{}
+			slots[slot] = g
+			groups = append(groups, g)
+		}
+		g.args = append(g.args, args[i:i+step]...)
+		g.keys = append(g.keys, i/step)
+	}
+	return
+}
+
+func (c *cluster) get(ctx context.Context, addr string) Conn {
+	c.mu.RLock()
+	p, ok := c.pools[addr]
+	closed := c.pools == nil
+	c.mu.RUnlock()
+	if closed {
+		return errorConnection{pool.ErrPoolClosed}
+	}
+	if !ok {
+		c.mu.Lock()
+		if p, ok = c.pools[addr]; !ok && c.pools != nil {
+			nc := *c.c
+			nc.Addr = addr
+			p = newPool(&nc, c.ops, func() (string, error) { return addr, nil })
+			c.pools[addr] = p
+		}
+		c.mu.Unlock()
+		if p == nil {
+			return errorConnection{pool.ErrPoolClosed}
+		}
+	}
+	return p.Get(ctx)
+}
+
+// addr returns the master of the slot, or a random node if the slot is -1
+// or unknown.
+func (c *cluster) addr(slot int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if slot >= 0 && c.slots[slot] != "" {
+		return c.slots[slot]
+	}
+	if len(c.nodes) > 0 {
+		return c.nodes[rand.Intn(len(c.nodes))]
+	}
+	return c.c.Cluster.Addrs[rand.Intn(len(c.c.Cluster.Addrs))]
+}
+
+func (c *cluster) moved(slot int, addr string) {
+	c.mu.Lock()
+	c.slots[slot] = addr
+	c.mu.Unlock()
+	c.triggerRefresh()
+}
+
+func (c *cluster) triggerRefresh() {
+	select {
+	case c.refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *cluster) proc() {
+	for {
+		select {
+		case <-c.refreshCh:
+			if err := c.refresh(); err != nil {
+				log.Error("redis: cluster(%v) refresh slots error(%v)", c.c.Cluster.Addrs, err)
+			}
+			// NOTE: the redirects of a migration trigger the refresh once in the interval.
+			select {
+			case <-time.After(_clusterRefreshInterval):
+			case <-c.closed:
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// refresh loads the slots from the known nodes and the seeds.
+func (c *cluster) refresh() (err error) {
+	c.mu.RLock()
+	addrs := append([]string(nil), c.nodes...)
+	c.mu.RUnlock()
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	for _, addr := range c.c.Cluster.Addrs {
+		if !contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	for _, addr := range addrs {
+		var res []interface{}
+		conn := c.get(context.Background(), addr)
+		res, err = Values(conn.Do("CLUSTER", "SLOTS"))
+		conn.Close()
+		if err != nil {
+			continue
+		}
+		var slots []string
+		if slots, err = parseSlots(res, addr); err != nil {
+			continue
+		}
+		c.update(slots)
+		return nil
+	}
+	return
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSlots parses the reply of CLUSTER SLOTS:
+// [[start, end, [ip, port, id], [replica ip, replica port, id]...]...]
+func parseSlots(res []interface{}, addr string) ([]string, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	slots := make([]string, _clusterSlots)
+	for _, r := range res {
+		v, err := Values(r, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) < 3 {
+			return nil, protocolError("bad cluster slots")
+		}
+		start, err := Int(v[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		end, err := Int(v[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		node, err := Values(v[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(node) < 2 || start < 0 || end >= _clusterSlots {
+			return nil, protocolError("bad cluster slots")
+		}
+		ip, err := String(node[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		port, err := Int(node[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		if ip == "" {
+			ip = host
+		}
+		master := net.JoinHostPort(ip, strconv.Itoa(port))
+		for i := start; i <= end; i++ {
+			slots[i] = master
+		}
+	}
+	return slots, nil
+}
+
+func (c *cluster) update(slots []string) {
+	var nodes []string
+	for _, addr := range slots {
+		if addr != "" && !contains(nodes, addr) {
+			nodes = append(nodes, addr)
+		}
+	}
+	var removed []*Pool
+	c.mu.Lock()
+	if c.pools == nil {
+		c.mu.Unlock()
+		return
+	}
+	c.slots, c.nodes = slots, nodes
+	for addr, p := range c.pools {
+		if !contains(nodes, addr) && !contains(c.c.Cluster.Addrs, addr) {
+			removed = append(removed, p)
+			delete(c.pools, addr)
+		}
+	}
+	c.mu.Unlock()
+	for _, p := range removed {
+		p.Close()
+	}
+}
+
+type redirectKind int
+
+const (
+	_redirectNone redirectKind = iota
+	_redirectMoved
+	_redirectAsk
+	_redirectRetry
+)
+
+// redirect parses the redirect error replied by the node addr.
+func redirect(err error, addr string) (kind redirectKind, slot int, to string) {
+	e, ok := err.(Error)
+	if !ok {
+		return
+	}
+	fields := strings.Fields(string(e))
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "MOVED", "ASK":
+		if len(fields) != 3 {
+			return
+		}
+		var err error
+		if slot, err = strconv.Atoi(fields[1]); err != nil || slot < 0 || slot >= _clusterSlots {
+			return
+		}
+		to = fields[2]
+		if strings.HasPrefix(to, ":") {
+			// NOTE: the endpoint of the node is unknown, which is the same host.
+			host, _, _ := net.SplitHostPort(addr)
+			to = net.JoinHostPort(host, to[1:])
+		}
+		if kind = _redirectMoved; fields[0] == "ASK" {
+			kind = _redirectAsk
+		}
+	case "TRYAGAIN", "CLUSTERDOWN":
+		kind = _redirectRetry
+	}
+	return
+}
+
+func (c *cluster) do(ctx context.Context, commandName string, args []interface{}) (interface{}, error) {
+	if LookupCommandInfo(commandName).Set != 0 {
+		return nil, ErrClusterUnsupported
+	}
+	if step, ok := _clusterMultiKeys[strings.ToUpper(commandName)]; ok {
+		if groups := splitKeys(args, step); len(groups) > 1 {
+			return c.doMulti(ctx, commandName, len(args)/step, groups)
+		}
+	}
+	addr := c.addr(commandSlot(commandName, args))
+	reply, err := c.call(ctx, addr, false, commandName, args)
+	return c.follow(ctx, addr, commandName, args, reply, err)
+}
+
+// doMulti executes the groups of the multi-key command concurrently and
+// merges the replies.
+func (c *cluster) doMulti(ctx context.Context, commandName string, keys int, groups []*slotGroup) (interface{}, error) {
+	rps := make([]*reply, len(groups))
+	var wg sync.WaitGroup
+	for i, g := range groups {
+		wg.Add(1)
+		go func(i int, g *slotGroup) {
+			defer wg.Done()
+			rp, err := c.do(ctx, commandName, g.args)
+			rps[i] = &reply{reply: rp, err: err}
+		}(i, g)
+	}
+	wg.Wait()
+	return merge(commandName, keys, groups, rps)
+}
+
+// merge merges the replies of the groups of the multi-key command.
+func merge(commandName string, keys int, groups []*slotGroup, rps []*reply) (interface{}, error) {
+	for _, rp := range rps {
+		if rp.err != nil {
+			return nil, rp.err
+		}
+	}
+	switch strings.ToUpper(commandName) {
+	case "MGET":
+		values := make([]interface{}, keys)
+		for i, g := range groups {
+			vs, err := Values(rps[i].reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(vs) != len(g.keys) {
+				return nil, protocolError("bad mget reply")
+			}
+			for j, k := range g.keys {
+				values[k] = vs[j]
+			}
+		}
+		return values, nil
+	case "MSET":
+		return okReply, nil
+	default:
+		var n int64
+		for _, rp := range rps {
+			v, err := Int64(rp.reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			n += v
+		}
+		return n, nil
+	}
+}
+
+func (c *cluster) call(ctx context.Context, addr string, asking bool, commandName string, args []interface{}) (interface{}, error) {
+	conn := c.get(ctx, addr)
+	defer conn.Close()
+	if asking {
+		if _, err := conn.Do("ASKING"); err != nil {
+			return nil, err
+		}
+	}
+	return conn.Do(commandName, args...)
+}
+
+// follow follows the redirects of the reply of the command executed on the
+// node addr.
+func (c *cluster) follow(ctx context.Context, addr string, commandName string, args []interface{}, reply interface{}, err error) (interface{}, error) {
+	asking := false
+	for i := 0; ; i++ {
+		kind, slot, to := redirect(err, addr)
+		if kind == _redirectNone || i >= c.c.Cluster.MaxRedirects {
+			if _, ok := err.(Error); err != nil && !ok {
+				// NOTE: the node may be down, the slots is refreshed.
+				c.triggerRefresh()
+			}
+			return reply, err
+		}
+		switch kind {
+		case _redirectMoved:
+			c.moved(slot, to)
+			addr, asking = to, false
+		case _redirectAsk:
+			addr, asking = to, true
+		case _redirectRetry:
+			select {
+			case <-time.After(_clusterRetryBackoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		reply, err = c.call(ctx, addr, asking, commandName, args)
+	}
+}
+
+// pipeline executes the commands on the nodes concurrently, the commands of
+// a node are pipelined in order and the redirected ones are followed one by
+// one. The multi-key commands are split by the slots and merged.
+func (c *cluster) pipeline(ctx context.Context, cmds []*cmd) []*reply {
+	var (
+		subs   []*cmd
+		parts  = make([][]int, len(cmds))
+		groups = make([][]*slotGroup, len(cmds))
+	)
+	for i, c := range cmds {
+		if LookupCommandInfo(c.commandName).Set != 0 {
+			continue
+		}
+		if step, ok := _clusterMultiKeys[strings.ToUpper(c.commandName)]; ok {
+			if gs := splitKeys(c.args, step); len(gs) > 1 {
+				groups[i] = gs
+				for _, g := range gs {
+					parts[i] = append(parts[i], len(subs))
+					subs = append(subs, &cmd{commandName: c.commandName, args: g.args})
+				}
+				continue
+			}
+		}
+		parts[i] = []int{len(subs)}
+		subs = append(subs, c)
+	}
+	srps := make([]*reply, len(subs))
+	batches := make(map[string][]int)
+	for i, sub := range subs {
+		addr := c.addr(commandSlot(sub.commandName, sub.args))
+		batches[addr] = append(batches[addr], i)
+	}
+	var wg sync.WaitGroup
+	for addr, idx := range batches {
+		wg.Add(1)
+		go func(addr string, idx []int) {
+			defer wg.Done()
+			c.batch(ctx, addr, subs, idx, srps)
+		}(addr, idx)
+	}
+	wg.Wait()
+	rps := make([]*reply, len(cmds))
+	for i, cmd := range cmds {
+		switch {
+		case len(parts[i]) == 0:
+			rps[i] = &reply{err: ErrClusterUnsupported}
+		case groups[i] == nil:
+			rps[i] = srps[parts[i][0]]
+		default:
+			grps := make([]*reply, len(parts[i]))
+			for j, k := range parts[i] {
+				grps[j] = srps[k]
+			}
+			step := _clusterMultiKeys[strings.ToUpper(cmd.commandName)]
+			rp, err := merge(cmd.commandName, len(cmd.args)/step, groups[i], grps)
+			rps[i] = &reply{reply: rp, err: err}
+		}
+	}
+	return rps
+}
+
+func (c *cluster) batch(ctx context.Context, addr string, cmds []*cmd, idx []int, rps []*reply) {
+	conn := c.get(ctx, addr)
+	err := func() error {
+		for _, i := range idx {
+			if err := conn.Send(cmds[i].commandName, cmds[i].args...); err != nil {
+				return err
+			}
+		}
+		return conn.Flush()
+	}()
+	for _, i := range idx {
+		if err != nil {
+			rps[i] = &reply{err: err}
+			continue
+		}
+		rp, err := conn.Receive()
+		rps[i] = &reply{reply: rp, err: err}
+	}
+	conn.Close()
+	for _, i := range idx {
+		if rps[i].err == nil {
+			continue
+		}
+		rp, err := c.follow(ctx, addr, cmds[i].commandName, cmds[i].args, rps[i].reply, rps[i].err)
+		rps[i] = &reply{reply: rp, err: err}
+	}
+}
+
+func (c *cluster) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	c.mu.Lock()
+	pools := c.pools
+	c.pools = nil
+	c.mu.Unlock()
+	for _, p := range pools {
+		p.Close()
+	}
+	return nil
+}
+
+type clusterPipeliner struct {
+	c    *cluster
+	cmds []*cmd
+}
+
+func (p *clusterPipeliner) Send(commandName string, args ...interface{}) {
+	p.cmds = append(p.cmds, &cmd{commandName: commandName, args: args})
+}
+
+func (p *clusterPipeliner) Exec(ctx context.Context) (rs *Replies, err error) {
+	if len(p.cmds) == 0 {
+		return &Replies{}, nil
+	}
+	cmds := p.cmds
+	p.cmds = nil
+	return &Replies{replies: p.c.pipeline(ctx, cmds)}, nil
+}
+
+// clusterConn is the connection of the cluster, the sent commands are
+// executed as a pipeline when flushed.
+type clusterConn struct {
+	c   *cluster
+	ctx context.Context
+	err error
+
+	pending []*cmd
+	replies []*reply
+}
+
+func (cc *clusterConn) Close() error {
+	cc.err = errConnClosed
+	cc.pending, cc.replies = nil, nil
+	return nil
+}
+
+func (cc *clusterConn) Err() error {
+	return cc.err
+}
+
+func (cc *clusterConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if cc.err != nil {
+		return nil, cc.err
+	}
+	if commandName == "" {
+		if len(cc.pending) == 0 && len(cc.replies) == 0 {
+			return nil, nil
+		}
+		cc.Flush()
+		values := make([]interface{}, len(cc.replies))
+		for i, rp := range cc.replies {
+			if e, ok := rp.err.(Error); ok {
+				values[i] = e
+			} else if rp.err != nil {
+				cc.replies = nil
+				return nil, rp.err
+			} else {
+				values[i] = rp.reply
+			}
+		}
+		cc.replies = nil
+		return values, nil
+	}
+	if len(cc.pending) == 0 && len(cc.replies) == 0 {
+		return cc.c.do(cc.ctx, commandName, args)
+	}
+	if err := cc.Send(commandName, args...); err != nil {
+		return nil, err
+	}
+	cc.Flush()
+	var err error
+	for _, rp := range cc.replies {
+		if rp.err != nil && err == nil {
+			err = rp.err
+		}
+	}
+	last := cc.replies[len(cc.replies)-1]
+	cc.replies = nil
+	return last.reply, err
+}
+
+func (cc *clusterConn) Send(commandName string, args ...interface{}) error {
+	if cc.err != nil {
+		return cc.err
+	}
+	if LookupCommandInfo(commandName).Set != 0 {
+		return ErrClusterUnsupported
+	}
+	cc.pending = append(cc.pending, &cmd{commandName: commandName, args: args})
+	return nil
+}
+
+func (cc *clusterConn) Flush() error {
+	if cc.err != nil {
+		return cc.err
+	}
+	if len(cc.pending) > 0 {
+		cc.replies = append(cc.replies, cc.c.pipeline(cc.ctx, cc.pending)...)
+		cc.pending = nil
+	}
+	return nil
+}
+
+func (cc *clusterConn) Receive() (interface{}, error) {
+	if cc.err != nil {
+		return nil, cc.err
+	}
+	if len(cc.replies) == 0 {
+		cc.Flush()
+	}
+	if len(cc.replies) == 0 {
+		return nil, ErrNoReply
+	}
+	rp := cc.replies[0]
+	cc.replies = cc.replies[1:]
+	return rp.reply, rp.err
+}
+
+func (cc *clusterConn) WithContext(ctx context.Context) Conn {
+	cc.ctx = ctx
+	return cc
+}
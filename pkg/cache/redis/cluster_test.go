@@ -0,0 +1,280 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis/internal/mockserver"
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCluster is a cluster of the mock servers, the slots [0, 8192) are
+// owned by the first node and the others by the second one.
+type testCluster struct {
+	nodes []*mockserver.Server
+
+	mu        sync.Mutex
+	owners    [_clusterSlots]int
+	migrating map[int]int // the slot is migrating to the node
+}
+
+func runTestCluster() *testCluster {
+	tc := &testCluster{migrating: make(map[int]int)}
+	for i := 0; i < 2; i++ {
+		i := i
+		node := mockserver.Run()
+		node.Hook(func(req *mockserver.Request) (interface{}, bool) { return tc.hook(i, req) })
+		tc.nodes = append(tc.nodes, node)
+	}
+	for slot := _clusterSlots / 2; slot < _clusterSlots; slot++ {
+		tc.owners[slot] = 1
+	}
+	return tc
+}
+
+func (tc *testCluster) Close() {
+	for _, node := range tc.nodes {
+		node.Close()
+	}
+}
+
+func (tc *testCluster) move(slot, node int) {
+	tc.mu.Lock()
+	tc.owners[slot] = node
+	tc.mu.Unlock()
+}
+
+func (tc *testCluster) migrate(slot, node int) {
+	tc.mu.Lock()
+	tc.migrating[slot] = node
+	tc.mu.Unlock()
+}
+
+func (tc *testCluster) slots() (res []interface{}) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	start := 0
+	for slot := 1; slot <= _clusterSlots; slot++ {
+		if slot < _clusterSlots && tc.owners[slot] == tc.owners[start] {
+			continue
+		}
+		host, port, _ := net.SplitHostPort(tc.nodes[tc.owners[start]].Addr())
+		p, _ := strconv.Atoi(port)
+		res = append(res, []interface{}{start, slot - 1, []interface{}{host, p, "id"}})
+		start = slot
+	}
+	return
+}
+
+func (tc *testCluster) hook(i int, req *mockserver.Request) (interface{}, bool) {
+	var keys []string
+	switch req.Command {
+	case "CLUSTER":
+		return tc.slots(), true
+	case "GET", "SET", "INCR":
+		keys = req.Args[:1]
+	case "MGET", "DEL", "EXISTS":
+		keys = req.Args
+	case "MSET":
+		for j := 0; j < len(req.Args); j += 2 {
+			keys = append(keys, req.Args[j])
+		}
+	default:
+		return nil, false
+	}
+	slot := Slot(keys[0])
+	for _, key := range keys[1:] {
+		if Slot(key) != slot {
+			return mockserver.Error("CROSSSLOT Keys in request don't hash to the same slot"), true
+		}
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	owner := tc.owners[slot]
+	to, migrating := tc.migrating[slot]
+	switch {
+	case owner == i && migrating:
+		if _, ok := tc.nodes[i].Get(keys[0]); !ok {
+			return mockserver.Error("ASK " + strconv.Itoa(slot) + " " + tc.nodes[to].Addr()), true
+		}
+	case owner != i && !(migrating && to == i && req.Asking):
+		return mockserver.Error("MOVED " + strconv.Itoa(slot) + " " + tc.nodes[owner].Addr()), true
+	}
+	return nil, false
+}
+
+func newTestClusterRedis(tc *testCluster) *Redis {
+	return NewRedis(&Config{
+		Config: &pool.Config{
+			Active:      10,
+			Idle:        2,
+			IdleTimeout: xtime.Duration(90 * time.Second),
+		},
+		Name:         "test",
+		Proto:        "tcp",
+		DialTimeout:  xtime.Duration(time.Second),
+		ReadTimeout:  xtime.Duration(time.Second),
+		WriteTimeout: xtime.Duration(time.Second),
+		Cluster:      &ClusterConfig{Addrs: []string{tc.nodes[0].Addr()}},
+	})
+}
+
+// keys returns the keys owned by the first and second node.
+func testClusterKeys() (k0, k1 string) {
+	for i := 0; k0 == "" || k1 == ""; i++ {
+		key := "key" + strconv.Itoa(i)
+		if Slot(key) < _clusterSlots/2 {
+			k0 = key
+		} else {
+			k1 = key
+		}
+	}
+	return
+}
+
+func TestSlot(t *testing.T) {
+	assert.Equal(t, 12739, Slot("123456789"))
+	assert.Equal(t, 12182, Slot("foo"))
+	assert.Equal(t, 5061, Slot("bar"))
+	assert.Equal(t, Slot("{user1000}.following"), Slot("{user1000}.followers"))
+	assert.Equal(t, Slot("user1000"), Slot("{user1000}.following"))
+	assert.Equal(t, Slot("bar"), Slot("foo{bar}{zap}"))
+	assert.NotEqual(t, Slot("foo"), Slot("{}foo"))
+
+	assert.Equal(t, -1, commandSlot("PING", nil))
+	assert.Equal(t, Slot("foo"), commandSlot("get", []interface{}{[]byte("foo")}))
+	assert.Equal(t, Slot("foo"), commandSlot("EVAL", []interface{}{"return 1", 1, "foo"}))
+	assert.Equal(t, -1, commandSlot("EVALSHA", []interface{}{"sha", 0}))
+	assert.Equal(t, Slot("foo"), commandSlot("XREAD", []interface{}{"COUNT", 1, "STREAMS", "foo", "0"}))
+}
+
+func TestClusterDo(t *testing.T) {
+	tc := runTestCluster()
+	defer tc.Close()
+	r := newTestClusterRedis(tc)
+	defer r.Close()
+	ctx := context.Background()
+	k0, k1 := testClusterKeys()
+
+	_, err := r.Do(ctx, "SET", k0, "v0")
+	assert.Nil(t, err)
+	_, err = r.Do(ctx, "SET", k1, "v1")
+	assert.Nil(t, err)
+	v, _ := tc.nodes[0].Get(k0)
+	assert.Equal(t, "v0", v)
+	v, _ = tc.nodes[1].Get(k1)
+	assert.Equal(t, "v1", v)
+	_, err = r.Do(ctx, "PING")
+	assert.Nil(t, err)
+
+	// the multi-key commands are split by the slots.
+	_, err = r.Do(ctx, "MSET", k1, "v1", "{"+k0+"}.a", "a", k0, "v0")
+	assert.Nil(t, err)
+	vs, err := Strings(r.Do(ctx, "MGET", k1, "absent", k0, "{"+k0+"}.a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"v1", "", "v0", "a"}, vs)
+	n, err := Int(r.Do(ctx, "DEL", k0, k1, "absent"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = Int(r.Do(ctx, "EXISTS", k0, k1))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = r.Do(ctx, "MULTI")
+	assert.Equal(t, ErrClusterUnsupported, err)
+}
+
+func TestClusterRedirect(t *testing.T) {
+	tc := runTestCluster()
+	defer tc.Close()
+	r := newTestClusterRedis(tc)
+	defer r.Close()
+	ctx := context.Background()
+	k0, k1 := testClusterKeys()
+
+	// MOVED updates the slot and refreshes the slots.
+	slot := Slot(k0)
+	tc.move(slot, 1)
+	_, err := r.Do(ctx, "SET", k0, "v0")
+	assert.Nil(t, err)
+	v, _ := tc.nodes[1].Get(k0)
+	assert.Equal(t, "v0", v)
+	assert.Equal(t, tc.nodes[1].Addr(), r.cluster.addr(slot))
+	waitUntil(t, func() bool { return tc.nodes[0].Commands("CLUSTER")+tc.nodes[1].Commands("CLUSTER") >= 2 })
+	// NOTE: wait the refreshed slots applied.
+	time.Sleep(_clusterRefreshInterval)
+
+	// ASK is followed without updating the slot.
+	tc.move(slot, 0)
+	tc.migrate(slot, 1)
+	r.cluster.moved(slot, tc.nodes[0].Addr())
+	v, err = String(r.Do(ctx, "GET", k0))
+	assert.Nil(t, err)
+	assert.Equal(t, "v0", v)
+	assert.Equal(t, tc.nodes[0].Addr(), r.cluster.addr(slot))
+	assert.Equal(t, 1, tc.nodes[1].Commands("ASKING"))
+
+	// the redirects are limited.
+	tc.mu.Lock()
+	for i := range tc.owners {
+		tc.owners[i] = 2
+	}
+	tc.nodes = append(tc.nodes, tc.nodes[0])
+	tc.mu.Unlock()
+	_, err = r.Do(ctx, "GET", k1)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "MOVED")
+}
+
+func TestClusterPipeline(t *testing.T) {
+	tc := runTestCluster()
+	defer tc.Close()
+	r := newTestClusterRedis(tc)
+	defer r.Close()
+	ctx := context.Background()
+	k0, k1 := testClusterKeys()
+	tc.move(Slot(k1), 0)
+
+	p := r.Pipeline()
+	p.Send("SET", k0, "v0")
+	p.Send("INCR", k1)
+	p.Send("MGET", k0, k1)
+	p.Send("INCR", k1)
+	p.Send("GET", k0)
+	rs, err := p.Exec(ctx)
+	assert.Nil(t, err)
+	for _, want := range []interface{}{"OK", int64(1), []interface{}{[]byte("v0"), []byte("1")}, int64(2), []byte("v0")} {
+		reply, err := rs.Scan()
+		assert.Nil(t, err)
+		assert.Equal(t, want, reply)
+	}
+	assert.False(t, rs.Next())
+
+	// the conn sends the commands as a pipeline.
+	conn := r.Conn(ctx)
+	assert.Nil(t, conn.Send("GET", k0))
+	assert.Nil(t, conn.Send("GET", k1))
+	assert.Nil(t, conn.Flush())
+	v, err := String(conn.Receive())
+	assert.Nil(t, err)
+	assert.Equal(t, "v0", v)
+	v, err = String(conn.Receive())
+	assert.Nil(t, err)
+	assert.Equal(t, "2", v)
+	_, err = conn.Receive()
+	assert.Equal(t, ErrNoReply, err)
+	assert.Equal(t, ErrClusterUnsupported, conn.Send("WATCH", k0))
+	n, err := Int(conn.Do("INCR", k1))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Nil(t, conn.Close())
+	_, err = conn.Do("GET", k0)
+	assert.Equal(t, errConnClosed, err)
+}
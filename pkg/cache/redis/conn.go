@@ -70,6 +70,7 @@ type conn struct {
 	// Read
 	readTimeout time.Duration
 	br          *bufio.Reader
+	push        func(Push)
 
 	// Write
 	writeTimeout time.Duration
@@ -105,6 +106,9 @@ type dialOptions struct {
 	dial         func(network, addr string) (net.Conn, error)
 	db           int
 	password     string
+	protocol     int
+	push         func(Push)
+	commands     []*cmd
 }
 
 // DialReadTimeout specifies the timeout for reading a single command reply.
@@ -153,6 +157,30 @@ func DialPassword(password string) DialOption {
 	}}
 }
 
+// DialProtocol specifies the version of the RESP protocol, the connection
+// switches to RESP3 by HELLO 3 if the version is 3.
+func DialProtocol(version int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.protocol = version
+	}}
+}
+
+// DialPushHandler specifies the handler of the RESP3 push replies, e.g. the
+// invalidation messages of client side caching. The pushes are returned by
+// Receive if the handler is not set.
+func DialPushHandler(fn func(Push)) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.push = fn
+	}}
+}
+
+// dialCommand specifies a command to execute when dialing a connection.
+func dialCommand(commandName string, args ...interface{}) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.commands = append(do.commands, &cmd{commandName: commandName, args: args})
+	}}
+}
+
 // Dial connects to the Redis server at the given network and
 // address using the specified options.
 func Dial(network, address string, options ...DialOption) (Conn, error) {
@@ -173,9 +201,19 @@ func Dial(network, address string, options ...DialOption) (Conn, error) {
 		br:           bufio.NewReader(netConn),
 		readTimeout:  do.readTimeout,
 		writeTimeout: do.writeTimeout,
+		push:         do.push,
 	}
 
-	if do.password != "" {
+	if do.protocol == 3 {
+		args := []interface{}{3}
+		if do.password != "" {
+			args = append(args, "AUTH", "default", do.password)
+		}
+		if _, err := c.Do("HELLO", args...); err != nil {
+			netConn.Close()
+			return nil, errors.WithStack(err)
+		}
+	} else if do.password != "" {
 		if _, err := c.Do("AUTH", do.password); err != nil {
 			netConn.Close()
 			return nil, errors.WithStack(err)
@@ -188,6 +226,13 @@ func Dial(network, address string, options ...DialOption) (Conn, error) {
 			return nil, errors.WithStack(err)
 		}
 	}
+
+	for _, cmd := range do.commands {
+		if _, err := c.Do(cmd.commandName, cmd.args...); err != nil {
+			netConn.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
 	return c, nil
 }
 
@@ -248,14 +293,14 @@ func NewConn(c *Config) (cn Conn, err error) {
 	rdop := DialReadTimeout(time.Duration(c.ReadTimeout))
 	wrop := DialWriteTimeout(time.Duration(c.WriteTimeout))
 	auop := DialPassword(c.Auth)
+	prop := DialProtocol(c.Protocol)
 	// new conn
-	cn, err = Dial(c.Proto, c.Addr, cnop, rdop, wrop, auop)
+	cn, err = Dial(c.Proto, c.Addr, cnop, rdop, wrop, auop, prop)
 	return
 }
 
 func (c *conn) Close() error {
 	c.mu.Lock()
-	c.ctx = nil
 	err := c.err
 	if c.err == nil {
 		c.err = errors.New("redigo: closed")
@@ -465,37 +510,117 @@ func (c *conn) readReply() (interface{}, error) {
 	case ':':
 		return parseInt(line[1:])
 	case '$':
+		return c.readBulk(line[1:])
+	case '*', '%', '~':
+		// NOTE: the RESP3 map and set are read as the array, the map is
+		// alternating keys and values like HGETALL of RESP2.
 		n, err := parseLen(line[1:])
 		if n < 0 || err != nil {
 			return nil, err
 		}
-		p := make([]byte, n)
-		_, err = io.ReadFull(c.br, p)
+		if line[0] == '%' {
+			n *= 2
+		}
+		return c.readArray(n)
+	case '_':
+		return nil, nil
+	case ',':
+		f, err := strconv.ParseFloat(string(line[1:]), 64)
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return nil, errors.WithStack(protocolError("malformed double"))
+		}
+		return f, nil
+	case '#':
+		if len(line) != 2 || line[1] != 't' && line[1] != 'f' {
+			return nil, errors.WithStack(protocolError("malformed boolean"))
+		}
+		return line[1] == 't', nil
+	case '(':
+		return append([]byte(nil), line[1:]...), nil
+	case '!':
+		p, err := c.readBulk(line[1:])
+		if p == nil || err != nil {
+			return nil, err
 		}
-		if line1, err := c.readLine(); err != nil {
+		return Error(p.([]byte)), nil
+	case '=':
+		p, err := c.readBulk(line[1:])
+		if p == nil || err != nil {
 			return nil, err
-		} else if len(line1) != 0 {
-			return nil, errors.WithStack(protocolError("bad bulk string format"))
+		}
+		// strip the format of the verbatim string, e.g. "txt:".
+		if b := p.([]byte); len(b) >= 4 && b[3] == ':' {
+			return b[4:], nil
 		}
 		return p, nil
-	case '*':
+	case '>':
 		n, err := parseLen(line[1:])
 		if n < 0 || err != nil {
 			return nil, err
 		}
-		r := make([]interface{}, n)
-		for i := range r {
-			r[i], err = c.readReply()
-			if err != nil {
-				return nil, err
-			}
+		r, err := c.readArray(n)
+		if err != nil {
+			return nil, err
+		}
+		return Push(r), nil
+	case '|':
+		// NOTE: the attributes are auxiliary data which is ignored, the
+		// reply follows them.
+		n, err := parseLen(line[1:])
+		if n < 0 || err != nil {
+			return nil, err
+		}
+		if _, err = c.readArray(n * 2); err != nil {
+			return nil, err
 		}
-		return r, nil
+		return c.readReply()
 	}
 	return nil, errors.WithStack(protocolError("unexpected response line"))
 }
+
+func (c *conn) readBulk(l []byte) (interface{}, error) {
+	n, err := parseLen(l)
+	if n < 0 || err != nil {
+		return nil, err
+	}
+	p := make([]byte, n)
+	_, err = io.ReadFull(c.br, p)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if line, err := c.readLine(); err != nil {
+		return nil, err
+	} else if len(line) != 0 {
+		return nil, errors.WithStack(protocolError("bad bulk string format"))
+	}
+	return p, nil
+}
+
+func (c *conn) readArray(n int) ([]interface{}, error) {
+	var err error
+	r := make([]interface{}, n)
+	for i := range r {
+		r[i], err = c.readReply()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// readResult reads a reply, the pushes are passed to the push handler if
+// it is set.
+func (c *conn) readResult() (interface{}, error) {
+	for {
+		reply, err := c.readReply()
+		if p, ok := reply.(Push); ok && err == nil && c.push != nil {
+			c.push(p)
+			continue
+		}
+		return reply, err
+	}
+}
+
 func (c *conn) Send(cmd string, args ...interface{}) (err error) {
 	c.mu.Lock()
 	c.pending++
@@ -520,7 +645,7 @@ func (c *conn) Receive() (reply interface{}, err error) {
 	if c.readTimeout != 0 {
 		c.conn.SetReadDeadline(shrinkDeadline(c.ctx, c.readTimeout))
 	}
-	if reply, err = c.readReply(); err != nil {
+	if reply, err = c.readResult(); err != nil {
 		return nil, c.fatal(err)
 	}
 	// When using pub/sub, the number of receives can be greater than the
@@ -566,7 +691,7 @@ func (c *conn) Do(cmd string, args ...interface{}) (reply interface{}, err error
 		reply := make([]interface{}, pending)
 		for i := range reply {
 			var r interface{}
-			r, err = c.readReply()
+			r, err = c.readResult()
 			if err != nil {
 				break
 			}
@@ -580,7 +705,7 @@ func (c *conn) Do(cmd string, args ...interface{}) (reply interface{}, err error
 
 	for i := 0; i <= pending; i++ {
 		var e error
-		if reply, e = c.readReply(); e != nil {
+		if reply, e = c.readResult(); e != nil {
 			return nil, c.fatal(e)
 		}
 		if e, ok := reply.(Error); ok && err == nil {
@@ -599,6 +724,7 @@ func (c *conn) copy() *conn {
 		br:           c.br,
 		readTimeout:  c.readTimeout,
 		writeTimeout: c.writeTimeout,
+		push:         c.push,
 	}
 }
 
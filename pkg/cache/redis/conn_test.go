@@ -154,6 +154,38 @@ var readTests = []struct {
 		"*3\r\n$3\r\nfoo\r\n$-1\r\n$3\r\nbar\r\n",
 		[]interface{}{[]byte("foo"), nil, []byte("bar")},
 	},
+	{
+		"%2\r\n+first\r\n:1\r\n$6\r\nsecond\r\n,2.5\r\n",
+		[]interface{}{"first", int64(1), []byte("second"), float64(2.5)},
+	},
+	{
+		"~2\r\n#t\r\n#f\r\n",
+		[]interface{}{true, false},
+	},
+	{
+		"_\r\n",
+		nil,
+	},
+	{
+		",-inf\r\n",
+		math.Inf(-1),
+	},
+	{
+		"(3492890328409238509324850943850943825024385\r\n",
+		[]byte("3492890328409238509324850943850943825024385"),
+	},
+	{
+		"=15\r\ntxt:Some string\r\n",
+		[]byte("Some string"),
+	},
+	{
+		">3\r\n$7\r\nmessage\r\n$3\r\nfoo\r\n$3\r\nbar\r\n",
+		Push{[]byte("message"), []byte("foo"), []byte("bar")},
+	},
+	{
+		"|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.19\r\n*1\r\n:2039123\r\n",
+		[]interface{}{int64(2039123)},
+	},
 
 	{
 		// "x" is not a valid length
@@ -185,6 +217,11 @@ var readTests = []struct {
 		"$6\r\nfoobarx\r\n",
 		errorSentinel,
 	},
+	{
+		// "x" is not a valid boolean
+		"#x\r\n",
+		errorSentinel,
+	},
 }
 
 func TestRead(t *testing.T) {
@@ -207,6 +244,35 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestReadPush(t *testing.T) {
+	var pushes []Push
+	c, _ := Dial("", "",
+		dialTestConn(strings.NewReader(">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n+OK\r\n!10\r\nERR failed\r\n"), nil),
+		DialPushHandler(func(p Push) { pushes = append(pushes, p) }),
+	)
+	reply, err := c.Receive()
+	if err != nil || reply != "OK" {
+		t.Fatalf("Receive() = %v, %v, want OK", reply, err)
+	}
+	if want := []Push{{[]byte("invalidate"), []interface{}{[]byte("foo")}}}; !reflect.DeepEqual(pushes, want) {
+		t.Errorf("pushes = %v, want %v", pushes, want)
+	}
+	if _, err = c.Receive(); err != Error("ERR failed") {
+		t.Errorf("Receive() error = %v, want ERR failed", err)
+	}
+}
+
+func TestDialProtocol(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := Dial("", "", dialTestConn(strings.NewReader("%1\r\n$5\r\nproto\r\n:3\r\n"), &buf), DialProtocol(3), DialPassword("pw"))
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if want := "*5\r\n$5\r\nHELLO\r\n$1\r\n3\r\n$4\r\nAUTH\r\n$7\r\ndefault\r\n$2\r\npw\r\n"; buf.String() != want {
+		t.Errorf("written = %q, want %q", buf.String(), want)
+	}
+}
+
 var testCommands = []struct {
 	args     []interface{}
 	expected interface{}
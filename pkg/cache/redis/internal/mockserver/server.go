@@ -0,0 +1,570 @@
+package mockserver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Status is a simple string reply.
+type Status string
+
+// Error is an error reply.
+type Error string
+
+// Map is a RESP3 map reply of the alternating keys and values, which is
+// written as an array to the RESP2 clients.
+type Map []interface{}
+
+// Push is a RESP3 push reply.
+type Push []interface{}
+
+// Double is a RESP3 double reply.
+type Double float64
+
+// Request is a command received by the server.
+type Request struct {
+	Command string // the upper case command name
+	Args    []string
+	Asking  bool // ASKING is sent before the command on the connection
+}
+
+// Hook intercepts the requests, the reply is written instead of executing
+// the command if ok.
+type Hook func(req *Request) (reply interface{}, ok bool)
+
+// Server is a redis server on a random local port, which supports the
+// string and pub/sub commands.
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	auth     string
+	hook     Hook
+	data     map[string][]byte
+	commands map[string]int
+	clients  map[*client]struct{}
+	// the clients tracking the keys for the client side caching.
+	nextID  int64
+	tracked map[string]map[int64]struct{}
+}
+
+type client struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	wmu sync.Mutex
+	bw  *bufio.Writer
+
+	id     int64
+	proto  int
+	authed bool
+	asking bool
+	subs   map[string]struct{}
+
+	tracking bool
+	redirect int64
+	bcast    bool
+	prefixes []string
+}
+
+type delivery struct {
+	c    *client
+	push Push
+}
+
+// Run runs a mock server on a random local port.
+func Run() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		ln:       ln,
+		data:     make(map[string][]byte),
+		commands: make(map[string]int),
+		clients:  make(map[*client]struct{}),
+		tracked:  make(map[string]map[int64]struct{}),
+	}
+	go s.serve()
+	return s
+}
+
+// Addr returns the address of the server.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close closes the server and the connections.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.CloseClients()
+}
+
+// CloseClients closes the connections of the clients.
+func (s *Server) CloseClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+}
+
+// SetAuth sets the password of the server.
+func (s *Server) SetAuth(auth string) {
+	s.mu.Lock()
+	s.auth = auth
+	s.mu.Unlock()
+}
+
+// Hook sets the hook of the requests.
+func (s *Server) Hook(hook Hook) {
+	s.mu.Lock()
+	s.hook = hook
+	s.mu.Unlock()
+}
+
+// Commands returns the number of the received commands of the name.
+func (s *Server) Commands(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[strings.ToUpper(name)]
+}
+
+// Get returns the value of the key.
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return string(v), ok
+}
+
+// Set sets the value of the key.
+func (s *Server) Set(key, value string) {
+	s.mu.Lock()
+	s.data[key] = []byte(value)
+	s.mu.Unlock()
+}
+
+// Publish publishes the message to the subscribers of the channel.
+func (s *Server) Publish(channel, message string) (n int) {
+	s.mu.Lock()
+	var subs []*client
+	for c := range s.clients {
+		if _, ok := c.subs[channel]; ok {
+			subs = append(subs, c)
+		}
+	}
+	s.mu.Unlock()
+	for _, c := range subs {
+		msg := []interface{}{"message", channel, message}
+		if c.proto == 3 {
+			msg = Push(msg)
+		}
+		if c.write(msg) == nil {
+			n++
+		}
+	}
+	return
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &client{
+			conn:  conn,
+			br:    bufio.NewReader(conn),
+			bw:    bufio.NewWriter(conn),
+			subs:  make(map[string]struct{}),
+			proto: 2,
+		}
+		s.mu.Lock()
+		s.nextID++
+		c.id = s.nextID
+		s.clients[c] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c *client) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+	for {
+		args, err := readCommand(c.br)
+		if err != nil || len(args) == 0 {
+			return
+		}
+		req := &Request{Command: strings.ToUpper(args[0]), Args: args[1:], Asking: c.asking}
+		c.asking = false
+		if c.write(s.exec(c, req)) != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(c *client, req *Request) interface{} {
+	s.mu.Lock()
+	s.commands[req.Command]++
+	auth, hook := s.auth, s.hook
+	s.mu.Unlock()
+	switch req.Command {
+	case "AUTH":
+		if len(req.Args) != 1 || req.Args[0] != auth {
+			return Error("ERR invalid password")
+		}
+		c.authed = true
+		return Status("OK")
+	case "HELLO":
+		proto := 2
+		if len(req.Args) > 0 {
+			proto, _ = strconv.Atoi(req.Args[0])
+		}
+		if len(req.Args) == 4 && strings.ToUpper(req.Args[1]) == "AUTH" {
+			if req.Args[3] != auth {
+				return Error("WRONGPASS invalid username-password pair")
+			}
+			c.authed = true
+		}
+		if auth != "" && !c.authed {
+			return Error("NOAUTH HELLO must be called with the client already authenticated")
+		}
+		c.proto = proto
+		return Map{"server", "redis", "proto", proto, "id", c.id}
+	}
+	if auth != "" && !c.authed {
+		return Error("NOAUTH Authentication required.")
+	}
+	if hook != nil {
+		if reply, ok := hook(req); ok {
+			return reply
+		}
+	}
+	s.mu.Lock()
+	reply, pushes := s.execLocked(c, req)
+	s.mu.Unlock()
+	for _, d := range pushes {
+		d.c.write(d.push)
+	}
+	return reply
+}
+
+func (s *Server) execLocked(c *client, req *Request) (reply interface{}, pushes []delivery) {
+	args := req.Args
+	switch req.Command {
+	case "CLIENT":
+		return s.client(c, args), nil
+	case "GET", "MGET", "EXISTS":
+		s.track(c, args)
+	case "SET", "INCR":
+		defer func() { pushes = s.invalidate(args[:1]) }()
+	case "DEL":
+		defer func() { pushes = s.invalidate(args) }()
+	case "MSET":
+		var keys []string
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		defer func() { pushes = s.invalidate(keys) }()
+	case "FLUSHALL":
+		s.data = make(map[string][]byte)
+		return Status("OK"), s.invalidate(nil)
+	}
+	return s.execCommand(c, req), nil
+}
+
+func (s *Server) execCommand(c *client, req *Request) interface{} {
+	args := req.Args
+	switch req.Command {
+	case "PING":
+		if len(args) > 0 {
+			return args[0]
+		}
+		return Status("PONG")
+	case "ECHO":
+		return args[0]
+	case "SELECT", "QUIT":
+		return Status("OK")
+	case "ASKING":
+		c.asking = true
+		return Status("OK")
+	case "GET":
+		if v, ok := s.data[args[0]]; ok {
+			return v
+		}
+		return nil
+	case "SET":
+		s.data[args[0]] = []byte(args[1])
+		return Status("OK")
+	case "MGET":
+		vs := make([]interface{}, len(args))
+		for i, k := range args {
+			if v, ok := s.data[k]; ok {
+				vs[i] = v
+			}
+		}
+		return vs
+	case "MSET":
+		for i := 0; i+1 < len(args); i += 2 {
+			s.data[args[i]] = []byte(args[i+1])
+		}
+		return Status("OK")
+	case "DEL", "EXISTS":
+		n := 0
+		for _, k := range args {
+			if _, ok := s.data[k]; ok {
+				n++
+				if req.Command == "DEL" {
+					delete(s.data, k)
+				}
+			}
+		}
+		return n
+	case "INCR":
+		n, _ := strconv.Atoi(string(s.data[args[0]]))
+		n++
+		s.data[args[0]] = []byte(strconv.Itoa(n))
+		return n
+	case "SUBSCRIBE":
+		replies := make([]interface{}, 0, len(args))
+		for _, ch := range args {
+			c.subs[ch] = struct{}{}
+			replies = append(replies, []interface{}{"subscribe", ch, len(c.subs)})
+		}
+		return multi(replies)
+	}
+	return Error(fmt.Sprintf("ERR unknown command '%s'", req.Command))
+}
+
+func (s *Server) client(c *client, args []string) interface{} {
+	if len(args) == 0 {
+		return Error("ERR wrong number of arguments for 'client' command")
+	}
+	switch strings.ToUpper(args[0]) {
+	case "ID":
+		return c.id
+	case "TRACKING":
+		if len(args) < 2 {
+			return Error("ERR syntax error")
+		}
+		c.tracking = strings.ToUpper(args[1]) == "ON"
+		c.redirect, c.bcast, c.prefixes = 0, false, nil
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "REDIRECT":
+				i++
+				c.redirect, _ = strconv.ParseInt(args[i], 10, 64)
+			case "BCAST":
+				c.bcast = true
+			case "PREFIX":
+				i++
+				c.prefixes = append(c.prefixes, args[i])
+			}
+		}
+		return Status("OK")
+	}
+	return Error("ERR unknown subcommand '" + args[0] + "'")
+}
+
+// track remembers the keys read by the tracking client.
+func (s *Server) track(c *client, keys []string) {
+	if !c.tracking || c.bcast {
+		return
+	}
+	target := c.id
+	if c.redirect != 0 {
+		target = c.redirect
+	}
+	for _, key := range keys {
+		if s.tracked[key] == nil {
+			s.tracked[key] = make(map[int64]struct{})
+		}
+		s.tracked[key][target] = struct{}{}
+	}
+}
+
+// invalidate returns the invalidation pushes of the keys, or of all the
+// keys if nil.
+func (s *Server) invalidate(keys []string) (pushes []delivery) {
+	byID := make(map[int64]*client)
+	for cli := range s.clients {
+		byID[cli.id] = cli
+	}
+	targets := make(map[int64][]interface{})
+	var order []int64
+	notify := func(id int64, key interface{}) {
+		if _, ok := targets[id]; !ok {
+			order = append(order, id)
+		}
+		if key != nil {
+			targets[id] = append(targets[id], key)
+		} else if targets[id] == nil {
+			targets[id] = []interface{}{}
+		}
+	}
+	if keys == nil {
+		for _, ids := range s.tracked {
+			for id := range ids {
+				notify(id, nil)
+			}
+		}
+		for cli := range s.clients {
+			if cli.tracking && cli.bcast {
+				notify(cli.id, nil)
+			}
+		}
+		s.tracked = make(map[string]map[int64]struct{})
+	}
+	for _, key := range keys {
+		for id := range s.tracked[key] {
+			notify(id, key)
+		}
+		delete(s.tracked, key)
+		for cli := range s.clients {
+			if !cli.tracking || !cli.bcast {
+				continue
+			}
+			for _, prefix := range cli.prefixes {
+				if strings.HasPrefix(key, prefix) {
+					notify(cli.id, key)
+					break
+				}
+			}
+		}
+	}
+	for _, id := range order {
+		cli, ok := byID[id]
+		if !ok || cli.proto != 3 {
+			continue
+		}
+		var ks interface{}
+		if keys != nil {
+			ks = targets[id]
+		}
+		pushes = append(pushes, delivery{c: cli, push: Push{"invalidate", ks}})
+	}
+	return
+}
+
+// multi is the replies of a command, e.g. SUBSCRIBE of the channels.
+type multi []interface{}
+
+func (c *client) write(reply interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if m, ok := reply.(multi); ok {
+		for _, r := range m {
+			writeReply(c.bw, r, c.proto)
+		}
+	} else {
+		writeReply(c.bw, reply, c.proto)
+	}
+	return c.bw.Flush()
+}
+
+func writeReply(w *bufio.Writer, reply interface{}, proto int) {
+	switch r := reply.(type) {
+	case nil:
+		if proto == 3 {
+			w.WriteString("_\r\n")
+		} else {
+			w.WriteString("$-1\r\n")
+		}
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", r)
+	case Error:
+		fmt.Fprintf(w, "-%s\r\n", r)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", r)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", r)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(r), r)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(r), r)
+	case Double:
+		fmt.Fprintf(w, ",%s\r\n", strconv.FormatFloat(float64(r), 'g', -1, 64))
+	case bool:
+		if r {
+			w.WriteString("#t\r\n")
+		} else {
+			w.WriteString("#f\r\n")
+		}
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(r))
+		for _, v := range r {
+			writeReply(w, v, proto)
+		}
+	case []interface{}:
+		writeAggregate(w, '*', len(r), r, proto)
+	case Map:
+		if proto == 3 {
+			writeAggregate(w, '%', len(r)/2, r, proto)
+		} else {
+			writeAggregate(w, '*', len(r), r, proto)
+		}
+	case Push:
+		writeAggregate(w, '>', len(r), r, proto)
+	default:
+		panic(fmt.Sprintf("mockserver: unsupported reply %T", reply))
+	}
+}
+
+func writeAggregate(w *bufio.Writer, prefix byte, n int, rs []interface{}, proto int) {
+	fmt.Fprintf(w, "%c%d\r\n", prefix, n)
+	for _, r := range rs {
+		writeReply(w, r, proto)
+	}
+}
+
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = readLine(br); err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("mockserver: bad bulk %q", line)
+		}
+		l, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		p := make([]byte, l+2)
+		if _, err = io.ReadFull(br, p); err != nil {
+			return nil, err
+		}
+		args[i] = string(p[:l])
+	}
+	return args, nil
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -50,4 +50,25 @@ var (
 		Help:      "redis client misses total.",
 		Labels:    []string{"name", "addr"},
 	})
+	_metricCacheHits = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "client_cache",
+		Name:      "hits_total",
+		Help:      "redis client side cache hits total.",
+		Labels:    []string{"name", "addr"},
+	})
+	_metricCacheMisses = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "client_cache",
+		Name:      "misses_total",
+		Help:      "redis client side cache misses total.",
+		Labels:    []string{"name", "addr"},
+	})
+	_metricCacheSize = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "client_cache",
+		Name:      "keys",
+		Help:      "redis client side cache keys current.",
+		Labels:    []string{"name", "addr"},
+	})
 )
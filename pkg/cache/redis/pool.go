@@ -43,10 +43,25 @@ type Pool struct {
 	c *Config
 	// statfunc
 	statfunc func(name, addr, cmd string, t time.Time, err error) func()
+	// stale reports whether the connections to the addr must not be reused,
+	// e.g. the addr is not the master any more.
+	stale func(addr string) bool
+	// sentinel and the replicas for the sentinel managed redis.
+	sentinel *sentinelClient
+	replica  *Pool
 }
 
-// NewPool creates a new pool.
+// NewPool creates a new pool. The master is discovered by the sentinels if
+// c.Sentinel is set, or c.Addr is dialed.
 func NewPool(c *Config, options ...DialOption) (p *Pool) {
+	ops := buildDialOptions(c, options)
+	if c.Sentinel != nil {
+		return newSentinelPool(c, ops)
+	}
+	return newPool(c, ops, func() (string, error) { return c.Addr, nil })
+}
+
+func buildDialOptions(c *Config, options []DialOption) []DialOption {
 	if c.DialTimeout <= 0 || c.ReadTimeout <= 0 || c.WriteTimeout <= 0 {
 		panic("must config redis timeout")
 	}
@@ -58,19 +73,29 @@ func NewPool(c *Config, options ...DialOption) (p *Pool) {
 		DialReadTimeout(time.Duration(c.ReadTimeout)),
 		DialWriteTimeout(time.Duration(c.WriteTimeout)),
 		DialPassword(c.Auth),
+		DialProtocol(c.Protocol),
 	}
-	ops = append(ops, options...)
+	return append(ops, options...)
+}
+
+// newPool creates a new pool which dials the address returned by addrFunc.
+func newPool(c *Config, ops []DialOption, addrFunc func() (string, error)) (p *Pool) {
 	p1 := pool.NewSlice(c.Config)
 
 	// new pool
 	p1.New = func(ctx context.Context) (io.Closer, error) {
-		conn, err := Dial(c.Proto, c.Addr, ops...)
+		addr, err := addrFunc()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := Dial(c.Proto, addr, ops...)
 		if err != nil {
 			return nil, err
 		}
 		return &traceConn{
 			Conn:             conn,
-			connTags:         []trace.Tag{trace.TagString(trace.TagPeerAddress, c.Addr)},
+			addr:             addr,
+			connTags:         []trace.Tag{trace.TagString(trace.TagPeerAddress, addr)},
 			slowLogThreshold: time.Duration(c.SlowLog),
 		}, nil
 	}
@@ -84,16 +109,36 @@ func NewPool(c *Config, options ...DialOption) (p *Pool) {
 // getting an underlying connection, then the connection Err, Do, Send, Flush
 // and Receive methods return that error.
 func (p *Pool) Get(ctx context.Context) Conn {
-	c, err := p.Slice.Get(ctx)
-	if err != nil {
-		return errorConnection{err}
+	for {
+		c, err := p.Slice.Get(ctx)
+		if err != nil {
+			return errorConnection{err}
+		}
+		c1, _ := c.(Conn)
+		addr := p.c.Addr
+		if tc, ok := c1.(*traceConn); ok {
+			addr = tc.addr
+		}
+		if p.isStale(addr) {
+			p.Slice.Put(ctx, c, true)
+			continue
+		}
+		return &pooledConnection{p: p, c: c1.WithContext(ctx), rc: c1, addr: addr, now: beginTime}
 	}
-	c1, _ := c.(Conn)
-	return &pooledConnection{p: p, c: c1.WithContext(ctx), rc: c1, now: beginTime}
+}
+
+func (p *Pool) isStale(addr string) bool {
+	return p.stale != nil && p.stale(addr)
 }
 
 // Close releases the resources used by the pool.
 func (p *Pool) Close() error {
+	if p.sentinel != nil {
+		p.sentinel.Close()
+	}
+	if p.replica != nil {
+		p.replica.Close()
+	}
 	return p.Slice.Close()
 }
 
@@ -101,6 +146,7 @@ type pooledConnection struct {
 	p     *Pool
 	rc    Conn
 	c     Conn
+	addr  string
 	state int
 
 	now  time.Time
@@ -176,7 +222,7 @@ func (pc *pooledConnection) Close() error {
 		}
 	}
 	_, err := c.Do("")
-	pc.p.Slice.Put(context.Background(), pc.rc, pc.state != 0 || c.Err() != nil)
+	pc.p.Slice.Put(context.Background(), pc.rc, pc.state != 0 || c.Err() != nil || pc.p.isStale(pc.addr))
 	return err
 }
 
@@ -190,7 +236,7 @@ func (pc *pooledConnection) Do(commandName string, args ...interface{}) (reply i
 	pc.state = (pc.state | ci.Set) &^ ci.Clear
 	reply, err = pc.c.Do(commandName, args...)
 	if pc.p.statfunc != nil {
-		pc.p.statfunc(pc.p.c.Name, pc.p.c.Addr, commandName, now, err)()
+		pc.p.statfunc(pc.p.c.Name, pc.addr, commandName, now, err)()
 	}
 	return
 }
@@ -216,7 +262,7 @@ func (pc *pooledConnection) Receive() (reply interface{}, err error) {
 		cmd := pc.cmds[0]
 		pc.cmds = pc.cmds[1:]
 		if pc.p.statfunc != nil {
-			pc.p.statfunc(pc.p.c.Name, pc.p.c.Addr, cmd, pc.now, err)()
+			pc.p.statfunc(pc.p.c.Name, pc.addr, cmd, pc.now, err)()
 		}
 	}
 	return
@@ -26,6 +26,10 @@ type Error string
 
 func (err Error) Error() string { return string(err) }
 
+// Push represents a RESP3 push reply, e.g. the pub/sub messages and the
+// invalidation messages of client side caching.
+type Push []interface{}
+
 // Config client settings.
 type Config struct {
 	*pool.Config
@@ -38,23 +42,58 @@ type Config struct {
 	ReadTimeout  xtime.Duration
 	WriteTimeout xtime.Duration
 	SlowLog      xtime.Duration
+	// Protocol is the version of the RESP protocol, RESP3 is used if 3.
+	Protocol int
+
+	// Sentinel discovers the master by the sentinels instead of Addr.
+	Sentinel *SentinelConfig
+	// Cluster talks to the redis cluster instead of Addr, which is only
+	// supported by Redis.
+	Cluster *ClusterConfig
+	// ClientCache caches the replies of Redis.Do in process, which is only
+	// supported with Addr.
+	ClientCache *ClientCacheConfig
 }
 
 type Redis struct {
-	pool *Pool
-	conf *Config
+	pool    *Pool
+	cluster *cluster
+	cache   *clientCache
+	conf    *Config
 }
 
 func NewRedis(c *Config, options ...DialOption) *Redis {
-	return &Redis{
+	if c.Cluster != nil {
+		return &Redis{
+			cluster: newCluster(c, options...),
+			conf:    c,
+		}
+	}
+	r := &Redis{
 		pool: NewPool(c, options...),
 		conf: c,
 	}
+	if c.ClientCache != nil {
+		r.cache = newClientCache(c, buildDialOptions(c, options))
+	}
+	return r
 }
 
 // Do gets a new conn from pool, then execute Do with this conn, finally close this conn.
 // ATTENTION: Don't use this method with transaction command like MULTI etc. Because every Do will close conn automatically, use r.Conn to get a raw conn for this situation.
+// The read only commands are sent to the replicas if Sentinel.ReadFromReplica is set,
+// and the commands are routed by the keys if Cluster is set.
+// The replies of the single key read only commands are cached if ClientCache is set.
 func (r *Redis) Do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if r.cluster != nil {
+		return r.cluster.do(ctx, commandName, args)
+	}
+	if r.cache != nil {
+		return r.cache.do(ctx, commandName, args)
+	}
+	if r.pool.replica != nil && isReadOnly(commandName) {
+		return r.pool.doReplica(ctx, commandName, args)
+	}
 	conn := r.pool.Get(ctx)
 	defer conn.Close()
 	reply, err = conn.Do(commandName, args...)
@@ -63,15 +102,28 @@ func (r *Redis) Do(ctx context.Context, commandName string, args ...interface{})
 
 // Close closes connection pool
 func (r *Redis) Close() error {
+	if r.cluster != nil {
+		return r.cluster.Close()
+	}
+	if r.cache != nil {
+		r.cache.Close()
+	}
 	return r.pool.Close()
 }
 
-// Conn direct gets a connection
+// Conn direct gets a connection, the connection of the cluster doesn't support
+// the transaction and pub/sub commands.
 func (r *Redis) Conn(ctx context.Context) Conn {
+	if r.cluster != nil {
+		return &clusterConn{c: r.cluster, ctx: ctx}
+	}
 	return r.pool.Get(ctx)
 }
 
 func (r *Redis) Pipeline() (p Pipeliner) {
+	if r.cluster != nil {
+		return &clusterPipeliner{c: r.cluster}
+	}
 	return &pipeliner{
 		pool: r.pool,
 	}
@@ -119,6 +119,7 @@ func Uint64(reply interface{}, err error) (uint64, error) {
 // the reply to an int as follows:
 //
 //  Reply type    Result
+//  double        reply, nil
 //  bulk string   parsed reply, nil
 //  nil           0, ErrNil
 //  other         0, error
@@ -127,6 +128,8 @@ func Float64(reply interface{}, err error) (float64, error) {
 		return 0, err
 	}
 	switch reply := reply.(type) {
+	case float64:
+		return reply, nil
 	case []byte:
 		n, err := strconv.ParseFloat(string(reply), 64)
 		return n, pkgerr.WithStack(err)
@@ -145,6 +148,7 @@ func Float64(reply interface{}, err error) (float64, error) {
 //  Reply type      Result
 //  bulk string     string(reply), nil
 //  simple string   reply, nil
+//  double          formatted reply, nil
 //  nil             "",  ErrNil
 //  other           "",  error
 func String(reply interface{}, err error) (string, error) {
@@ -156,6 +160,8 @@ func String(reply interface{}, err error) (string, error) {
 		return string(reply), nil
 	case string:
 		return reply, nil
+	case float64:
+		return strconv.FormatFloat(reply, 'g', -1, 64), nil
 	case nil:
 		return "", ErrNil
 	case Error:
@@ -171,6 +177,7 @@ func String(reply interface{}, err error) (string, error) {
 //  Reply type      Result
 //  bulk string     reply, nil
 //  simple string   []byte(reply), nil
+//  double          formatted reply, nil
 //  nil             nil, ErrNil
 //  other           nil, error
 func Bytes(reply interface{}, err error) ([]byte, error) {
@@ -182,6 +189,8 @@ func Bytes(reply interface{}, err error) ([]byte, error) {
 		return reply, nil
 	case string:
 		return []byte(reply), nil
+	case float64:
+		return strconv.AppendFloat(nil, reply, 'g', -1, 64), nil
 	case nil:
 		return nil, ErrNil
 	case Error:
@@ -196,6 +205,7 @@ func Bytes(reply interface{}, err error) ([]byte, error) {
 //
 //  Reply type      Result
 //  integer         value != 0, nil
+//  boolean         reply, nil
 //  bulk string     strconv.ParseBool(reply)
 //  nil             false, ErrNil
 //  other           false, error
@@ -206,6 +216,8 @@ func Bool(reply interface{}, err error) (bool, error) {
 	switch reply := reply.(type) {
 	case int64:
 		return reply != 0, nil
+	case bool:
+		return reply, nil
 	case []byte:
 		b, e := strconv.ParseBool(string(reply))
 		return b, pkgerr.WithStack(e)
@@ -228,6 +240,8 @@ func MultiBulk(reply interface{}, err error) ([]interface{}, error) { return Val
 //
 //  Reply type      Result
 //  array           reply, nil
+//  map, set        reply, nil
+//  push            []interface{}(reply), nil
 //  nil             nil, ErrNil
 //  other           nil, error
 func Values(reply interface{}, err error) ([]interface{}, error) {
@@ -237,6 +251,8 @@ func Values(reply interface{}, err error) ([]interface{}, error) {
 	switch reply := reply.(type) {
 	case []interface{}:
 		return reply, nil
+	case Push:
+		return reply, nil
 	case nil:
 		return nil, ErrNil
 	case Error:
@@ -86,6 +86,31 @@ var replyTests = []struct {
 		ve(Float64(nil, nil)),
 		ve(float64(0.0), ErrNil),
 	},
+	{
+		"float64(double)",
+		ve(Float64(float64(1.5), nil)),
+		ve(float64(1.5), nil),
+	},
+	{
+		"string(double)",
+		ve(String(float64(1.5), nil)),
+		ve("1.5", nil),
+	},
+	{
+		"bytes(double)",
+		ve(Bytes(float64(2), nil)),
+		ve([]byte("2"), nil),
+	},
+	{
+		"bool(boolean)",
+		ve(Bool(true, nil)),
+		ve(true, nil),
+	},
+	{
+		"values(push)",
+		ve(Values(Push{[]byte("invalidate"), nil}, nil)),
+		ve([]interface{}{[]byte("invalidate"), nil}, nil),
+	},
 	{
 		"uint64(1)",
 		ve(Uint64(int64(1), nil)),
@@ -46,6 +46,12 @@ func cannotConvert(d reflect.Value, s interface{}) error {
 		sname = "Redis bulk string"
 	case []interface{}:
 		sname = "Redis array"
+	case float64:
+		sname = "Redis double"
+	case bool:
+		sname = "Redis boolean"
+	case Push:
+		sname = "Redis push"
 	default:
 		sname = reflect.TypeOf(s).String()
 	}
@@ -113,12 +119,46 @@ func convertAssignInt(d reflect.Value, s int64) (err error) {
 	return
 }
 
+func convertAssignDouble(d reflect.Value, s float64) (err error) {
+	switch d.Type().Kind() {
+	case reflect.Float32, reflect.Float64:
+		d.SetFloat(s)
+	case reflect.String:
+		d.SetString(strconv.FormatFloat(s, 'g', -1, 64))
+	default:
+		err = cannotConvert(d, s)
+	}
+	err = pkgerr.WithStack(err)
+	return
+}
+
+func convertAssignBool(d reflect.Value, s bool) (err error) {
+	switch d.Type().Kind() {
+	case reflect.Bool:
+		d.SetBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s {
+			d.SetInt(1)
+		} else {
+			d.SetInt(0)
+		}
+	default:
+		err = cannotConvert(d, s)
+	}
+	err = pkgerr.WithStack(err)
+	return
+}
+
 func convertAssignValue(d reflect.Value, s interface{}) (err error) {
 	switch s := s.(type) {
 	case []byte:
 		err = convertAssignBulkString(d, s)
 	case int64:
 		err = convertAssignInt(d, s)
+	case float64:
+		err = convertAssignDouble(d, s)
+	case bool:
+		err = convertAssignBool(d, s)
 	default:
 		err = cannotConvert(d, s)
 	}
@@ -194,6 +234,39 @@ func convertAssign(d interface{}, s interface{}) (err error) {
 		default:
 			err = cannotConvert(reflect.ValueOf(d), s)
 		}
+	case float64:
+		switch d := d.(type) {
+		case *float64:
+			*d = s
+		case *interface{}:
+			*d = s
+		case nil:
+			// skip value
+		default:
+			if d := reflect.ValueOf(d); d.Type().Kind() != reflect.Ptr {
+				err = cannotConvert(d, s)
+			} else {
+				err = convertAssignDouble(d.Elem(), s)
+			}
+		}
+	case bool:
+		switch d := d.(type) {
+		case *bool:
+			*d = s
+		case *interface{}:
+			*d = s
+		case nil:
+			// skip value
+		default:
+			if d := reflect.ValueOf(d); d.Type().Kind() != reflect.Ptr {
+				err = cannotConvert(d, s)
+			} else {
+				err = convertAssignBool(d.Elem(), s)
+			}
+		}
+	case Push:
+		s1 := []interface{}(s)
+		err = convertAssign(d, s1)
 	case []interface{}:
 		switch d := d.(type) {
 		case *[]interface{}:
@@ -222,7 +295,8 @@ func convertAssign(d interface{}, s interface{}) (err error) {
 //
 // The values pointed at by dest must be an integer, float, boolean, string,
 // []byte, interface{} or slices of these types. Scan uses the standard strconv
-// package to convert bulk strings to numeric and boolean types.
+// package to convert bulk strings to numeric and boolean types, the RESP3
+// doubles and booleans are converted to the float, string and boolean types.
 //
 // If a dest value is nil, then the corresponding src value is skipped.
 //
@@ -57,6 +57,14 @@ var scanConversionTests = []struct {
 	{[]interface{}{[]byte("1"), []byte("2")}, []float64{1, 2}},
 	{[]interface{}{[]byte("1")}, []byte{1}},
 	{[]interface{}{[]byte("1")}, []bool{true}},
+	{float64(3.5), float64(3.5)},
+	{float64(3.5), float32(3.5)},
+	{float64(3.5), "3.5"},
+	{math.Inf(1), math.Inf(1)},
+	{true, true},
+	{false, int(0)},
+	{[]interface{}{float64(1), float64(2.5)}, []float64{1, 2.5}},
+	{Push{[]byte("message"), []byte("foo")}, []string{"message", "foo"}},
 }
 
 func TestScanConversion(t *testing.T) {
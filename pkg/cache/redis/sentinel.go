@@ -0,0 +1,364 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+)
+
+// ErrNoMaster is returned if the master isn't discovered by the sentinels.
+var ErrNoMaster = errors.New("redis: no master discovered by the sentinels")
+
+var (
+	// the interval of polling the sentinels, in case of the missed events.
+	_sentinelPoll = 10 * time.Second
+	// the backoff of resubscribing the sentinels.
+	_sentinelMinBackoff = 100 * time.Millisecond
+	_sentinelMaxBackoff = 5 * time.Second
+)
+
+// the channels of the sentinel events which change the master or replicas.
+var _sentinelChannels = []interface{}{"+switch-master", "+sdown", "-sdown", "+slave", "+slave-reconf-done"}
+
+var _readOnlyCommands = make(map[string]struct{})
+
+func init() {
+	for _, cmd := range []string{
+		"GET", "MGET", "GETRANGE", "STRLEN", "EXISTS", "TYPE", "TTL", "PTTL", "GETBIT", "BITCOUNT", "BITPOS",
+		"HGET", "HMGET", "HGETALL", "HKEYS", "HVALS", "HLEN", "HEXISTS", "HSTRLEN", "HSCAN",
+		"LRANGE", "LLEN", "LINDEX",
+		"SMEMBERS", "SISMEMBER", "SMISMEMBER", "SCARD", "SRANDMEMBER", "SSCAN", "SINTER", "SUNION", "SDIFF",
+		"ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE", "ZREVRANGEBYSCORE", "ZRANGEBYLEX", "ZREVRANGEBYLEX",
+		"ZSCORE", "ZMSCORE", "ZCARD", "ZCOUNT", "ZLEXCOUNT", "ZRANK", "ZREVRANK", "ZSCAN",
+		"PFCOUNT", "GEOPOS", "GEODIST", "GEOHASH", "GEORADIUS_RO", "GEORADIUSBYMEMBER_RO",
+		"XRANGE", "XREVRANGE", "XLEN", "SCAN", "KEYS", "DBSIZE", "RANDOMKEY",
+	} {
+		_readOnlyCommands[cmd] = struct{}{}
+	}
+}
+
+func isReadOnly(commandName string) bool {
+	_, ok := _readOnlyCommands[strings.ToUpper(commandName)]
+	return ok
+}
+
+// SentinelConfig is the config of the redis managed by the sentinels.
+type SentinelConfig struct {
+	MasterName string   // the name of the master monitored by the sentinels
+	Addrs      []string // the addresses of the sentinels
+	Auth       string   // the password of the sentinels
+	// ReadFromReplica sends the read only commands of Redis.Do to a random
+	// healthy replica, the master is used if there is no replica.
+	ReadFromReplica bool
+}
+
+type sentinelClient struct {
+	c *Config
+
+	mu       sync.RWMutex
+	addrs    []string // the sentinels, the last available one first
+	master   string
+	replicas []string
+	sub      Conn
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newSentinelPool(c *Config, ops []DialOption) *Pool {
+	if c.Sentinel.MasterName == "" || len(c.Sentinel.Addrs) == 0 {
+		panic("must config redis sentinel master name and addrs")
+	}
+	s := &sentinelClient{
+		c:      c,
+		addrs:  append([]string(nil), c.Sentinel.Addrs...),
+		closed: make(chan struct{}),
+	}
+	if err := s.discover(); err != nil {
+		log.Error("redis: sentinels(%v) discover master(%s) error(%v)", c.Sentinel.Addrs, c.Sentinel.MasterName, err)
+	}
+	p := newPool(c, ops, s.masterAddr)
+	p.stale = func(addr string) bool { return addr != s.getMaster() }
+	p.sentinel = s
+	if c.Sentinel.ReadFromReplica {
+		p.replica = newPool(c, ops, s.replicaAddr)
+		p.replica.stale = s.staleReplica
+	}
+	go s.watch()
+	go s.poll()
+	return p
+}
+
+// doReplica executes the read only command on a replica, the master is used
+// if the replica fails.
+func (p *Pool) doReplica(ctx context.Context, commandName string, args []interface{}) (reply interface{}, err error) {
+	conn := p.replica.Get(ctx)
+	reply, err = conn.Do(commandName, args...)
+	conn.Close()
+	if e, ok := err.(Error); err == nil || ok && !strings.HasPrefix(string(e), "LOADING") && !strings.HasPrefix(string(e), "MASTERDOWN") {
+		return
+	}
+	conn = p.Get(ctx)
+	defer conn.Close()
+	return conn.Do(commandName, args...)
+}
+
+func (s *sentinelClient) dial(addr string, readTimeout bool) (Conn, error) {
+	ops := []DialOption{
+		DialConnectTimeout(time.Duration(s.c.DialTimeout)),
+		DialWriteTimeout(time.Duration(s.c.WriteTimeout)),
+		DialPassword(s.c.Sentinel.Auth),
+	}
+	if readTimeout {
+		ops = append(ops, DialReadTimeout(time.Duration(s.c.ReadTimeout)))
+	}
+	return Dial("tcp", addr, ops...)
+}
+
+func (s *sentinelClient) getMaster() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.master
+}
+
+func (s *sentinelClient) masterAddr() (string, error) {
+	if master := s.getMaster(); master != "" {
+		return master, nil
+	}
+	return "", ErrNoMaster
+}
+
+func (s *sentinelClient) replicaAddr() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.replicas) > 0 {
+		return s.replicas[rand.Intn(len(s.replicas))], nil
+	}
+	if s.master != "" {
+		return s.master, nil
+	}
+	return "", ErrNoMaster
+}
+
+func (s *sentinelClient) staleReplica(addr string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.replicas) == 0 {
+		return addr != s.master
+	}
+	for _, replica := range s.replicas {
+		if replica == addr {
+			return false
+		}
+	}
+	return true
+}
+
+// discover queries the master and replicas from the first available sentinel.
+func (s *sentinelClient) discover() (err error) {
+	s.mu.RLock()
+	addrs := append([]string(nil), s.addrs...)
+	s.mu.RUnlock()
+	for i, addr := range addrs {
+		var (
+			master   string
+			replicas []string
+		)
+		if master, replicas, err = s.query(addr); err != nil {
+			log.Warn("redis: sentinel(%s) query master(%s) error(%v)", addr, s.c.Sentinel.MasterName, err)
+			continue
+		}
+		s.mu.Lock()
+		if i > 0 {
+			s.addrs = append([]string{addr}, append(addrs[:i:i], addrs[i+1:]...)...)
+		}
+		if s.master != master {
+			log.Info("redis: master(%s) switched from %s to %s", s.c.Sentinel.MasterName, s.master, master)
+		}
+		s.master, s.replicas = master, replicas
+		s.mu.Unlock()
+		return nil
+	}
+	return
+}
+
+func (s *sentinelClient) query(addr string) (master string, replicas []string, err error) {
+	conn, err := s.dial(addr, true)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	name := s.c.Sentinel.MasterName
+	res, err := Strings(conn.Do("SENTINEL", "get-master-addr-by-name", name))
+	if err == ErrNil {
+		err = ErrNoMaster
+	}
+	if err != nil {
+		return
+	}
+	if len(res) != 2 {
+		err = protocolError("bad master address")
+		return
+	}
+	master = net.JoinHostPort(res[0], res[1])
+	if !s.c.Sentinel.ReadFromReplica {
+		return
+	}
+	nodes, err := Values(conn.Do("SENTINEL", "replicas", name))
+	if err != nil {
+		// NOTE: the replicas subcommand is added by redis 5.0.
+		if nodes, err = Values(conn.Do("SENTINEL", "slaves", name)); err != nil {
+			return
+		}
+	}
+	for _, node := range nodes {
+		info, err := StringMap(node, nil)
+		if err != nil {
+			continue
+		}
+		if healthy(info) {
+			replicas = append(replicas, net.JoinHostPort(info["ip"], info["port"]))
+		}
+	}
+	return
+}
+
+func healthy(info map[string]string) bool {
+	for _, flag := range strings.Split(info["flags"], ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return false
+		}
+	}
+	return info["master-link-status"] == "ok"
+}
+
+// watch subscribes the events of the first available sentinel.
+func (s *sentinelClient) watch() {
+	backoff := _sentinelMinBackoff
+	for {
+		subscribed, err := s.subscribe()
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+		log.Error("redis: sentinel subscribe master(%s) error(%v)", s.c.Sentinel.MasterName, err)
+		if subscribed {
+			backoff = _sentinelMinBackoff
+		}
+		s.discover()
+		select {
+		case <-time.After(backoff):
+		case <-s.closed:
+			return
+		}
+		if backoff *= 2; backoff > _sentinelMaxBackoff {
+			backoff = _sentinelMaxBackoff
+		}
+	}
+}
+
+func (s *sentinelClient) subscribe() (subscribed bool, err error) {
+	s.mu.RLock()
+	addr := s.addrs[0]
+	s.mu.RUnlock()
+	// NOTE: no read timeout for waiting the events.
+	c, err := s.dial(addr, false)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		c.Close()
+		return
+	default:
+	}
+	s.sub = c
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.sub = nil
+		s.mu.Unlock()
+		c.Close()
+	}()
+	psc := PubSubConn{Conn: c}
+	if err = psc.Subscribe(_sentinelChannels...); err != nil {
+		return
+	}
+	for {
+		switch m := psc.Receive().(type) {
+		case Subscription:
+			if m.Count == len(_sentinelChannels) {
+				subscribed = true
+				// catch up the events missed before subscribing.
+				s.discover()
+			}
+		case Message:
+			s.handle(m)
+		case error:
+			return subscribed, m
+		}
+	}
+}
+
+func (s *sentinelClient) handle(m Message) {
+	name := s.c.Sentinel.MasterName
+	fields := strings.Fields(string(m.Data))
+	switch m.Channel {
+	case "+switch-master":
+		// <master name> <old ip> <old port> <new ip> <new port>
+		if len(fields) != 5 || fields[0] != name {
+			return
+		}
+		master := net.JoinHostPort(fields[3], fields[4])
+		s.mu.Lock()
+		if s.master != master {
+			log.Info("redis: master(%s) switched from %s to %s", name, s.master, master)
+			s.master = master
+		}
+		s.mu.Unlock()
+	default:
+		// <instance type> <name> <ip> <port> @ <master name> <master ip> <master port>
+		if len(fields) < 6 || fields[0] != "slave" || fields[5] != name {
+			return
+		}
+	}
+	if s.c.Sentinel.ReadFromReplica {
+		s.discover()
+	}
+}
+
+func (s *sentinelClient) poll() {
+	ticker := time.NewTicker(_sentinelPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.discover(); err != nil {
+				log.Error("redis: sentinels discover master(%s) error(%v)", s.c.Sentinel.MasterName, err)
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *sentinelClient) Close() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		close(s.closed)
+		if s.sub != nil {
+			s.sub.Close()
+		}
+		s.mu.Unlock()
+	})
+}
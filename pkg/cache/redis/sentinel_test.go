@@ -0,0 +1,225 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis/internal/mockserver"
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSentinel struct {
+	*mockserver.Server
+
+	mu       sync.Mutex
+	master   string
+	replicas []map[string]string
+}
+
+func runTestSentinel(master string) *testSentinel {
+	s := &testSentinel{Server: mockserver.Run(), master: master}
+	s.Hook(func(req *mockserver.Request) (interface{}, bool) {
+		if req.Command != "SENTINEL" {
+			return nil, false
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if req.Args[1] != "mymaster" {
+			return nil, true
+		}
+		switch req.Args[0] {
+		case "get-master-addr-by-name":
+			host, port, _ := net.SplitHostPort(s.master)
+			return []string{host, port}, true
+		case "replicas":
+			return mockserver.Error("ERR Unknown sentinel subcommand 'replicas'"), true
+		case "slaves":
+			var res []interface{}
+			for _, info := range s.replicas {
+				var kvs []string
+				for k, v := range info {
+					kvs = append(kvs, k, v)
+				}
+				res = append(res, kvs)
+			}
+			return res, true
+		}
+		return nil, false
+	})
+	return s
+}
+
+func (s *testSentinel) switchMaster(master string) {
+	s.mu.Lock()
+	old := s.master
+	s.master = master
+	s.mu.Unlock()
+	oh, op, _ := net.SplitHostPort(old)
+	nh, np, _ := net.SplitHostPort(master)
+	s.Publish("+switch-master", "mymaster "+oh+" "+op+" "+nh+" "+np)
+}
+
+func (s *testSentinel) setReplicas(replicas ...map[string]string) {
+	s.mu.Lock()
+	s.replicas = replicas
+	s.mu.Unlock()
+}
+
+func replicaInfo(addr, flags, link string) map[string]string {
+	host, port, _ := net.SplitHostPort(addr)
+	return map[string]string{"ip": host, "port": port, "flags": flags, "master-link-status": link}
+}
+
+func newTestSentinelConfig(sc *SentinelConfig) *Config {
+	return &Config{
+		Config: &pool.Config{
+			Active:      10,
+			Idle:        2,
+			IdleTimeout: xtime.Duration(90 * time.Second),
+		},
+		Name:         "test",
+		Proto:        "tcp",
+		DialTimeout:  xtime.Duration(time.Second),
+		ReadTimeout:  xtime.Duration(time.Second),
+		WriteTimeout: xtime.Duration(time.Second),
+		Sentinel:     sc,
+	}
+}
+
+func waitUntil(t *testing.T, fn func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("wait timeout")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSentinelFailover(t *testing.T) {
+	m1 := mockserver.Run()
+	defer m1.Close()
+	m2 := mockserver.Run()
+	defer m2.Close()
+	m2.SetAuth("secret")
+	s := runTestSentinel(m1.Addr())
+	defer s.Close()
+	dead, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead.Close()
+
+	c := newTestSentinelConfig(&SentinelConfig{MasterName: "mymaster", Addrs: []string{dead.Addr().String(), s.Addr()}})
+	c.Auth = "secret"
+	m1.SetAuth("secret")
+	r := NewRedis(c)
+	defer r.Close()
+	ctx := context.Background()
+
+	_, err := r.Do(ctx, "SET", "foo", "bar")
+	assert.Nil(t, err)
+	v, _ := m1.Get("foo")
+	assert.Equal(t, "bar", v)
+	// the available sentinel is subscribed.
+	waitUntil(t, func() bool { return s.Commands("SUBSCRIBE") == 1 })
+
+	s.switchMaster(m2.Addr())
+	waitUntil(t, func() bool { return r.pool.sentinel.getMaster() == m2.Addr() })
+	_, err = r.Do(ctx, "SET", "foo", "baz")
+	assert.Nil(t, err)
+	v, _ = m2.Get("foo")
+	assert.Equal(t, "baz", v)
+	v, _ = m1.Get("foo")
+	assert.Equal(t, "bar", v)
+
+	// the pipeline and conn use the new master too.
+	p := r.Pipeline()
+	p.Send("GET", "foo")
+	rs, err := p.Exec(ctx)
+	assert.Nil(t, err)
+	reply, err := String(rs.Scan())
+	assert.Nil(t, err)
+	assert.Equal(t, "baz", reply)
+}
+
+func TestSentinelResubscribe(t *testing.T) {
+	backoff := _sentinelMinBackoff
+	_sentinelMinBackoff = 10 * time.Millisecond
+	defer func() { _sentinelMinBackoff = backoff }()
+	m1 := mockserver.Run()
+	defer m1.Close()
+	m2 := mockserver.Run()
+	defer m2.Close()
+	s := runTestSentinel(m1.Addr())
+	defer s.Close()
+
+	r := NewRedis(newTestSentinelConfig(&SentinelConfig{MasterName: "mymaster", Addrs: []string{s.Addr()}}))
+	defer r.Close()
+	waitUntil(t, func() bool { return s.Commands("SUBSCRIBE") == 1 })
+
+	// the missed switch is discovered after resubscribing.
+	s.CloseClients()
+	s.mu.Lock()
+	s.master = m2.Addr()
+	s.mu.Unlock()
+	waitUntil(t, func() bool { return s.Commands("SUBSCRIBE") == 2 })
+	waitUntil(t, func() bool { return r.pool.sentinel.getMaster() == m2.Addr() })
+	_, err := r.Do(context.Background(), "SET", "foo", "bar")
+	assert.Nil(t, err)
+	v, _ := m2.Get("foo")
+	assert.Equal(t, "bar", v)
+}
+
+func TestSentinelReadFromReplica(t *testing.T) {
+	master := mockserver.Run()
+	defer master.Close()
+	r1 := mockserver.Run()
+	defer r1.Close()
+	r2 := mockserver.Run()
+	defer r2.Close()
+	down := mockserver.Run()
+	defer down.Close()
+	s := runTestSentinel(master.Addr())
+	defer s.Close()
+	s.setReplicas(
+		replicaInfo(r1.Addr(), "slave", "ok"),
+		replicaInfo(down.Addr(), "s_down,slave", "ok"),
+		replicaInfo(r2.Addr(), "slave", "err"),
+	)
+
+	r := NewRedis(newTestSentinelConfig(&SentinelConfig{MasterName: "mymaster", Addrs: []string{s.Addr()}, ReadFromReplica: true}))
+	defer r.Close()
+	ctx := context.Background()
+	r1.Set("foo", "replica")
+	master.Set("foo", "master")
+
+	for i := 0; i < 5; i++ {
+		v, err := String(r.Do(ctx, "GET", "foo"))
+		assert.Nil(t, err)
+		assert.Equal(t, "replica", v)
+	}
+	_, err := r.Do(ctx, "SET", "foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, master.Commands("SET"))
+	assert.Equal(t, 0, down.Commands("GET"))
+	assert.Equal(t, 0, r2.Commands("GET"))
+
+	// the replicas are refreshed by the events.
+	s.setReplicas(replicaInfo(r2.Addr(), "slave", "ok"))
+	s.Publish("+slave", "slave "+r2.Addr()+" 127.0.0.1 0 @ mymaster 127.0.0.1 0")
+	r2.Set("foo", "replica2")
+	waitUntil(t, func() bool {
+		v, _ := String(r.Do(ctx, "GET", "foo"))
+		return v == "replica2"
+	})
+
+	// the master is used if the replica fails.
+	r2.Close()
+	v, err := String(r.Do(ctx, "GET", "foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", v)
+}
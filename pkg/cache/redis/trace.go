@@ -26,6 +26,8 @@ type traceConn struct {
 	tr trace.Trace
 	// connTag include e.g. ip,port
 	connTags []trace.Tag
+	// addr is the dialed address.
+	addr string
 
 	ctx context.Context
 
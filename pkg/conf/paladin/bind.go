@@ -0,0 +1,111 @@
+package paladin
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mapgoo-lab/atreus/pkg/validation"
+
+	"github.com/pkg/errors"
+)
+
+// Validator is implemented by the config which validates itself besides
+// the validate tags.
+type Validator interface {
+	Validate() error
+}
+
+// Binding is the typed config of a key, the config is unmarshaled by the
+// format of the key extension, validated by the validate tags and
+// swapped atomically on change. The invalid updates are rejected and the last
+// good config is kept.
+type Binding[T any] struct {
+	key    string
+	format string
+	value  atomic.Value // *T
+
+	mu   sync.Mutex
+	subs []func(old, new *T)
+}
+
+// Bind binds the config of key in the default client to T.
+func Bind[T any](key string) (*Binding[T], error) {
+	return BindClient[T](DefaultClient, key)
+}
+
+// BindClient binds the config of key in c to T, the config must exist and be
+// valid, then it's reloaded on every change of key.
+//
+//	type Config struct {
+//		Addr string `toml:"addr" validate:"required"`
+//	}
+//	b, err := paladin.Bind[Config]("redis.toml")
+//	b.Subscribe(func(old, new *Config) { ... })
+//	addr := b.Load().Addr
+func BindClient[T any](c Client, key string) (*Binding[T], error) {
+	b := &Binding[T]{key: key, format: FormatOf(key)}
+	raw, err := c.Get(key).Raw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "paladin: bind key(%s)", key)
+	}
+	if err = b.Set(raw); err != nil {
+		return nil, err
+	}
+	go func() {
+		for event := range c.WatchEvent(context.Background(), key) {
+			if KeyNamed(event.Key) != KeyNamed(key) {
+				continue
+			}
+			if event.Event == EventRemove {
+				log.Printf("paladin: bind key %s removed, keep the last config", key)
+				continue
+			}
+			if err := b.Set(event.Value); err != nil {
+				log.Printf("paladin: bind key %s reload rejected, keep the last config: %v", key, err)
+			}
+		}
+	}()
+	return b, nil
+}
+
+// Load returns the current config, it must not be modified.
+func (b *Binding[T]) Load() *T {
+	return b.value.Load().(*T)
+}
+
+// Subscribe adds fn which is called with the old and new config after every
+// change in order, fn must not call Set.
+func (b *Binding[T]) Subscribe(fn func(old, new *T)) {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+}
+
+// Set unmarshals and validates text, then swaps the config, the config is
+// kept if text is invalid.
+func (b *Binding[T]) Set(text string) error {
+	cfg := new(T)
+	if err := NewValue(text, text).UnmarshalFormat(b.format, cfg); err != nil {
+		return errors.Wrapf(err, "paladin: bind key(%s) unmarshal", b.key)
+	}
+	if err := validation.Struct(cfg); err != nil {
+		return errors.Wrapf(err, "paladin: bind key(%s) validate", b.key)
+	}
+	if v, ok := interface{}(cfg).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return errors.Wrapf(err, "paladin: bind key(%s) validate", b.key)
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old, _ := b.value.Load().(*T)
+	b.value.Store(cfg)
+	if old != nil {
+		for _, fn := range b.subs {
+			fn(old, cfg)
+		}
+	}
+	return nil
+}
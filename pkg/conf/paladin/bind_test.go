@@ -0,0 +1,66 @@
+package paladin_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bindConfig struct {
+	Addr   string `toml:"addr" yaml:"addr" validate:"required"`
+	Active int    `toml:"active" yaml:"active" validate:"min=1"`
+	Idle   int    `toml:"idle" yaml:"idle"`
+}
+
+func (c *bindConfig) Validate() error {
+	if c.Idle > c.Active {
+		return errors.New("idle greater than active")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	yamls := paladin.NewMock(map[string]string{
+		"redis.yaml":  "addr: 127.0.0.1:6380\nactive: 1",
+		"invalid.yml": "active: 1",
+	})
+	_, err := paladin.BindClient[bindConfig](yamls, "invalid.yml")
+	assert.NotNil(t, err)
+	_, err = paladin.BindClient[bindConfig](yamls, "absent.toml")
+	assert.NotNil(t, err)
+	y, err := paladin.BindClient[bindConfig](yamls, "redis.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:6380", y.Load().Addr)
+
+	mock := paladin.NewMock(map[string]string{
+		"redis.toml": "addr = \"127.0.0.1:6379\"\nactive = 10\nidle = 5",
+	}).(*paladin.Mock)
+	b, err := paladin.BindClient[bindConfig](mock, "redis.toml")
+	assert.Nil(t, err)
+	assert.Equal(t, &bindConfig{Addr: "127.0.0.1:6379", Active: 10, Idle: 5}, b.Load())
+	changes := make(chan [2]*bindConfig, 1)
+	b.Subscribe(func(old, new *bindConfig) {
+		changes <- [2]*bindConfig{old, new}
+	})
+
+	// the invalid updates are rejected.
+	assert.NotNil(t, b.Set("addr = \"\"\nactive = 10"))
+	assert.NotNil(t, b.Set("addr = \"a\"\nactive = 1\nidle = 2"))
+	assert.NotNil(t, b.Set("addr = "))
+	assert.Equal(t, "127.0.0.1:6379", b.Load().Addr)
+
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "redis.toml", Value: "addr = \"127.0.0.1:6381\"\nactive = 20"}
+	select {
+	case change := <-changes:
+		assert.Equal(t, "127.0.0.1:6379", change[0].Addr)
+		assert.Equal(t, "127.0.0.1:6381", change[1].Addr)
+		assert.Equal(t, 20, change[1].Active)
+	case <-time.After(time.Second):
+		t.Fatal("wait change timeout")
+	}
+	assert.Equal(t, "127.0.0.1:6381", b.Load().Addr)
+}
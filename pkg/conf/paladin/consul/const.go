@@ -0,0 +1,6 @@
+package consul
+
+const (
+	// PaladinDriverConsul ...
+	PaladinDriverConsul = "consul"
+)
@@ -0,0 +1,226 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin/internal/remote"
+)
+
+var _ paladin.Client = &consul{}
+
+const _loadTimeout = 5 * time.Second
+
+// consul is consul KV config client.
+// the keys under the prefix are the keys of paladin, e.g. the value of
+// config/demo/app.toml is the key app.toml with the prefix config/demo.
+type consul struct {
+	*remote.Store
+	client *http.Client
+	conf   *Config
+	prefix string
+	// index is the X-Consul-Index of the loaded values for the blocking query.
+	index  uint64
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config is consul config client config.
+type Config struct {
+	// Address is the http address of consul, e.g. http://127.0.0.1:8500.
+	Address    string        `json:"address"`
+	Prefix     string        `json:"prefix"`
+	Token      string        `json:"token"`
+	Datacenter string        `json:"datacenter"`
+	CacheDir   string        `json:"cache_dir"`
+	Wait       time.Duration `json:"wait"`
+}
+
+type kvPair struct {
+	Key         string
+	Value       []byte
+	ModifyIndex uint64
+}
+
+type consulDriver struct{}
+
+var (
+	confAddress, confPrefix, confCacheDir string
+)
+
+func init() {
+	addConsulFlags()
+	paladin.Register(PaladinDriverConsul, &consulDriver{})
+}
+
+func addConsulFlags() {
+	flag.StringVar(&confAddress, "paladin.consul.addr", "", "consul http address of config, e.g. http://127.0.0.1:8500")
+	flag.StringVar(&confPrefix, "paladin.consul.prefix", "", "consul key prefix of config, e.g. config/demo.service")
+	flag.StringVar(&confCacheDir, "paladin.consul.cachedir", "/tmp", "consul config cache dir")
+}
+
+func buildConfigForConsul() (c *Config, err error) {
+	if addrFromEnv := os.Getenv("PALADIN_CONSUL_ADDR"); addrFromEnv != "" {
+		confAddress = addrFromEnv
+	}
+	if confAddress == "" {
+		confAddress = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if confAddress == "" {
+		err = errors.New("invalid consul addr, pass it via PALADIN_CONSUL_ADDR=xxx with env or --paladin.consul.addr=xxx with flag")
+		return
+	}
+	if prefixFromEnv := os.Getenv("PALADIN_CONSUL_PREFIX"); prefixFromEnv != "" {
+		confPrefix = prefixFromEnv
+	}
+	if confPrefix == "" {
+		err = errors.New("invalid consul prefix, pass it via PALADIN_CONSUL_PREFIX=xxx with env or --paladin.consul.prefix=xxx with flag")
+		return
+	}
+	if cacheDirFromEnv := os.Getenv("PALADIN_CONSUL_CACHE_DIR"); cacheDirFromEnv != "" {
+		confCacheDir = cacheDirFromEnv
+	}
+	token := os.Getenv("PALADIN_CONSUL_TOKEN")
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	c = &Config{
+		Address:    confAddress,
+		Prefix:     confPrefix,
+		Token:      token,
+		Datacenter: os.Getenv("PALADIN_CONSUL_DATACENTER"),
+		CacheDir:   confCacheDir,
+	}
+	return
+}
+
+// New new a consul config client.
+// it watches the keys under the prefix by blocking queries and updates local cache.
+func (cd *consulDriver) New() (paladin.Client, error) {
+	c, err := buildConfigForConsul()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c)
+}
+
+// NewClient new a consul config client with the config.
+func NewClient(conf *Config) (paladin.Client, error) {
+	if conf == nil || conf.Address == "" || conf.Prefix == "" {
+		return nil, errors.New("invalid consul conf")
+	}
+	cc := *conf
+	if !strings.Contains(cc.Address, "://") {
+		cc.Address = "http://" + cc.Address
+	}
+	cc.Address = strings.TrimSuffix(cc.Address, "/")
+	if cc.Wait <= 0 {
+		cc.Wait = 55 * time.Second
+	}
+	prefix := strings.Trim(cc.Prefix, "/") + "/"
+	c := &consul{
+		Store:  remote.NewStore(remote.CacheFile(cc.CacheDir, PaladinDriverConsul, cc.Address, cc.Datacenter, prefix)),
+		client: &http.Client{},
+		conf:   &cc,
+		prefix: prefix,
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	name := "consul prefix " + prefix
+	if err := c.Load(name, func() error { return c.load(0) }); err != nil {
+		c.cancel()
+		return nil, err
+	}
+	go remote.Watch(c.ctx, name, c.watch)
+	return c, nil
+}
+
+// load loads the values under the prefix, it blocks until the values change
+// or the wait time passes if index is greater than zero.
+func (c *consul) load(index uint64) (err error) {
+	params := url.Values{}
+	params.Set("recurse", "true")
+	if c.conf.Datacenter != "" {
+		params.Set("dc", c.conf.Datacenter)
+	}
+	timeout := _loadTimeout
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", fmt.Sprintf("%dms", c.conf.Wait/time.Millisecond))
+		// consul adds a jitter of wait/16 to the blocking query.
+		timeout += c.conf.Wait + c.conf.Wait/16
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, c.conf.Address+"/v1/kv/"+c.prefix+"?"+params.Encode(), nil)
+	if err != nil {
+		return
+	}
+	if c.conf.Token != "" {
+		req.Header.Set("X-Consul-Token", c.conf.Token)
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var pairs []*kvPair
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err = json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+			return
+		}
+	case http.StatusNotFound:
+		// no key under the prefix.
+	default:
+		return fmt.Errorf("consul status %d", resp.StatusCode)
+	}
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid X-Consul-Index %q", resp.Header.Get("X-Consul-Index"))
+	}
+	if newIndex == c.index && c.index != 0 {
+		return
+	}
+	raws := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key := strings.TrimPrefix(pair.Key, c.prefix)
+		// skip the folders.
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		raws[key] = string(pair.Value)
+	}
+	c.Replace(raws)
+	c.index = newIndex
+	return
+}
+
+// watch waits for the changes of the prefix by the blocking query.
+func (c *consul) watch() error {
+	index := c.index
+	if err := c.load(index); err != nil {
+		return err
+	}
+	// NOTE: the index may go backwards after the consul servers are
+	// restored, reset it so that the next query doesn't block forever.
+	if c.index < index {
+		c.index = 0
+	}
+	return nil
+}
+
+// Close close watcher.
+func (c *consul) Close() error {
+	c.cancel()
+	return c.Store.Close()
+}
@@ -0,0 +1,95 @@
+package consul
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin/consul/internal/mockserver"
+)
+
+func waitEvent(t *testing.T, ch <-chan paladin.Event) paladin.Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait event timeout")
+	}
+	return paladin.Event{}
+}
+
+func assertValue(t *testing.T, c paladin.Client, key, expected string) {
+	if content, _ := c.Get(key).String(); content != expected {
+		t.Fatalf("got %s unexpected value %s, expected %s", key, content, expected)
+	}
+}
+
+func TestConsul(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paladin-consul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Token = "secret"
+	conf := &Config{Address: srv.URL(), Prefix: "/config/demo/", Token: "secret", CacheDir: dir, Wait: time.Second}
+
+	srv.Down(true)
+	if _, err = NewClient(conf); err == nil {
+		t.Fatal("new consul without consul and cache should fail")
+	}
+	srv.Down(false)
+	srv.Set("config/demo/app.toml", "test: 1")
+	srv.Set("config/demo/client.json", `{"name":"consul"}`)
+	srv.Set("config/demo/sub/", "")
+	srv.Set("config/other/app.toml", "other")
+	c, err := NewClient(conf)
+	if err != nil {
+		t.Fatalf("new consul error, %v", err)
+	}
+	defer c.Close()
+	assertValue(t, c, "app.toml", "test: 1")
+	assertValue(t, c, "client.json", `{"name":"consul"}`)
+	if keys := c.GetAll().Keys(); len(keys) != 2 {
+		t.Fatalf("got unexpected keys %v", keys)
+	}
+
+	updates := c.WatchEvent(context.TODO(), "app.toml", "db.toml")
+	srv.Set("config/demo/app.toml", "test: 2")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventUpdate || ev.Key != "app.toml" || ev.Value != "test: 2" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	srv.Set("config/demo/db.toml", "dsn: a")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventAdd || ev.Key != "db.toml" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	srv.Delete("config/demo/db.toml")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventRemove || ev.Key != "db.toml" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	// the changes of other prefixes are ignored.
+	srv.Set("config/other/app.toml", "other 2")
+	srv.Set("config/demo/app.toml", "test: 3")
+	if ev := waitEvent(t, updates); ev.Value != "test: 3" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+
+	// cold start from the cache.
+	srv.Down(true)
+	cached, err := NewClient(conf)
+	if err != nil {
+		t.Fatalf("new consul from cache error, %v", err)
+	}
+	defer cached.Close()
+	assertValue(t, cached, "app.toml", "test: 3")
+	srv.Down(false)
+	srv.Set("config/demo/app.toml", "test: 4")
+	updates = cached.WatchEvent(context.TODO(), "app.toml")
+	if ev := waitEvent(t, updates); ev.Value != "test: 4" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+}
@@ -0,0 +1,131 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type kvPair struct {
+	Key         string
+	Value       []byte
+	ModifyIndex uint64
+}
+
+// Server is a consul KV http server which supports the recurse and blocking queries.
+type Server struct {
+	server *httptest.Server
+
+	lock    sync.Mutex
+	index   uint64
+	kvs     map[string]*kvPair
+	changed chan struct{}
+	down    bool
+	// Token is the required X-Consul-Token if not empty.
+	Token string
+}
+
+// Run runs a mock server on a random local port.
+func Run() *Server {
+	s := &Server{
+		index:   1,
+		kvs:     make(map[string]*kvPair),
+		changed: make(chan struct{}),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.KVHandler))
+	return s
+}
+
+// URL returns the address of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Down makes the server respond 500, or recovers it.
+func (s *Server) Down(down bool) {
+	s.lock.Lock()
+	s.down = down
+	s.lock.Unlock()
+}
+
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Set sets the value of key.
+func (s *Server) Set(key, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.index++
+	s.kvs[key] = &kvPair{Key: key, Value: []byte(value), ModifyIndex: s.index}
+	s.notify()
+}
+
+// Delete deletes key.
+func (s *Server) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.index++
+	delete(s.kvs, key)
+	s.notify()
+}
+
+// KVHandler handles GET /v1/kv/<prefix>?recurse=true&index=&wait=.
+func (s *Server) KVHandler(rw http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, "/v1/kv/") || req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if s.Token != "" && req.Header.Get("X-Consul-Token") != s.Token {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	prefix := strings.TrimPrefix(req.URL.Path, "/v1/kv/")
+	index, _ := strconv.ParseUint(req.FormValue("index"), 10, 64)
+	wait, err := time.ParseDuration(req.FormValue("wait"))
+	if err != nil || wait <= 0 {
+		wait = 5 * time.Minute
+	}
+	s.lock.Lock()
+	if index > 0 && index >= s.index && !s.down {
+		changed := s.changed
+		s.lock.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return
+		}
+		s.lock.Lock()
+	}
+	defer s.lock.Unlock()
+	if s.down {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var pairs []*kvPair
+	for key, pair := range s.kvs {
+		if strings.HasPrefix(key, prefix) {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	rw.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	if len(pairs) == 0 {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(pairs)
+}
@@ -4,16 +4,29 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"log"
+	"os"
+	"strconv"
 )
 
 var (
 	// DefaultClient default client.
 	DefaultClient Client
-	confPath      string
+	// DefaultHistory is the config history of the default client, it's nil
+	// if the history is disabled.
+	DefaultHistory *History
+	confPath       string
+	confOverlay    bool
+	confHistory    int
+	confHistoryDir string
 )
 
 func init() {
 	flag.StringVar(&confPath, "conf", "", "default config path")
+	history, _ := strconv.Atoi(os.Getenv("CONF_HISTORY"))
+	flag.IntVar(&confHistory, "conf.history", history, "keep the last N versions of every config key for diff and rollback, 0 is disabled, or use CONF_HISTORY env variable.")
+	flag.StringVar(&confHistoryDir, "conf.history.dir", os.Getenv("CONF_HISTORY_DIR"), "the dir to save the config history, or use CONF_HISTORY_DIR env variable.")
+	flag.BoolVar(&confOverlay, "conf.overlay", os.Getenv("CONF_OVERLAY") == "true", "resolve ${ENV_VAR:default} placeholders and APP__SECTION__KEY env overrides in config values, or use CONF_OVERLAY=true env variable.")
 }
 
 // Init init config client.
@@ -45,22 +58,40 @@ func Init(args ...interface{}) (err error) {
 	if err != nil {
 		return
 	}
+	if confHistory > 0 {
+		if DefaultHistory, err = NewHistory(DefaultClient, confHistory, confHistoryDir); err != nil {
+			return
+		}
+		DefaultClient = DefaultHistory
+	}
+	if confOverlay {
+		DefaultClient = NewOverlay(DefaultClient)
+	}
 	return
 }
 
 // Watch watch on a key. The configuration implements the setter interface, which is invoked when the configuration changes.
 func Watch(key string, s Setter) error {
-	v := DefaultClient.Get(key)
-	str, err := v.Raw()
+	raw, err := DefaultClient.Get(key).Raw()
+	if err != nil {
+		return err
+	}
+	str, err := decryptText(FormatOf(key), raw)
 	if err != nil {
 		return err
 	}
 	if err := s.Set(str); err != nil {
 		return err
 	}
+	events := WatchEvent(context.Background(), key)
 	go func() {
-		for event := range WatchEvent(context.Background(), key) {
-			s.Set(event.Value)
+		for event := range events {
+			text, err := decryptText(FormatOf(key), event.Value)
+			if err != nil {
+				log.Printf("paladin: watch key %s decrypt error: %v", key, err)
+				continue
+			}
+			s.Set(text)
 		}
 	}()
 	return nil
@@ -81,6 +112,12 @@ func GetAll() *Map {
 	return DefaultClient.GetAll()
 }
 
+// Unmarshal unmarshal the value of key to struct by the format of the key
+// extension, e.g. app.yaml is unmarshaled as yaml, toml by default.
+func Unmarshal(key string, dst interface{}) error {
+	return DefaultClient.Get(key).UnmarshalFormat(FormatOf(key), dst)
+}
+
 // Keys return values key.
 func Keys() []string {
 	return DefaultClient.GetAll().Keys()
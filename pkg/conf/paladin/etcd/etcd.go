@@ -0,0 +1,228 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin/internal/remote"
+)
+
+var _ paladin.Client = &etcd{}
+
+const _loadTimeout = 5 * time.Second
+
+// kv is the etcd client used by the driver, it's *clientv3.Client.
+type kv interface {
+	clientv3.KV
+	clientv3.Watcher
+}
+
+// etcd is etcd config client.
+// the keys under the prefix are the keys of paladin, e.g. the value of
+// /config/demo/app.toml is the key app.toml with the prefix /config/demo.
+type etcd struct {
+	*remote.Store
+	client kv
+	prefix string
+	// rev is the revision of the loaded values, the watch resumes from rev+1.
+	rev    int64
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config is etcd config client config.
+type Config struct {
+	Endpoints   []string      `json:"endpoints"`
+	Prefix      string        `json:"prefix"`
+	CacheDir    string        `json:"cache_dir"`
+	DialTimeout time.Duration `json:"dial_timeout"`
+	Username    string        `json:"username"`
+	Password    string        `json:"password"`
+}
+
+type etcdDriver struct{}
+
+var (
+	confEndpoints, confPrefix, confCacheDir string
+)
+
+func init() {
+	addEtcdFlags()
+	paladin.Register(PaladinDriverEtcd, &etcdDriver{})
+}
+
+func addEtcdFlags() {
+	flag.StringVar(&confEndpoints, "paladin.etcd.endpoints", "", "etcd endpoints of config, comma separated, e.g. 127.0.0.1:2379,127.0.0.2:2379")
+	flag.StringVar(&confPrefix, "paladin.etcd.prefix", "", "etcd key prefix of config, e.g. /config/demo.service")
+	flag.StringVar(&confCacheDir, "paladin.etcd.cachedir", "/tmp", "etcd config cache dir")
+}
+
+func buildConfigForEtcd() (c *Config, err error) {
+	if endpointsFromEnv := os.Getenv("PALADIN_ETCD_ENDPOINTS"); endpointsFromEnv != "" {
+		confEndpoints = endpointsFromEnv
+	}
+	if confEndpoints == "" {
+		confEndpoints = os.Getenv("ETCD_ENDPOINTS")
+	}
+	if confEndpoints == "" {
+		err = errors.New("invalid etcd endpoints, pass it via PALADIN_ETCD_ENDPOINTS=xxx with env or --paladin.etcd.endpoints=xxx with flag")
+		return
+	}
+	if prefixFromEnv := os.Getenv("PALADIN_ETCD_PREFIX"); prefixFromEnv != "" {
+		confPrefix = prefixFromEnv
+	}
+	if confPrefix == "" {
+		err = errors.New("invalid etcd prefix, pass it via PALADIN_ETCD_PREFIX=xxx with env or --paladin.etcd.prefix=xxx with flag")
+		return
+	}
+	if cacheDirFromEnv := os.Getenv("PALADIN_ETCD_CACHE_DIR"); cacheDirFromEnv != "" {
+		confCacheDir = cacheDirFromEnv
+	}
+	c = &Config{
+		Endpoints:   strings.Split(confEndpoints, ","),
+		Prefix:      confPrefix,
+		CacheDir:    confCacheDir,
+		DialTimeout: 5 * time.Second,
+		Username:    os.Getenv("PALADIN_ETCD_USERNAME"),
+		Password:    os.Getenv("PALADIN_ETCD_PASSWORD"),
+	}
+	return
+}
+
+// New new an etcd config client.
+// it watches the keys under the prefix and updates local cache.
+func (ed *etcdDriver) New() (paladin.Client, error) {
+	c, err := buildConfigForEtcd()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c)
+}
+
+// NewClient new an etcd config client with the config.
+func NewClient(conf *Config) (paladin.Client, error) {
+	if conf == nil || len(conf.Endpoints) == 0 || conf.Prefix == "" {
+		return nil, errors.New("invalid etcd conf")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   conf.Endpoints,
+		DialTimeout: conf.DialTimeout,
+		Username:    conf.Username,
+		Password:    conf.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEtcd(conf, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func newEtcd(conf *Config, client kv) (*etcd, error) {
+	prefix := strings.TrimSuffix(conf.Prefix, "/") + "/"
+	cache := remote.CacheFile(conf.CacheDir, PaladinDriverEtcd, strings.Join(conf.Endpoints, ","), prefix)
+	e := &etcd{
+		Store:  remote.NewStore(cache),
+		client: client,
+		prefix: prefix,
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	name := "etcd prefix " + prefix
+	if err := e.Load(name, e.load); err != nil {
+		e.cancel()
+		return nil, err
+	}
+	go remote.Watch(e.ctx, name, e.watch)
+	return e, nil
+}
+
+// load loads all the values under the prefix.
+func (e *etcd) load() error {
+	ctx, cancel := context.WithTimeout(e.ctx, _loadTimeout)
+	defer cancel()
+	resp, err := e.client.Get(ctx, e.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	raws := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if key := e.key(kv.Key); key != "" {
+			raws[key] = string(kv.Value)
+		}
+	}
+	e.Replace(raws)
+	e.rev = resp.Header.Revision
+	return nil
+}
+
+func (e *etcd) key(k []byte) string {
+	return strings.TrimPrefix(string(k), e.prefix)
+}
+
+// watch watches the prefix from the last revision so that no change is lost
+// after reconnecting, the values are reloaded if the revision has been
+// compacted. It returns nil to rewatch at once if the watch has made progress.
+func (e *etcd) watch() error {
+	if e.rev == 0 {
+		if err := e.load(); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(e.ctx))
+	defer cancel()
+	var progress bool
+	for wr := range e.client.Watch(ctx, e.prefix, clientv3.WithPrefix(), clientv3.WithRev(e.rev+1)) {
+		if wr.CompactRevision != 0 {
+			log.Printf("paladin: etcd prefix %s revision %d compacted, reload", e.prefix, e.rev)
+			e.rev = 0
+			return nil
+		}
+		if err := wr.Err(); err != nil {
+			if !progress {
+				return err
+			}
+			log.Printf("paladin: watch etcd prefix %s error: %s", e.prefix, err)
+			return nil
+		}
+		for _, ev := range wr.Events {
+			key := e.key(ev.Kv.Key)
+			if key == "" {
+				continue
+			}
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				e.Set(key, string(ev.Kv.Value))
+			case clientv3.EventTypeDelete:
+				e.Delete(key)
+			}
+		}
+		if wr.Header.Revision > e.rev {
+			e.rev = wr.Header.Revision
+		}
+		progress = true
+	}
+	if progress {
+		return nil
+	}
+	return errors.New("watch channel closed")
+}
+
+// Close close watcher.
+func (e *etcd) Close() (err error) {
+	e.cancel()
+	if err = e.Store.Close(); err != nil {
+		return
+	}
+	return e.client.Close()
+}
@@ -0,0 +1,104 @@
+package etcd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin/etcd/internal/mockserver"
+)
+
+func waitEvent(t *testing.T, ch <-chan paladin.Event) paladin.Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait event timeout")
+	}
+	return paladin.Event{}
+}
+
+func assertValue(t *testing.T, c paladin.Client, key, expected string) {
+	if content, _ := c.Get(key).String(); content != expected {
+		t.Fatalf("got %s unexpected value %s, expected %s", key, content, expected)
+	}
+}
+
+func TestEtcd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paladin-etcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var (
+		ctx  = context.TODO()
+		srv  = mockserver.New()
+		conf = &Config{Endpoints: []string{"mock:2379"}, Prefix: "/config/demo", CacheDir: dir}
+	)
+	srv.Down(true)
+	if _, err = newEtcd(conf, srv); err == nil {
+		t.Fatal("new etcd without etcd and cache should fail")
+	}
+	srv.Down(false)
+	srv.Put(ctx, "/config/demo/app.toml", "test: 1")
+	srv.Put(ctx, "/config/demo/client.json", `{"name":"etcd"}`)
+	srv.Put(ctx, "/config/other/app.toml", "other")
+	e, err := newEtcd(conf, srv)
+	if err != nil {
+		t.Fatalf("new etcd error, %v", err)
+	}
+	defer e.Close()
+	assertValue(t, e, "app.toml", "test: 1")
+	assertValue(t, e, "client.json", `{"name":"etcd"}`)
+	if keys := e.GetAll().Keys(); len(keys) != 2 {
+		t.Fatalf("got unexpected keys %v", keys)
+	}
+
+	updates := e.WatchEvent(ctx, "app.toml", "db.toml")
+	srv.Put(ctx, "/config/demo/app.toml", "test: 2")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventUpdate || ev.Key != "app.toml" || ev.Value != "test: 2" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	srv.Put(ctx, "/config/demo/db.toml", "dsn: a")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventAdd || ev.Key != "db.toml" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	srv.Delete(ctx, "/config/demo/db.toml")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventRemove || ev.Key != "db.toml" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+
+	// the watch resumes from the last revision.
+	srv.Down(true)
+	srv.Put(ctx, "/config/demo/app.toml", "test: 3")
+	srv.Put(ctx, "/config/demo/db.toml", "dsn: b")
+	srv.Down(false)
+	waitEvent(t, updates)
+	waitEvent(t, updates)
+	assertValue(t, e, "app.toml", "test: 3")
+	assertValue(t, e, "db.toml", "dsn: b")
+
+	// reload if the revision is compacted.
+	srv.Down(true)
+	srv.Put(ctx, "/config/demo/app.toml", "test: 4")
+	resp, _ := srv.Put(ctx, "/config/demo/client.json", `{"name":"compacted"}`)
+	srv.Compact(ctx, resp.Header.Revision)
+	srv.Down(false)
+	if ev := waitEvent(t, updates); ev.Key != "app.toml" || ev.Value != "test: 4" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	assertValue(t, e, "client.json", `{"name":"compacted"}`)
+
+	// cold start from the cache.
+	srv.Down(true)
+	cached, err := newEtcd(conf, srv)
+	if err != nil {
+		t.Fatalf("new etcd from cache error, %v", err)
+	}
+	defer cached.Close()
+	assertValue(t, cached, "app.toml", "test: 4")
+	assertValue(t, cached, "db.toml", "dsn: b")
+}
@@ -0,0 +1,232 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var errUnsupported = errors.New("mockserver: unsupported")
+
+// Server is an in-memory etcd which implements the KV and Watcher of clientv3
+// with revisions, compaction and resumable watches.
+type Server struct {
+	lock      sync.Mutex
+	rev       int64
+	compacted int64
+	kvs       map[string]*mvccpb.KeyValue
+	events    []*clientv3.Event
+	watches   map[*watch]struct{}
+	down      bool
+}
+
+type watch struct {
+	key, end []byte
+	next     int64
+	notify   chan struct{}
+	cancel   context.CancelFunc
+}
+
+// New new an in-memory etcd.
+func New() *Server {
+	return &Server{
+		rev:     1,
+		kvs:     make(map[string]*mvccpb.KeyValue),
+		watches: make(map[*watch]struct{}),
+	}
+}
+
+func inRange(k, key, end []byte) bool {
+	if len(end) == 0 {
+		return bytes.Equal(k, key)
+	}
+	return bytes.Compare(k, key) >= 0 && (bytes.Equal(end, []byte{0}) || bytes.Compare(k, end) < 0)
+}
+
+func (s *Server) header() *pb.ResponseHeader {
+	return &pb.ResponseHeader{Revision: s.rev}
+}
+
+func (s *Server) append(ev *clientv3.Event) {
+	s.events = append(s.events, ev)
+	for w := range s.watches {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Put puts the value of key.
+func (s *Server) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rev++
+	kv := &mvccpb.KeyValue{Key: []byte(key), Value: []byte(val), CreateRevision: s.rev, ModRevision: s.rev, Version: 1}
+	if old, ok := s.kvs[key]; ok {
+		kv.CreateRevision = old.CreateRevision
+		kv.Version = old.Version + 1
+	}
+	s.kvs[key] = kv
+	s.append(&clientv3.Event{Type: clientv3.EventTypePut, Kv: kv})
+	return &clientv3.PutResponse{Header: s.header()}, nil
+}
+
+// Get gets the values of the key or the range.
+func (s *Server) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.down {
+		return nil, context.DeadlineExceeded
+	}
+	op := clientv3.OpGet(key, opts...)
+	resp := &clientv3.GetResponse{Header: s.header()}
+	for _, kv := range s.kvs {
+		if inRange(kv.Key, op.KeyBytes(), op.RangeBytes()) {
+			resp.Kvs = append(resp.Kvs, kv)
+		}
+	}
+	sort.Slice(resp.Kvs, func(i, j int) bool { return bytes.Compare(resp.Kvs[i].Key, resp.Kvs[j].Key) < 0 })
+	resp.Count = int64(len(resp.Kvs))
+	return resp, nil
+}
+
+// Delete deletes the key or the range.
+func (s *Server) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	op := clientv3.OpDelete(key, opts...)
+	var deleted []string
+	for k, kv := range s.kvs {
+		if inRange(kv.Key, op.KeyBytes(), op.RangeBytes()) {
+			deleted = append(deleted, k)
+		}
+	}
+	if len(deleted) == 0 {
+		return &clientv3.DeleteResponse{Header: s.header()}, nil
+	}
+	s.rev++
+	for _, k := range deleted {
+		delete(s.kvs, k)
+		s.append(&clientv3.Event{Type: clientv3.EventTypeDelete, Kv: &mvccpb.KeyValue{Key: []byte(k), ModRevision: s.rev}})
+	}
+	return &clientv3.DeleteResponse{Header: s.header(), Deleted: int64(len(deleted))}, nil
+}
+
+// Compact compacts the events before rev, the watches from a compacted
+// revision are canceled with the CompactRevision.
+func (s *Server) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.compacted = rev
+	events := s.events[:0]
+	for _, ev := range s.events {
+		if ev.Kv.ModRevision >= rev {
+			events = append(events, ev)
+		}
+	}
+	s.events = events
+	return &clientv3.CompactResponse{Header: s.header()}, nil
+}
+
+// Do is unsupported.
+func (s *Server) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	return clientv3.OpResponse{}, errUnsupported
+}
+
+// Txn is unsupported.
+func (s *Server) Txn(ctx context.Context) clientv3.Txn {
+	return nil
+}
+
+// Watch watches the key or the range from the revision of WithRev.
+func (s *Server) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	op := clientv3.OpGet(key, opts...)
+	ctx, cancel := context.WithCancel(ctx)
+	w := &watch{key: op.KeyBytes(), end: op.RangeBytes(), next: op.Rev(), notify: make(chan struct{}, 1), cancel: cancel}
+	ch := make(chan clientv3.WatchResponse)
+	s.lock.Lock()
+	if w.next == 0 {
+		w.next = s.rev + 1
+	}
+	if s.down {
+		cancel()
+	} else {
+		s.watches[w] = struct{}{}
+	}
+	s.lock.Unlock()
+	w.notify <- struct{}{}
+	go s.watchproc(ctx, w, ch)
+	return ch
+}
+
+func (s *Server) watchproc(ctx context.Context, w *watch, ch chan clientv3.WatchResponse) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.watches, w)
+		s.lock.Unlock()
+		close(ch)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.notify:
+		}
+		s.lock.Lock()
+		var resp clientv3.WatchResponse
+		if w.next < s.compacted {
+			resp.CompactRevision = s.compacted
+			resp.Canceled = true
+		} else {
+			for _, ev := range s.events {
+				if ev.Kv.ModRevision >= w.next && inRange(ev.Kv.Key, w.key, w.end) {
+					resp.Events = append(resp.Events, ev)
+				}
+			}
+			w.next = s.rev + 1
+		}
+		resp.Header = *s.header()
+		s.lock.Unlock()
+		if len(resp.Events) == 0 && !resp.Canceled {
+			continue
+		}
+		select {
+		case ch <- resp:
+		case <-ctx.Done():
+			return
+		}
+		if resp.Canceled {
+			return
+		}
+	}
+}
+
+// RequestProgress does nothing.
+func (s *Server) RequestProgress(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing.
+func (s *Server) Close() error {
+	return nil
+}
+
+// Down makes Get and Watch fail and breaks the watches, or recovers them, Put
+// and Delete always succeed so that the changes can be made during the outage.
+func (s *Server) Down(down bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.down = down
+	if down {
+		for w := range s.watches {
+			w.cancel()
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package paladin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// formats of the config values.
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// FormatOf returns the format by the key extension, e.g. app.yml is yaml,
+// the key without a known extension returns empty.
+func FormatOf(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	}
+	return ""
+}
+
+// UnmarshalFormat unmarshal the value to struct in the format, toml by default.
+func (v *Value) UnmarshalFormat(format string, dst interface{}) error {
+	switch format {
+	case FormatYAML:
+		return v.UnmarshalYAML(dst)
+	case FormatJSON:
+		return v.UnmarshalJSON(dst)
+	default:
+		return v.UnmarshalTOML(dst)
+	}
+}
+
+// unmarshalMap unmarshal the text in the format to a map, the values are
+// normalized to the types of toml, e.g. int64 and map[string]interface{}.
+func unmarshalMap(format string, text []byte) (raws map[string]interface{}, err error) {
+	raws = map[string]interface{}{}
+	switch format {
+	case FormatYAML:
+		var doc map[interface{}]interface{}
+		if err = yaml.Unmarshal(text, &doc); err != nil {
+			return
+		}
+		for k, v := range doc {
+			raws[fmt.Sprint(k)] = normalize(v)
+		}
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.UseNumber()
+		if err = dec.Decode(&raws); err != nil {
+			return
+		}
+		for k, v := range raws {
+			raws[k] = normalize(v)
+		}
+	default:
+		err = toml.Unmarshal(text, &raws)
+	}
+	return
+}
+
+func normalize(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case int:
+		return int64(vv)
+	case uint64:
+		return int64(vv)
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return i
+		}
+		f, _ := vv.Float64()
+		return f
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, x := range vv {
+			m[fmt.Sprint(k)] = normalize(x)
+		}
+		return m
+	case map[string]interface{}:
+		for k, x := range vv {
+			vv[k] = normalize(x)
+		}
+	case []interface{}:
+		for i, x := range vv {
+			vv[i] = normalize(x)
+		}
+	}
+	return v
+}
+
+// marshalMap marshal the map in the format.
+func marshalMap(format string, raws interface{}) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(raws)
+	case FormatJSON:
+		return json.Marshal(raws)
+	default:
+		buf := bytes.NewBuffer(nil)
+		err := toml.NewEncoder(buf).Encode(raws)
+		return buf.Bytes(), err
+	}
+}
+
+// storeFormat stores the top level keys of the text in the format to the map.
+func (m *Map) storeFormat(format string, text []byte) error {
+	raws, err := unmarshalMap(format, text)
+	if err != nil {
+		return err
+	}
+	values := map[string]*Value{}
+	for k, v := range raws {
+		k = KeyNamed(k)
+		if v == nil {
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Map:
+			b, err := marshalMap(format, v)
+			if err != nil {
+				return err
+			}
+			// NOTE: value is map[string]interface{}
+			values[k] = &Value{val: v, raw: string(b)}
+		case reflect.Slice:
+			var raw interface{} = v
+			if format != FormatYAML && format != FormatJSON {
+				// NOTE: toml can't encode an array without key.
+				raw = map[string]interface{}{k: v}
+			}
+			b, err := marshalMap(format, raw)
+			if err != nil {
+				return err
+			}
+			// NOTE: value is []interface{}
+			values[k] = &Value{val: v, raw: string(b)}
+		case reflect.Bool:
+			b := v.(bool)
+			values[k] = &Value{val: b, raw: strconv.FormatBool(b)}
+		case reflect.Int64:
+			i := v.(int64)
+			values[k] = &Value{val: i, raw: strconv.FormatInt(i, 10)}
+		case reflect.Float64:
+			f := v.(float64)
+			values[k] = &Value{val: f, raw: strconv.FormatFloat(f, 'f', -1, 64)}
+		case reflect.String:
+			s := v.(string)
+			values[k] = &Value{val: s, raw: s}
+		default:
+			return errors.Errorf("Unmarshal%s: unknown kind(%v)", strings.ToUpper(format), rv.Kind())
+		}
+	}
+	m.Store(values)
+	return nil
+}
@@ -0,0 +1,89 @@
+package paladin_test
+
+import (
+	"testing"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, paladin.FormatTOML, paladin.FormatOf("app.toml"))
+	assert.Equal(t, paladin.FormatYAML, paladin.FormatOf("app.yml"))
+	assert.Equal(t, paladin.FormatYAML, paladin.FormatOf("APP.YAML"))
+	assert.Equal(t, paladin.FormatJSON, paladin.FormatOf("client.json"))
+	assert.Equal(t, "", paladin.FormatOf("app"))
+}
+
+func TestYAML(t *testing.T) {
+	s := `
+text: hello
+number: 100
+point: 100.1
+boolean: true
+KeyCase: test
+numbers: [1, 2, 3]
+database:
+  server: 192.168.1.1
+  connection_max: 5000
+`
+	var m paladin.YAML
+	assert.Nil(t, m.Set(s))
+	str, err := m.Get("text").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", str)
+	n, err := m.Get("number").Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), n)
+	p, err := m.Get("point").Float64()
+	assert.Nil(t, err)
+	assert.Equal(t, 100.1, p)
+	b, err := m.Get("boolean").Bool()
+	assert.Nil(t, err)
+	assert.True(t, b)
+	lt, err := m.Get("keycase").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "test", lt)
+	var numbers []int64
+	assert.Nil(t, m.Get("numbers").Slice(&numbers))
+	assert.Equal(t, []int64{1, 2, 3}, numbers)
+	var db struct {
+		Server        string `yaml:"server"`
+		ConnectionMax int    `yaml:"connection_max"`
+	}
+	assert.Nil(t, m.Get("database").UnmarshalYAML(&db))
+	assert.Equal(t, "192.168.1.1", db.Server)
+	assert.Equal(t, 5000, db.ConnectionMax)
+}
+
+func TestJSON(t *testing.T) {
+	s := `{"text":"hello","number":100,"point":100.1,"boolean":true,"strings":["a","b"],"database":{"server":"192.168.1.1"}}`
+	var m paladin.JSON
+	assert.Nil(t, m.Set(s))
+	n, err := m.Get("number").Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), n)
+	p, err := m.Get("point").Float64()
+	assert.Nil(t, err)
+	assert.Equal(t, 100.1, p)
+	var strs []string
+	assert.Nil(t, m.Get("strings").Slice(&strs))
+	assert.Equal(t, []string{"a", "b"}, strs)
+	raw, err := m.Get("database").Raw()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"server":"192.168.1.1"}`, raw)
+	assert.NotNil(t, m.Set("{"))
+}
+
+func TestUnmarshalFormat(t *testing.T) {
+	var conf struct {
+		Name string `toml:"name" yaml:"name" json:"name"`
+	}
+	assert.Nil(t, paladin.NewValue("", `name = "toml"`).UnmarshalFormat(paladin.FormatOf("app.toml"), &conf))
+	assert.Equal(t, "toml", conf.Name)
+	assert.Nil(t, paladin.NewValue("", `name: yaml`).UnmarshalFormat(paladin.FormatOf("app.yaml"), &conf))
+	assert.Equal(t, "yaml", conf.Name)
+	assert.Nil(t, paladin.NewValue("", `{"name":"json"}`).UnmarshalFormat(paladin.FormatOf("app.json"), &conf))
+	assert.Equal(t, "json", conf.Name)
+}
@@ -0,0 +1,424 @@
+package paladin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrVersionNotExist value version not exist.
+var ErrVersionNotExist = errors.New("paladin: value version not exist")
+
+const (
+	_historyFile = "paladin.history.json"
+	_pinsFile    = "paladin.pins.json"
+)
+
+var _ Client = &History{}
+
+// Version is a version of the config value.
+type Version struct {
+	Version int64     `json:"version"`
+	Hash    string    `json:"hash"`
+	Time    time.Time `json:"time"`
+	Value   string    `json:"value"`
+}
+
+// History is the config client which keeps the last versions of every key on
+// top of another client, the hashes and line counts of the diff are logged on
+// every change. A key can be pinned to a previous version, the changes of the
+// pinned key are recorded but not applied until it's released.
+type History struct {
+	Client
+	size int
+	dir  string
+
+	mu       sync.RWMutex
+	versions map[string][]*Version
+	pins     map[string]*Version
+
+	wmu      sync.RWMutex
+	watchers map[*watcher]struct{}
+	cancel   context.CancelFunc
+}
+
+// NewHistory new a config client which keeps the last size versions of every
+// key of c, the versions and the pins are saved to dir if it's not empty and
+// restored from it.
+func NewHistory(c Client, size int, dir string) (*History, error) {
+	if size <= 0 {
+		size = 10
+	}
+	h := &History{
+		Client:   c,
+		size:     size,
+		dir:      dir,
+		versions: make(map[string][]*Version),
+		pins:     make(map[string]*Version),
+		watchers: make(map[*watcher]struct{}),
+	}
+	if dir != "" {
+		if err := loadHistoryFile(filepath.Join(dir, _historyFile), &h.versions); err != nil {
+			return nil, err
+		}
+		if err := loadHistoryFile(filepath.Join(dir, _pinsFile), &h.pins); err != nil {
+			return nil, err
+		}
+		for key, pin := range h.pins {
+			log.Printf("paladin: key %s pinned to version %d hash %s is restored", key, pin.Version, pin.Hash)
+		}
+	}
+	for key, v := range c.GetAll().Load() {
+		h.record(key, v.raw)
+	}
+	h.save()
+	h.savePins()
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	go h.watchproc(c.WatchEvent(ctx))
+	return h, nil
+}
+
+// Get return value by key, the pinned version if the key is pinned.
+func (h *History) Get(key string) *Value {
+	h.mu.RLock()
+	pin, ok := h.pins[KeyNamed(key)]
+	h.mu.RUnlock()
+	if ok {
+		return &Value{val: pin.Value, raw: pin.Value}
+	}
+	return h.Client.Get(key)
+}
+
+// GetAll return value map, the pinned keys are the pinned versions.
+func (h *History) GetAll() *Map {
+	values := h.Client.GetAll().Load()
+	h.mu.RLock()
+	for key, pin := range h.pins {
+		values[key] = &Value{val: pin.Value, raw: pin.Value}
+	}
+	h.mu.RUnlock()
+	m := new(Map)
+	m.Store(values)
+	return m
+}
+
+// WatchEvent watch with the specified keys, the changes of the pinned keys
+// are held back until they're released.
+func (h *History) WatchEvent(ctx context.Context, keys ...string) <-chan Event {
+	w := newWatcher(keys)
+	h.wmu.Lock()
+	h.watchers[w] = struct{}{}
+	h.wmu.Unlock()
+	return w.C
+}
+
+// Close close watcher.
+func (h *History) Close() error {
+	h.cancel()
+	h.wmu.Lock()
+	for w := range h.watchers {
+		close(w.C)
+	}
+	h.watchers = make(map[*watcher]struct{})
+	h.wmu.Unlock()
+	return h.Client.Close()
+}
+
+// Keys returns the keys which have versions.
+func (h *History) Keys() []string {
+	h.mu.RLock()
+	keys := make([]string, 0, len(h.versions))
+	for key := range h.versions {
+		keys = append(keys, key)
+	}
+	h.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// Versions returns the versions of key from the oldest to the latest.
+func (h *History) Versions(key string) []Version {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	versions := make([]Version, 0, len(h.versions[KeyNamed(key)]))
+	for _, v := range h.versions[KeyNamed(key)] {
+		versions = append(versions, *v)
+	}
+	return versions
+}
+
+// Version returns the version of key, the latest version if version is 0.
+func (h *History) Version(key string, version int64) (Version, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	v := h.version(KeyNamed(key), version)
+	if v == nil {
+		return Version{}, errors.Wrapf(ErrVersionNotExist, "key(%s) version(%d)", key, version)
+	}
+	return *v, nil
+}
+
+// Pinned returns the pinned version of key.
+func (h *History) Pinned(key string) (version int64, ok bool) {
+	h.mu.RLock()
+	pin, ok := h.pins[KeyNamed(key)]
+	h.mu.RUnlock()
+	if ok {
+		version = pin.Version
+	}
+	return
+}
+
+// Diff returns the line diff of key from version from to version to, the
+// latest version if to is 0 and the previous version of to if from is 0.
+func (h *History) Diff(key string, from, to int64) (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	key = KeyNamed(key)
+	t := h.version(key, to)
+	if t == nil {
+		return "", errors.Wrapf(ErrVersionNotExist, "key(%s) version(%d)", key, to)
+	}
+	if from == 0 {
+		from = t.Version - 1
+	}
+	f := h.version(key, from)
+	if f == nil {
+		return "", errors.Wrapf(ErrVersionNotExist, "key(%s) version(%d)", key, from)
+	}
+	return diff(f.Value, t.Value), nil
+}
+
+// Pin pins key to version, the value of key is the version until Release,
+// the watchers are notified with the version.
+func (h *History) Pin(key string, version int64) error {
+	key = KeyNamed(key)
+	h.mu.Lock()
+	v := h.version(key, version)
+	if v == nil {
+		h.mu.Unlock()
+		return errors.Wrapf(ErrVersionNotExist, "key(%s) version(%d)", key, version)
+	}
+	h.pins[key] = v
+	h.mu.Unlock()
+	h.savePins()
+	log.Printf("paladin: key %s pinned to version %d hash %s", key, v.Version, v.Hash)
+	h.notify(Event{Event: EventUpdate, Key: key, Value: v.Value})
+	return nil
+}
+
+// Release releases the pinned key, the watchers are notified with the
+// current value of key.
+func (h *History) Release(key string) {
+	key = KeyNamed(key)
+	h.mu.Lock()
+	_, ok := h.pins[key]
+	delete(h.pins, key)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	h.savePins()
+	log.Printf("paladin: key %s released", key)
+	v := h.Client.Get(key)
+	if v.val == nil {
+		h.notify(Event{Event: EventRemove, Key: key})
+		return
+	}
+	h.notify(Event{Event: EventUpdate, Key: key, Value: v.raw})
+}
+
+func (h *History) watchproc(events <-chan Event) {
+	for event := range events {
+		key := KeyNamed(event.Key)
+		h.mu.RLock()
+		pin, pinned := h.pins[key]
+		h.mu.RUnlock()
+		if event.Event == EventRemove {
+			log.Printf("paladin: key %s removed", key)
+		} else if h.record(key, event.Value) {
+			h.save()
+		}
+		if pinned {
+			log.Printf("paladin: key %s is pinned to version %d, the change is held back", key, pin.Version)
+			continue
+		}
+		h.notify(event)
+	}
+}
+
+func (h *History) notify(event Event) {
+	h.wmu.RLock()
+	for w := range h.watchers {
+		if w.HasKey(KeyNamed(event.Key)) {
+			w.Handle(event)
+		}
+	}
+	h.wmu.RUnlock()
+}
+
+// record records value as the latest version of key if it changes.
+func (h *History) record(key, value string) bool {
+	key = KeyNamed(key)
+	sum := sha256.Sum256([]byte(value))
+	hash := hex.EncodeToString(sum[:])
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	versions := h.versions[key]
+	v := &Version{Version: 1, Hash: hash, Time: time.Now(), Value: value}
+	if n := len(versions); n > 0 {
+		last := versions[n-1]
+		if last.Hash == hash {
+			return false
+		}
+		v.Version = last.Version + 1
+		// NOTE: the values may have plaintext credentials, only the hashes
+		// and the line counts are logged, use Diff for the lines.
+		added, removed := diffStat(diff(last.Value, value))
+		log.Printf("paladin: key %s changed from version %d hash %s to version %d hash %s, +%d -%d lines", key, last.Version, last.Hash, v.Version, v.Hash, added, removed)
+	}
+	if versions = append(versions, v); len(versions) > h.size {
+		versions = versions[len(versions)-h.size:]
+	}
+	h.versions[key] = versions
+	return true
+}
+
+// save saves the versions to dir.
+func (h *History) save() {
+	if h.dir == "" {
+		return
+	}
+	h.mu.RLock()
+	data, err := json.Marshal(h.versions)
+	h.mu.RUnlock()
+	if err == nil {
+		err = writeHistoryFile(filepath.Join(h.dir, _historyFile), data)
+	}
+	if err != nil {
+		log.Printf("paladin: write history %s error: %s", h.dir, err)
+	}
+}
+
+// savePins saves the pins to dir.
+func (h *History) savePins() {
+	if h.dir == "" {
+		return
+	}
+	h.mu.RLock()
+	data, err := json.Marshal(h.pins)
+	h.mu.RUnlock()
+	if err == nil {
+		err = writeHistoryFile(filepath.Join(h.dir, _pinsFile), data)
+	}
+	if err != nil {
+		log.Printf("paladin: write pins %s error: %s", h.dir, err)
+	}
+}
+
+// loadHistoryFile loads the json file to v, it's ok if the file doesn't exist.
+func loadHistoryFile(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	return errors.Wrapf(err, "paladin: load history %s", file)
+}
+
+// writeHistoryFile writes data to file by renaming a temp file, the file is only
+// readable by the owner as the values may have plaintext credentials.
+func writeHistoryFile(file string, data []byte) (err error) {
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return
+	}
+	tmp := file + ".tmp"
+	// NOTE: the perm of WriteFile is only applied to a new file.
+	os.Remove(tmp)
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	return os.Rename(tmp, file)
+}
+
+func (h *History) version(key string, version int64) *Version {
+	versions := h.versions[key]
+	if len(versions) == 0 {
+		return nil
+	}
+	if version == 0 {
+		return versions[len(versions)-1]
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return v
+		}
+	}
+	return nil
+}
+
+// diff returns the line diff from a to b, the removed lines start with "-",
+// the added lines with "+" and the others with " ".
+func diff(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var buf strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			buf.WriteString(" " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			buf.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			buf.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+	return buf.String()
+}
+
+// diffStat returns the counts of the added and removed lines of the diff.
+func diffStat(d string) (added, removed int) {
+	for _, line := range strings.Split(d, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package paladin_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitHistoryEvent(t *testing.T, ch <-chan paladin.Event) paladin.Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("wait event timeout")
+	}
+	return paladin.Event{}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paladin-history")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	mock := paladin.NewMock(map[string]string{
+		"app.toml": "a = 1\nb = 2",
+	}).(*paladin.Mock)
+	h, err := paladin.NewHistory(mock, 2, dir)
+	assert.Nil(t, err)
+	events := h.WatchEvent(context.TODO(), "app.toml")
+
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "app.toml", Value: "a = 1\nb = 3"}
+	ev := waitHistoryEvent(t, events)
+	assert.Equal(t, "a = 1\nb = 3", ev.Value)
+	versions := h.Versions("app.toml")
+	assert.Len(t, versions, 2)
+	assert.Equal(t, int64(2), versions[1].Version)
+	assert.Len(t, versions[1].Hash, 64)
+	d, err := h.Diff("app.toml", 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, " a = 1\n-b = 2\n+b = 3\n", d)
+
+	// pin to version 1, the changes are held back until release.
+	assert.NotNil(t, h.Pin("app.toml", 3))
+	assert.Nil(t, h.Pin("app.toml", 1))
+	ev = waitHistoryEvent(t, events)
+	assert.Equal(t, "a = 1\nb = 2", ev.Value)
+	s, _ := h.Get("app.toml").String()
+	assert.Equal(t, "a = 1\nb = 2", s)
+	version, ok := h.Pinned("app.toml")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), version)
+
+	mock.Store(map[string]*paladin.Value{"app.toml": paladin.NewValue("a = 1\nb = 4", "a = 1\nb = 4")})
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "app.toml", Value: "a = 1\nb = 4"}
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "app.toml", Value: "a = 1\nb = 4"}
+	select {
+	case ev = <-events:
+		t.Fatalf("got unexpected event %+v of the pinned key", ev)
+	default:
+	}
+	versions = h.Versions("app.toml")
+	assert.Len(t, versions, 2)
+	assert.Equal(t, int64(3), versions[1].Version)
+	s, _ = h.GetAll().Get("app.toml").String()
+	assert.Equal(t, "a = 1\nb = 2", s)
+
+	// the version 1 is evicted, but the pinned value is kept.
+	_, err = h.Version("app.toml", 1)
+	assert.NotNil(t, err)
+	h.Release("app.toml")
+	ev = waitHistoryEvent(t, events)
+	assert.Equal(t, "a = 1\nb = 4", ev.Value)
+	_, ok = h.Pinned("app.toml")
+	assert.False(t, ok)
+	assert.Nil(t, h.Close())
+
+	// the history is loaded from dir.
+	h, err = paladin.NewHistory(paladin.NewMock(map[string]string{"app.toml": "a = 1\nb = 4"}), 2, dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app.toml"}, h.Keys())
+	v, err := h.Version("app.toml", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), v.Version)
+	assert.Nil(t, h.Pin("app.toml", 2))
+	assert.Nil(t, h.Close())
+
+	// the pins are restored from dir too.
+	h, err = paladin.NewHistory(paladin.NewMock(map[string]string{"app.toml": "a = 1\nb = 5"}), 2, dir)
+	assert.Nil(t, err)
+	defer h.Close()
+	version, ok = h.Pinned("app.toml")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), version)
+	s, _ = h.Get("app.toml").String()
+	assert.Equal(t, "a = 1\nb = 3", s)
+	for _, name := range []string{"paladin.history.json", "paladin.pins.json"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+}
+
+func TestHistoryLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	mock := paladin.NewMock(map[string]string{
+		"app.toml": "password = \"old\"",
+	}).(*paladin.Mock)
+	h, err := paladin.NewHistory(mock, 2, "")
+	assert.Nil(t, err)
+	defer h.Close()
+	events := h.WatchEvent(context.TODO(), "app.toml")
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "app.toml", Value: "password = \"new\"\nuser = \"atreus\""}
+	waitHistoryEvent(t, events)
+	// only the hashes and the line counts are logged.
+	assert.Contains(t, buf.String(), "+2 -1 lines")
+	assert.NotContains(t, buf.String(), "old")
+	assert.NotContains(t, buf.String(), "new")
+}
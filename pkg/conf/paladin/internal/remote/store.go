@@ -0,0 +1,240 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+)
+
+type watcher struct {
+	keys []string
+	C    chan paladin.Event
+}
+
+func newWatcher(keys []string) *watcher {
+	return &watcher{keys: keys, C: make(chan paladin.Event, 5)}
+}
+
+func (w *watcher) HasKey(key string) bool {
+	if len(w.keys) == 0 {
+		return true
+	}
+	for _, k := range w.keys {
+		if paladin.KeyNamed(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *watcher) Handle(event paladin.Event) {
+	select {
+	case w.C <- event:
+	default:
+		log.Printf("paladin: event channel full discard key %s update event", event.Key)
+	}
+}
+
+// Store is the config values of a remote config center, it implements the
+// Getter and Watcher of paladin for the drivers. The values are saved to the
+// local cache file on every change so that the client can start from the
+// cache when the config center is unreachable.
+type Store struct {
+	values *paladin.Map
+	raws   map[string]string
+	mu     sync.Mutex
+	cache  string
+
+	wmu      sync.RWMutex
+	watchers map[*watcher]struct{}
+}
+
+// NewStore new an empty store with the cache file, no cache if it's empty.
+func NewStore(cache string) *Store {
+	values := new(paladin.Map)
+	values.Store(map[string]*paladin.Value{})
+	return &Store{
+		values:   values,
+		raws:     make(map[string]string),
+		cache:    cache,
+		watchers: make(map[*watcher]struct{}),
+	}
+}
+
+// CacheFile returns the cache file path of the driver in dir, the names
+// identify the config, e.g. the endpoints and the prefix.
+func CacheFile(dir, driver string, names ...string) string {
+	if dir == "" {
+		return ""
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, strings.Join(names, "_"))
+	return filepath.Join(dir, "paladin_"+driver+"_"+name+".json")
+}
+
+// LoadCache loads the values from the cache file.
+func (s *Store) LoadCache() (err error) {
+	if s.cache == "" {
+		return os.ErrNotExist
+	}
+	data, err := ioutil.ReadFile(s.cache)
+	if err != nil {
+		return
+	}
+	raws := make(map[string]string)
+	if err = json.Unmarshal(data, &raws); err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.raws = raws
+	s.store()
+	s.mu.Unlock()
+	log.Printf("paladin: load %d configs from cache %s", len(raws), s.cache)
+	return
+}
+
+// Replace replaces all the values with raws, the changes are sent to the watchers.
+func (s *Store) Replace(raws map[string]string) {
+	s.mu.Lock()
+	var events []paladin.Event
+	for key, raw := range raws {
+		if old, ok := s.raws[key]; !ok {
+			events = append(events, paladin.Event{Event: paladin.EventAdd, Key: key, Value: raw})
+		} else if old != raw {
+			events = append(events, paladin.Event{Event: paladin.EventUpdate, Key: key, Value: raw})
+		}
+	}
+	for key := range s.raws {
+		if _, ok := raws[key]; !ok {
+			events = append(events, paladin.Event{Event: paladin.EventRemove, Key: key})
+		}
+	}
+	s.raws = make(map[string]string, len(raws))
+	for key, raw := range raws {
+		s.raws[key] = raw
+	}
+	s.save()
+	s.mu.Unlock()
+	s.notify(events...)
+}
+
+// Set adds or updates the value of key.
+func (s *Store) Set(key, raw string) {
+	s.mu.Lock()
+	old, ok := s.raws[key]
+	if ok && old == raw {
+		s.mu.Unlock()
+		return
+	}
+	s.raws[key] = raw
+	s.save()
+	s.mu.Unlock()
+	event := paladin.Event{Event: paladin.EventUpdate, Key: key, Value: raw}
+	if !ok {
+		event.Event = paladin.EventAdd
+	}
+	s.notify(event)
+}
+
+// Delete removes the value of key.
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	if _, ok := s.raws[key]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.raws, key)
+	s.save()
+	s.mu.Unlock()
+	s.notify(paladin.Event{Event: paladin.EventRemove, Key: key})
+}
+
+// save stores the values and writes the cache file, it must be called with mu held.
+func (s *Store) save() {
+	s.store()
+	if s.cache == "" {
+		return
+	}
+	data, err := json.Marshal(s.raws)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(s.cache), 0755); err == nil {
+		tmp := s.cache + ".tmp"
+		if err = ioutil.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, s.cache)
+		}
+	}
+	if err != nil {
+		log.Printf("paladin: write cache %s error: %s", s.cache, err)
+	}
+}
+
+func (s *Store) store() {
+	values := make(map[string]*paladin.Value, len(s.raws))
+	for key, raw := range s.raws {
+		values[key] = paladin.NewValue(raw, raw)
+	}
+	s.values.Store(values)
+}
+
+func (s *Store) notify(events ...paladin.Event) {
+	if len(events) == 0 {
+		return
+	}
+	s.wmu.RLock()
+	for _, event := range events {
+		event.Key = paladin.KeyNamed(event.Key)
+		n := 0
+		for w := range s.watchers {
+			if w.HasKey(event.Key) {
+				n++
+				w.Handle(event)
+			}
+		}
+		log.Printf("paladin: reload config: %s events: %d\n", event.Key, n)
+	}
+	s.wmu.RUnlock()
+}
+
+// Get return value by key.
+func (s *Store) Get(key string) *paladin.Value {
+	return s.values.Get(key)
+}
+
+// GetAll return value map.
+func (s *Store) GetAll() *paladin.Map {
+	return s.values
+}
+
+// WatchEvent watch with the specified keys.
+func (s *Store) WatchEvent(ctx context.Context, keys ...string) <-chan paladin.Event {
+	w := newWatcher(keys)
+	s.wmu.Lock()
+	s.watchers[w] = struct{}{}
+	s.wmu.Unlock()
+	return w.C
+}
+
+// Close closes the watchers.
+func (s *Store) Close() error {
+	s.wmu.Lock()
+	for w := range s.watchers {
+		close(w.C)
+		delete(s.watchers, w)
+	}
+	s.wmu.Unlock()
+	return nil
+}
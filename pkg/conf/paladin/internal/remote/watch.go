@@ -0,0 +1,56 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	_minBackoff = 100 * time.Millisecond
+	_maxBackoff = 10 * time.Second
+)
+
+// Load loads the values of the store by load. The values are loaded from the
+// cache file if the config center is unreachable, and they are reloaded by
+// the watch once the config center is back. The name identifies the config
+// in the logs and errors, e.g. "etcd prefix /config/demo/".
+func (s *Store) Load(name string, load func() error) error {
+	err := load()
+	if err == nil {
+		return nil
+	}
+	if cerr := s.LoadCache(); cerr != nil {
+		return fmt.Errorf("paladin: load %s error: %s, and no cache: %s", name, err, cerr)
+	}
+	log.Printf("paladin: load %s error: %s, start with cache", name, err)
+	return nil
+}
+
+// Watch calls watch until ctx is done, watch returns when the watch of the
+// config center breaks. It's called again at once if it returns nil, or after
+// the exponential backoff from 100ms to 10s if it returns an error, and the
+// backoff is reset once it returns nil.
+func Watch(ctx context.Context, name string, watch func() error) {
+	backoff := _minBackoff
+	for {
+		err := watch()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = _minBackoff
+			continue
+		}
+		log.Printf("paladin: watch %s error: %s, retry after %s", name, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > _maxBackoff {
+			backoff = _maxBackoff
+		}
+	}
+}
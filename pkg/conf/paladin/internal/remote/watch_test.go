@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "cache.json")
+	unreachable := func() error { return errors.New("unreachable") }
+	assert.Error(t, NewStore(cache).Load("test", unreachable))
+
+	s := NewStore(cache)
+	assert.NoError(t, s.Load("test", func() error {
+		s.Replace(map[string]string{"app.toml": "a = 1"})
+		return nil
+	}))
+	// start with the cache if the config center is unreachable.
+	cached := NewStore(cache)
+	assert.NoError(t, cached.Load("test", unreachable))
+	raw, err := cached.Get("app.toml").Raw()
+	assert.NoError(t, err)
+	assert.Equal(t, "a = 1", raw)
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		calls []time.Time
+		done  = make(chan struct{})
+	)
+	go func() {
+		Watch(ctx, "test", func() error {
+			calls = append(calls, time.Now())
+			switch len(calls) {
+			case 3:
+				// the backoff is reset by the success.
+				return nil
+			case 5:
+				cancel()
+			}
+			return errors.New("unreachable")
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch is not stopped")
+	}
+	if !assert.Len(t, calls, 5) {
+		return
+	}
+	assert.True(t, calls[1].Sub(calls[0]) >= _minBackoff)
+	assert.True(t, calls[2].Sub(calls[1]) >= 2*_minBackoff)
+	assert.True(t, calls[3].Sub(calls[2]) < _minBackoff)
+	assert.True(t, calls[4].Sub(calls[3]) >= _minBackoff)
+	assert.True(t, calls[4].Sub(calls[3]) < 2*_minBackoff)
+}
@@ -0,0 +1,16 @@
+package paladin
+
+// JSON is json map.
+type JSON struct {
+	Map
+}
+
+// Set set the map by value.
+func (m *JSON) Set(text string) error {
+	return m.UnmarshalText([]byte(text))
+}
+
+// UnmarshalText implemented json.
+func (m *JSON) UnmarshalText(text []byte) error {
+	return m.storeFormat(FormatJSON, text)
+}
@@ -0,0 +1,6 @@
+package nacos
+
+const (
+	// PaladinDriverNacos ...
+	PaladinDriverNacos = "nacos"
+)
@@ -0,0 +1,197 @@
+package mockserver
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_wordSeparator = "\x02"
+	_lineSeparator = "\x01"
+)
+
+// Server is a nacos config http server which supports the get, listener
+// and login open APIs.
+type Server struct {
+	server *httptest.Server
+
+	lock    sync.Mutex
+	configs map[string]string
+	changed chan struct{}
+	down    bool
+	fetches int
+	// Username and Password are required by login if not empty.
+	Username, Password string
+	token              string
+}
+
+// Run runs a mock server on a random local port.
+func Run() *Server {
+	s := &Server{
+		configs: make(map[string]string),
+		changed: make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/cs/configs", s.ConfigHandler)
+	mux.HandleFunc("/nacos/v1/cs/configs/listener", s.ListenerHandler)
+	mux.HandleFunc("/nacos/v1/auth/login", s.LoginHandler)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the address of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Down makes the server respond 500, or recovers it.
+func (s *Server) Down(down bool) {
+	s.lock.Lock()
+	s.down = down
+	s.lock.Unlock()
+}
+
+func configKey(dataID, group, tenant string) string {
+	return dataID + _wordSeparator + group + _wordSeparator + tenant
+}
+
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Publish publishes the content of the config.
+func (s *Server) Publish(dataID, group, tenant, content string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.configs[configKey(dataID, group, tenant)] = content
+	s.notify()
+}
+
+// Remove removes the config.
+func (s *Server) Remove(dataID, group, tenant string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.configs, configKey(dataID, group, tenant))
+	s.notify()
+}
+
+// Fetches returns the number of the config gets.
+func (s *Server) Fetches() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.fetches
+}
+
+// check checks the server status and the access token, it must be called with lock held.
+func (s *Server) check(rw http.ResponseWriter, req *http.Request) bool {
+	if s.down {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	if s.Username != "" && (s.token == "" || req.FormValue("accessToken") != s.token) {
+		rw.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// LoginHandler handles POST /nacos/v1/auth/login.
+func (s *Server) LoginHandler(rw http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if req.FormValue("username") != s.Username || req.FormValue("password") != s.Password {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	s.token = strconv.FormatInt(time.Now().UnixNano(), 36)
+	json.NewEncoder(rw).Encode(map[string]interface{}{"accessToken": s.token, "tokenTtl": 18000})
+}
+
+// ConfigHandler handles GET /nacos/v1/cs/configs.
+func (s *Server) ConfigHandler(rw http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.check(rw, req) {
+		return
+	}
+	s.fetches++
+	content, ok := s.configs[configKey(req.FormValue("dataId"), req.FormValue("group"), req.FormValue("tenant"))]
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte("config data not exist"))
+		return
+	}
+	rw.Write([]byte(content))
+}
+
+// changes returns the listening configs whose md5 differ, it must be called with lock held.
+func (s *Server) changes(listening string) (changes []string) {
+	for _, line := range strings.Split(listening, _lineSeparator) {
+		words := strings.Split(line, _wordSeparator)
+		if len(words) < 3 {
+			continue
+		}
+		var tenant string
+		if len(words) > 3 {
+			tenant = words[3]
+		}
+		var sum string
+		if content, ok := s.configs[configKey(words[0], words[1], tenant)]; ok {
+			h := md5.Sum([]byte(content))
+			sum = hex.EncodeToString(h[:])
+		}
+		if sum != words[2] {
+			change := words[0] + _wordSeparator + words[1]
+			if tenant != "" {
+				change += _wordSeparator + tenant
+			}
+			changes = append(changes, change+_lineSeparator)
+		}
+	}
+	return
+}
+
+// ListenerHandler handles POST /nacos/v1/cs/configs/listener, it responds
+// the changed configs immediately or until the configs change or timeout.
+func (s *Server) ListenerHandler(rw http.ResponseWriter, req *http.Request) {
+	timeout, _ := strconv.ParseInt(req.Header.Get("Long-Pulling-Timeout"), 10, 64)
+	if timeout <= 0 {
+		timeout = 30000
+	}
+	listening := req.FormValue("Listening-Configs")
+	deadline := time.After(time.Duration(timeout) * time.Millisecond)
+	for {
+		s.lock.Lock()
+		if !s.check(rw, req) {
+			s.lock.Unlock()
+			return
+		}
+		changes, changed := s.changes(listening), s.changed
+		s.lock.Unlock()
+		if len(changes) > 0 {
+			rw.Write([]byte(url.QueryEscape(strings.Join(changes, ""))))
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package nacos
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin/internal/remote"
+)
+
+var _ paladin.Client = &nacos{}
+
+const (
+	_defaultGroup = "DEFAULT_GROUP"
+	_loadTimeout  = 5 * time.Second
+
+	// separators of the listening configs.
+	_wordSeparator = "\x02"
+	_lineSeparator = "\x01"
+)
+
+// nacos is nacos config client.
+// the data ids of the group are the keys of paladin.
+type nacos struct {
+	*remote.Store
+	client *http.Client
+	conf   *Config
+
+	token       string
+	tokenExpire time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config is nacos config client config.
+type Config struct {
+	// Address is the http address of nacos, e.g. http://127.0.0.1:8848.
+	Address string `json:"address"`
+	// Namespace is the namespace id, the public namespace if empty.
+	Namespace   string        `json:"namespace"`
+	Group       string        `json:"group"`
+	DataIDs     []string      `json:"data_ids"`
+	CacheDir    string        `json:"cache_dir"`
+	Username    string        `json:"username"`
+	Password    string        `json:"password"`
+	PollTimeout time.Duration `json:"poll_timeout"`
+}
+
+type nacosDriver struct{}
+
+var (
+	confAddress, confNamespace, confGroup, confDataIDs, confCacheDir string
+)
+
+func init() {
+	addNacosFlags()
+	paladin.Register(PaladinDriverNacos, &nacosDriver{})
+}
+
+func addNacosFlags() {
+	flag.StringVar(&confAddress, "paladin.nacos.addr", "", "nacos http address of config, e.g. http://127.0.0.1:8848")
+	flag.StringVar(&confNamespace, "paladin.nacos.namespace", "", "nacos namespace id of config")
+	flag.StringVar(&confGroup, "paladin.nacos.group", _defaultGroup, "nacos group of config")
+	flag.StringVar(&confDataIDs, "paladin.nacos.dataids", "", "subscribed nacos data ids, comma separated, e.g. app.toml,mysql.toml")
+	flag.StringVar(&confCacheDir, "paladin.nacos.cachedir", "/tmp", "nacos config cache dir")
+}
+
+func buildConfigForNacos() (c *Config, err error) {
+	if addrFromEnv := os.Getenv("PALADIN_NACOS_ADDR"); addrFromEnv != "" {
+		confAddress = addrFromEnv
+	}
+	if confAddress == "" {
+		err = errors.New("invalid nacos addr, pass it via PALADIN_NACOS_ADDR=xxx with env or --paladin.nacos.addr=xxx with flag")
+		return
+	}
+	if namespaceFromEnv := os.Getenv("PALADIN_NACOS_NAMESPACE"); namespaceFromEnv != "" {
+		confNamespace = namespaceFromEnv
+	}
+	if groupFromEnv := os.Getenv("PALADIN_NACOS_GROUP"); groupFromEnv != "" {
+		confGroup = groupFromEnv
+	}
+	if dataIDsFromEnv := os.Getenv("PALADIN_NACOS_DATAIDS"); dataIDsFromEnv != "" {
+		confDataIDs = dataIDsFromEnv
+	}
+	if confDataIDs == "" {
+		err = errors.New("invalid nacos data ids, pass it via PALADIN_NACOS_DATAIDS=xxx with env or --paladin.nacos.dataids=xxx with flag")
+		return
+	}
+	if cacheDirFromEnv := os.Getenv("PALADIN_NACOS_CACHE_DIR"); cacheDirFromEnv != "" {
+		confCacheDir = cacheDirFromEnv
+	}
+	c = &Config{
+		Address:   confAddress,
+		Namespace: confNamespace,
+		Group:     confGroup,
+		DataIDs:   strings.Split(confDataIDs, ","),
+		CacheDir:  confCacheDir,
+		Username:  os.Getenv("PALADIN_NACOS_USERNAME"),
+		Password:  os.Getenv("PALADIN_NACOS_PASSWORD"),
+	}
+	return
+}
+
+// New new a nacos config client.
+// it listens the data ids by long polling and updates local cache.
+func (nd *nacosDriver) New() (paladin.Client, error) {
+	c, err := buildConfigForNacos()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c)
+}
+
+// NewClient new a nacos config client with the config.
+func NewClient(conf *Config) (paladin.Client, error) {
+	if conf == nil || conf.Address == "" || len(conf.DataIDs) == 0 {
+		return nil, errors.New("invalid nacos conf")
+	}
+	cc := *conf
+	if !strings.Contains(cc.Address, "://") {
+		cc.Address = "http://" + cc.Address
+	}
+	cc.Address = strings.TrimSuffix(cc.Address, "/")
+	if cc.Group == "" {
+		cc.Group = _defaultGroup
+	}
+	if cc.PollTimeout <= 0 {
+		cc.PollTimeout = 30 * time.Second
+	}
+	n := &nacos{
+		Store:  remote.NewStore(remote.CacheFile(cc.CacheDir, PaladinDriverNacos, cc.Address, cc.Namespace, cc.Group)),
+		client: &http.Client{},
+		conf:   &cc,
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	name := "nacos group " + cc.Group
+	if err := n.Load(name, n.load); err != nil {
+		n.cancel()
+		return nil, err
+	}
+	go remote.Watch(n.ctx, name, n.watch)
+	return n, nil
+}
+
+// do sends the request with the access token and returns the body, the
+// request of an absent config returns a nil body.
+func (n *nacos) do(ctx context.Context, method, path string, params url.Values, header http.Header) (body []byte, err error) {
+	if err = n.login(ctx); err != nil {
+		return
+	}
+	if n.token != "" {
+		params.Set("accessToken", n.token)
+	}
+	var req *http.Request
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, n.conf.Address+path+"?"+params.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(method, n.conf.Address+path, strings.NewReader(params.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if body == nil {
+			body = []byte{}
+		}
+	case http.StatusNotFound:
+		body = nil
+	case http.StatusForbidden:
+		// the token may be revoked, login again on the next request.
+		n.tokenExpire = time.Time{}
+		err = fmt.Errorf("nacos %s status %d: %s", path, resp.StatusCode, body)
+	default:
+		err = fmt.Errorf("nacos %s status %d: %s", path, resp.StatusCode, body)
+	}
+	return
+}
+
+// login gets the access token if the username is set and the token expires.
+func (n *nacos) login(ctx context.Context) (err error) {
+	if n.conf.Username == "" || time.Now().Before(n.tokenExpire) {
+		return
+	}
+	params := url.Values{}
+	params.Set("username", n.conf.Username)
+	params.Set("password", n.conf.Password)
+	req, err := http.NewRequest(http.MethodPost, n.conf.Address+"/nacos/v1/auth/login", strings.NewReader(params.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nacos login status %d", resp.StatusCode)
+	}
+	var res struct {
+		AccessToken string `json:"accessToken"`
+		TokenTTL    int64  `json:"tokenTtl"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return
+	}
+	n.token = res.AccessToken
+	// refresh the token before it expires.
+	n.tokenExpire = time.Now().Add(time.Duration(res.TokenTTL) * time.Second * 9 / 10)
+	return
+}
+
+func (n *nacos) params(dataID string) url.Values {
+	params := url.Values{}
+	params.Set("dataId", dataID)
+	params.Set("group", n.conf.Group)
+	if n.conf.Namespace != "" {
+		params.Set("tenant", n.conf.Namespace)
+	}
+	return params
+}
+
+// load loads the contents of all the data ids.
+func (n *nacos) load() error {
+	ctx, cancel := context.WithTimeout(n.ctx, _loadTimeout)
+	defer cancel()
+	raws := make(map[string]string, len(n.conf.DataIDs))
+	for _, dataID := range n.conf.DataIDs {
+		body, err := n.do(ctx, http.MethodGet, "/nacos/v1/cs/configs", n.params(dataID), nil)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			raws[dataID] = string(body)
+		}
+	}
+	n.Replace(raws)
+	return nil
+}
+
+// reload reloads the content of the data id.
+func (n *nacos) reload(dataID string) error {
+	ctx, cancel := context.WithTimeout(n.ctx, _loadTimeout)
+	defer cancel()
+	body, err := n.do(ctx, http.MethodGet, "/nacos/v1/cs/configs", n.params(dataID), nil)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		n.Delete(dataID)
+	} else {
+		n.Set(dataID, string(body))
+	}
+	return nil
+}
+
+// listen long polls the changed data ids, the md5 of the loaded contents
+// are compared by nacos.
+func (n *nacos) listen() (changed []string, err error) {
+	values := n.GetAll().Load()
+	var configs strings.Builder
+	for _, dataID := range n.conf.DataIDs {
+		var sum string
+		if v, ok := values[paladin.KeyNamed(dataID)]; ok {
+			raw, _ := v.Raw()
+			h := md5.Sum([]byte(raw))
+			sum = hex.EncodeToString(h[:])
+		}
+		configs.WriteString(dataID + _wordSeparator + n.conf.Group + _wordSeparator + sum)
+		if n.conf.Namespace != "" {
+			configs.WriteString(_wordSeparator + n.conf.Namespace)
+		}
+		configs.WriteString(_lineSeparator)
+	}
+	params := url.Values{}
+	params.Set("Listening-Configs", configs.String())
+	header := http.Header{}
+	header.Set("Long-Pulling-Timeout", strconv.FormatInt(int64(n.conf.PollTimeout/time.Millisecond), 10))
+	ctx, cancel := context.WithTimeout(n.ctx, n.conf.PollTimeout+_loadTimeout)
+	defer cancel()
+	body, err := n.do(ctx, http.MethodPost, "/nacos/v1/cs/configs/listener", params, header)
+	if err != nil || len(body) == 0 {
+		return
+	}
+	res, err := url.QueryUnescape(string(body))
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(res, _lineSeparator) {
+		if words := strings.Split(line, _wordSeparator); len(words) >= 2 && words[0] != "" {
+			changed = append(changed, words[0])
+		}
+	}
+	return
+}
+
+// watch listens the data ids and reloads the changed ones.
+func (n *nacos) watch() error {
+	changed, err := n.listen()
+	if err != nil {
+		return err
+	}
+	for _, dataID := range changed {
+		if err = n.reload(dataID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close close watcher.
+func (n *nacos) Close() error {
+	n.cancel()
+	return n.Store.Close()
+}
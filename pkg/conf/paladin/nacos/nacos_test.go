@@ -0,0 +1,142 @@
+package nacos
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin/nacos/internal/mockserver"
+)
+
+func waitEvent(t *testing.T, ch <-chan paladin.Event) paladin.Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait event timeout")
+	}
+	return paladin.Event{}
+}
+
+func assertValue(t *testing.T, c paladin.Client, key, expected string) {
+	if content, _ := c.Get(key).String(); content != expected {
+		t.Fatalf("got %s unexpected value %s, expected %s", key, content, expected)
+	}
+}
+
+func TestNacos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paladin-nacos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Username, srv.Password = "nacos", "secret"
+	conf := &Config{
+		Address:     srv.URL(),
+		Namespace:   "dev",
+		DataIDs:     []string{"app.toml", "client.json", "db.toml"},
+		CacheDir:    dir,
+		Username:    "nacos",
+		Password:    "secret",
+		PollTimeout: time.Second,
+	}
+
+	srv.Down(true)
+	if _, err = NewClient(conf); err == nil {
+		t.Fatal("new nacos without nacos and cache should fail")
+	}
+	srv.Down(false)
+	srv.Publish("app.toml", _defaultGroup, "dev", "test: 1")
+	srv.Publish("client.json", _defaultGroup, "dev", `{"name":"nacos"}`)
+	srv.Publish("app.toml", _defaultGroup, "", "public")
+	c, err := NewClient(conf)
+	if err != nil {
+		t.Fatalf("new nacos error, %v", err)
+	}
+	defer c.Close()
+	assertValue(t, c, "app.toml", "test: 1")
+	assertValue(t, c, "client.json", `{"name":"nacos"}`)
+	if keys := c.GetAll().Keys(); len(keys) != 2 {
+		t.Fatalf("got unexpected keys %v", keys)
+	}
+
+	updates := c.WatchEvent(context.TODO(), "app.toml", "db.toml")
+	srv.Publish("app.toml", _defaultGroup, "dev", "test: 2")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventUpdate || ev.Key != "app.toml" || ev.Value != "test: 2" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	srv.Publish("db.toml", _defaultGroup, "dev", "dsn: a")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventAdd || ev.Key != "db.toml" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	srv.Remove("db.toml", _defaultGroup, "dev")
+	if ev := waitEvent(t, updates); ev.Event != paladin.EventRemove || ev.Key != "db.toml" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+
+	// cold start from the cache.
+	srv.Down(true)
+	cached, err := NewClient(conf)
+	if err != nil {
+		t.Fatalf("new nacos from cache error, %v", err)
+	}
+	defer cached.Close()
+	assertValue(t, cached, "app.toml", "test: 2")
+	updates = cached.WatchEvent(context.TODO(), "app.toml")
+	srv.Publish("app.toml", _defaultGroup, "dev", "test: 3")
+	srv.Down(false)
+	if ev := waitEvent(t, updates); ev.Value != "test: 3" {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+}
+
+func TestNacosSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paladin-nacos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, _ := paladin.GenerateAESKey()
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	p, err := paladin.NewAESProvider(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paladin.RegisterSecretProvider("", p)
+	enc, _ := paladin.EncryptSecret("", "p@ssw0rd")
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Publish("password", _defaultGroup, "", enc)
+	c, err := NewClient(&Config{
+		Address:     srv.URL(),
+		DataIDs:     []string{"password"},
+		CacheDir:    dir,
+		PollTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("new nacos error, %v", err)
+	}
+	defer c.Close()
+	assertValue(t, c, "password", "p@ssw0rd")
+
+	// the md5 of the stored ciphertext matches nacos, the config isn't
+	// fetched again until it changes.
+	fetches := srv.Fetches()
+	time.Sleep(500 * time.Millisecond)
+	if n := srv.Fetches(); n != fetches {
+		t.Fatalf("got unexpected fetches %d, expected %d", n, fetches)
+	}
+	updates := c.WatchEvent(context.TODO(), "password")
+	enc, _ = paladin.EncryptSecret("", "n3w")
+	srv.Publish("password", _defaultGroup, "", enc)
+	if ev := waitEvent(t, updates); ev.Value != enc {
+		t.Fatalf("got unexpected event %+v", ev)
+	}
+	assertValue(t, c, "password", "n3w")
+}
@@ -0,0 +1,212 @@
+package paladin
+
+import (
+	"context"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var _ Client = &overlay{}
+
+// _placeholder matches ${ENV_VAR} or ${ENV_VAR:default}.
+var _placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+// overlay is the config client which resolves the env vars on top of another client.
+type overlay struct {
+	Client
+	environ func() []string
+	// cache is key -> *overlayValue, the value is resolved again if the raw changes.
+	cache sync.Map
+}
+
+type overlayValue struct {
+	raw   string
+	value *Value
+}
+
+// NewOverlay new a config client which resolves the env vars on top of c:
+//
+//  1. the ${ENV_VAR:default} placeholders in the values are replaced by the
+//     env var, or the default if the env var isn't set, the placeholder
+//     without default is kept if the env var isn't set.
+//  2. the APP__SECTION__KEY env vars override the section.key of the toml,
+//     yaml or json value whose key is app.toml, app.yaml or app.json, the
+//     names are case-insensitive.
+func NewOverlay(c Client) Client {
+	return &overlay{Client: c, environ: os.Environ}
+}
+
+// Get return value by key.
+func (o *overlay) Get(key string) *Value {
+	v := o.Client.Get(key)
+	raw, err := v.Raw()
+	if err != nil {
+		return v
+	}
+	return o.value(key, raw)
+}
+
+// GetAll return value map.
+func (o *overlay) GetAll() *Map {
+	values := o.Client.GetAll().Load()
+	for key, v := range values {
+		if raw, err := v.Raw(); err == nil {
+			values[key] = o.value(key, raw)
+		}
+	}
+	m := new(Map)
+	m.Store(values)
+	return m
+}
+
+// WatchEvent watch with the specified keys, the values of the events are resolved.
+func (o *overlay) WatchEvent(ctx context.Context, keys ...string) <-chan Event {
+	in := o.Client.WatchEvent(ctx, keys...)
+	out := make(chan Event, 5)
+	go func() {
+		defer close(out)
+		for event := range in {
+			if event.Event != EventRemove {
+				event.Value = o.value(event.Key, event.Value).raw
+			}
+			out <- event
+		}
+	}()
+	return out
+}
+
+func (o *overlay) value(key, raw string) *Value {
+	key = KeyNamed(key)
+	if cached, ok := o.cache.Load(key); ok && cached.(*overlayValue).raw == raw {
+		return cached.(*overlayValue).value
+	}
+	resolved := o.resolve(key, raw)
+	v := &Value{val: resolved, raw: resolved}
+	o.cache.Store(key, &overlayValue{raw: raw, value: v})
+	return v
+}
+
+func (o *overlay) resolve(key, raw string) string {
+	env := make(map[string]string)
+	for _, kv := range o.environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	raw = _placeholder.ReplaceAllStringFunc(raw, func(s string) string {
+		sub := _placeholder.FindStringSubmatch(s)
+		if v, ok := env[sub[1]]; ok {
+			return v
+		}
+		if strings.Contains(s, ":") {
+			return sub[2]
+		}
+		return s
+	})
+	format := FormatOf(key)
+	if format == "" {
+		return raw
+	}
+	prefix := envName(strings.TrimSuffix(key, key[strings.LastIndexByte(key, '.'):])) + "__"
+	var overrides [][2]string
+	for name, v := range env {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			overrides = append(overrides, [2]string{name[len(prefix):], v})
+		}
+	}
+	if len(overrides) == 0 {
+		return raw
+	}
+	doc, err := unmarshalMap(format, []byte(raw))
+	if err != nil {
+		log.Printf("paladin: overlay key %s unmarshal %s error: %s, env overrides skipped", key, format, err)
+		return raw
+	}
+	for _, override := range overrides {
+		if !set(doc, strings.Split(override[0], "__"), override[1]) {
+			log.Printf("paladin: overlay key %s env %s%s can't be set, skipped", key, prefix, override[0])
+		}
+	}
+	b, err := marshalMap(format, doc)
+	if err != nil {
+		log.Printf("paladin: overlay key %s marshal %s error: %s, env overrides skipped", key, format, err)
+		return raw
+	}
+	return string(b)
+}
+
+// envName converts the name to the env var style, e.g. demo-app.v1 is DEMO_APP_V1.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// set sets the value of path in doc, the names of path match the keys of doc
+// in the env var style, the value is converted to the type of the old value.
+func set(doc map[string]interface{}, path []string, value string) bool {
+	for i, name := range path {
+		if name == "" {
+			return false
+		}
+		key := strings.ToLower(name)
+		for k := range doc {
+			if envName(k) == strings.ToUpper(name) {
+				key = k
+				break
+			}
+		}
+		if i == len(path)-1 {
+			doc[key] = convert(doc[key], value)
+			return true
+		}
+		next, ok := doc[key]
+		if !ok {
+			next = map[string]interface{}{}
+			doc[key] = next
+		}
+		if doc, ok = next.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return false
+}
+
+func convert(old interface{}, value string) interface{} {
+	switch old.(type) {
+	case bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case int64:
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case float64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case []interface{}:
+		// NOTE: the elements are converted to the type of the first old element.
+		var elem interface{}
+		if olds := old.([]interface{}); len(olds) > 0 {
+			elem = olds[0]
+		}
+		var s []interface{}
+		for _, v := range strings.Split(value, ",") {
+			s = append(s, convert(elem, strings.TrimSpace(v)))
+		}
+		return s
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package paladin_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlay(t *testing.T) {
+	os.Setenv("PALADIN_TEST_DSN", "root:secret@tcp(127.0.0.1:3306)/demo")
+	os.Setenv("OVERLAY__SERVER__TIMEOUT", "2s")
+	os.Setenv("OVERLAY__SERVER__ENABLED", "false")
+	os.Setenv("OVERLAY__CLIENT__ADDRS", "a,b")
+	os.Setenv("OVERLAY_YML__REDIS__ADDR", "127.0.0.1:6380")
+	defer func() {
+		for _, k := range []string{"PALADIN_TEST_DSN", "OVERLAY__SERVER__TIMEOUT", "OVERLAY__SERVER__ENABLED", "OVERLAY__CLIENT__ADDRS", "OVERLAY_YML__REDIS__ADDR"} {
+			os.Unsetenv(k)
+		}
+	}()
+	mock := paladin.NewMock(map[string]string{
+		"overlay.toml": `
+dsn = "${PALADIN_TEST_DSN}"
+name = "${PALADIN_TEST_NAME:demo}"
+raw = "${PALADIN_TEST_UNSET}"
+[Server]
+timeout = "1s"
+enabled = true
+[client]
+addrs = ["x"]
+`,
+		"overlay_yml.yml": "redis:\n  addr: 127.0.0.1:6379\n  idle: 10\n",
+		"plain":           "${PALADIN_TEST_NAME:plain}",
+	}).(*paladin.Mock)
+	cli := paladin.NewOverlay(mock)
+
+	var conf struct {
+		DSN    string
+		Name   string
+		Raw    string
+		Server struct {
+			Timeout string
+			Enabled bool
+		}
+		Client struct {
+			Addrs []string
+		}
+	}
+	assert.Nil(t, cli.Get("overlay.toml").UnmarshalTOML(&conf))
+	assert.Equal(t, "root:secret@tcp(127.0.0.1:3306)/demo", conf.DSN)
+	assert.Equal(t, "demo", conf.Name)
+	assert.Equal(t, "${PALADIN_TEST_UNSET}", conf.Raw)
+	assert.Equal(t, "2s", conf.Server.Timeout)
+	assert.False(t, conf.Server.Enabled)
+	assert.Equal(t, []string{"a", "b"}, conf.Client.Addrs)
+
+	var redis struct {
+		Redis struct {
+			Addr string
+			Idle int
+		}
+	}
+	assert.Nil(t, cli.Get("overlay_yml.yml").UnmarshalYAML(&redis))
+	assert.Equal(t, "127.0.0.1:6380", redis.Redis.Addr)
+	assert.Equal(t, 10, redis.Redis.Idle)
+
+	plain, err := cli.GetAll().Get("plain").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", plain)
+
+	events := cli.WatchEvent(context.TODO(), "plain")
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "plain", Value: "${PALADIN_TEST_NAME:updated}"}
+	event := <-events
+	assert.Equal(t, "updated", event.Value)
+}
@@ -0,0 +1,267 @@
+package paladin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSecretProvider secret provider not found.
+var ErrNoSecretProvider = errors.New("paladin: secret provider not found")
+
+// _secret matches ENC(ciphertext) or ENC(provider:ciphertext).
+var _secret = regexp.MustCompile(`ENC\(([^()\s]+)\)`)
+
+var (
+	confSecretKey, confSecretKeyFile string
+
+	secretMu        sync.RWMutex
+	secretProviders = make(map[string]SecretProvider)
+)
+
+func init() {
+	flag.StringVar(&confSecretKeyFile, "conf.secret.keyfile", os.Getenv("PALADIN_SECRET_KEY_FILE"), "AES key file of the ENC() secrets in config, or use PALADIN_SECRET_KEY_FILE env variable.")
+	confSecretKey = os.Getenv("PALADIN_SECRET_KEY")
+}
+
+// SecretProvider encrypts and decrypts the secrets of the ENC() references in
+// config values, e.g. AES-GCM with a local key or a KMS.
+type SecretProvider interface {
+	// Encrypt encrypts plaintext and returns the ciphertext inside ENC().
+	Encrypt(plaintext []byte) (string, error)
+	// Decrypt decrypts the ciphertext inside ENC().
+	Decrypt(ciphertext string) ([]byte, error)
+}
+
+// RegisterSecretProvider registers the provider by name, the references of
+// ENC(name:ciphertext) are decrypted by the provider of name, and ENC(ciphertext)
+// by the provider of empty name. The AES-GCM provider keyed from the
+// PALADIN_SECRET_KEY env var or the -conf.secret.keyfile file is used if no
+// provider of empty name is registered.
+func RegisterSecretProvider(name string, p SecretProvider) {
+	secretMu.Lock()
+	secretProviders[name] = p
+	secretMu.Unlock()
+}
+
+func secretProvider(name string) (p SecretProvider, err error) {
+	secretMu.RLock()
+	p, ok := secretProviders[name]
+	secretMu.RUnlock()
+	if ok {
+		return
+	}
+	if name != "" {
+		return nil, errors.Wrapf(ErrNoSecretProvider, "name(%s)", name)
+	}
+	var key []byte
+	switch {
+	case confSecretKey != "":
+		key, err = decodeAESKey(confSecretKey)
+	case confSecretKeyFile != "":
+		key, err = LoadAESKey(confSecretKeyFile)
+	default:
+		return nil, errors.Wrap(ErrNoSecretProvider, "set PALADIN_SECRET_KEY or -conf.secret.keyfile")
+	}
+	if err != nil {
+		return
+	}
+	if p, err = NewAESProvider(key); err != nil {
+		return
+	}
+	RegisterSecretProvider("", p)
+	return
+}
+
+// EncryptSecret encrypts plaintext by the provider of name and returns the
+// ENC() reference which can be put in config values.
+func EncryptSecret(name, plaintext string) (string, error) {
+	p, err := secretProvider(name)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := p.Encrypt([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	if name != "" {
+		ciphertext = name + ":" + ciphertext
+	}
+	return "ENC(" + ciphertext + ")", nil
+}
+
+// DecryptSecrets replaces the ENC() references in text with the plaintexts.
+func DecryptSecrets(text string) (string, error) {
+	if !strings.Contains(text, "ENC(") {
+		return text, nil
+	}
+	var err error
+	text = _secret.ReplaceAllStringFunc(text, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		var name, ciphertext = "", _secret.FindStringSubmatch(ref)[1]
+		if i := strings.IndexByte(ciphertext, ':'); i >= 0 {
+			name, ciphertext = ciphertext[:i], ciphertext[i+1:]
+		}
+		var (
+			p         SecretProvider
+			plaintext []byte
+		)
+		if p, err = secretProvider(name); err != nil {
+			return ref
+		}
+		if plaintext, err = p.Decrypt(ciphertext); err != nil {
+			err = errors.Wrapf(err, "paladin: decrypt secret(%s)", ref)
+			return ref
+		}
+		return string(plaintext)
+	})
+	return text, err
+}
+
+// decryptText decrypts the ENC() secrets in the text of the format, the text
+// of a known format is decoded, decrypted and encoded again so that the
+// plaintexts are escaped, the others are replaced as is.
+func decryptText(format, text string) (string, error) {
+	if format == "" || !strings.Contains(text, "ENC(") {
+		return DecryptSecrets(text)
+	}
+	raws, err := unmarshalMap(format, []byte(text))
+	if err != nil {
+		// NOTE: e.g. the yaml of a top level list, it's left to the setter.
+		return DecryptSecrets(text)
+	}
+	if err = decryptValue(reflect.ValueOf(raws)); err != nil {
+		return "", err
+	}
+	b, err := marshalMap(format, raws)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(b), nil
+}
+
+// decryptValue decrypts the ENC() secrets in the strings of the decoded v,
+// the plaintexts needn't be escaped as they are not in the text any more.
+func decryptValue(v reflect.Value) (err error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			err = decryptValue(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return
+		}
+		// NOTE: the value in the interface isn't settable, decrypt a copy.
+		e := reflect.New(v.Elem().Type()).Elem()
+		e.Set(v.Elem())
+		if err = decryptValue(e); err == nil {
+			v.Set(e)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField() && err == nil; i++ {
+			if f := v.Field(i); f.CanSet() {
+				err = decryptValue(f)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len() && err == nil; i++ {
+			err = decryptValue(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			if err = decryptValue(e); err != nil {
+				return
+			}
+			v.SetMapIndex(k, e)
+		}
+	case reflect.String:
+		if v.CanSet() && strings.Contains(v.String(), "ENC(") {
+			var s string
+			if s, err = DecryptSecrets(v.String()); err == nil {
+				v.SetString(s)
+			}
+		}
+	}
+	return
+}
+
+type aesProvider struct {
+	aead cipher.AEAD
+}
+
+// NewAESProvider new an AES-GCM secret provider, the key is 16, 24 or 32
+// bytes for AES-128, AES-192 or AES-256, the ciphertext is the base64 of the
+// nonce and the sealed plaintext.
+func NewAESProvider(key []byte) (SecretProvider, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "paladin: invalid aes key")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &aesProvider{aead: aead}, nil
+}
+
+func (p *aesProvider) Encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, p.aead.NonceSize(), p.aead.NonceSize()+len(plaintext)+p.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.StdEncoding.EncodeToString(p.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func (p *aesProvider) Decrypt(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(data) < p.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := p.aead.Open(nil, data[:p.aead.NonceSize()], data[p.aead.NonceSize():], nil)
+	return plaintext, errors.WithStack(err)
+}
+
+// GenerateAESKey generates a base64 encoded random AES-256 key.
+func GenerateAESKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// LoadAESKey loads the base64 encoded AES key from the file.
+func LoadAESKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "paladin: read aes key file(%s)", path)
+	}
+	return decodeAESKey(string(data))
+}
+
+func decodeAESKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "paladin: aes key must be base64 encoded")
+	}
+	return key, nil
+}
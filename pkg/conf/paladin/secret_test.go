@@ -0,0 +1,132 @@
+package paladin_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type base64Provider struct{}
+
+func (base64Provider) Encrypt(plaintext []byte) (string, error) {
+	return base64.StdEncoding.EncodeToString(plaintext), nil
+}
+
+func (base64Provider) Decrypt(ciphertext string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(ciphertext)
+}
+
+func TestSecret(t *testing.T) {
+	_, err := paladin.NewAESProvider([]byte("short"))
+	assert.NotNil(t, err)
+	_, err = paladin.DecryptSecrets("ENC(kms:c2VjcmV0)")
+	assert.NotNil(t, err)
+
+	key, err := paladin.GenerateAESKey()
+	assert.Nil(t, err)
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	p, err := paladin.NewAESProvider(raw)
+	assert.Nil(t, err)
+	paladin.RegisterSecretProvider("", p)
+	paladin.RegisterSecretProvider("kms", base64Provider{})
+
+	enc, err := paladin.EncryptSecret("", "p@ssw0rd")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(enc, "ENC("))
+	assert.NotContains(t, enc, "p@ssw0rd")
+	again, _ := paladin.EncryptSecret("", "p@ssw0rd")
+	assert.NotEqual(t, enc, again)
+	kms, err := paladin.EncryptSecret("kms", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, "ENC(kms:dG9rZW4=)", kms)
+
+	m := paladin.NewMock(map[string]string{
+		"password": enc,
+		"db.toml":  "dsn = \"root:" + enc + "@tcp(127.0.0.1:3306)/db\"\ntoken = \"" + kms + "\"",
+		"plain":    "ENC is not a secret",
+		"broken":   "ENC(bm90IGEgc2VjcmV0)",
+	})
+	s, err := m.Get("password").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "p@ssw0rd", s)
+	s, err = m.Get("plain").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "ENC is not a secret", s)
+	_, err = m.Get("broken").Text()
+	assert.NotNil(t, err)
+	s, err = m.Get("password").Raw()
+	assert.Nil(t, err)
+	assert.Equal(t, enc, s)
+
+	var db struct {
+		DSN   string `toml:"dsn"`
+		Token string `toml:"token"`
+	}
+	assert.Nil(t, m.Get("db.toml").UnmarshalTOML(&db))
+	assert.Equal(t, "root:p@ssw0rd@tcp(127.0.0.1:3306)/db", db.DSN)
+	assert.Equal(t, "token", db.Token)
+
+	var tm paladin.TOML
+	assert.Nil(t, tm.Set("password = \""+enc+"\""))
+	s, err = tm.Get("password").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "p@ssw0rd", s)
+}
+
+type textSetter struct {
+	text string
+}
+
+func (s *textSetter) Set(text string) error {
+	s.text = text
+	return nil
+}
+
+func TestSecretEscape(t *testing.T) {
+	paladin.RegisterSecretProvider("kms", base64Provider{})
+	plain := "pa\"ss\\wo'rd\nline2"
+	enc, err := paladin.EncryptSecret("kms", plain)
+	assert.Nil(t, err)
+
+	type db struct {
+		DSN  string            `toml:"dsn" json:"dsn" yaml:"dsn"`
+		Tags []string          `toml:"tags" json:"tags" yaml:"tags"`
+		Meta map[string]string `toml:"meta" json:"meta" yaml:"meta"`
+	}
+	m := paladin.NewMock(map[string]string{
+		"db.toml": "dsn = \"root:" + enc + "@tcp\"\ntags = [\"" + enc + "\"]\n[meta]\npassword = '" + enc + "'\n",
+		"db.json": `{"dsn": "root:` + enc + `@tcp", "tags": ["` + enc + `"], "meta": {"password": "` + enc + `"}}`,
+		"db.yaml": "dsn: root:" + enc + "@tcp\ntags:\n  - " + enc + "\nmeta:\n  password: '" + enc + "'\n",
+	})
+	for _, key := range []string{"db.toml", "db.json", "db.yaml"} {
+		var d db
+		if !assert.Nil(t, m.Get(key).UnmarshalFormat(paladin.FormatOf(key), &d), key) {
+			continue
+		}
+		assert.Equal(t, "root:"+plain+"@tcp", d.DSN, key)
+		assert.Equal(t, []string{plain}, d.Tags, key)
+		assert.Equal(t, plain, d.Meta["password"], key)
+	}
+	var conf map[string]interface{}
+	assert.Nil(t, m.Get("db.json").UnmarshalJSON(&conf))
+	assert.Equal(t, plain, conf["meta"].(map[string]interface{})["password"])
+
+	// the watched text of a known format is encoded with the plaintexts.
+	client := paladin.DefaultClient
+	paladin.DefaultClient = m
+	defer func() { paladin.DefaultClient = client }()
+	for _, key := range []string{"db.toml", "db.json", "db.yaml"} {
+		s := &textSetter{}
+		if !assert.Nil(t, paladin.Watch(key, s), key) {
+			continue
+		}
+		assert.NotContains(t, s.text, "ENC(", key)
+		var d db
+		assert.Nil(t, paladin.NewValue(s.text, s.text).UnmarshalFormat(paladin.FormatOf(key), &d), key)
+		assert.Equal(t, "root:"+plain+"@tcp", d.DSN, key)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding"
 	"encoding/json"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -90,7 +91,7 @@ func (v *Value) Float64() (float64, error) {
 	return f, nil
 }
 
-// String return string value.
+// String return string value, the ENC() secrets are decrypted.
 func (v *Value) String() (string, error) {
 	if v.val == nil {
 		return "", ErrNotExist
@@ -99,7 +100,7 @@ func (v *Value) String() (string, error) {
 	if !ok {
 		return "", ErrTypeAssertion
 	}
-	return s, nil
+	return DecryptSecrets(s)
 }
 
 // Duration parses a duration string. A duration string is a possibly signed sequence of decimal numbers
@@ -112,7 +113,7 @@ func (v *Value) Duration() (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
-// Raw return raw value.
+// Raw return raw value, the ENC() secrets are kept as is.
 func (v *Value) Raw() (string, error) {
 	if v.val == nil {
 		return "", ErrNotExist
@@ -120,6 +121,15 @@ func (v *Value) Raw() (string, error) {
 	return v.raw, nil
 }
 
+// Text return raw value, the ENC() secrets are replaced by the plaintexts as
+// is, use the Unmarshal methods to decode the toml, json or yaml values.
+func (v *Value) Text() (string, error) {
+	if v.val == nil {
+		return "", ErrNotExist
+	}
+	return DecryptSecrets(v.raw)
+}
+
 // Slice scan a slice interface, if slice has element it will be discard.
 func (v *Value) Slice(dst interface{}) error {
 	// NOTE: val is []interface{}, slice is []type
@@ -150,7 +160,7 @@ func (v *Value) Slice(dst interface{}) error {
 
 // Unmarshal is the interface implemented by an object that can unmarshal a textual representation of itself.
 func (v *Value) Unmarshal(un encoding.TextUnmarshaler) error {
-	text, err := v.Raw()
+	text, err := v.Text()
 	if err != nil {
 		return err
 	}
@@ -159,27 +169,30 @@ func (v *Value) Unmarshal(un encoding.TextUnmarshaler) error {
 
 // UnmarshalTOML unmarhsal toml to struct.
 func (v *Value) UnmarshalTOML(dst interface{}) error {
-	text, err := v.Raw()
-	if err != nil {
-		return err
-	}
-	return toml.Unmarshal([]byte(text), dst)
+	return v.unmarshal(toml.Unmarshal, dst)
 }
 
 // UnmarshalJSON unmarhsal json to struct.
 func (v *Value) UnmarshalJSON(dst interface{}) error {
-	text, err := v.Raw()
-	if err != nil {
-		return err
-	}
-	return json.Unmarshal([]byte(text), dst)
+	return v.unmarshal(json.Unmarshal, dst)
 }
 
 // UnmarshalYAML unmarshal yaml to struct.
 func (v *Value) UnmarshalYAML(dst interface{}) error {
-	text, err := v.Raw()
-	if err != nil {
+	return v.unmarshal(yaml.Unmarshal, dst)
+}
+
+// unmarshal unmarshals the raw value to dst, then decrypts the ENC() secrets
+// in the strings of dst, so the plaintexts needn't be escaped in the format.
+func (v *Value) unmarshal(unmarshal func([]byte, interface{}) error, dst interface{}) error {
+	if v.val == nil {
+		return ErrNotExist
+	}
+	if err := unmarshal([]byte(v.raw), dst); err != nil {
 		return err
 	}
-	return yaml.Unmarshal([]byte(text), dst)
+	if !strings.Contains(v.raw, "ENC(") {
+		return nil
+	}
+	return decryptValue(reflect.ValueOf(dst))
 }
@@ -0,0 +1,16 @@
+package paladin
+
+// YAML is yaml map.
+type YAML struct {
+	Map
+}
+
+// Set set the map by value.
+func (m *YAML) Set(text string) error {
+	return m.UnmarshalText([]byte(text))
+}
+
+// UnmarshalText implemented yaml.
+func (m *YAML) UnmarshalText(text []byte) error {
+	return m.storeFormat(FormatYAML, text)
+}
@@ -27,6 +27,9 @@ func (db *DB) nodeList() (nodes []string) {
 		return
 	}
 	for _, in := range ins {
+		if in.Status == naming.StatusWaiting {
+			continue
+		}
 		for _, addr := range in.Addrs {
 			if strings.HasPrefix(addr, _schema) {
 				addr = strings.Replace(addr, _schema, "", -1)
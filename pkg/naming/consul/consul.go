@@ -0,0 +1,604 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+)
+
+const (
+	_registerURL   = "%s/v1/agent/service/register"
+	_deregisterURL = "%s/v1/agent/service/deregister/%s"
+	_serviceURL    = "%s/v1/agent/service/%s"
+	_passURL       = "%s/v1/agent/check/pass/service:%s"
+	_healthURL     = "%s/v1/health/service/%s"
+
+	_timeout    = 5 * time.Second
+	_minBackoff = 100 * time.Millisecond
+	_maxBackoff = 10 * time.Second
+
+	// the meta keys of the instance fields, the others are the metadata.
+	_metaRegion   = "region"
+	_metaZone     = "zone"
+	_metaEnv      = "env"
+	_metaHostname = "hostname"
+	_metaVersion  = "version"
+	_metaAddrs    = "addrs"
+	_metaStatus   = "status"
+
+	_statusWarning  = "warning"
+	_statusCritical = "critical"
+)
+
+var (
+	_ naming.Builder  = &ConsulBuilder{}
+	_ naming.Registry = &ConsulBuilder{}
+
+	// ErrDuplication is a register duplication err
+	ErrDuplication = errors.New("consul: instance duplicate registration")
+	// ErrNotFound the service or check isn't registered in the consul agent.
+	ErrNotFound = errors.New("consul: not found")
+)
+
+var (
+	addr, token, datacenter string
+
+	_once    sync.Once
+	_builder naming.Builder
+)
+
+func init() {
+	addFlag(flag.CommandLine)
+}
+
+func addFlag(fs *flag.FlagSet) {
+	fs.StringVar(&addr, "consul.addr", defaultString("CONSUL_HTTP_ADDR", "127.0.0.1:8500"), "consul http address or use CONSUL_HTTP_ADDR env variable. value: 127.0.0.1:8500 etc.")
+	fs.StringVar(&token, "consul.token", os.Getenv("CONSUL_HTTP_TOKEN"), "consul acl token or use CONSUL_HTTP_TOKEN env variable.")
+	fs.StringVar(&datacenter, "consul.datacenter", os.Getenv("CONSUL_DATACENTER"), "consul datacenter or use CONSUL_DATACENTER env variable.")
+}
+
+func defaultString(env, value string) string {
+	v := os.Getenv(env)
+	if v == "" {
+		return value
+	}
+	return v
+}
+
+// Config consul naming config.
+type Config struct {
+	// Addr is the http address of the consul agent, e.g. 127.0.0.1:8500.
+	Addr       string
+	Token      string
+	Datacenter string
+	// TTL is the TTL check of the registered instances which is passed every
+	// TTL/3, it's used if neither HTTPCheck nor GRPCCheck is set.
+	TTL time.Duration
+	// HTTPCheck is the path checked by consul on the http address of the
+	// registered instances, e.g. /metrics.
+	HTTPCheck string
+	// GRPCCheck makes consul check the grpc health service on the grpc
+	// address of the registered instances.
+	GRPCCheck bool
+	// CheckInterval is the interval of the HTTP and gRPC checks.
+	CheckInterval time.Duration
+	// DeregisterAfter deregisters the instances which are critical longer than it.
+	DeregisterAfter time.Duration
+	// Wait is the wait time of the blocking queries.
+	Wait time.Duration
+}
+
+type agentCheck struct {
+	CheckID                        string `json:",omitempty"`
+	TTL                            string `json:",omitempty"`
+	HTTP                           string `json:",omitempty"`
+	GRPC                           string `json:",omitempty"`
+	Interval                       string `json:",omitempty"`
+	DeregisterCriticalServiceAfter string `json:",omitempty"`
+}
+
+type agentService struct {
+	ID      string
+	Name    string
+	Tags    []string          `json:",omitempty"`
+	Address string            `json:",omitempty"`
+	Port    int               `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Check   *agentCheck       `json:",omitempty"`
+}
+
+type serviceEntry struct {
+	Node struct {
+		Node       string
+		Address    string
+		Datacenter string
+	}
+	Service struct {
+		ID      string
+		Service string
+		Tags    []string
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+	Checks []struct {
+		Status string
+	}
+}
+
+// Builder return default consul resolver builder.
+func Builder(c *Config) naming.Builder {
+	_once.Do(func() {
+		_builder, _ = New(c)
+	})
+	return _builder
+}
+
+// Build register resolver into default consul.
+func Build(c *Config, id string) naming.Resolver {
+	return Builder(c).Build(id)
+}
+
+// ConsulBuilder is the consul naming builder and registry, the instances
+// are registered to the local consul agent and resolved by the blocking
+// queries of the health api.
+type ConsulBuilder struct {
+	c          *Config
+	client     *http.Client
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	mutex    sync.RWMutex
+	apps     map[string]*appInfo
+	registry map[string]struct{}
+}
+
+type appInfo struct {
+	resolver map[*Resolve]struct{}
+	ins      atomic.Value
+	e        *ConsulBuilder
+	once     sync.Once
+}
+
+// Resolve consul resolver.
+type Resolve struct {
+	id    string
+	event chan struct{}
+	e     *ConsulBuilder
+}
+
+// New new a consul builder.
+func New(c *Config) (e *ConsulBuilder, err error) {
+	if c == nil {
+		c = &Config{Addr: addr, Token: token, Datacenter: datacenter}
+	}
+	if c.Addr == "" {
+		return nil, errors.New("consul: invalid config addr")
+	}
+	cc := *c
+	if !strings.Contains(cc.Addr, "://") {
+		cc.Addr = "http://" + cc.Addr
+	}
+	cc.Addr = strings.TrimSuffix(cc.Addr, "/")
+	if cc.TTL <= 0 {
+		cc.TTL = 30 * time.Second
+	}
+	if cc.CheckInterval <= 0 {
+		cc.CheckInterval = 10 * time.Second
+	}
+	if cc.DeregisterAfter <= 0 {
+		cc.DeregisterAfter = time.Minute
+	}
+	if cc.Wait <= 0 {
+		cc.Wait = 55 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e = &ConsulBuilder{
+		c:          &cc,
+		client:     &http.Client{},
+		ctx:        ctx,
+		cancelFunc: cancel,
+		apps:       map[string]*appInfo{},
+		registry:   map[string]struct{}{},
+	}
+	return
+}
+
+// Build consul resolver builder.
+func (e *ConsulBuilder) Build(appid string) naming.Resolver {
+	r := &Resolve{
+		id:    appid,
+		e:     e,
+		event: make(chan struct{}, 1),
+	}
+	e.mutex.Lock()
+	app, ok := e.apps[appid]
+	if !ok {
+		app = &appInfo{
+			resolver: make(map[*Resolve]struct{}),
+			e:        e,
+		}
+		e.apps[appid] = app
+	}
+	app.resolver[r] = struct{}{}
+	e.mutex.Unlock()
+	if ok {
+		select {
+		case r.event <- struct{}{}:
+		default:
+		}
+	}
+	app.once.Do(func() {
+		go app.watch(appid)
+		log.Info("consul: AddWatch(%s) already watch(%v)", appid, ok)
+	})
+	return r
+}
+
+// Scheme return consul's scheme
+func (e *ConsulBuilder) Scheme() string {
+	return "consul"
+}
+
+// Register registers the instance to the consul agent, the instance is
+// renewed until cancel is called.
+func (e *ConsulBuilder) Register(ctx context.Context, ins *naming.Instance) (cancelFunc context.CancelFunc, err error) {
+	e.mutex.Lock()
+	if _, ok := e.registry[ins.AppID]; ok {
+		err = ErrDuplication
+	} else {
+		e.registry[ins.AppID] = struct{}{}
+	}
+	e.mutex.Unlock()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	if err = e.register(ctx, ins); err != nil {
+		e.mutex.Lock()
+		delete(e.registry, ins.AppID)
+		e.mutex.Unlock()
+		cancel()
+		return
+	}
+	ch := make(chan struct{}, 1)
+	cancelFunc = context.CancelFunc(func() {
+		cancel()
+		<-ch
+	})
+	go func() {
+		interval := e.c.TTL / 3
+		if e.c.HTTPCheck != "" || e.c.GRPCCheck {
+			interval = e.c.CheckInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// NOTE: register again if the agent lost the service, e.g. the
+				// agent restarted without its data dir.
+				if err := e.renew(ctx, ins); err == ErrNotFound {
+					log.Warn("consul: renew appid(%s) hostname(%s) not found, register again", ins.AppID, ins.Hostname)
+					_ = e.register(ctx, ins)
+				}
+			case <-ctx.Done():
+				_ = e.deregister(ins)
+				e.mutex.Lock()
+				delete(e.registry, ins.AppID)
+				e.mutex.Unlock()
+				ch <- struct{}{}
+				return
+			}
+		}
+	}()
+	return
+}
+
+func (e *ConsulBuilder) register(ctx context.Context, ins *naming.Instance) (err error) {
+	svc := &agentService{
+		ID:   ins.Hostname,
+		Name: ins.AppID,
+		Meta: map[string]string{},
+	}
+	for k, v := range ins.Metadata {
+		svc.Meta[k] = v
+	}
+	svc.Meta[_metaRegion] = ins.Region
+	svc.Meta[_metaZone] = ins.Zone
+	svc.Meta[_metaEnv] = ins.Env
+	svc.Meta[_metaHostname] = ins.Hostname
+	svc.Meta[_metaVersion] = ins.Version
+	svc.Meta[_metaAddrs] = strings.Join(ins.Addrs, ",")
+	if ins.Status != 0 {
+		svc.Meta[_metaStatus] = strconv.FormatInt(ins.Status, 10)
+	}
+	check := &agentCheck{
+		CheckID:                        "service:" + ins.Hostname,
+		DeregisterCriticalServiceAfter: e.c.DeregisterAfter.String(),
+	}
+	for _, a := range ins.Addrs {
+		u, err := url.Parse(a)
+		if err != nil {
+			continue
+		}
+		if svc.Address == "" {
+			svc.Address = u.Hostname()
+			svc.Port, _ = strconv.Atoi(u.Port())
+		}
+		svc.Tags = append(svc.Tags, u.Scheme)
+		switch {
+		case u.Scheme == "http" && e.c.HTTPCheck != "" && check.HTTP == "":
+			check.HTTP = "http://" + u.Host + "/" + strings.TrimPrefix(e.c.HTTPCheck, "/")
+			check.Interval = e.c.CheckInterval.String()
+		case u.Scheme == "grpc" && e.c.GRPCCheck && check.GRPC == "":
+			check.GRPC = u.Host
+			check.Interval = e.c.CheckInterval.String()
+		}
+	}
+	if check.HTTP == "" && check.GRPC == "" {
+		check.TTL = e.c.TTL.String()
+	}
+	svc.Check = check
+	if err = e.do(ctx, http.MethodPut, fmt.Sprintf(_registerURL, e.c.Addr), svc, nil); err != nil {
+		log.Error("consul: register appid(%s) hostname(%s) error(%v)", ins.AppID, ins.Hostname, err)
+		return
+	}
+	if check.TTL != "" {
+		err = e.renew(ctx, ins)
+	}
+	return
+}
+
+// renew passes the TTL check, or checks the service is still registered.
+func (e *ConsulBuilder) renew(ctx context.Context, ins *naming.Instance) (err error) {
+	if e.c.HTTPCheck != "" || e.c.GRPCCheck {
+		err = e.do(ctx, http.MethodGet, fmt.Sprintf(_serviceURL, e.c.Addr, url.PathEscape(ins.Hostname)), nil, nil)
+	} else {
+		err = e.do(ctx, http.MethodPut, fmt.Sprintf(_passURL, e.c.Addr, url.PathEscape(ins.Hostname)), nil, nil)
+	}
+	if err != nil && err != ErrNotFound {
+		log.Error("consul: renew appid(%s) hostname(%s) error(%v)", ins.AppID, ins.Hostname, err)
+	}
+	return
+}
+
+func (e *ConsulBuilder) deregister(ins *naming.Instance) (err error) {
+	if err = e.do(context.Background(), http.MethodPut, fmt.Sprintf(_deregisterURL, e.c.Addr, url.PathEscape(ins.Hostname)), nil, nil); err != nil {
+		log.Error("consul: deregister appid(%s) hostname(%s) error(%v)", ins.AppID, ins.Hostname, err)
+		return
+	}
+	log.Info("consul: deregister appid(%s) hostname(%s) success", ins.AppID, ins.Hostname)
+	return
+}
+
+// do sends the request with the json body and decodes the json response to res.
+func (e *ConsulBuilder) do(ctx context.Context, method, uri string, body, res interface{}) (err error) {
+	_, err = e.query(ctx, method, uri, body, res, _timeout)
+	return
+}
+
+// query is do with the timeout, it returns the X-Consul-Index of the response.
+func (e *ConsulBuilder) query(ctx context.Context, method, uri string, body, res interface{}, timeout time.Duration) (index uint64, err error) {
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return
+	}
+	if e.c.Token != "" {
+		req.Header.Set("X-Consul-Token", e.c.Token)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return 0, fmt.Errorf("consul: %s %s status %d", method, uri, resp.StatusCode)
+	}
+	if res != nil {
+		if err = json.NewDecoder(resp.Body).Decode(res); err != nil {
+			return
+		}
+	}
+	index, _ = strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return
+}
+
+// Close stop all running process including the watches and the registrations.
+func (e *ConsulBuilder) Close() error {
+	e.cancelFunc()
+	return nil
+}
+
+// watch watches the instances of appID by the blocking queries.
+func (a *appInfo) watch(appID string) {
+	var (
+		index   uint64
+		backoff = _minBackoff
+	)
+	for {
+		newIndex, err := a.fetchstore(appID, index)
+		if err != nil {
+			if a.e.ctx.Err() != nil {
+				return
+			}
+			log.Error("consul: watch appid(%s) error(%v), retry after %s", appID, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-a.e.ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > _maxBackoff {
+				backoff = _maxBackoff
+			}
+			continue
+		}
+		backoff = _minBackoff
+		index = newIndex
+	}
+}
+
+func (a *appInfo) fetchstore(appID string, index uint64) (newIndex uint64, err error) {
+	params := url.Values{}
+	if a.e.c.Datacenter != "" {
+		params.Set("dc", a.e.c.Datacenter)
+	}
+	timeout := _timeout
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", fmt.Sprintf("%dms", a.e.c.Wait/time.Millisecond))
+		// consul adds a jitter of wait/16 to the blocking query.
+		timeout += a.e.c.Wait + a.e.c.Wait/16
+	}
+	var entries []*serviceEntry
+	uri := fmt.Sprintf(_healthURL, a.e.c.Addr, url.PathEscape(appID)) + "?" + params.Encode()
+	if newIndex, err = a.e.query(a.e.ctx, http.MethodGet, uri, nil, &entries, timeout); err != nil {
+		return
+	}
+	if newIndex < index {
+		// NOTE: reset the index if it goes backwards, e.g. the consul servers
+		// are restored, so that the next query doesn't block forever.
+		return 0, nil
+	}
+	if newIndex == index {
+		return
+	}
+	ins := &naming.InstancesInfo{
+		Instances: make(map[string][]*naming.Instance),
+		LastTs:    int64(newIndex),
+	}
+	for _, entry := range entries {
+		if in, ok := instance(entry); ok {
+			ins.Instances[in.Zone] = append(ins.Instances[in.Zone], in)
+		}
+	}
+	a.store(ins)
+	return
+}
+
+// instance converts the service entry to the instance, the critical entries
+// are skipped and the warning entries are waiting.
+func instance(entry *serviceEntry) (ins *naming.Instance, ok bool) {
+	ins = &naming.Instance{
+		AppID:    entry.Service.Service,
+		Hostname: entry.Service.ID,
+		Metadata: map[string]string{},
+		Status:   naming.StatusUP,
+	}
+	for k, v := range entry.Service.Meta {
+		switch k {
+		case _metaRegion:
+			ins.Region = v
+		case _metaZone:
+			ins.Zone = v
+		case _metaEnv:
+			ins.Env = v
+		case _metaHostname:
+			ins.Hostname = v
+		case _metaVersion:
+			ins.Version = v
+		case _metaAddrs:
+			if v != "" {
+				ins.Addrs = strings.Split(v, ",")
+			}
+		case _metaStatus:
+			ins.Status, _ = strconv.ParseInt(v, 10, 64)
+		default:
+			ins.Metadata[k] = v
+		}
+	}
+	if len(ins.Addrs) == 0 {
+		// NOTE: the services aren't registered by atreus, the tags are the
+		// schemes of the address, both http and grpc if no tags.
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		hostport := net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))
+		schemes := entry.Service.Tags
+		if len(schemes) == 0 {
+			schemes = []string{"http", "grpc"}
+		}
+		for _, scheme := range schemes {
+			ins.Addrs = append(ins.Addrs, scheme+"://"+hostport)
+		}
+	}
+	for _, check := range entry.Checks {
+		switch check.Status {
+		case _statusCritical:
+			return nil, false
+		case _statusWarning:
+			ins.Status = naming.StatusWaiting
+		}
+	}
+	return ins, true
+}
+
+func (a *appInfo) store(ins *naming.InstancesInfo) {
+	a.ins.Store(ins)
+	a.e.mutex.RLock()
+	for rs := range a.resolver {
+		select {
+		case rs.event <- struct{}{}:
+		default:
+		}
+	}
+	a.e.mutex.RUnlock()
+}
+
+// Watch watch instance.
+func (r *Resolve) Watch() <-chan struct{} {
+	return r.event
+}
+
+// Fetch fetch resolver instance.
+func (r *Resolve) Fetch(ctx context.Context) (ins *naming.InstancesInfo, ok bool) {
+	r.e.mutex.RLock()
+	app, ok := r.e.apps[r.id]
+	r.e.mutex.RUnlock()
+	if ok {
+		ins, ok = app.ins.Load().(*naming.InstancesInfo)
+		return
+	}
+	return
+}
+
+// Close close resolver.
+func (r *Resolve) Close() error {
+	r.e.mutex.Lock()
+	if app, ok := r.e.apps[r.id]; ok && len(app.resolver) != 0 {
+		delete(app.resolver, r)
+	}
+	r.e.mutex.Unlock()
+	return nil
+}
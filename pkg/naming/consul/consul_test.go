@@ -0,0 +1,134 @@
+package consul
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/naming/consul/internal/mockserver"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitInstances(t *testing.T, r naming.Resolver, fn func(*naming.InstancesInfo) bool) *naming.InstancesInfo {
+	deadline := time.After(5 * time.Second)
+	for {
+		if ins, ok := r.Fetch(context.Background()); ok && fn(ins) {
+			return ins
+		}
+		select {
+		case <-r.Watch():
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("wait instances timeout")
+		}
+	}
+}
+
+func count(ins *naming.InstancesInfo) (n int) {
+	for _, zins := range ins.Instances {
+		n += len(zins)
+	}
+	return
+}
+
+func TestConsul(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Token = "secret"
+	b, err := New(&Config{Addr: srv.URL(), Token: "secret", TTL: 300 * time.Millisecond, Wait: time.Second})
+	assert.Nil(t, err)
+	defer b.Close()
+
+	r := b.Build("demo")
+	defer r.Close()
+	ins := &naming.Instance{
+		Region:   "sh",
+		Zone:     "sh001",
+		Env:      "dev",
+		AppID:    "demo",
+		Hostname: "demo-1",
+		Addrs:    []string{"grpc://127.0.0.1:9000", "http://127.0.0.1:8000"},
+		Version:  "v1",
+		Metadata: map[string]string{naming.MetaWeight: "20", naming.MetaColor: "red"},
+	}
+	cancel, err := b.Register(context.Background(), ins)
+	assert.Nil(t, err)
+	_, err = b.Register(context.Background(), ins)
+	assert.Equal(t, ErrDuplication, err)
+
+	svc, check, ok := srv.Service("demo-1")
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1", svc.Address)
+	assert.Equal(t, 9000, svc.Port)
+	assert.Equal(t, "300ms", check.TTL)
+
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	got := res.Instances["sh001"][0]
+	assert.Equal(t, ins.Addrs, got.Addrs)
+	assert.Equal(t, "v1", got.Version)
+	assert.Equal(t, "sh", got.Region)
+	assert.Equal(t, int64(naming.StatusUP), got.Status)
+	assert.Equal(t, map[string]string{naming.MetaWeight: "20", naming.MetaColor: "red"}, got.Metadata)
+
+	// register again after the agent lost the service.
+	srv.Reset()
+	assert.Eventually(t, func() bool {
+		_, check, ok := srv.Service("demo-1")
+		return ok && check.Passes > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool {
+		return count(ins) == 1 && ins.Instances["sh001"][0].Status == naming.StatusUP
+	})
+
+	// the services which aren't registered by atreus.
+	srv.Register(&mockserver.Service{ID: "other", Name: "demo", Tags: []string{"http"}, Address: "10.0.0.1", Port: 80})
+	res = waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 2 })
+	assert.Equal(t, []string{"http://10.0.0.1:80"}, res.Instances[""][0].Addrs)
+
+	cancel()
+	_, _, ok = srv.Service("demo-1")
+	assert.False(t, ok)
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	_, err = b.Register(context.Background(), ins)
+	assert.Nil(t, err)
+}
+
+func TestConsulHealthCheck(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	b, err := New(&Config{Addr: srv.URL(), HTTPCheck: "/metrics", GRPCCheck: true, CheckInterval: 100 * time.Millisecond})
+	assert.Nil(t, err)
+	defer b.Close()
+	cancel, err := b.Register(context.Background(), &naming.Instance{
+		AppID:    "demo",
+		Hostname: "demo-1",
+		Addrs:    []string{"grpc://127.0.0.1:9000", "http://127.0.0.1:8000"},
+	})
+	assert.Nil(t, err)
+	defer cancel()
+	_, check, ok := srv.Service("demo-1")
+	assert.True(t, ok)
+	assert.Equal(t, "", check.TTL)
+	assert.Equal(t, "http://127.0.0.1:8000/metrics", check.HTTP)
+	assert.Equal(t, "127.0.0.1:9000", check.GRPC)
+	assert.Equal(t, "100ms", check.Interval)
+
+	// the warning instances are waiting and the critical ones are removed.
+	r := b.Build("demo")
+	defer r.Close()
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	srv.SetStatus("demo-1", "warning")
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool {
+		return count(ins) == 1 && ins.Instances[""][0].Status == naming.StatusWaiting
+	})
+	srv.SetStatus("demo-1", "critical")
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 0 })
+
+	srv.Reset()
+	assert.Eventually(t, func() bool {
+		_, _, ok := srv.Service("demo-1")
+		return ok
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,243 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service is the registered service.
+type Service struct {
+	ID      string
+	Name    string `json:"Service"`
+	Tags    []string
+	Address string
+	Port    int
+	Meta    map[string]string
+}
+
+// Check is the registered check of the service.
+type Check struct {
+	TTL      string
+	HTTP     string
+	GRPC     string
+	Interval string
+	// Status is passing, warning or critical.
+	Status string
+	// Passes is the number of the TTL check passes.
+	Passes int
+}
+
+type entry struct {
+	Node    map[string]string
+	Service *Service
+	Checks  []map[string]string
+}
+
+// Server is a consul agent http server which supports the service
+// registration, the TTL checks and the blocking queries of the health api.
+type Server struct {
+	server *httptest.Server
+
+	lock     sync.Mutex
+	index    uint64
+	services map[string]*Service
+	checks   map[string]*Check
+	changed  chan struct{}
+	// Token is the required X-Consul-Token if not empty.
+	Token string
+}
+
+// Run runs a mock server on a random local port.
+func Run() *Server {
+	s := &Server{
+		index:    1,
+		services: make(map[string]*Service),
+		checks:   make(map[string]*Check),
+		changed:  make(chan struct{}),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL returns the address of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) notify() {
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Service returns the registered service and its check.
+func (s *Server) Service(id string) (svc Service, check Check, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok = s.services[id]; ok {
+		svc, check = *s.services[id], *s.checks[id]
+	}
+	return
+}
+
+// SetStatus sets the check status of the service.
+func (s *Server) SetStatus(id, status string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if check, ok := s.checks[id]; ok {
+		check.Status = status
+		s.notify()
+	}
+}
+
+// Register registers the service which isn't registered by the agent api.
+func (s *Server) Register(svc *Service) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.services[svc.ID] = svc
+	s.checks[svc.ID] = &Check{Status: "passing"}
+	s.notify()
+}
+
+// Reset forgets all the services, e.g. the agent restarted.
+func (s *Server) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.services = make(map[string]*Service)
+	s.checks = make(map[string]*Check)
+	s.notify()
+}
+
+func (s *Server) handle(rw http.ResponseWriter, req *http.Request) {
+	if s.Token != "" && req.Header.Get("X-Consul-Token") != s.Token {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	path := req.URL.Path
+	switch {
+	case req.Method == http.MethodPut && path == "/v1/agent/service/register":
+		s.register(rw, req)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "/v1/agent/service/deregister/"):
+		s.deregister(rw, strings.TrimPrefix(path, "/v1/agent/service/deregister/"))
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "/v1/agent/check/pass/service:"):
+		s.pass(rw, strings.TrimPrefix(path, "/v1/agent/check/pass/service:"))
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "/v1/agent/service/"):
+		s.service(rw, strings.TrimPrefix(path, "/v1/agent/service/"))
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "/v1/health/service/"):
+		s.health(rw, req, strings.TrimPrefix(path, "/v1/health/service/"))
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) register(rw http.ResponseWriter, req *http.Request) {
+	var reg struct {
+		Service
+		Name  string
+		Check *Check
+	}
+	if err := json.NewDecoder(req.Body).Decode(&reg); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	reg.Service.Name = reg.Name
+	check := reg.Check
+	if check == nil {
+		check = &Check{}
+	}
+	check.Status = "passing"
+	if check.TTL != "" {
+		// NOTE: the TTL check is critical until it's passed.
+		check.Status = "critical"
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.services[reg.ID] = &reg.Service
+	s.checks[reg.ID] = check
+	s.notify()
+}
+
+func (s *Server) deregister(rw http.ResponseWriter, id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.services[id]; !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	delete(s.services, id)
+	delete(s.checks, id)
+	s.notify()
+}
+
+func (s *Server) pass(rw http.ResponseWriter, id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	check, ok := s.checks[id]
+	if !ok || check.TTL == "" {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	check.Passes++
+	if check.Status != "passing" {
+		check.Status = "passing"
+		s.notify()
+	}
+}
+
+func (s *Server) service(rw http.ResponseWriter, id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	svc, ok := s.services[id]
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(rw).Encode(svc)
+}
+
+func (s *Server) health(rw http.ResponseWriter, req *http.Request, name string) {
+	index, _ := strconv.ParseUint(req.FormValue("index"), 10, 64)
+	wait, err := time.ParseDuration(req.FormValue("wait"))
+	if err != nil || wait <= 0 {
+		wait = 5 * time.Minute
+	}
+	s.lock.Lock()
+	if index > 0 && index >= s.index {
+		changed := s.changed
+		s.lock.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return
+		}
+		s.lock.Lock()
+	}
+	defer s.lock.Unlock()
+	entries := make([]*entry, 0)
+	for id, svc := range s.services {
+		if svc.Name != name {
+			continue
+		}
+		entries = append(entries, &entry{
+			Node:    map[string]string{"Node": "mock", "Address": "127.0.0.1", "Datacenter": "dc1"},
+			Service: svc,
+			Checks:  []map[string]string{{"Status": s.checks[id].Status}},
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Service.ID < entries[j].Service.ID })
+	rw.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(entries)
+}
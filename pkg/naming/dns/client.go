@@ -0,0 +1,254 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"crypto/rand"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	_resolvConf = "/etc/resolv.conf"
+	_udpSize    = 4096
+)
+
+var (
+	// ErrNotFound the domain name doesn't exist.
+	ErrNotFound = errors.New("dns: no such host")
+
+	errMismatch = errors.New("dns: mismatched response")
+)
+
+// client is a minimal stub resolver which returns the ttls of the records,
+// which the net.Resolver doesn't.
+type client struct {
+	nameservers []string
+	search      []string
+	ndots       int
+	timeout     time.Duration
+}
+
+// resolvConf is the nameservers, search list and ndots of resolv.conf.
+type resolvConf struct {
+	nameservers []string
+	search      []string
+	ndots       int
+}
+
+// readResolvConf reads the resolv.conf of the path, the nameserver is
+// 127.0.0.1:53 and ndots is 1 by default.
+func readResolvConf(path string) *resolvConf {
+	conf := &resolvConf{ndots: 1}
+	if f, err := os.Open(path); err == nil {
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "nameserver":
+				conf.nameservers = append(conf.nameservers, net.JoinHostPort(fields[1], "53"))
+			case "domain":
+				// NOTE: the last one of domain and search wins.
+				conf.search = fields[1:2]
+			case "search":
+				conf.search = fields[1:]
+			case "options":
+				for _, opt := range fields[1:] {
+					if strings.HasPrefix(opt, "ndots:") {
+						if n, err := strconv.Atoi(opt[len("ndots:"):]); err == nil && n >= 0 {
+							conf.ndots = n
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(conf.nameservers) == 0 {
+		conf.nameservers = []string{"127.0.0.1:53"}
+	}
+	return conf
+}
+
+// names returns the fully qualified names to query like the libc resolver,
+// the name is tried before the search list if it has at least ndots dots.
+func (c *client) names(name string) (names []string) {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	rooted := strings.Count(name, ".") >= c.ndots
+	if rooted {
+		names = append(names, name+".")
+	}
+	for _, suffix := range c.search {
+		names = append(names, name+"."+strings.Trim(suffix, ".")+".")
+	}
+	if !rooted {
+		names = append(names, name+".")
+	}
+	return
+}
+
+// query asks the names of the search list in order until one has the records,
+// the name without records is returned if none has.
+func (c *client) query(ctx context.Context, name string, typ dnsmessage.Type) (msg *dnsmessage.Message, err error) {
+	var empty *dnsmessage.Message
+	for _, fqdn := range c.names(name) {
+		if msg, err = c.queryName(ctx, fqdn, typ); err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if len(msg.Answers) > 0 {
+			return
+		}
+		if empty == nil {
+			empty = msg
+		}
+	}
+	if empty != nil {
+		return empty, nil
+	}
+	return nil, ErrNotFound
+}
+
+// queryName asks the nameservers in order until one answers, NXDOMAIN is ErrNotFound.
+func (c *client) queryName(ctx context.Context, name string, typ dnsmessage.Type) (msg *dnsmessage.Message, err error) {
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return
+	}
+	q := dnsmessage.Question{Name: n, Type: typ, Class: dnsmessage.ClassINET}
+	for _, server := range c.nameservers {
+		if msg, err = c.exchange(ctx, server, q); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	switch msg.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+		return
+	case dnsmessage.RCodeNameError:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("dns: query %s %s rcode %s", name, typ, msg.Header.RCode)
+	}
+}
+
+// exchange sends the question over udp, and over tcp if the response is truncated.
+func (c *client) exchange(ctx context.Context, server string, q dnsmessage.Question) (*dnsmessage.Message, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(make([]byte, 2, 514), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	// NOTE: advertise the udp payload size by edns0.
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var rh dnsmessage.ResourceHeader
+	if err := rh.SetEDNS0(_udpSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(rh, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	req, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	// the first two bytes are the length for tcp.
+	binary.BigEndian.PutUint16(req, uint16(len(req)-2))
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	msg, err := c.roundTrip(ctx, "udp", server, req, id, q)
+	if err == nil && msg.Header.Truncated {
+		msg, err = c.roundTrip(ctx, "tcp", server, req, id, q)
+	}
+	return msg, err
+}
+
+// newID returns a random query id, which is unpredictable against spoofing.
+func newID() (uint16, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
+
+func (c *client) roundTrip(ctx context.Context, network, server string, req []byte, id uint16, q dnsmessage.Question) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	var resp []byte
+	if network == "tcp" {
+		if _, err = conn.Write(req); err != nil {
+			return nil, err
+		}
+		l := make([]byte, 2)
+		if _, err = io.ReadFull(conn, l); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(l))
+		if _, err = io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err = conn.Write(req[2:]); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, _udpSize)
+		for {
+			n, err := conn.Read(resp)
+			if err != nil {
+				return nil, err
+			}
+			// NOTE: skip the responses of the other queries on the same port.
+			var h dnsmessage.Parser
+			hdr, err := h.Start(resp[:n])
+			if err != nil || hdr.ID != id {
+				continue
+			}
+			resp = resp[:n]
+			break
+		}
+	}
+	msg := new(dnsmessage.Message)
+	if err = msg.Unpack(resp); err != nil {
+		return nil, err
+	}
+	if msg.Header.ID != id || !msg.Header.Response || len(msg.Questions) != 1 ||
+		!strings.EqualFold(msg.Questions[0].Name.String(), q.Name.String()) || msg.Questions[0].Type != q.Type {
+		return nil, errMismatch
+	}
+	return msg, nil
+}
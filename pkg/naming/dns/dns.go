@@ -0,0 +1,440 @@
+package dns
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/env"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var (
+	_ naming.Builder = &DNSBuilder{}
+)
+
+var (
+	servers string
+
+	_once    sync.Once
+	_builder naming.Builder
+)
+
+func init() {
+	addFlag(flag.CommandLine)
+}
+
+func addFlag(fs *flag.FlagSet) {
+	fs.StringVar(&servers, "dns.nameservers", os.Getenv("DNS_NAMESERVERS"), "dns nameservers, ip:port separated by commas or use DNS_NAMESERVERS env variable, the ones of /etc/resolv.conf by default.")
+}
+
+// Config dns naming config.
+type Config struct {
+	// Nameservers is the ip:port of the nameservers asked in order.
+	Nameservers []string
+	// Search is the search list of the names with less than Ndots dots,
+	// they're the ones of /etc/resolv.conf if nil. The names ending with
+	// "." are queried as is.
+	Search []string
+	Ndots  int
+	// Zone is the zone of the instances, env.Zone by default.
+	Zone    string
+	Timeout time.Duration
+	// MinRefresh and MaxRefresh clamp the refresh interval which is the
+	// minimal ttl of the records, MinRefresh is also the retry interval.
+	MinRefresh time.Duration
+	MaxRefresh time.Duration
+}
+
+// Builder return default dns naming builder.
+func Builder(c *Config) naming.Builder {
+	_once.Do(func() {
+		_builder = New(c)
+	})
+	return _builder
+}
+
+// Build register resolver into default dns.
+func Build(c *Config, id string) naming.Resolver {
+	return Builder(c).Build(id)
+}
+
+// DNSBuilder is the dns naming builder, the ids are either the host:port
+// whose A/AAAA records are the instances with both http and grpc addrs, or
+// the SRV names like _grpc._tcp.demo.example.com whose service label is the
+// scheme of the addrs. The SRV weights are the instance weights and only the
+// targets of the lowest priority are used.
+// The records are refreshed when their ttls expire.
+type DNSBuilder struct {
+	c          *Config
+	client     *client
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	mutex sync.RWMutex
+	apps  map[string]*appInfo
+}
+
+type appInfo struct {
+	resolver map[*Resolve]struct{}
+	ins      atomic.Value
+	e        *DNSBuilder
+	once     sync.Once
+
+	id string
+	// host and port of the A/AAAA records, or the SRV name and its scheme.
+	host, port string
+	srv        bool
+	scheme     string
+}
+
+// Resolve dns resolver.
+type Resolve struct {
+	id    string
+	event chan struct{}
+	e     *DNSBuilder
+}
+
+// New new a dns builder, the nameservers of the flag or /etc/resolv.conf if c is nil.
+func New(c *Config) (e *DNSBuilder) {
+	if c == nil {
+		c = &Config{}
+	}
+	cc := *c
+	cc.Nameservers = append([]string(nil), cc.Nameservers...)
+	if len(cc.Nameservers) == 0 && servers != "" {
+		cc.Nameservers = strings.Split(servers, ",")
+	}
+	rc := readResolvConf(_resolvConf)
+	if len(cc.Nameservers) == 0 {
+		cc.Nameservers = rc.nameservers
+	}
+	if cc.Search == nil {
+		cc.Search, cc.Ndots = rc.search, rc.ndots
+	}
+	for i, s := range cc.Nameservers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			cc.Nameservers[i] = net.JoinHostPort(s, "53")
+		}
+	}
+	if cc.Zone == "" {
+		cc.Zone = env.Zone
+	}
+	if cc.Timeout <= 0 {
+		cc.Timeout = 2 * time.Second
+	}
+	if cc.MinRefresh <= 0 {
+		cc.MinRefresh = 5 * time.Second
+	}
+	if cc.MaxRefresh < cc.MinRefresh {
+		cc.MaxRefresh = 5 * time.Minute
+		if cc.MaxRefresh < cc.MinRefresh {
+			cc.MaxRefresh = cc.MinRefresh
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DNSBuilder{
+		c:          &cc,
+		client:     &client{nameservers: cc.Nameservers, search: cc.Search, ndots: cc.Ndots, timeout: cc.Timeout},
+		ctx:        ctx,
+		cancelFunc: cancel,
+		apps:       map[string]*appInfo{},
+	}
+}
+
+// Build dns resolver builder, appid is host:port or the SRV name.
+func (e *DNSBuilder) Build(appid string) naming.Resolver {
+	r := &Resolve{
+		id:    appid,
+		e:     e,
+		event: make(chan struct{}, 1),
+	}
+	e.mutex.Lock()
+	app, ok := e.apps[appid]
+	if !ok {
+		app = &appInfo{
+			resolver: make(map[*Resolve]struct{}),
+			e:        e,
+			id:       appid,
+		}
+		if strings.HasPrefix(appid, "_") && (strings.Contains(appid, "._tcp.") || strings.Contains(appid, "._udp.")) {
+			app.srv = true
+			app.host = appid
+			app.scheme = strings.TrimPrefix(appid[:strings.IndexByte(appid, '.')], "_")
+		} else {
+			app.host, app.port, _ = net.SplitHostPort(appid)
+		}
+		e.apps[appid] = app
+	}
+	app.resolver[r] = struct{}{}
+	e.mutex.Unlock()
+	if ok {
+		select {
+		case r.event <- struct{}{}:
+		default:
+		}
+	}
+	app.once.Do(func() {
+		go app.refreshproc()
+		log.Info("dns: AddWatch(%s) already watch(%v)", appid, ok)
+	})
+	return r
+}
+
+// Scheme return dns's scheme
+func (e *DNSBuilder) Scheme() string {
+	return "dns"
+}
+
+// Close stop all running process including the refreshes.
+func (e *DNSBuilder) Close() error {
+	e.cancelFunc()
+	return nil
+}
+
+// refreshproc resolves the records and refreshes them after the min ttl,
+// the last instances are kept on errors.
+func (a *appInfo) refreshproc() {
+	if a.host == "" {
+		log.Error("dns: invalid appid(%s), must be host:port or _scheme._tcp.name", a.id)
+		return
+	}
+	for {
+		ins, ttl, err := a.resolve()
+		interval := a.e.c.MinRefresh
+		if err != nil {
+			if a.e.ctx.Err() != nil {
+				return
+			}
+			log.Error("dns: resolve(%s) error(%v), retry after %s", a.host, err, interval)
+		} else {
+			if interval = ttl; interval < a.e.c.MinRefresh {
+				interval = a.e.c.MinRefresh
+			} else if interval > a.e.c.MaxRefresh {
+				interval = a.e.c.MaxRefresh
+			}
+			a.store(ins)
+		}
+		select {
+		case <-time.After(interval):
+		case <-a.e.ctx.Done():
+			return
+		}
+	}
+}
+
+type target struct {
+	host   string
+	port   string
+	weight uint16
+	ips    []string
+}
+
+// resolve returns the instances and the min ttl of the records.
+func (a *appInfo) resolve() (ins []*naming.Instance, ttl time.Duration, err error) {
+	var (
+		targets []*target
+		minTTL  = uint32(a.e.c.MaxRefresh / time.Second)
+	)
+	if a.srv {
+		if targets, minTTL, err = a.resolveSRV(minTTL); err != nil {
+			return
+		}
+	} else {
+		t := &target{host: a.host, port: a.port}
+		if ip := net.ParseIP(a.host); ip != nil {
+			t.ips = []string{ip.String()}
+		} else if t.ips, minTTL, err = a.resolveIP(a.host, minTTL); err != nil {
+			return
+		}
+		targets = []*target{t}
+	}
+	allZero := true
+	for _, t := range targets {
+		if t.weight > 0 {
+			allZero = false
+		}
+	}
+	for _, t := range targets {
+		for _, ip := range t.ips {
+			hostport := net.JoinHostPort(ip, t.port)
+			in := &naming.Instance{
+				Zone:     a.e.c.Zone,
+				AppID:    a.host,
+				Hostname: t.host,
+				Metadata: map[string]string{},
+				Status:   naming.StatusUP,
+			}
+			if !a.srv || len(t.ips) > 1 {
+				in.Hostname = ip
+			}
+			if a.srv {
+				in.Addrs = []string{a.scheme + "://" + hostport}
+			} else {
+				in.Addrs = []string{"http://" + hostport, "grpc://" + hostport}
+			}
+			// NOTE: the zero weight is the lowest one unless all are zero.
+			if !allZero {
+				w := t.weight
+				if w == 0 {
+					w = 1
+				}
+				in.Metadata[naming.MetaWeight] = strconv.Itoa(int(w))
+			}
+			ins = append(ins, in)
+		}
+	}
+	sort.Slice(ins, func(i, j int) bool { return ins[i].Addrs[0] < ins[j].Addrs[0] })
+	return ins, time.Duration(minTTL) * time.Second, nil
+}
+
+func (a *appInfo) resolveSRV(minTTL uint32) (targets []*target, _ uint32, err error) {
+	msg, err := a.e.client.query(a.e.ctx, a.host, dnsmessage.TypeSRV)
+	if err != nil {
+		if err == ErrNotFound {
+			err = nil
+		}
+		return nil, minTTL, err
+	}
+	priority := -1
+	for _, rr := range msg.Answers {
+		srv, ok := rr.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		if rr.Header.TTL < minTTL {
+			minTTL = rr.Header.TTL
+		}
+		if priority >= 0 && int(srv.Priority) > priority {
+			continue
+		}
+		if int(srv.Priority) < priority || priority < 0 {
+			priority = int(srv.Priority)
+			targets = targets[:0]
+		}
+		targets = append(targets, &target{
+			host:   strings.TrimSuffix(srv.Target.String(), "."),
+			port:   strconv.Itoa(int(srv.Port)),
+			weight: srv.Weight,
+		})
+	}
+	for _, t := range targets {
+		// the addresses of the targets are usually in the additional section.
+		for _, rr := range msg.Additionals {
+			if !strings.EqualFold(strings.TrimSuffix(rr.Header.Name.String(), "."), t.host) {
+				continue
+			}
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				t.ips = append(t.ips, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				t.ips = append(t.ips, net.IP(body.AAAA[:]).String())
+			default:
+				continue
+			}
+			if rr.Header.TTL < minTTL {
+				minTTL = rr.Header.TTL
+			}
+		}
+		if len(t.ips) == 0 {
+			if t.ips, minTTL, err = a.resolveIP(t.host, minTTL); err != nil {
+				return
+			}
+		}
+	}
+	return targets, minTTL, nil
+}
+
+// resolveIP returns the ips of the A and AAAA records of host.
+func (a *appInfo) resolveIP(host string, minTTL uint32) (ips []string, _ uint32, err error) {
+	var found bool
+	for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		msg, err := a.e.client.query(a.e.ctx, host, typ)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, minTTL, err
+		}
+		found = true
+		for _, rr := range msg.Answers {
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				ips = append(ips, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				ips = append(ips, net.IP(body.AAAA[:]).String())
+			default:
+				// NOTE: the CNAMEs are followed by the recursive nameserver.
+				continue
+			}
+			if rr.Header.TTL < minTTL {
+				minTTL = rr.Header.TTL
+			}
+		}
+	}
+	if !found {
+		log.Warn("dns: host(%s) not found", host)
+	}
+	return ips, minTTL, nil
+}
+
+// store stores the instances and notifies the resolvers if they're changed.
+func (a *appInfo) store(inss []*naming.Instance) {
+	ins := &naming.InstancesInfo{
+		Instances: make(map[string][]*naming.Instance),
+		LastTs:    time.Now().Unix(),
+	}
+	for _, in := range inss {
+		ins.Instances[in.Zone] = append(ins.Instances[in.Zone], in)
+	}
+	if old, ok := a.ins.Load().(*naming.InstancesInfo); ok && reflect.DeepEqual(old.Instances, ins.Instances) {
+		return
+	}
+	a.ins.Store(ins)
+	a.e.mutex.RLock()
+	for rs := range a.resolver {
+		select {
+		case rs.event <- struct{}{}:
+		default:
+		}
+	}
+	a.e.mutex.RUnlock()
+}
+
+// Watch watch instance.
+func (r *Resolve) Watch() <-chan struct{} {
+	return r.event
+}
+
+// Fetch fetch resolver instance.
+func (r *Resolve) Fetch(ctx context.Context) (ins *naming.InstancesInfo, ok bool) {
+	r.e.mutex.RLock()
+	app, ok := r.e.apps[r.id]
+	r.e.mutex.RUnlock()
+	if ok {
+		ins, ok = app.ins.Load().(*naming.InstancesInfo)
+		return
+	}
+	return
+}
+
+// Close close resolver.
+func (r *Resolve) Close() error {
+	r.e.mutex.Lock()
+	if app, ok := r.e.apps[r.id]; ok && len(app.resolver) != 0 {
+		delete(app.resolver, r)
+	}
+	r.e.mutex.Unlock()
+	return nil
+}
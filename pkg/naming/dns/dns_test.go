@@ -0,0 +1,154 @@
+package dns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/naming/dns/internal/mockserver"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func waitInstances(t *testing.T, r naming.Resolver, fn func(*naming.InstancesInfo) bool) *naming.InstancesInfo {
+	deadline := time.After(5 * time.Second)
+	for {
+		if ins, ok := r.Fetch(context.Background()); ok && fn(ins) {
+			return ins
+		}
+		select {
+		case <-r.Watch():
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("wait instances timeout")
+		}
+	}
+}
+
+func count(ins *naming.InstancesInfo) (n int) {
+	for _, zins := range ins.Instances {
+		n += len(zins)
+	}
+	return
+}
+
+func TestHost(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Set("demo.example.com", dnsmessage.TypeA, []dnsmessage.Resource{
+		mockserver.A("demo.example.com", 1, "10.0.0.2"),
+		mockserver.A("demo.example.com", 60, "10.0.0.1"),
+	})
+	srv.Set("demo.example.com", dnsmessage.TypeAAAA, []dnsmessage.Resource{
+		mockserver.AAAA("demo.example.com", 60, "fd00::1"),
+	})
+	b := New(&Config{Nameservers: []string{srv.Addr()}, Zone: "sh001", MinRefresh: 100 * time.Millisecond})
+	defer b.Close()
+	assert.Equal(t, "dns", b.Scheme())
+	r := b.Build("demo.example.com:9000")
+	defer r.Close()
+
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 3 })
+	inss := res.Instances["sh001"]
+	assert.Equal(t, "demo.example.com", inss[0].AppID)
+	assert.Equal(t, "10.0.0.1", inss[0].Hostname)
+	assert.Equal(t, []string{"http://10.0.0.1:9000", "grpc://10.0.0.1:9000"}, inss[0].Addrs)
+	assert.Equal(t, int64(naming.StatusUP), inss[0].Status)
+	assert.Equal(t, []string{"http://[fd00::1]:9000", "grpc://[fd00::1]:9000"}, inss[2].Addrs)
+	assert.Empty(t, inss[0].Metadata)
+
+	// the records are refreshed after the min ttl.
+	srv.Set("demo.example.com", dnsmessage.TypeA, []dnsmessage.Resource{mockserver.A("demo.example.com", 1, "10.0.0.3")})
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 2 })
+	queries := srv.Queries("demo.example.com", dnsmessage.TypeA)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, queries, srv.Queries("demo.example.com", dnsmessage.TypeA))
+
+	// the ip literal isn't resolved.
+	ip := b.Build("127.0.0.1:8000")
+	defer ip.Close()
+	res = waitInstances(t, ip, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	assert.Equal(t, []string{"http://127.0.0.1:8000", "grpc://127.0.0.1:8000"}, res.Instances["sh001"][0].Addrs)
+}
+
+func TestSRV(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	name := "_grpc._tcp.demo.example.com"
+	srv.Set(name, dnsmessage.TypeSRV, []dnsmessage.Resource{
+		mockserver.SRV(name, 60, 10, 20, 9000, "demo-1.example.com"),
+		mockserver.SRV(name, 60, 10, 0, 9001, "demo-2.example.com"),
+		mockserver.SRV(name, 60, 20, 50, 9000, "backup.example.com"),
+	}, mockserver.A("demo-1.example.com", 60, "10.0.0.1"))
+	srv.Set("demo-2.example.com", dnsmessage.TypeA, []dnsmessage.Resource{mockserver.A("demo-2.example.com", 60, "10.0.0.2")})
+	srv.Truncate(true)
+	b := New(&Config{Nameservers: []string{srv.Addr()}})
+	defer b.Close()
+	r := b.Build(name)
+	defer r.Close()
+
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 2 })
+	var inss []*naming.Instance
+	for _, zins := range res.Instances {
+		inss = append(inss, zins...)
+	}
+	assert.Equal(t, "demo-1.example.com", inss[0].Hostname)
+	assert.Equal(t, []string{"grpc://10.0.0.1:9000"}, inss[0].Addrs)
+	assert.Equal(t, map[string]string{naming.MetaWeight: "20"}, inss[0].Metadata)
+	assert.Equal(t, "demo-2.example.com", inss[1].Hostname)
+	assert.Equal(t, []string{"grpc://10.0.0.2:9001"}, inss[1].Addrs)
+	assert.Equal(t, map[string]string{naming.MetaWeight: "1"}, inss[1].Metadata)
+	assert.Equal(t, 0, srv.Queries("backup.example.com", dnsmessage.TypeA))
+	assert.Equal(t, 0, srv.Queries("demo-1.example.com", dnsmessage.TypeA))
+
+	// the unknown name has no instances.
+	absent := b.Build("_grpc._tcp.absent.example.com")
+	defer absent.Close()
+	waitInstances(t, absent, func(ins *naming.InstancesInfo) bool { return count(ins) == 0 })
+}
+
+func TestResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "domain example.com\nsearch ns.svc.cluster.local svc.cluster.local\nnameserver 10.0.0.10\noptions ndots:5 timeout:1\n"
+	assert.Nil(t, os.WriteFile(path, []byte(conf), 0644))
+	rc := readResolvConf(path)
+	assert.Equal(t, []string{"10.0.0.10:53"}, rc.nameservers)
+	assert.Equal(t, []string{"ns.svc.cluster.local", "svc.cluster.local"}, rc.search)
+	assert.Equal(t, 5, rc.ndots)
+
+	rc = readResolvConf(filepath.Join(t.TempDir(), "absent"))
+	assert.Equal(t, []string{"127.0.0.1:53"}, rc.nameservers)
+	assert.Equal(t, 1, rc.ndots)
+
+	c := &client{search: []string{"a.com", "b.com."}, ndots: 1}
+	assert.Equal(t, []string{"demo.a.com.", "demo.b.com.", "demo."}, c.names("demo"))
+	assert.Equal(t, []string{"demo.x.", "demo.x.a.com.", "demo.x.b.com."}, c.names("demo.x"))
+	assert.Equal(t, []string{"demo.x."}, c.names("demo.x."))
+}
+
+func TestSearch(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Set("demo.svc.cluster.local", dnsmessage.TypeA, []dnsmessage.Resource{
+		mockserver.A("demo.svc.cluster.local", 60, "10.0.0.1"),
+	})
+	b := New(&Config{
+		Nameservers: []string{srv.Addr()},
+		Search:      []string{"ns.svc.cluster.local", "svc.cluster.local"},
+		Ndots:       5,
+		Zone:        "sh001",
+	})
+	defer b.Close()
+	r := b.Build("demo:9000")
+	defer r.Close()
+
+	// the short name is resolved by the search list.
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	assert.Equal(t, []string{"http://10.0.0.1:9000", "grpc://10.0.0.1:9000"}, res.Instances["sh001"][0].Addrs)
+	assert.Equal(t, 1, srv.Queries("demo.ns.svc.cluster.local", dnsmessage.TypeA))
+	assert.Equal(t, 0, srv.Queries("demo", dnsmessage.TypeA))
+}
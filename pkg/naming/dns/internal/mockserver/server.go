@@ -0,0 +1,212 @@
+package mockserver
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Server is a dns server on udp and tcp of the same local port, which
+// answers the records set by Set.
+type Server struct {
+	udp net.PacketConn
+	tcp net.Listener
+
+	lock     sync.Mutex
+	records  map[string][]dnsmessage.Resource
+	extra    map[string][]dnsmessage.Resource
+	queries  map[string]int
+	truncate bool
+}
+
+// Run runs a mock server on a random local port.
+func Run() *Server {
+	s := &Server{
+		records: make(map[string][]dnsmessage.Resource),
+		extra:   make(map[string][]dnsmessage.Resource),
+		queries: make(map[string]int),
+	}
+	for {
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			panic(err)
+		}
+		tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+		if err != nil {
+			udp.Close()
+			continue
+		}
+		s.udp, s.tcp = udp, tcp
+		break
+	}
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+// Addr returns the address of the server.
+func (s *Server) Addr() string {
+	return s.udp.LocalAddr().String()
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.udp.Close()
+	s.tcp.Close()
+}
+
+func key(name string, typ dnsmessage.Type) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "/" + typ.String()
+}
+
+// Set sets the answers of the name and type, the additionals are returned
+// with the answers, no answers is NXDOMAIN.
+func (s *Server) Set(name string, typ dnsmessage.Type, answers []dnsmessage.Resource, additionals ...dnsmessage.Resource) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(answers) == 0 {
+		delete(s.records, key(name, typ))
+		delete(s.extra, key(name, typ))
+		return
+	}
+	s.records[key(name, typ)] = answers
+	s.extra[key(name, typ)] = additionals
+}
+
+// Truncate truncates the udp responses so that the clients retry over tcp.
+func (s *Server) Truncate(truncate bool) {
+	s.lock.Lock()
+	s.truncate = truncate
+	s.lock.Unlock()
+}
+
+// Queries returns the number of the queries of the name and type.
+func (s *Server) Queries(name string, typ dnsmessage.Type) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.queries[key(name, typ)]
+}
+
+// A returns an A record.
+func A(name string, ttl uint32, ip string) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], net.ParseIP(ip).To4())
+	return dnsmessage.Resource{Header: header(name, dnsmessage.TypeA, ttl), Body: &dnsmessage.AResource{A: a}}
+}
+
+// AAAA returns an AAAA record.
+func AAAA(name string, ttl uint32, ip string) dnsmessage.Resource {
+	var a [16]byte
+	copy(a[:], net.ParseIP(ip).To16())
+	return dnsmessage.Resource{Header: header(name, dnsmessage.TypeAAAA, ttl), Body: &dnsmessage.AAAAResource{AAAA: a}}
+}
+
+// SRV returns a SRV record.
+func SRV(name string, ttl uint32, priority, weight, port uint16, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: header(name, dnsmessage.TypeSRV, ttl),
+		Body:   &dnsmessage.SRVResource{Priority: priority, Weight: weight, Port: port, Target: dnsmessage.MustNewName(fqdn(target))},
+	}
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func header(name string, typ dnsmessage.Type, ttl uint32) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(fqdn(name)), Type: typ, Class: dnsmessage.ClassINET, TTL: ttl}
+}
+
+func (s *Server) answer(req []byte, udp bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(req); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	q := msg.Questions[0]
+	s.lock.Lock()
+	k := key(q.Name.String(), q.Type)
+	s.queries[k]++
+	answers, ok := s.records[k]
+	extra := s.extra[k]
+	truncate := s.truncate && udp
+	s.lock.Unlock()
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.Header.ID, Response: true, RecursionAvailable: true},
+		Questions: msg.Questions,
+	}
+	switch {
+	case truncate:
+		resp.Header.Truncated = true
+	case ok:
+		resp.Answers = answers
+		resp.Additionals = extra
+	case s.exists(q.Name.String()):
+		// NOTE: the name exists without the records of the type.
+	default:
+		resp.Header.RCode = dnsmessage.RCodeNameError
+	}
+	b, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func (s *Server) exists(name string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	prefix := key(name, 0)
+	prefix = prefix[:strings.IndexByte(prefix, '/')+1]
+	for k := range s.records {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.answer(buf[:n], true); resp != nil {
+			s.udp.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *Server) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			l := make([]byte, 2)
+			if _, err := io.ReadFull(conn, l); err != nil {
+				return
+			}
+			req := make([]byte, binary.BigEndian.Uint16(l))
+			if _, err := io.ReadFull(conn, req); err != nil {
+				return
+			}
+			resp := s.answer(req, false)
+			if resp == nil {
+				return
+			}
+			binary.BigEndian.PutUint16(l, uint16(len(resp)))
+			conn.Write(append(l, resp...))
+		}()
+	}
+}
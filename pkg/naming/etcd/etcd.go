@@ -6,8 +6,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,7 +15,9 @@ import (
 
 	"github.com/mapgoo-lab/atreus/pkg/log"
 	"github.com/mapgoo-lab/atreus/pkg/naming"
+
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
 )
 
@@ -27,6 +29,9 @@ var (
 	//Time units is second
 	registerTTL        = 90
 	defaultDialTimeout = 30
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 10 * time.Second
 )
 
 var (
@@ -34,6 +39,8 @@ var (
 	_builder naming.Builder
 	//ErrDuplication is a register duplication err
 	ErrDuplication = errors.New("etcd: instance duplicate registration")
+	// ErrNotRegistered the instance isn't registered.
+	ErrNotRegistered = errors.New("etcd: instance not registered")
 )
 
 func init() {
@@ -75,13 +82,25 @@ type EtcdBuilder struct {
 
 	mutex    sync.RWMutex
 	apps     map[string]*appInfo
-	registry map[string]struct{}
+	registry map[string]*registration
 }
 type appInfo struct {
 	resolver map[*Resolve]struct{}
 	ins      atomic.Value
 	e        *EtcdBuilder
 	once     sync.Once
+
+	// kvs is the instances by key, only used by the watch goroutine.
+	kvs map[string]*naming.Instance
+}
+
+// registration is a registered instance and its current lease.
+type registration struct {
+	key string
+
+	mu    sync.Mutex
+	ins   *naming.Instance
+	lease clientv3.LeaseID
 }
 
 // Resolve etch resolver.
@@ -113,7 +132,7 @@ func New(c *clientv3.Config) (e *EtcdBuilder, err error) {
 		ctx:        ctx,
 		cancelFunc: cancel,
 		apps:       map[string]*appInfo{},
-		registry:   map[string]struct{}{},
+		registry:   map[string]*registration{},
 	}
 	return
 }
@@ -156,77 +175,166 @@ func (e *EtcdBuilder) Scheme() string {
 
 }
 
-// Register is register instance
+// Register is register instance, the instance is kept alive by a lease and
+// registered again with a new lease if the lease is lost, e.g. the session
+// expired while etcd was unreachable.
 func (e *EtcdBuilder) Register(ctx context.Context, ins *naming.Instance) (cancelFunc context.CancelFunc, err error) {
+	in := *ins
+	if in.Status == 0 {
+		in.Status = naming.StatusUP
+	}
+	reg := &registration{key: e.keyPrefix(&in), ins: &in}
 	e.mutex.Lock()
 	if _, ok := e.registry[ins.AppID]; ok {
 		err = ErrDuplication
 	} else {
-		e.registry[ins.AppID] = struct{}{}
+		e.registry[ins.AppID] = reg
 	}
 	e.mutex.Unlock()
 	if err != nil {
 		return
 	}
 	ctx, cancel := context.WithCancel(e.ctx)
-	if err = e.register(ctx, ins); err != nil {
+	kach, err := e.register(ctx, reg)
+	if err != nil {
 		e.mutex.Lock()
 		delete(e.registry, ins.AppID)
 		e.mutex.Unlock()
 		cancel()
 		return
 	}
-	ch := make(chan struct{}, 1)
+	ch := make(chan struct{})
 	cancelFunc = context.CancelFunc(func() {
 		cancel()
 		<-ch
 	})
-
 	go func() {
+		e.keepalive(ctx, reg, kach)
+		_ = e.unregister(reg)
+		e.mutex.Lock()
+		delete(e.registry, ins.AppID)
+		e.mutex.Unlock()
+		close(ch)
+	}()
+	return
+}
 
-		ticker := time.NewTicker(time.Duration(registerTTL/3) * time.Second)
-		defer ticker.Stop()
+// keepalive consumes the keepalive responses, and registers again if the
+// lease is lost until ctx is done.
+func (e *EtcdBuilder) keepalive(ctx context.Context, reg *registration, kach <-chan *clientv3.LeaseKeepAliveResponse) {
+	for {
+		select {
+		case _, ok := <-kach:
+			if ok {
+				continue
+			}
+		case <-ctx.Done():
+			return
+		}
+		backoff := minBackoff
 		for {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn("etcd: lease of key(%s) lost, register again", reg.key)
+			var err error
+			if kach, err = e.register(ctx, reg); err == nil {
+				break
+			}
 			select {
-			case <-ticker.C:
-				_ = e.register(ctx, ins)
+			case <-time.After(backoff):
 			case <-ctx.Done():
-				_ = e.unregister(ins)
-				ch <- struct{}{}
 				return
 			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
 		}
-	}()
-	return
+	}
 }
 
-//注册和续约公用一个操作
-func (e *EtcdBuilder) register(ctx context.Context, ins *naming.Instance) (err error) {
-	prefix := e.keyPrefix(ins)
+// register grants a lease, puts the instance with it and keeps it alive.
+func (e *EtcdBuilder) register(ctx context.Context, reg *registration) (kach <-chan *clientv3.LeaseKeepAliveResponse, err error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.lease = clientv3.NoLease
+	ins, key := reg.ins, reg.key
 	val, _ := json.Marshal(ins)
 
-	ttlResp, err := e.cli.Grant(context.TODO(), int64(registerTTL))
+	ttlResp, err := e.cli.Grant(ctx, int64(registerTTL))
 	if err != nil {
 		log.Error("etcd: register client.Grant(%v) error(%v)", registerTTL, err)
-		return err
+		return
 	}
-	_, err = e.cli.Put(ctx, prefix, string(val), clientv3.WithLease(ttlResp.ID))
+	_, err = e.cli.Put(ctx, key, string(val), clientv3.WithLease(ttlResp.ID))
 	if err != nil {
 		log.Error("etcd: register client.Put(%v) appid(%s) hostname(%s) error(%v)",
-			prefix, ins.AppID, ins.Hostname, err)
-		return err
+			key, ins.AppID, ins.Hostname, err)
+		e.cli.Revoke(context.TODO(), ttlResp.ID)
+		return
 	}
-	return nil
+	if kach, err = e.cli.KeepAlive(ctx, ttlResp.ID); err != nil {
+		log.Error("etcd: register client.KeepAlive(%v) appid(%s) hostname(%s) error(%v)",
+			ttlResp.ID, ins.AppID, ins.Hostname, err)
+		e.cli.Revoke(context.TODO(), ttlResp.ID)
+		return
+	}
+	reg.lease = ttlResp.ID
+	return
 }
-func (e *EtcdBuilder) unregister(ins *naming.Instance) (err error) {
-	prefix := e.keyPrefix(ins)
 
-	if _, err = e.cli.Delete(context.TODO(), prefix); err != nil {
+func (e *EtcdBuilder) unregister(reg *registration) (err error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	ins, key := reg.ins, reg.key
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(defaultDialTimeout)*time.Second)
+	defer cancel()
+	if _, err = e.cli.Delete(ctx, key); err != nil {
 		log.Error("etcd: unregister client.Delete(%v) appid(%s) hostname(%s) error(%v)",
-			prefix, ins.AppID, ins.Hostname, err)
+			key, ins.AppID, ins.Hostname, err)
+		return
+	}
+	if reg.lease != clientv3.NoLease {
+		e.cli.Revoke(ctx, reg.lease)
+		reg.lease = clientv3.NoLease
 	}
 	log.Info("etcd: unregister client.Delete(%v)  appid(%s) hostname(%s) success",
-		prefix, ins.AppID, ins.Hostname)
+		key, ins.AppID, ins.Hostname)
+	return
+}
+
+// Set updates the status, metadata and version of the registered instance
+// of ins.AppID in place, with the same lease.
+func (e *EtcdBuilder) Set(ins *naming.Instance) (err error) {
+	e.mutex.RLock()
+	reg, ok := e.registry[ins.AppID]
+	e.mutex.RUnlock()
+	if !ok {
+		return ErrNotRegistered
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	in := *reg.ins
+	in.Status = ins.Status
+	if in.Status == 0 {
+		in.Status = naming.StatusUP
+	}
+	in.Metadata = ins.Metadata
+	in.Version = ins.Version
+	reg.ins = &in
+	if reg.lease == clientv3.NoLease {
+		// NOTE: it's put when registering again.
+		return
+	}
+	key := reg.key
+	val, _ := json.Marshal(&in)
+	if _, err = e.cli.Put(context.TODO(), key, string(val), clientv3.WithLease(reg.lease)); err != nil {
+		log.Error("etcd: set client.Put(%v) appid(%s) hostname(%s) error(%v)",
+			key, in.AppID, in.Hostname, err)
+		return
+	}
+	log.Info("etcd: set client.Put(%v) appid(%s) hostname(%s) status(%d) metadata(%v) success",
+		key, in.AppID, in.Hostname, in.Status, in.Metadata)
 	return
 }
 
@@ -239,36 +347,131 @@ func (e *EtcdBuilder) Close() error {
 	e.cancelFunc()
 	return nil
 }
+
+// watch fetches the instances then watches the changes from the next
+// revision, the watch is resumed from the last revision if it's broken, and
+// the instances are fetched again if the revision is compacted. The fetch
+// and the resume are retried after the backoff.
 func (a *appInfo) watch(appID string) {
-	_ = a.fetchstore(appID)
-	prefix := fmt.Sprintf("/%s/%s/", etcdPrefix, appID)
-	rch := a.e.cli.Watch(a.e.ctx, prefix, clientv3.WithPrefix())
+	var (
+		rev       int64
+		err       error
+		compacted bool
+		backoff   = minBackoff
+		prefix    = fmt.Sprintf("/%s/%s/", etcdPrefix, appID)
+	)
+	for {
+		if rev == 0 {
+			if rev, err = a.fetchstore(prefix); err != nil {
+				rev = 0
+				log.Warn("etcd: watch(%s) fetch failed, retry after %s", prefix, backoff)
+			}
+		}
+		if rev != 0 {
+			ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(a.e.ctx))
+			rch := a.e.cli.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+			rev, compacted = a.watchFrom(rch, rev, &backoff)
+			cancel()
+		}
+		if a.e.ctx.Err() != nil {
+			return
+		}
+		if compacted {
+			log.Warn("etcd: watch(%s) revision compacted, fetch again", prefix)
+			rev = 0
+			continue
+		}
+		if rev != 0 {
+			log.Warn("etcd: watch(%s) broken, resume from revision(%d) after %s", prefix, rev, backoff)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-a.e.ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// watchFrom applies the events until the watch is broken, it returns the
+// last revision, and compacted is true if the revision is compacted.
+func (a *appInfo) watchFrom(rch clientv3.WatchChan, rev int64, backoff *time.Duration) (int64, bool) {
 	for wresp := range rch {
+		if wresp.CompactRevision != 0 {
+			return rev, true
+		}
+		if err := wresp.Err(); err != nil {
+			log.Error("etcd: watch revision(%d) error(%v)", rev, err)
+			return rev, false
+		}
+		*backoff = minBackoff
+		if len(wresp.Events) == 0 {
+			if wresp.IsProgressNotify() {
+				rev = wresp.Header.Revision
+			}
+			continue
+		}
 		for _, ev := range wresp.Events {
-			if ev.Type == mvccpb.PUT || ev.Type == mvccpb.DELETE {
-				_ = a.fetchstore(appID)
+			switch ev.Type {
+			case mvccpb.PUT:
+				in := new(naming.Instance)
+				if err := json.Unmarshal(ev.Kv.Value, in); err != nil {
+					log.Error("etcd: watch json.Unmarshal(%s) key(%s) error(%v)", ev.Kv.Value, ev.Kv.Key, err)
+					continue
+				}
+				a.kvs[string(ev.Kv.Key)] = in
+			case mvccpb.DELETE:
+				delete(a.kvs, string(ev.Kv.Key))
 			}
+			rev = ev.Kv.ModRevision
 		}
+		a.store()
 	}
+	return rev, false
 }
 
-func (a *appInfo) fetchstore(appID string) (err error) {
-	prefix := fmt.Sprintf("/%s/%s/", etcdPrefix, appID)
+// fetchstore gets all the instances and returns the revision.
+func (a *appInfo) fetchstore(prefix string) (rev int64, err error) {
 	resp, err := a.e.cli.Get(a.e.ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		log.Error("etcd: fetch client.Get(%s) error(%+v)", prefix, err)
-		return err
+		return
 	}
-
-	ins, err := a.paserIns(resp)
-	if err != nil {
-		return err
+	kvs := make(map[string]*naming.Instance, len(resp.Kvs))
+	for _, ev := range resp.Kvs {
+		in := new(naming.Instance)
+		if err := json.Unmarshal(ev.Value, in); err != nil {
+			log.Error("etcd: fetch json.Unmarshal(%s) key(%s) error(%v)", ev.Value, ev.Key, err)
+			continue
+		}
+		kvs[string(ev.Key)] = in
 	}
-	a.store(ins)
-	return nil
+	a.kvs = kvs
+	a.store()
+	return resp.Header.Revision, nil
 }
-func (a *appInfo) store(ins *naming.InstancesInfo) {
 
+// store stores the instances of a.kvs grouped by zone, the status of the
+// instances registered by the older versions is UP.
+func (a *appInfo) store() {
+	ins := &naming.InstancesInfo{
+		Instances: make(map[string][]*naming.Instance),
+		LastTs:    time.Now().Unix(),
+	}
+	keys := make([]string, 0, len(a.kvs))
+	for k := range a.kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		in := *a.kvs[k]
+		if in.Status == 0 {
+			in.Status = naming.StatusUP
+		}
+		ins.Instances[in.Zone] = append(ins.Instances[in.Zone], &in)
+	}
 	a.ins.Store(ins)
 	a.e.mutex.RLock()
 	for rs := range a.resolver {
@@ -280,22 +483,6 @@ func (a *appInfo) store(ins *naming.InstancesInfo) {
 	a.e.mutex.RUnlock()
 }
 
-func (a *appInfo) paserIns(resp *clientv3.GetResponse) (ins *naming.InstancesInfo, err error) {
-	ins = &naming.InstancesInfo{
-		Instances: make(map[string][]*naming.Instance, 0),
-	}
-	for _, ev := range resp.Kvs {
-		in := new(naming.Instance)
-
-		err := json.Unmarshal(ev.Value, in)
-		if err != nil {
-			return nil, err
-		}
-		ins.Instances[in.Zone] = append(ins.Instances[in.Zone], in)
-	}
-	return ins, nil
-}
-
 // Watch watch instance.
 func (r *Resolve) Watch() <-chan struct{} {
 	return r.event
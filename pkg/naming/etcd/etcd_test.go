@@ -2,98 +2,213 @@ package etcd
 
 import (
 	"context"
-	"fmt"
-	"github.com/mapgoo-lab/atreus/pkg/naming"
-	"go.etcd.io/etcd/client/v3"
-	"google.golang.org/grpc"
 	"testing"
 	"time"
-)
 
-func TestNew(t *testing.T) {
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/naming/etcd/internal/mockserver"
 
-	config := &clientv3.Config{
-		Endpoints:   []string{"127.0.0.1:2379"},
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+func newBuilder(t *testing.T, srv *mockserver.Server) *EtcdBuilder {
+	builder, err := New(&clientv3.Config{
+		Endpoints:   []string{srv.Addr()},
 		DialTimeout: time.Second * 3,
-		DialOptions: []grpc.DialOption{grpc.WithBlock()},
-	}
-	builder, err := New(config)
+		DialOptions: append(srv.DialOptions(), grpc.WithBlock()),
+	})
+	assert.Nil(t, err)
+	return builder
+}
 
-	if err != nil {
-		fmt.Println("etcd 连接失败")
-		return
+func waitInstances(t *testing.T, r naming.Resolver, fn func(*naming.InstancesInfo) bool) *naming.InstancesInfo {
+	deadline := time.After(5 * time.Second)
+	for {
+		if ins, ok := r.Fetch(context.Background()); ok && fn(ins) {
+			return ins
+		}
+		select {
+		case <-r.Watch():
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("wait instances timeout")
+		}
 	}
-	app1 := builder.Build("app1")
-
-	go func() {
-		fmt.Printf("Watch \n")
-		for {
-			select {
-			case <-app1.Watch():
-				fmt.Printf("app1 节点发生变化 \n")
-			}
+}
 
-		}
+func count(ins *naming.InstancesInfo) (n int) {
+	for _, zins := range ins.Instances {
+		n += len(zins)
+	}
+	return
+}
 
-	}()
-	time.Sleep(time.Second)
+func TestNew(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	builder := newBuilder(t, srv)
+	defer builder.Close()
 
+	app1 := builder.Build("app1")
+	defer app1.Close()
 	app1Cancel, err := builder.Register(context.Background(), &naming.Instance{
 		AppID:    "app1",
 		Hostname: "h1",
 		Zone:     "z1",
 	})
-
+	assert.Nil(t, err)
+	_, err = builder.Register(context.Background(), &naming.Instance{AppID: "app1", Hostname: "h2"})
+	assert.Equal(t, ErrDuplication, err)
 	app2Cancel, err := builder.Register(context.Background(), &naming.Instance{
 		AppID:    "app2",
 		Hostname: "h5",
 		Zone:     "z3",
 	})
-
-	if err != nil {
-		fmt.Println(err)
-	}
-
+	assert.Nil(t, err)
 	app2 := builder.Build("app2")
+	defer app2.Close()
 
-	go func() {
-		fmt.Println("节点列表")
-		for {
-			fmt.Printf("app1: ")
-			r1, _ := app1.Fetch(context.Background())
-			if r1 != nil {
-				for z, ins := range r1.Instances {
-					fmt.Printf("zone: %s :", z)
-					for _, in := range ins {
-						fmt.Printf("app: %s host %s \n", in.AppID, in.Hostname)
-					}
-				}
-			} else {
-				fmt.Printf("\n")
-			}
-			fmt.Printf("app2: ")
-			r2, _ := app2.Fetch(context.Background())
-			if r2 != nil {
-				for z, ins := range r2.Instances {
-					fmt.Printf("zone: %s :", z)
-					for _, in := range ins {
-						fmt.Printf("app: %s host %s \n", in.AppID, in.Hostname)
-					}
-				}
-			} else {
-				fmt.Printf("\n")
-			}
-			time.Sleep(time.Second)
-		}
-	}()
+	res := waitInstances(t, app1, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	assert.Equal(t, "h1", res.Instances["z1"][0].Hostname)
+	assert.Equal(t, int64(naming.StatusUP), res.Instances["z1"][0].Status)
+	res = waitInstances(t, app2, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	assert.Equal(t, "h5", res.Instances["z3"][0].Hostname)
 
-	time.Sleep(time.Second * 5)
-	fmt.Println("取消app1")
 	app1Cancel()
-
-	time.Sleep(time.Second * 10)
-	fmt.Println("取消app2")
+	waitInstances(t, app1, func(ins *naming.InstancesInfo) bool { return count(ins) == 0 })
 	app2Cancel()
+	waitInstances(t, app2, func(ins *naming.InstancesInfo) bool { return count(ins) == 0 })
+	assert.Equal(t, 0, srv.Leases())
+
+	// register again after cancel.
+	app1Cancel, err = builder.Register(context.Background(), &naming.Instance{AppID: "app1", Hostname: "h1"})
+	assert.Nil(t, err)
+	app1Cancel()
+}
+
+func TestSet(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	builder := newBuilder(t, srv)
+	defer builder.Close()
+	r := builder.Build("app1")
+	defer r.Close()
+
+	ins := &naming.Instance{AppID: "app1", Hostname: "h1", Version: "v1", Metadata: map[string]string{naming.MetaWeight: "10"}}
+	assert.Equal(t, ErrNotRegistered, builder.Set(ins))
+	cancel, err := builder.Register(context.Background(), ins)
+	assert.Nil(t, err)
+	defer cancel()
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+
+	assert.Nil(t, builder.Set(&naming.Instance{
+		AppID:    "app1",
+		Status:   naming.StatusWaiting,
+		Version:  "v2",
+		Metadata: map[string]string{naming.MetaWeight: "20"},
+	}))
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool {
+		return count(ins) == 1 && ins.Instances[""][0].Status == naming.StatusWaiting
+	})
+	in := res.Instances[""][0]
+	assert.Equal(t, "h1", in.Hostname)
+	assert.Equal(t, "v2", in.Version)
+	assert.Equal(t, map[string]string{naming.MetaWeight: "20"}, in.Metadata)
+	// the same lease is used.
+	assert.Equal(t, 1, srv.Leases())
+}
+
+func TestLeaseLost(t *testing.T) {
+	ttl := registerTTL
+	registerTTL = 1
+	defer func() { registerTTL = ttl }()
+	srv := mockserver.Run()
+	defer srv.Close()
+	builder := newBuilder(t, srv)
+	defer builder.Close()
+	r := builder.Build("app1")
+	defer r.Close()
 
-	time.Sleep(30 * time.Second)
+	cancel, err := builder.Register(context.Background(), &naming.Instance{AppID: "app1", Hostname: "h1"})
+	assert.Nil(t, err)
+	defer cancel()
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	// the lease is kept alive.
+	time.Sleep(1500 * time.Millisecond)
+	res, _ := r.Fetch(context.Background())
+	assert.Equal(t, 1, count(res))
+
+	// register again after the session expired.
+	assert.Nil(t, builder.Set(&naming.Instance{AppID: "app1", Status: naming.StatusWaiting}))
+	srv.Expire()
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 0 })
+	res = waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	assert.Equal(t, int64(naming.StatusWaiting), res.Instances[""][0].Status)
+	assert.Equal(t, 1, srv.Leases())
+}
+
+func TestWatchResume(t *testing.T) {
+	// NOTE: the watch is resumed after the backoff.
+	backoff := minBackoff
+	minBackoff = 500 * time.Millisecond
+	defer func() { minBackoff = backoff }()
+	srv := mockserver.Run()
+	defer srv.Close()
+	builder := newBuilder(t, srv)
+	defer builder.Close()
+	r := builder.Build("app1")
+	defer r.Close()
+	other := newBuilder(t, srv)
+	defer other.Close()
+
+	cancel1, err := other.Register(context.Background(), &naming.Instance{AppID: "app1", Hostname: "h1"})
+	assert.Nil(t, err)
+	defer cancel1()
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+	ranges := srv.Ranges()
+
+	// the watch is resumed from the last revision without fetching.
+	srv.CancelWatches()
+	cancel2, err := other.Register(context.Background(), &naming.Instance{AppID: "app2", Hostname: "h2"})
+	assert.Nil(t, err)
+	defer cancel2()
+	assert.Nil(t, other.Set(&naming.Instance{AppID: "app1", Status: naming.StatusWaiting}))
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool {
+		return count(ins) == 1 && ins.Instances[""][0].Status == naming.StatusWaiting
+	})
+	assert.Equal(t, ranges, srv.Ranges())
+
+	// fetch again if the revision is compacted before resuming.
+	srv.CancelWatches()
+	assert.Nil(t, other.Set(&naming.Instance{AppID: "app1", Status: naming.StatusUP}))
+	srv.CompactAll()
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool {
+		return count(ins) == 1 && ins.Instances[""][0].Status == naming.StatusUP
+	})
+	assert.True(t, srv.Ranges() > ranges)
+}
+
+func TestWatchFetchBackoff(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	builder := newBuilder(t, srv)
+	defer builder.Close()
+	other := newBuilder(t, srv)
+	defer other.Close()
+	cancel, err := other.Register(context.Background(), &naming.Instance{AppID: "app1", Hostname: "h1"})
+	assert.Nil(t, err)
+	defer cancel()
+
+	// the failed fetch is retried after the backoff.
+	srv.SetRangeError(rpctypes.ErrGRPCPermissionDenied)
+	ranges := srv.Ranges()
+	r := builder.Build("app1")
+	defer r.Close()
+	time.Sleep(time.Second)
+	assert.True(t, srv.Ranges()-ranges <= 5, srv.Ranges()-ranges)
+	srv.SetRangeError(nil)
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
 }
@@ -0,0 +1,180 @@
+// Package mockserver runs an embedded etcd server for the tests, the faults
+// of the range and watch apis are injected by the client interceptors.
+package mockserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"google.golang.org/grpc"
+)
+
+const (
+	_rangeMethod = "/etcdserverpb.KV/Range"
+	_watchMethod = "/etcdserverpb.Watch/Watch"
+)
+
+// Server is an embedded etcd server.
+type Server struct {
+	etcd *embed.Etcd
+	dir  string
+	cli  *clientv3.Client
+
+	lock     sync.Mutex
+	ranges   int
+	rangeErr error
+	// cancels cancels the watch streams of the clients.
+	cancels map[*context.CancelFunc]struct{}
+}
+
+func freeURL() *url.URL {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer lis.Close()
+	return &url.URL{Scheme: "http", Host: lis.Addr().String()}
+}
+
+// Run runs an etcd server on the random local ports.
+func Run() *Server {
+	dir, err := ioutil.TempDir("", "etcd-mockserver")
+	if err != nil {
+		panic(err)
+	}
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+	cu, pu := freeURL(), freeURL()
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*cu}, []url.URL{*cu}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*pu}, []url.URL{*pu}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		panic(err)
+	}
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		panic("mockserver: etcd isn't ready")
+	}
+	s := &Server{etcd: e, dir: dir, cancels: make(map[*context.CancelFunc]struct{})}
+	if s.cli, err = clientv3.New(clientv3.Config{Endpoints: []string{s.Addr()}, DialTimeout: 3 * time.Second}); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Addr returns the client address of the server.
+func (s *Server) Addr() string {
+	return s.etcd.Clients[0].Addr().String()
+}
+
+// Close closes the server and removes its data.
+func (s *Server) Close() {
+	s.cli.Close()
+	s.etcd.Close()
+	os.RemoveAll(s.dir)
+}
+
+// DialOptions returns the dial options of the clients to count the ranges
+// and inject the faults.
+func (s *Server) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(s.unary),
+		grpc.WithChainStreamInterceptor(s.stream),
+	}
+}
+
+func (s *Server) unary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if method == _rangeMethod {
+		s.lock.Lock()
+		s.ranges++
+		err := s.rangeErr
+		s.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (s *Server) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if method != _watchMethod {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.lock.Lock()
+	s.cancels[&cancel] = struct{}{}
+	s.lock.Unlock()
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		cancel()
+	}
+	return cs, err
+}
+
+// Ranges returns the count of the range requests.
+func (s *Server) Ranges() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.ranges
+}
+
+// SetRangeError sets the error of the range requests, nil to recover.
+func (s *Server) SetRangeError(err error) {
+	s.lock.Lock()
+	s.rangeErr = err
+	s.lock.Unlock()
+}
+
+// CompactAll compacts the revisions until the current revision, the watches
+// from the older revisions are canceled with the compact revision.
+func (s *Server) CompactAll() {
+	// NOTE: the current revision is kept by the compaction, a key is put to
+	// compact the watches from the current revision too.
+	resp, err := s.cli.Put(context.Background(), "/mockserver/compact", "")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = s.cli.Compact(context.Background(), resp.Header.Revision, clientv3.WithCompactPhysical()); err != nil {
+		panic(err)
+	}
+}
+
+// CancelWatches breaks the watch streams of the clients.
+func (s *Server) CancelWatches() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for cancel := range s.cancels {
+		(*cancel)()
+	}
+	s.cancels = make(map[*context.CancelFunc]struct{})
+}
+
+// Expire revokes all the leases like they're expired.
+func (s *Server) Expire() {
+	resp, err := s.cli.Leases(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	for _, l := range resp.Leases {
+		s.cli.Revoke(context.Background(), l.ID)
+	}
+}
+
+// Leases returns the count of the leases.
+func (s *Server) Leases() int {
+	resp, err := s.cli.Leases(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	return len(resp.Leases)
+}
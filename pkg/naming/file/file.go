@@ -0,0 +1,300 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+
+	"github.com/fsnotify/fsnotify"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	_ naming.Builder = &FileBuilder{}
+
+	// ErrNoPath the path of the naming file is empty.
+	ErrNoPath = errors.New("file: naming file path is empty, use -naming.file or NAMING_FILE env variable")
+)
+
+var (
+	path string
+
+	_once    sync.Once
+	_builder naming.Builder
+)
+
+func init() {
+	addFlag(flag.CommandLine)
+}
+
+func addFlag(fs *flag.FlagSet) {
+	fs.StringVar(&path, "naming.file", os.Getenv("NAMING_FILE"), "naming file of the instances or use NAMING_FILE env variable, yaml or json.")
+}
+
+// Builder return default file naming builder, p is the -naming.file flag if empty.
+func Builder(p string) naming.Builder {
+	_once.Do(func() {
+		_builder, _ = New(p)
+	})
+	return _builder
+}
+
+// Build register resolver into default file naming.
+func Build(p string, id string) naming.Resolver {
+	return Builder(p).Build(id)
+}
+
+// FileBuilder is the file naming builder, the file is a map from the appid
+// to its instances in yaml or json (by the .json extension), e.g.
+//
+//	demo.service:
+//	  - zone: sh001
+//	    hostname: demo-1
+//	    addrs: ["grpc://127.0.0.1:9000", "http://127.0.0.1:8000"]
+//	    metadata: {weight: "10", color: "red"}
+//
+// The appid of the instances is the key and the status is UP by default.
+// The file is watched and reloaded on changes, the last good content is kept
+// if the new one is invalid.
+type FileBuilder struct {
+	path       string
+	notify     *fsnotify.Watcher
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	mutex sync.RWMutex
+	raw   []byte
+	conf  map[string][]*naming.Instance
+	apps  map[string]*appInfo
+}
+
+type appInfo struct {
+	resolver map[*Resolve]struct{}
+	ins      atomic.Value
+	e        *FileBuilder
+}
+
+// Resolve file resolver.
+type Resolve struct {
+	id    string
+	event chan struct{}
+	e     *FileBuilder
+}
+
+// New new a file naming builder of the file p, which is loaded at once.
+func New(p string) (e *FileBuilder, err error) {
+	if p == "" {
+		if p = path; p == "" {
+			return nil, ErrNoPath
+		}
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return
+	}
+	e = &FileBuilder{
+		path: p,
+		apps: make(map[string]*appInfo),
+	}
+	if _, err = e.reload(); err != nil {
+		return nil, err
+	}
+	if e.notify, err = fsnotify.NewWatcher(); err != nil {
+		return nil, err
+	}
+	// NOTE: watch the dir because the editors and configmaps replace the
+	// file by renaming, which breaks the watch of the file itself.
+	if err = e.notify.Add(filepath.Dir(p)); err != nil {
+		e.notify.Close()
+		return nil, err
+	}
+	e.ctx, e.cancelFunc = context.WithCancel(context.Background())
+	go e.watchproc()
+	return
+}
+
+// Build file resolver builder.
+func (e *FileBuilder) Build(appid string) naming.Resolver {
+	r := &Resolve{
+		id:    appid,
+		e:     e,
+		event: make(chan struct{}, 1),
+	}
+	e.mutex.Lock()
+	app, ok := e.apps[appid]
+	if !ok {
+		app = &appInfo{
+			resolver: make(map[*Resolve]struct{}),
+			e:        e,
+		}
+		app.ins.Store(e.instances(appid))
+		e.apps[appid] = app
+	}
+	app.resolver[r] = struct{}{}
+	e.mutex.Unlock()
+	r.event <- struct{}{}
+	log.Info("file: AddWatch(%s) already watch(%v)", appid, ok)
+	return r
+}
+
+// Scheme return file's scheme
+func (e *FileBuilder) Scheme() string {
+	return "file"
+}
+
+// Close stop watching the file.
+func (e *FileBuilder) Close() error {
+	e.cancelFunc()
+	return e.notify.Close()
+}
+
+func (e *FileBuilder) watchproc() {
+	for {
+		select {
+		case event, ok := <-e.notify.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Chmod == event.Op {
+				continue
+			}
+			changed, err := e.reload()
+			if err != nil {
+				if !os.IsNotExist(err) {
+					log.Error("file: reload naming file(%s) error(%v), keep the last instances", e.path, err)
+				}
+				continue
+			}
+			if changed {
+				log.Info("file: naming file(%s) reloaded", e.path)
+			}
+		case err, ok := <-e.notify.Errors:
+			if !ok {
+				return
+			}
+			log.Error("file: watch naming file(%s) error(%v)", e.path, err)
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// reload loads the file and notifies the resolvers of the changed apps.
+func (e *FileBuilder) reload() (changed bool, err error) {
+	raw, err := ioutil.ReadFile(e.path)
+	if err != nil {
+		return
+	}
+	e.mutex.RLock()
+	same := e.raw != nil && bytes.Equal(raw, e.raw)
+	e.mutex.RUnlock()
+	if same {
+		return
+	}
+	conf, err := parse(e.path, raw)
+	if err != nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.raw, e.conf = raw, conf
+	for appid, app := range e.apps {
+		ins := e.instances(appid)
+		old, _ := app.ins.Load().(*naming.InstancesInfo)
+		if old != nil && ins != nil && reflect.DeepEqual(old.Instances, ins.Instances) || old == nil && ins == nil {
+			continue
+		}
+		app.ins.Store(ins)
+		for rs := range app.resolver {
+			select {
+			case rs.event <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return true, nil
+}
+
+func parse(p string, raw []byte) (conf map[string][]*naming.Instance, err error) {
+	if strings.EqualFold(filepath.Ext(p), ".json") {
+		err = json.Unmarshal(raw, &conf)
+	} else {
+		// NOTE: the default yaml keys of naming.Instance are the lowercase
+		// field names, which are the same as the json keys.
+		err = yaml.Unmarshal(raw, &conf)
+	}
+	for appid, inss := range conf {
+		for i, ins := range inss {
+			if ins == nil || len(ins.Addrs) == 0 {
+				return nil, errors.New("file: app(" + appid + ") has an instance without addrs")
+			}
+			if ins.AppID == "" {
+				ins.AppID = appid
+			}
+			if ins.Hostname == "" {
+				ins.Hostname = appid + "-" + ins.Addrs[0]
+			}
+			if ins.Status == 0 {
+				ins.Status = naming.StatusUP
+			}
+			inss[i] = ins
+		}
+	}
+	return
+}
+
+// instances returns the instances of the app grouped by zone, nil if the
+// app isn't in the file, e.mutex is held.
+func (e *FileBuilder) instances(appid string) *naming.InstancesInfo {
+	inss, ok := e.conf[appid]
+	if !ok {
+		return nil
+	}
+	res := &naming.InstancesInfo{
+		Instances: make(map[string][]*naming.Instance),
+		LastTs:    time.Now().Unix(),
+	}
+	for _, ins := range inss {
+		res.Instances[ins.Zone] = append(res.Instances[ins.Zone], ins)
+	}
+	return res
+}
+
+// Watch watch instance.
+func (r *Resolve) Watch() <-chan struct{} {
+	return r.event
+}
+
+// Fetch fetch resolver instance, ok is false if the app isn't in the file.
+func (r *Resolve) Fetch(ctx context.Context) (ins *naming.InstancesInfo, ok bool) {
+	r.e.mutex.RLock()
+	app, ok := r.e.apps[r.id]
+	r.e.mutex.RUnlock()
+	if ok {
+		ins, _ = app.ins.Load().(*naming.InstancesInfo)
+		ok = ins != nil
+	}
+	return
+}
+
+// Close close resolver.
+func (r *Resolve) Close() error {
+	r.e.mutex.Lock()
+	if app, ok := r.e.apps[r.id]; ok && len(app.resolver) != 0 {
+		delete(app.resolver, r)
+	}
+	r.e.mutex.Unlock()
+	return nil
+}
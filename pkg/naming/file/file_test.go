@@ -0,0 +1,106 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitInstances(t *testing.T, r naming.Resolver, fn func(*naming.InstancesInfo, bool) bool) *naming.InstancesInfo {
+	deadline := time.After(5 * time.Second)
+	for {
+		if ins, ok := r.Fetch(context.Background()); fn(ins, ok) {
+			return ins
+		}
+		select {
+		case <-r.Watch():
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("wait instances timeout")
+		}
+	}
+}
+
+const _yaml = `
+demo.service:
+  - zone: sh001
+    hostname: demo-1
+    addrs: ["grpc://127.0.0.1:9000", "http://127.0.0.1:8000"]
+    metadata: {weight: "20", color: "red"}
+  - zone: sh002
+    addrs: ["grpc://127.0.0.2:9000"]
+    status: 2
+`
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naming-file")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "naming.yaml")
+	assert.Nil(t, ioutil.WriteFile(p, []byte(_yaml), 0644))
+
+	b, err := New(p)
+	assert.Nil(t, err)
+	defer b.Close()
+	assert.Equal(t, "file", b.Scheme())
+	r := b.Build("demo.service")
+	defer r.Close()
+	res, ok := r.Fetch(context.Background())
+	assert.True(t, ok)
+	in := res.Instances["sh001"][0]
+	assert.Equal(t, "demo.service", in.AppID)
+	assert.Equal(t, "demo-1", in.Hostname)
+	assert.Equal(t, int64(naming.StatusUP), in.Status)
+	assert.Equal(t, []string{"grpc://127.0.0.1:9000", "http://127.0.0.1:8000"}, in.Addrs)
+	assert.Equal(t, map[string]string{naming.MetaWeight: "20", naming.MetaColor: "red"}, in.Metadata)
+	in = res.Instances["sh002"][0]
+	assert.Equal(t, "demo.service-grpc://127.0.0.2:9000", in.Hostname)
+	assert.Equal(t, int64(naming.StatusWaiting), in.Status)
+
+	absent := b.Build("absent")
+	defer absent.Close()
+	_, ok = absent.Fetch(context.Background())
+	assert.False(t, ok)
+
+	// the invalid content is skipped.
+	assert.Nil(t, ioutil.WriteFile(p, []byte("demo.service: [{zone: sh001}]"), 0644))
+	time.Sleep(100 * time.Millisecond)
+	res, ok = r.Fetch(context.Background())
+	assert.True(t, ok)
+	assert.Len(t, res.Instances, 2)
+
+	// replace the file by renaming like the editors.
+	tmp := filepath.Join(dir, "naming.yaml.tmp")
+	assert.Nil(t, ioutil.WriteFile(tmp, []byte("absent:\n  - addrs: [\"grpc://127.0.0.3:9000\"]\n"), 0644))
+	assert.Nil(t, os.Rename(tmp, p))
+	waitInstances(t, r, func(_ *naming.InstancesInfo, ok bool) bool { return !ok })
+	res = waitInstances(t, absent, func(_ *naming.InstancesInfo, ok bool) bool { return ok })
+	assert.Equal(t, []string{"grpc://127.0.0.3:9000"}, res.Instances[""][0].Addrs)
+}
+
+func TestFileJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naming-file")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "naming.json")
+	assert.Nil(t, ioutil.WriteFile(p, []byte(`{"demo": [{"appid": "demo.service", "addrs": ["grpc://127.0.0.1:9000"]}]}`), 0644))
+	b, err := New(p)
+	assert.Nil(t, err)
+	defer b.Close()
+	r := b.Build("demo")
+	defer r.Close()
+	<-r.Watch()
+	res, ok := r.Fetch(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, "demo.service", res.Instances[""][0].AppID)
+
+	_, err = New(filepath.Join(dir, "absent.json"))
+	assert.NotNil(t, err)
+}
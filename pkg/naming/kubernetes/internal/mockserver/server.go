@@ -0,0 +1,318 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is a kubernetes object.
+type Object = map[string]interface{}
+
+type event struct {
+	rv       int64
+	resource string
+	ns       string
+	typ      string
+	obj      Object
+}
+
+// Server is a kubernetes api server which supports the list and watch of
+// the endpointslices, endpoints and pods, and the get and merge patch of
+// the objects.
+type Server struct {
+	server *httptest.Server
+
+	lock      sync.Mutex
+	rv        int64
+	compacted int64
+	// objects is resource/namespace/name -> object.
+	objects map[string]Object
+	events  []*event
+	changed chan struct{}
+	// requests is the count of the requests by method and path.
+	requests map[string]int
+	// Token is the required bearer token if not empty.
+	Token string
+}
+
+// Run runs a mock server on a random local port.
+func Run() *Server {
+	s := &Server{
+		rv:       1,
+		objects:  make(map[string]Object),
+		changed:  make(chan struct{}),
+		requests: make(map[string]int),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL returns the address of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+func key(resource, ns, name string) string {
+	return resource + "/" + ns + "/" + name
+}
+
+func meta(obj Object) map[string]interface{} {
+	m, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		obj["metadata"] = m
+	}
+	return m
+}
+
+func (s *Server) record(resource, ns, typ string, obj Object) {
+	s.rv++
+	meta(obj)["resourceVersion"] = strconv.FormatInt(s.rv, 10)
+	s.events = append(s.events, &event{rv: s.rv, resource: resource, ns: ns, typ: typ, obj: clone(obj)})
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func clone(obj Object) Object {
+	b, _ := json.Marshal(obj)
+	c := Object{}
+	json.Unmarshal(b, &c)
+	return c
+}
+
+// Set creates or updates the object of the resource, e.g. endpointslices,
+// endpoints or pods.
+func (s *Server) Set(resource, ns string, obj Object) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	obj = clone(obj)
+	k := key(resource, ns, meta(obj)["name"].(string))
+	typ := "ADDED"
+	if _, ok := s.objects[k]; ok {
+		typ = "MODIFIED"
+	}
+	s.objects[k] = obj
+	s.record(resource, ns, typ, obj)
+}
+
+// Delete deletes the object of the resource.
+func (s *Server) Delete(resource, ns, name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	k := key(resource, ns, name)
+	if obj, ok := s.objects[k]; ok {
+		delete(s.objects, k)
+		s.record(resource, ns, "DELETED", obj)
+	}
+}
+
+// Get returns the object of the resource.
+func (s *Server) Get(resource, ns, name string) (Object, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	obj, ok := s.objects[key(resource, ns, name)]
+	if !ok {
+		return nil, false
+	}
+	return clone(obj), true
+}
+
+// Requests returns the count of the requests of the method and path.
+func (s *Server) Requests(method, path string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[method+" "+path]
+}
+
+// Compact drops the events so that the watches from the old resource
+// versions are gone.
+func (s *Server) Compact() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.compacted = s.rv
+	s.events = nil
+	// NOTE: close the watches like the api server restarts.
+	s.server.CloseClientConnections()
+}
+
+func (s *Server) handle(rw http.ResponseWriter, req *http.Request) {
+	if s.Token != "" && req.Header.Get("Authorization") != "Bearer "+s.Token {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.lock.Lock()
+	s.requests[req.Method+" "+req.URL.Path]++
+	s.lock.Unlock()
+	path := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	var resource, ns, name string
+	switch {
+	case len(path) == 6 && path[0] == "apis" && path[1] == "discovery.k8s.io" && path[3] == "namespaces":
+		resource, ns = path[5], path[4]
+	case len(path) >= 5 && path[0] == "api" && path[2] == "namespaces":
+		resource, ns = path[4], path[3]
+		if len(path) == 6 {
+			name = path[5]
+		}
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case name != "" && req.Method == http.MethodGet:
+		s.get(rw, resource, ns, name)
+	case name != "" && req.Method == http.MethodPatch:
+		s.patch(rw, req, resource, ns, name)
+	case name == "" && req.Method == http.MethodGet && req.FormValue("watch") != "":
+		s.watch(rw, req, resource, ns)
+	case name == "" && req.Method == http.MethodGet:
+		s.list(rw, req, resource, ns)
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) get(rw http.ResponseWriter, resource, ns, name string) {
+	obj, ok := s.Get(resource, ns, name)
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(rw).Encode(obj)
+}
+
+func (s *Server) patch(rw http.ResponseWriter, req *http.Request, resource, ns, name string) {
+	if req.Header.Get("Content-Type") != "application/merge-patch+json" {
+		rw.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	var patch Object
+	if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	k := key(resource, ns, name)
+	obj, ok := s.objects[k]
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	merge(obj, patch)
+	s.record(resource, ns, "MODIFIED", obj)
+	json.NewEncoder(rw).Encode(obj)
+}
+
+// merge applies the json merge patch.
+func merge(dst, patch map[string]interface{}) {
+	for k, v := range patch {
+		switch pv := v.(type) {
+		case nil:
+			delete(dst, k)
+		case map[string]interface{}:
+			dv, ok := dst[k].(map[string]interface{})
+			if !ok {
+				dv = map[string]interface{}{}
+				dst[k] = dv
+			}
+			merge(dv, pv)
+		default:
+			dst[k] = v
+		}
+	}
+}
+
+// match matches the labelSelector k1=v1,k2=v2 and the fieldSelector metadata.name=v.
+func match(req *http.Request, obj Object) bool {
+	m := meta(obj)
+	if sel := req.FormValue("labelSelector"); sel != "" {
+		labels, _ := m["labels"].(map[string]interface{})
+		for _, s := range strings.Split(sel, ",") {
+			kv := strings.SplitN(s, "=", 2)
+			if len(kv) != 2 || labels[kv[0]] != kv[1] {
+				return false
+			}
+		}
+	}
+	if sel := req.FormValue("fieldSelector"); sel != "" {
+		if strings.TrimPrefix(sel, "metadata.name=") != m["name"] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) list(rw http.ResponseWriter, req *http.Request, resource, ns string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	prefix := key(resource, ns, "")
+	items := make([]Object, 0)
+	for k, obj := range s.objects {
+		if strings.HasPrefix(k, prefix) && match(req, obj) {
+			items = append(items, obj)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return meta(items[i])["name"].(string) < meta(items[j])["name"].(string)
+	})
+	json.NewEncoder(rw).Encode(Object{
+		"metadata": Object{"resourceVersion": strconv.FormatInt(s.rv, 10)},
+		"items":    items,
+	})
+}
+
+func (s *Server) watch(rw http.ResponseWriter, req *http.Request, resource, ns string) {
+	rv, _ := strconv.ParseInt(req.FormValue("resourceVersion"), 10, 64)
+	timeout, _ := strconv.Atoi(req.FormValue("timeoutSeconds"))
+	if timeout <= 0 {
+		timeout = 300
+	}
+	deadline := time.After(time.Duration(timeout) * time.Second)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(rw)
+	flusher, _ := rw.(http.Flusher)
+	for {
+		s.lock.Lock()
+		if rv < s.compacted {
+			s.lock.Unlock()
+			enc.Encode(Object{"type": "ERROR", "object": Object{"kind": "Status", "code": http.StatusGone, "message": "too old resource version"}})
+			return
+		}
+		var events []*event
+		for _, e := range s.events {
+			if e.rv > rv && e.resource == resource && e.ns == ns && match(req, e.obj) {
+				events = append(events, e)
+			}
+		}
+		for _, e := range events {
+			b, _ := json.Marshal(e.obj)
+			enc.Encode(Object{"type": e.typ, "object": json.RawMessage(b)})
+		}
+		rv = s.rv
+		changed := s.changed
+		s.lock.Unlock()
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,891 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+)
+
+const (
+	_serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+	_slicesURL    = "/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices"
+	_endpointsURL = "/api/v1/namespaces/%s/endpoints"
+	_podsURL      = "/api/v1/namespaces/%s/pods"
+	_podURL       = "/api/v1/namespaces/%s/pods/%s"
+	_serviceURL   = "/api/v1/namespaces/%s/services/%s"
+
+	// _prefix is the prefix of the pod labels and annotations of the
+	// instance, e.g. atreus.io/weight, the annotations override the labels.
+	_prefix = "atreus.io/"
+
+	_labelServiceName = "kubernetes.io/service-name"
+	_labelZone        = "topology.kubernetes.io/zone"
+	_labelRegion      = "topology.kubernetes.io/region"
+	_labelVersion     = "app.kubernetes.io/version"
+
+	_timeout      = 5 * time.Second
+	_watchTimeout = 5 * time.Minute
+	_minBackoff   = 100 * time.Millisecond
+	_maxBackoff   = 10 * time.Second
+)
+
+var (
+	_ naming.Builder  = &KubeBuilder{}
+	_ naming.Registry = &KubeBuilder{}
+
+	// ErrDuplication is a register duplication err
+	ErrDuplication = errors.New("kubernetes: instance duplicate registration")
+	// ErrNotFound the resource isn't found.
+	ErrNotFound = errors.New("kubernetes: not found")
+
+	// errGone the resource version of the watch is too old.
+	errGone = errors.New("kubernetes: resource version gone")
+)
+
+var (
+	namespace string
+
+	_once    sync.Once
+	_builder naming.Builder
+)
+
+func init() {
+	addFlag(flag.CommandLine)
+}
+
+func addFlag(fs *flag.FlagSet) {
+	fs.StringVar(&namespace, "kubernetes.namespace", os.Getenv("POD_NAMESPACE"), "kubernetes namespace of the services or use POD_NAMESPACE env variable, the namespace of the pod by default.")
+}
+
+// Config kubernetes naming config.
+type Config struct {
+	// Host is the url of the api server, the in-cluster
+	// https://$KUBERNETES_SERVICE_HOST:$KUBERNETES_SERVICE_PORT by default.
+	Host string
+	// Token is the bearer token, which is read from TokenFile on every
+	// request if it's empty, the service account token by default.
+	Token     string
+	TokenFile string
+	// CAFile is the ca of the api server, the service account ca by default.
+	CAFile   string
+	Insecure bool
+	// Namespace is the namespace of the services whose ids have no namespace.
+	Namespace string
+	// Endpoints watches the core/v1 Endpoints instead of the EndpointSlices,
+	// for the clusters older than v1.21.
+	Endpoints bool
+	// Resync is the interval to refresh the pod labels and annotations, the
+	// pods are listed by the label selector of the service.
+	Resync time.Duration
+	// PodName is the pod annotated by Register, $POD_NAME or the hostname by default.
+	PodName string
+}
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+type objectRef struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type service struct {
+	Spec struct {
+		Selector map[string]string `json:"selector"`
+	} `json:"spec"`
+}
+
+type port struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+type endpointSlice struct {
+	Metadata  objectMeta `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+		Hostname  string     `json:"hostname"`
+		Zone      string     `json:"zone"`
+		TargetRef *objectRef `json:"targetRef"`
+	} `json:"endpoints"`
+	Ports []port `json:"ports"`
+}
+
+type endpointAddress struct {
+	IP        string     `json:"ip"`
+	Hostname  string     `json:"hostname"`
+	TargetRef *objectRef `json:"targetRef"`
+}
+
+type endpoints struct {
+	Metadata objectMeta `json:"metadata"`
+	Subsets  []struct {
+		Addresses         []endpointAddress `json:"addresses"`
+		NotReadyAddresses []endpointAddress `json:"notReadyAddresses"`
+		Ports             []port            `json:"ports"`
+	} `json:"subsets"`
+}
+
+type list struct {
+	Metadata objectMeta        `json:"metadata"`
+	Items    []json.RawMessage `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// endpoint is an address of the EndpointSlices or the Endpoints.
+type endpoint struct {
+	IP       string
+	Hostname string
+	Zone     string
+	Pod      string
+	Ready    bool
+	Ports    []port
+}
+
+// Builder return default kubernetes resolver builder.
+func Builder(c *Config) naming.Builder {
+	_once.Do(func() {
+		_builder, _ = New(c)
+	})
+	return _builder
+}
+
+// Build register resolver into default kubernetes.
+func Build(c *Config, id string) naming.Resolver {
+	return Builder(c).Build(id)
+}
+
+// KubeBuilder is the kubernetes naming builder, the ids are the service
+// names (or name.namespace) whose EndpointSlices are watched, the instances
+// are the endpoints with the labels and annotations of their pods.
+// It's also the registry which annotates the pod with the instance metadata.
+type KubeBuilder struct {
+	c          *Config
+	client     *http.Client
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	mutex    sync.RWMutex
+	apps     map[string]*appInfo
+	registry map[string]struct{}
+}
+
+type appInfo struct {
+	resolver map[*Resolve]struct{}
+	ins      atomic.Value
+	e        *KubeBuilder
+	once     sync.Once
+
+	name, namespace string
+
+	mu sync.Mutex
+	// objects is the endpoints of the EndpointSlices or Endpoints by name.
+	objects map[string][]*endpoint
+	pods    map[string]*objectMeta
+}
+
+// Resolve kubernetes resolver.
+type Resolve struct {
+	id    string
+	event chan struct{}
+	e     *KubeBuilder
+}
+
+// New new a kubernetes builder, the in-cluster config if c is nil.
+func New(c *Config) (e *KubeBuilder, err error) {
+	if c == nil {
+		c = &Config{Namespace: namespace}
+	}
+	cc := *c
+	if cc.Host == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("kubernetes: invalid config host, not in cluster")
+		}
+		cc.Host = "https://" + net.JoinHostPort(host, port)
+	}
+	cc.Host = strings.TrimSuffix(cc.Host, "/")
+	if cc.Token == "" && cc.TokenFile == "" {
+		if _, err := os.Stat(_serviceAccountDir + "token"); err == nil {
+			cc.TokenFile = _serviceAccountDir + "token"
+		}
+	}
+	if cc.CAFile == "" {
+		if _, err := os.Stat(_serviceAccountDir + "ca.crt"); err == nil {
+			cc.CAFile = _serviceAccountDir + "ca.crt"
+		}
+	}
+	if cc.Namespace == "" {
+		if b, err := ioutil.ReadFile(_serviceAccountDir + "namespace"); err == nil {
+			cc.Namespace = strings.TrimSpace(string(b))
+		}
+	}
+	if cc.Namespace == "" {
+		cc.Namespace = "default"
+	}
+	if cc.Resync <= 0 {
+		cc.Resync = 30 * time.Second
+	}
+	if cc.PodName == "" {
+		if cc.PodName = os.Getenv("POD_NAME"); cc.PodName == "" {
+			cc.PodName, _ = os.Hostname()
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: cc.Insecure}
+	if cc.CAFile != "" && !cc.Insecure {
+		pem, err := ioutil.ReadFile(cc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kubernetes: invalid ca file %s", cc.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e = &KubeBuilder{
+		c:          &cc,
+		client:     &http.Client{Transport: transport},
+		ctx:        ctx,
+		cancelFunc: cancel,
+		apps:       map[string]*appInfo{},
+		registry:   map[string]struct{}{},
+	}
+	return
+}
+
+// Build kubernetes resolver builder, appid is the service name or name.namespace.
+func (e *KubeBuilder) Build(appid string) naming.Resolver {
+	r := &Resolve{
+		id:    appid,
+		e:     e,
+		event: make(chan struct{}, 1),
+	}
+	e.mutex.Lock()
+	app, ok := e.apps[appid]
+	if !ok {
+		app = &appInfo{
+			resolver:  make(map[*Resolve]struct{}),
+			e:         e,
+			name:      appid,
+			namespace: e.c.Namespace,
+			objects:   make(map[string][]*endpoint),
+			pods:      make(map[string]*objectMeta),
+		}
+		if i := strings.IndexByte(appid, '.'); i > 0 {
+			app.name, app.namespace = appid[:i], appid[i+1:]
+		}
+		e.apps[appid] = app
+	}
+	app.resolver[r] = struct{}{}
+	e.mutex.Unlock()
+	if ok {
+		select {
+		case r.event <- struct{}{}:
+		default:
+		}
+	}
+	app.once.Do(func() {
+		go app.watch()
+		go app.resync()
+		log.Info("kubernetes: AddWatch(%s) already watch(%v)", appid, ok)
+	})
+	return r
+}
+
+// Scheme return kubernetes's scheme
+func (e *KubeBuilder) Scheme() string {
+	return "kubernetes"
+}
+
+// Register annotates the pod with the metadata, version, env and status of
+// the instance, which are removed when cancel is called. The addresses are
+// the endpoints of the services which select the pod.
+func (e *KubeBuilder) Register(ctx context.Context, ins *naming.Instance) (cancelFunc context.CancelFunc, err error) {
+	e.mutex.Lock()
+	if _, ok := e.registry[ins.AppID]; ok {
+		err = ErrDuplication
+	} else {
+		e.registry[ins.AppID] = struct{}{}
+	}
+	e.mutex.Unlock()
+	if err != nil {
+		return
+	}
+	annotations := map[string]interface{}{}
+	for k, v := range ins.Metadata {
+		annotations[_prefix+k] = v
+	}
+	if ins.Version != "" {
+		annotations[_prefix+"version"] = ins.Version
+	}
+	if ins.Env != "" {
+		annotations[_prefix+"env"] = ins.Env
+	}
+	if ins.Status != 0 {
+		annotations[_prefix+"status"] = strconv.FormatInt(ins.Status, 10)
+	}
+	if err = e.annotate(ctx, annotations); err != nil {
+		e.mutex.Lock()
+		delete(e.registry, ins.AppID)
+		e.mutex.Unlock()
+		log.Error("kubernetes: register appid(%s) pod(%s) error(%v)", ins.AppID, e.c.PodName, err)
+		return
+	}
+	cancelFunc = context.CancelFunc(func() {
+		for k := range annotations {
+			annotations[k] = nil
+		}
+		if err := e.annotate(context.Background(), annotations); err != nil {
+			log.Error("kubernetes: unregister appid(%s) pod(%s) error(%v)", ins.AppID, e.c.PodName, err)
+		}
+		e.mutex.Lock()
+		delete(e.registry, ins.AppID)
+		e.mutex.Unlock()
+	})
+	return
+}
+
+// annotate merges the annotations into the pod, the nil annotations are removed.
+func (e *KubeBuilder) annotate(ctx context.Context, annotations map[string]interface{}) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	}
+	return e.do(ctx, http.MethodPatch, fmt.Sprintf(_podURL, url.PathEscape(e.c.Namespace), url.PathEscape(e.c.PodName)), nil, patch, nil)
+}
+
+// do sends the request with the json body and decodes the json response to res.
+func (e *KubeBuilder) do(ctx context.Context, method, path string, query url.Values, body, res interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, _timeout)
+	defer cancel()
+	resp, err := e.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+func (e *KubeBuilder) request(ctx context.Context, method, path string, query url.Values, body interface{}) (resp *http.Response, err error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	uri := e.c.Host + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+	token := e.c.Token
+	if token == "" && e.c.TokenFile != "" {
+		// NOTE: the bound service account tokens are rotated, read it every time.
+		b, err := ioutil.ReadFile(e.c.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if resp, err = e.client.Do(req.WithContext(ctx)); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if resp.StatusCode == http.StatusGone {
+			return nil, errGone
+		}
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("kubernetes: %s %s status %d: %s", method, path, resp.StatusCode, b)
+	}
+	return
+}
+
+// Close stop all running process including the watches.
+func (e *KubeBuilder) Close() error {
+	e.cancelFunc()
+	return nil
+}
+
+func (a *appInfo) path() (path string, query url.Values) {
+	query = url.Values{}
+	if a.e.c.Endpoints {
+		query.Set("fieldSelector", "metadata.name="+a.name)
+		return fmt.Sprintf(_endpointsURL, url.PathEscape(a.namespace)), query
+	}
+	query.Set("labelSelector", _labelServiceName+"="+a.name)
+	return fmt.Sprintf(_slicesURL, url.PathEscape(a.namespace)), query
+}
+
+// watch lists the endpoints then watches the changes from the resource
+// version, it lists again if the version is gone.
+func (a *appInfo) watch() {
+	backoff := _minBackoff
+	for {
+		rv, err := a.list()
+		for err == nil {
+			backoff = _minBackoff
+			rv, err = a.watchFrom(rv)
+		}
+		if a.e.ctx.Err() != nil {
+			return
+		}
+		if err == errGone {
+			log.Warn("kubernetes: watch service(%s.%s) resource version gone, list again", a.name, a.namespace)
+			continue
+		}
+		log.Error("kubernetes: watch service(%s.%s) error(%v), retry after %s", a.name, a.namespace, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-a.e.ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > _maxBackoff {
+			backoff = _maxBackoff
+		}
+	}
+}
+
+func (a *appInfo) list() (rv string, err error) {
+	path, query := a.path()
+	var l list
+	if err = a.e.do(a.e.ctx, http.MethodGet, path, query, nil, &l); err != nil {
+		return
+	}
+	objects := make(map[string][]*endpoint, len(l.Items))
+	for _, item := range l.Items {
+		name, eps, err := a.parse(item)
+		if err != nil {
+			return "", err
+		}
+		objects[name] = eps
+	}
+	// NOTE: the pods are fetched without a.mu, the resolvers get the
+	// instances with the metadata at the first time.
+	pods, err := a.listPods(podNames(objects))
+	if err != nil {
+		log.Warn("kubernetes: list pods of service(%s.%s) error(%v)", a.name, a.namespace, err)
+	}
+	a.mu.Lock()
+	a.objects = objects
+	if pods != nil {
+		a.pods = pods
+	}
+	a.update()
+	a.mu.Unlock()
+	return l.Metadata.ResourceVersion, nil
+}
+
+// watchFrom watches the changes from rv until the api server closes the
+// watch, it returns the last resource version.
+func (a *appInfo) watchFrom(rv string) (string, error) {
+	path, query := a.path()
+	query.Set("watch", "1")
+	query.Set("resourceVersion", rv)
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", strconv.Itoa(int(_watchTimeout/time.Second)))
+	ctx, cancel := context.WithTimeout(a.e.ctx, _watchTimeout+_timeout)
+	defer cancel()
+	resp, err := a.e.request(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return rv, err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err = dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				return rv, nil
+			}
+			return rv, err
+		}
+		switch event.Type {
+		case "ERROR":
+			var s status
+			json.Unmarshal(event.Object, &s)
+			if s.Code == http.StatusGone {
+				return rv, errGone
+			}
+			return rv, fmt.Errorf("kubernetes: watch error(%d) %s", s.Code, s.Message)
+		case "BOOKMARK":
+			var obj struct {
+				Metadata objectMeta `json:"metadata"`
+			}
+			if err = json.Unmarshal(event.Object, &obj); err != nil {
+				return rv, err
+			}
+			rv = obj.Metadata.ResourceVersion
+			continue
+		}
+		name, eps, err := a.parse(event.Object)
+		if err != nil {
+			return rv, err
+		}
+		// NOTE: the pods of the new endpoints are fetched without a.mu.
+		var (
+			names []string
+			pods  map[string]*objectMeta
+		)
+		a.mu.Lock()
+		for _, ep := range eps {
+			if _, ok := a.pods[ep.Pod]; ep.Pod != "" && !ok && event.Type != "DELETED" {
+				objects := make(map[string][]*endpoint, len(a.objects)+1)
+				for k, v := range a.objects {
+					objects[k] = v
+				}
+				objects[name] = eps
+				names = podNames(objects)
+				break
+			}
+		}
+		a.mu.Unlock()
+		if names != nil {
+			if pods, err = a.listPods(names); err != nil {
+				log.Warn("kubernetes: list pods of service(%s.%s) error(%v)", a.name, a.namespace, err)
+			}
+		}
+		a.mu.Lock()
+		if pods != nil {
+			a.pods = pods
+		}
+		if event.Type == "DELETED" {
+			delete(a.objects, name)
+		} else {
+			a.objects[name] = eps
+		}
+		a.update()
+		a.mu.Unlock()
+		var obj struct {
+			Metadata objectMeta `json:"metadata"`
+		}
+		json.Unmarshal(event.Object, &obj)
+		rv = obj.Metadata.ResourceVersion
+	}
+}
+
+// parse parses the EndpointSlice or Endpoints to the endpoints.
+func (a *appInfo) parse(raw json.RawMessage) (name string, eps []*endpoint, err error) {
+	if a.e.c.Endpoints {
+		var obj endpoints
+		if err = json.Unmarshal(raw, &obj); err != nil {
+			return
+		}
+		for _, subset := range obj.Subsets {
+			for i, addrs := range [][]endpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+				for _, addr := range addrs {
+					ep := &endpoint{IP: addr.IP, Hostname: addr.Hostname, Ready: i == 0, Ports: subset.Ports}
+					if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+						ep.Pod = addr.TargetRef.Name
+					}
+					eps = append(eps, ep)
+				}
+			}
+		}
+		return obj.Metadata.Name, eps, nil
+	}
+	var obj endpointSlice
+	if err = json.Unmarshal(raw, &obj); err != nil {
+		return
+	}
+	for _, e := range obj.Endpoints {
+		for _, ip := range e.Addresses {
+			// NOTE: the endpoint is ready if the condition is unknown.
+			ep := &endpoint{IP: ip, Hostname: e.Hostname, Zone: e.Zone, Ready: e.Conditions.Ready == nil || *e.Conditions.Ready, Ports: obj.Ports}
+			if e.TargetRef != nil && e.TargetRef.Kind == "Pod" {
+				ep.Pod = e.TargetRef.Name
+			}
+			eps = append(eps, ep)
+		}
+	}
+	return obj.Metadata.Name, eps, nil
+}
+
+// resync refreshes the labels and annotations of the pods.
+func (a *appInfo) resync() {
+	ticker := time.NewTicker(a.e.c.Resync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.e.ctx.Done():
+			return
+		}
+		a.mu.Lock()
+		names := podNames(a.objects)
+		a.mu.Unlock()
+		pods, err := a.listPods(names)
+		if err != nil {
+			log.Warn("kubernetes: list pods of service(%s.%s) error(%v)", a.name, a.namespace, err)
+			continue
+		}
+		a.mu.Lock()
+		if !reflect.DeepEqual(a.pods, pods) {
+			a.pods = pods
+			a.update()
+		}
+		a.mu.Unlock()
+	}
+}
+
+// listPods lists the pods by the label selector of the service, the pods
+// names of the endpoints are got one by one if the service has no selector.
+// It's called without a.mu.
+func (a *appInfo) listPods(names []string) (map[string]*objectMeta, error) {
+	var svc service
+	err := a.e.do(a.e.ctx, http.MethodGet, fmt.Sprintf(_serviceURL, url.PathEscape(a.namespace), url.PathEscape(a.name)), nil, nil, &svc)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	pods := make(map[string]*objectMeta)
+	if len(svc.Spec.Selector) > 0 {
+		selector := make([]string, 0, len(svc.Spec.Selector))
+		for k, v := range svc.Spec.Selector {
+			selector = append(selector, k+"="+v)
+		}
+		sort.Strings(selector)
+		var l struct {
+			Items []struct {
+				Metadata objectMeta `json:"metadata"`
+			} `json:"items"`
+		}
+		query := url.Values{"labelSelector": []string{strings.Join(selector, ",")}}
+		if err = a.e.do(a.e.ctx, http.MethodGet, fmt.Sprintf(_podsURL, url.PathEscape(a.namespace)), query, nil, &l); err != nil {
+			return nil, err
+		}
+		for i := range l.Items {
+			meta := &l.Items[i].Metadata
+			// NOTE: the resource version changes on every status change.
+			meta.ResourceVersion = ""
+			pods[meta.Name] = meta
+		}
+		return pods, nil
+	}
+	for _, name := range names {
+		var pod struct {
+			Metadata objectMeta `json:"metadata"`
+		}
+		if err = a.e.do(a.e.ctx, http.MethodGet, fmt.Sprintf(_podURL, url.PathEscape(a.namespace), url.PathEscape(name)), nil, nil, &pod); err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			return nil, err
+		}
+		pod.Metadata.ResourceVersion = ""
+		pods[name] = &pod.Metadata
+	}
+	return pods, nil
+}
+
+// podNames returns the pod names of the endpoints.
+func podNames(objects map[string][]*endpoint) (names []string) {
+	seen := make(map[string]struct{})
+	for _, objs := range objects {
+		for _, ep := range objs {
+			if _, ok := seen[ep.Pod]; ep.Pod != "" && !ok {
+				seen[ep.Pod] = struct{}{}
+				names = append(names, ep.Pod)
+			}
+		}
+	}
+	return
+}
+
+// update stores the instances of the endpoints, a.mu is held.
+func (a *appInfo) update() {
+	var eps []*endpoint
+	for _, objs := range a.objects {
+		eps = append(eps, objs...)
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].IP < eps[j].IP })
+	ins := &naming.InstancesInfo{
+		Instances: make(map[string][]*naming.Instance),
+		LastTs:    time.Now().Unix(),
+	}
+	seen := make(map[string]struct{}, len(eps))
+	for _, ep := range eps {
+		// NOTE: an endpoint may be in two slices during the slice changes.
+		if _, ok := seen[ep.IP]; ok {
+			continue
+		}
+		seen[ep.IP] = struct{}{}
+		in := a.instance(ep)
+		ins.Instances[in.Zone] = append(ins.Instances[in.Zone], in)
+	}
+	a.store(ins)
+}
+
+// instance converts the endpoint to the instance with the pod labels and
+// annotations.
+func (a *appInfo) instance(ep *endpoint) *naming.Instance {
+	ins := &naming.Instance{
+		AppID:    a.name,
+		Hostname: ep.Pod,
+		Zone:     ep.Zone,
+		Metadata: map[string]string{},
+		Status:   naming.StatusUP,
+	}
+	if ins.Hostname == "" {
+		if ins.Hostname = ep.Hostname; ins.Hostname == "" {
+			ins.Hostname = ep.IP
+		}
+	}
+	if !ep.Ready {
+		ins.Status = naming.StatusWaiting
+	}
+	if pod, ok := a.pods[ep.Pod]; ok {
+		if ins.Zone == "" {
+			ins.Zone = pod.Labels[_labelZone]
+		}
+		ins.Region = pod.Labels[_labelRegion]
+		ins.Version = pod.Labels[_labelVersion]
+		for _, kvs := range []map[string]string{pod.Labels, pod.Annotations} {
+			for k, v := range kvs {
+				if !strings.HasPrefix(k, _prefix) {
+					continue
+				}
+				switch k = strings.TrimPrefix(k, _prefix); k {
+				case naming.MetaZone:
+					if ep.Zone == "" {
+						ins.Zone = v
+					}
+				case "region":
+					ins.Region = v
+				case "env":
+					ins.Env = v
+				case "version":
+					ins.Version = v
+				case "status":
+					if s, _ := strconv.ParseInt(v, 10, 64); s == naming.StatusWaiting {
+						ins.Status = naming.StatusWaiting
+					}
+				default:
+					ins.Metadata[k] = v
+				}
+			}
+		}
+	}
+	ins.Addrs = addrs(ep.IP, ep.Ports)
+	return ins
+}
+
+// addrs returns the addresses of the ports, the schemes are the port names
+// http, grpc or their prefixes like grpc-web. Both http and grpc if no port
+// has a known name.
+func addrs(ip string, ports []port) (addrs []string) {
+	var unknown []string
+	for _, p := range ports {
+		hostport := net.JoinHostPort(ip, strconv.Itoa(p.Port))
+		scheme := p.Name
+		if i := strings.IndexByte(scheme, '-'); i > 0 {
+			scheme = scheme[:i]
+		}
+		switch scheme {
+		case "http", "grpc":
+			addrs = append(addrs, scheme+"://"+hostport)
+		default:
+			unknown = append(unknown, "http://"+hostport, "grpc://"+hostport)
+		}
+	}
+	if len(addrs) == 0 {
+		return unknown
+	}
+	return
+}
+
+func (a *appInfo) store(ins *naming.InstancesInfo) {
+	a.ins.Store(ins)
+	a.e.mutex.RLock()
+	for rs := range a.resolver {
+		select {
+		case rs.event <- struct{}{}:
+		default:
+		}
+	}
+	a.e.mutex.RUnlock()
+}
+
+// Watch watch instance.
+func (r *Resolve) Watch() <-chan struct{} {
+	return r.event
+}
+
+// Fetch fetch resolver instance.
+func (r *Resolve) Fetch(ctx context.Context) (ins *naming.InstancesInfo, ok bool) {
+	r.e.mutex.RLock()
+	app, ok := r.e.apps[r.id]
+	r.e.mutex.RUnlock()
+	if ok {
+		ins, ok = app.ins.Load().(*naming.InstancesInfo)
+		return
+	}
+	return
+}
+
+// Close close resolver.
+func (r *Resolve) Close() error {
+	r.e.mutex.Lock()
+	if app, ok := r.e.apps[r.id]; ok && len(app.resolver) != 0 {
+		delete(app.resolver, r)
+	}
+	r.e.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,187 @@
+package kubernetes
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/naming/kubernetes/internal/mockserver"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type obj = mockserver.Object
+
+func waitInstances(t *testing.T, r naming.Resolver, fn func(*naming.InstancesInfo) bool) *naming.InstancesInfo {
+	deadline := time.After(5 * time.Second)
+	for {
+		if ins, ok := r.Fetch(context.Background()); ok && fn(ins) {
+			return ins
+		}
+		select {
+		case <-r.Watch():
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("wait instances timeout")
+		}
+	}
+}
+
+func count(ins *naming.InstancesInfo) (n int) {
+	for _, zins := range ins.Instances {
+		n += len(zins)
+	}
+	return
+}
+
+func slice(name string, ports []obj, endpoints ...obj) obj {
+	return obj{
+		"metadata":  obj{"name": name, "labels": obj{_labelServiceName: "demo"}},
+		"endpoints": endpoints,
+		"ports":     ports,
+	}
+}
+
+func sliceEndpoint(ip, pod, zone string, ready bool) obj {
+	return obj{
+		"addresses":  []string{ip},
+		"conditions": obj{"ready": ready},
+		"zone":       zone,
+		"targetRef":  obj{"kind": "Pod", "name": pod},
+	}
+}
+
+func pod(name string, labels, annotations obj) obj {
+	return obj{"metadata": obj{"name": name, "labels": labels, "annotations": annotations}}
+}
+
+// selected adds the labels of the demo service selector to labels.
+func selected(labels obj) obj {
+	labels["app"], labels["tier"] = "demo", "backend"
+	return labels
+}
+
+func TestEndpointSlices(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	tokenFile, err := ioutil.TempFile("", "kubernetes-token")
+	assert.Nil(t, err)
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("secret\n")
+	tokenFile.Close()
+	srv.Token = "secret"
+
+	srv.Set("services", "prod", obj{"metadata": obj{"name": "demo"}, "spec": obj{"selector": obj{"app": "demo", "tier": "backend"}}})
+	srv.Set("pods", "prod", pod("demo-1",
+		selected(obj{_labelZone: "sh001", _labelVersion: "v1", "atreus.io/color": "blue"}),
+		obj{"atreus.io/weight": "20", "atreus.io/color": "red", "other.io/ignored": "x"}))
+	srv.Set("pods", "prod", pod("demo-2", selected(obj{}), obj{}))
+	ports := []obj{{"name": "grpc", "port": 9000}, {"name": "http-api", "port": 8000}, {"name": "metrics", "port": 9090}}
+	srv.Set("endpointslices", "prod", slice("demo-abc", ports,
+		sliceEndpoint("10.0.0.1", "demo-1", "", true),
+		sliceEndpoint("10.0.0.2", "demo-2", "sh002", false)))
+	// the other services are ignored.
+	other := slice("other-abc", ports, sliceEndpoint("10.0.0.9", "other-1", "", true))
+	other["metadata"].(obj)["labels"] = obj{_labelServiceName: "other"}
+	srv.Set("endpointslices", "prod", other)
+
+	b, err := New(&Config{Host: srv.URL(), TokenFile: tokenFile.Name(), Namespace: "prod", Resync: 100 * time.Millisecond})
+	assert.Nil(t, err)
+	defer b.Close()
+	r := b.Build("demo")
+	defer r.Close()
+
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 2 })
+	in := res.Instances["sh001"][0]
+	assert.Equal(t, "demo", in.AppID)
+	assert.Equal(t, "demo-1", in.Hostname)
+	assert.Equal(t, "v1", in.Version)
+	assert.Equal(t, int64(naming.StatusUP), in.Status)
+	assert.Equal(t, []string{"grpc://10.0.0.1:9000", "http://10.0.0.1:8000"}, in.Addrs)
+	assert.Equal(t, map[string]string{naming.MetaWeight: "20", naming.MetaColor: "red"}, in.Metadata)
+	in = res.Instances["sh002"][0]
+	assert.Equal(t, int64(naming.StatusWaiting), in.Status)
+
+	// the endpoint changes are watched.
+	srv.Set("pods", "prod", pod("demo-3", selected(obj{}), obj{"atreus.io/zone": "sh003"}))
+	srv.Set("endpointslices", "prod", slice("demo-def", []obj{{"port": 80}}, sliceEndpoint("10.0.0.3", "demo-3", "", true)))
+	res = waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 3 })
+	assert.Equal(t, []string{"http://10.0.0.3:80", "grpc://10.0.0.3:80"}, res.Instances["sh003"][0].Addrs)
+	srv.Delete("endpointslices", "prod", "demo-abc")
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 1 })
+
+	// the pod annotations are resynced.
+	srv.Set("pods", "prod", pod("demo-3", selected(obj{}), obj{"atreus.io/zone": "sh003", "atreus.io/status": "2"}))
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool {
+		return count(ins) == 1 && ins.Instances["sh003"][0].Status == naming.StatusWaiting
+	})
+
+	// list again after the watch is broken and the version is gone.
+	srv.Compact()
+	srv.Set("endpointslices", "prod", slice("demo-abc", ports, sliceEndpoint("10.0.0.1", "demo-1", "", true)))
+	waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 2 })
+
+	// the pods are listed by the selector of the service instead of one by one.
+	assert.True(t, srv.Requests("GET", "/api/v1/namespaces/prod/pods") > 0)
+	for _, name := range []string{"demo-1", "demo-2", "demo-3"} {
+		assert.Equal(t, 0, srv.Requests("GET", "/api/v1/namespaces/prod/pods/"+name), name)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Set("pods", "default", pod("demo-1", obj{}, obj{"atreus.io/cluster": "c1"}))
+	srv.Set("endpoints", "prod", obj{
+		"metadata": obj{"name": "demo"},
+		"subsets": []obj{{
+			"addresses":         []obj{{"ip": "10.0.0.1", "targetRef": obj{"kind": "Pod", "name": "demo-1"}}},
+			"notReadyAddresses": []obj{{"ip": "10.0.0.2", "hostname": "demo-2"}},
+			"ports":             []obj{{"name": "grpc", "port": 9000}},
+		}},
+	})
+	b, err := New(&Config{Host: srv.URL(), Endpoints: true})
+	assert.Nil(t, err)
+	defer b.Close()
+	r := b.Build("demo.prod")
+	defer r.Close()
+	res := waitInstances(t, r, func(ins *naming.InstancesInfo) bool { return count(ins) == 2 })
+	assert.Equal(t, "demo-1", res.Instances[""][0].Hostname)
+	assert.Equal(t, int64(naming.StatusUP), res.Instances[""][0].Status)
+	assert.Equal(t, "demo-2", res.Instances[""][1].Hostname)
+	assert.Equal(t, int64(naming.StatusWaiting), res.Instances[""][1].Status)
+	assert.Equal(t, []string{"grpc://10.0.0.2:9000"}, res.Instances[""][1].Addrs)
+}
+
+func TestRegister(t *testing.T) {
+	srv := mockserver.Run()
+	defer srv.Close()
+	srv.Set("pods", "prod", pod("demo-1", obj{}, obj{"keep": "1"}))
+	b, err := New(&Config{Host: srv.URL(), Namespace: "prod", PodName: "demo-1"})
+	assert.Nil(t, err)
+	defer b.Close()
+	ins := &naming.Instance{
+		AppID:    "demo",
+		Env:      "dev",
+		Version:  "v2",
+		Metadata: map[string]string{naming.MetaWeight: "30"},
+	}
+	cancel, err := b.Register(context.Background(), ins)
+	assert.Nil(t, err)
+	_, err = b.Register(context.Background(), ins)
+	assert.Equal(t, ErrDuplication, err)
+	p, _ := srv.Get("pods", "prod", "demo-1")
+	assert.Equal(t, obj{"keep": "1", "atreus.io/weight": "30", "atreus.io/env": "dev", "atreus.io/version": "v2"}, p["metadata"].(obj)["annotations"])
+	cancel()
+	p, _ = srv.Get("pods", "prod", "demo-1")
+	assert.Equal(t, obj{"keep": "1"}, p["metadata"].(obj)["annotations"])
+
+	b, err = New(&Config{Host: srv.URL(), Namespace: "prod", PodName: "absent"})
+	assert.Nil(t, err)
+	defer b.Close()
+	_, err = b.Register(context.Background(), ins)
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -0,0 +1,54 @@
+// Package claims carries the verified token claims in the context, it's
+// shared by the blademaster and warden authentication, so the rpc packages
+// needn't depend on blademaster.
+package claims
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Claims is the verified claims, services embed the registered claims into
+// their own claims type.
+type Claims interface {
+	jwt.Claims
+	// Registered returns the registered claims to check and set.
+	Registered() *jwt.RegisteredClaims
+}
+
+// UserNamer is implemented by the claims which carry the user name.
+type UserNamer interface {
+	UserName() string
+}
+
+type claimsKey struct{}
+
+// NewContext returns a new context with the verified claims.
+func NewContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the verified claims of the context.
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// User returns the user of the verified claims, it's the user name if the
+// claims implement UserNamer, or the subject.
+func User(ctx context.Context) string {
+	claims, ok := FromContext(ctx)
+	if !ok || claims == nil {
+		return ""
+	}
+	if un, ok := claims.(UserNamer); ok {
+		if name := un.UserName(); name != "" {
+			return name
+		}
+	}
+	if rc := claims.Registered(); rc != nil {
+		return rc.Subject
+	}
+	return ""
+}
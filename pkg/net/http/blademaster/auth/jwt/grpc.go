@@ -0,0 +1,52 @@
+package jwt
+
+import (
+	"context"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/net/claims"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// NewContext returns a new context with the verified claims.
+func NewContext(ctx context.Context, c Claims) context.Context {
+	return claims.NewContext(ctx, c)
+}
+
+// FromContext returns the claims verified by the warden interceptor or the
+// Auth middleware.
+func FromContext(ctx context.Context) (Claims, bool) {
+	return claims.FromContext(ctx)
+}
+
+// UnaryServerInterceptor returns a warden server interceptor which verifies
+// the bearer token of the authorization metadata, the methods in skip are not verified.
+func (j *JwtAuth) UnaryServerInterceptor(skip ...string) grpc.UnaryServerInterceptor {
+	skips := make(map[string]struct{}, len(skip))
+	for _, m := range skip {
+		skips[m] = struct{}{}
+	}
+	return func(ctx context.Context, req interface{}, args *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := skips[args.FullMethod]; ok {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		var authorization string
+		if vs := md.Get("authorization"); len(vs) > 0 {
+			authorization = vs[0]
+		}
+		token, ok := bearer(authorization)
+		if !ok {
+			return nil, ecode.Unauthorized
+		}
+		claims := j.newClaims()
+		if err := j.Verify(ctx, token, claims); err != nil {
+			log.Error("jwt: method(%s) verify token error(%v)", args.FullMethod, err)
+			return nil, ecode.Unauthorized
+		}
+		return handler(NewContext(ctx, claims), req)
+	}
+}
@@ -1,14 +1,25 @@
 package jwt
 
 import (
-	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
 	"fmt"
-	"github.com/mapgoo-lab/atreus/pkg/log"
+	"io/ioutil"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/net/claims"
 	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -24,29 +35,380 @@ const (
 
 	// reason holds the error reason.
 	reason string = "UNAUTHORIZED"
+
+	// typAccess and typRefresh are the typ headers of the access and refresh tokens.
+	typAccess  = "at+jwt"
+	typRefresh = "refresh+jwt"
+
+	// ClaimsKey is the context key of the verified claims.
+	ClaimsKey = "jwt_claims"
+	// userTitleKey is the context key of the auth.UserTitle.
+	userTitleKey = "user_title"
 )
 
-type Config struct{
-	//加密秘钥
+// jwt errors.
+var (
+	ErrTokenInvalid = errors.New("jwt: token invalid")
+	ErrTokenExpired = errors.New("jwt: token expired")
+	ErrTokenRevoked = errors.New("jwt: token revoked")
+	ErrKeyNotFound  = errors.New("jwt: key not found")
+)
+
+type Config struct {
+	//加密秘钥, HS256
 	Secret string
 
-	//过期时间（单位：天）
+	//过期时间（单位：天）, 兼容旧配置, AccessTTL 优先
 	Expire int
+
+	// Algorithm is the signing algorithm, e.g. RS256, ES256, HS256 by default.
+	Algorithm string
+	// SigningKey is the PEM file of the RSA or ECDSA private key for signing.
+	SigningKey string
+	// SigningKeyID is the kid header of the signed tokens.
+	SigningKeyID string
+	// JWKS is the http(s) URL or the file path of the verification keys.
+	JWKS string
+	// JWKSRefresh is the refresh interval of JWKS, zero disables the refresh.
+	JWKSRefresh xtime.Duration
+
+	// Issuer is set into the issued tokens and checked if not empty.
+	Issuer string
+	// Audience is set into the issued tokens and one of it must match if not empty.
+	Audience []string
+	// AccessTTL is the expiry of the access token, 2h by default.
+	AccessTTL xtime.Duration
+	// RefreshTTL is the expiry of the refresh token, 7 days by default.
+	RefreshTTL xtime.Duration
+	// Leeway is the clock skew allowed.
+	Leeway xtime.Duration
+}
+
+// Claims is the claims verified and issued by JwtAuth, services embed
+// RegisteredClaims into their own claims type.
+type Claims = claims.Claims
+
+// RegisteredClaims implements Claims with the registered claims only.
+type RegisteredClaims struct {
+	jwt.RegisteredClaims
+}
+
+// Registered returns the registered claims.
+func (c *RegisteredClaims) Registered() *jwt.RegisteredClaims {
+	return &c.RegisteredClaims
+}
+
+// Valid is checked by JwtAuth, see Verify.
+func (c *RegisteredClaims) Valid() error {
+	return nil
+}
+
+// Option is the option of JwtAuth.
+type Option func(*JwtAuth)
+
+// WithClaims sets the claims factory of the verified tokens, MapgooClaims by default.
+func WithClaims(newClaims func() Claims) Option {
+	return func(j *JwtAuth) {
+		j.newClaims = newClaims
+	}
+}
+
+// WithRevoker sets the revocation list of the tokens.
+func WithRevoker(r Revoker) Option {
+	return func(j *JwtAuth) {
+		j.revoker = r
+	}
+}
+
+// WithKeySet sets the verification keys, the keys of config are added into it.
+func WithKeySet(ks *KeySet) Option {
+	return func(j *JwtAuth) {
+		j.keys = ks
+	}
 }
 
 type JwtAuth struct {
-	config *Config
+	config    *Config
+	method    jwt.SigningMethod
+	signKey   interface{}
+	keys      *KeySet
+	revoker   Revoker
+	newClaims func() Claims
+	parser    *jwt.Parser
+}
+
+var _ auth.Auth = &JwtAuth{}
+
+// New new a JwtAuth, it panics if the keys of config are invalid.
+func New(cfg *Config, opts ...Option) *JwtAuth {
+	j, err := NewJwtAuth(cfg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return j
+}
+
+// NewJwtAuth new a JwtAuth.
+func NewJwtAuth(cfg *Config, opts ...Option) (j *JwtAuth, err error) {
+	c := *cfg
+	if c.AccessTTL <= 0 {
+		c.AccessTTL = xtime.Duration(2 * time.Hour)
+		if c.Expire > 0 {
+			c.AccessTTL = xtime.Duration(time.Hour * 24 * time.Duration(c.Expire))
+		}
+	}
+	if c.RefreshTTL <= 0 {
+		c.RefreshTTL = xtime.Duration(7 * 24 * time.Hour)
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = jwt.SigningMethodHS256.Alg()
+	}
+	j = &JwtAuth{
+		config:    &c,
+		newClaims: func() Claims { return &MapgooClaims{} },
+		parser:    jwt.NewParser(jwt.WithoutClaimsValidation()),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	if j.keys == nil {
+		j.keys = NewKeySet()
+	}
+	if j.method = jwt.GetSigningMethod(c.Algorithm); j.method == nil {
+		return nil, errors.Errorf("jwt: unknown algorithm %s", c.Algorithm)
+	}
+	if err = j.loadSigningKey(); err != nil {
+		return nil, err
+	}
+	if c.JWKS != "" {
+		if err = j.keys.LoadJWKS(c.JWKS, time.Duration(c.JWKSRefresh)); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+func (j *JwtAuth) loadSigningKey() (err error) {
+	c := j.config
+	switch j.method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if c.Secret == "" {
+			return
+		}
+		j.signKey = []byte(c.Secret)
+		j.keys.Add(&Key{ID: c.SigningKeyID, Algorithm: c.Algorithm, Key: j.signKey})
+		return
+	}
+	if c.SigningKey == "" {
+		// NOTE: verification only by the JWKS.
+		return
+	}
+	pem, err := ioutil.ReadFile(c.SigningKey)
+	if err != nil {
+		return errors.Wrapf(err, "jwt: read signing key(%s)", c.SigningKey)
+	}
+	var pub interface{}
+	switch j.method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		var key *rsa.PrivateKey
+		if key, err = jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+			j.signKey, pub = key, &key.PublicKey
+		}
+	case *jwt.SigningMethodECDSA:
+		var key *ecdsa.PrivateKey
+		if key, err = jwt.ParseECPrivateKeyFromPEM(pem); err == nil {
+			j.signKey, pub = key, &key.PublicKey
+		}
+	default:
+		return errors.Errorf("jwt: unsupported algorithm %s", c.Algorithm)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "jwt: parse signing key(%s)", c.SigningKey)
+	}
+	j.keys.Add(&Key{ID: c.SigningKeyID, Algorithm: c.Algorithm, Key: pub})
+	return
+}
+
+func (j *JwtAuth) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := j.keys.Get(kid)
+	if !ok {
+		j.keys.refreshUnknown(kid)
+		if k, ok = j.keys.Get(kid); !ok {
+			return nil, ErrKeyNotFound
+		}
+	}
+	if !k.allow(token.Method) {
+		return nil, errors.Wrapf(ErrTokenInvalid, "algorithm %s not allowed by key(%s)", token.Method.Alg(), kid)
+	}
+	return k.Key, nil
+}
+
+// Verify verifies the access token and parses it into claims.
+func (j *JwtAuth) Verify(ctx context.Context, token string, claims Claims) error {
+	return j.verify(ctx, token, typAccess, claims)
 }
 
-func New(cfg *Config) *JwtAuth {
-	return &JwtAuth{
-		config: cfg,
+func (j *JwtAuth) verify(ctx context.Context, tokenStr, typ string, claims Claims) (err error) {
+	token, err := j.parser.ParseWithClaims(tokenStr, claims, j.keyFunc)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return ErrKeyNotFound
+		}
+		return errors.Wrap(ErrTokenInvalid, err.Error())
+	}
+	// NOTE: the legacy tokens have the JWT typ header.
+	if ttyp, _ := token.Header["typ"].(string); ttyp != typ && !(typ == typAccess && (ttyp == "" || ttyp == "JWT")) {
+		return errors.Wrapf(ErrTokenInvalid, "unexpected token type %s", ttyp)
+	}
+	rc := claims.Registered()
+	if rc == nil {
+		return errors.Wrap(ErrTokenInvalid, "no registered claims")
+	}
+	now, leeway := time.Now(), time.Duration(j.config.Leeway)
+	if !rc.VerifyExpiresAt(now.Add(-leeway), true) {
+		return ErrTokenExpired
+	}
+	if !rc.VerifyNotBefore(now.Add(leeway), false) {
+		return errors.Wrap(ErrTokenInvalid, "token used before valid")
+	}
+	if j.config.Issuer != "" && !rc.VerifyIssuer(j.config.Issuer, true) {
+		return errors.Wrapf(ErrTokenInvalid, "invalid issuer %s", rc.Issuer)
+	}
+	if len(j.config.Audience) > 0 {
+		var ok bool
+		for _, aud := range j.config.Audience {
+			if ok = rc.VerifyAudience(aud, true); ok {
+				break
+			}
+		}
+		if !ok {
+			return errors.Wrapf(ErrTokenInvalid, "invalid audience %v", rc.Audience)
+		}
+	}
+	if err = claims.Valid(); err != nil {
+		return errors.Wrap(ErrTokenInvalid, err.Error())
+	}
+	if j.revoker != nil && rc.ID != "" {
+		var revoked bool
+		if revoked, err = j.revoker.IsRevoked(ctx, rc.ID); err != nil {
+			return
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	return
+}
+
+// TokenPair is the access and refresh tokens.
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Sign signs an access token with claims, the iat, exp, jti and the missing iss and aud are set.
+func (j *JwtAuth) Sign(claims Claims) (string, error) {
+	return j.sign(claims, typAccess, time.Duration(j.config.AccessTTL))
+}
+
+// IssuePair signs an access token and a refresh token with claims.
+func (j *JwtAuth) IssuePair(claims Claims) (pair *TokenPair, err error) {
+	pair = new(TokenPair)
+	if pair.AccessToken, err = j.sign(claims, typAccess, time.Duration(j.config.AccessTTL)); err != nil {
+		return nil, err
+	}
+	pair.AccessExpiresAt = claims.Registered().ExpiresAt.Time
+	if pair.RefreshToken, err = j.sign(claims, typRefresh, time.Duration(j.config.RefreshTTL)); err != nil {
+		return nil, err
+	}
+	pair.RefreshExpiresAt = claims.Registered().ExpiresAt.Time
+	return
+}
+
+// Refresh verifies the refresh token into claims and issues a new pair with
+// them, the refresh token is revoked if a Revoker is set so it's used only once.
+func (j *JwtAuth) Refresh(ctx context.Context, refreshToken string, claims Claims) (pair *TokenPair, err error) {
+	if err = j.verify(ctx, refreshToken, typRefresh, claims); err != nil {
+		return
+	}
+	if rc := claims.Registered(); j.revoker != nil && rc.ID != "" {
+		if err = j.revoker.Revoke(ctx, rc.ID, rc.ExpiresAt.Time); err != nil {
+			return
+		}
+	}
+	return j.IssuePair(claims)
+}
+
+// Revoke revokes the token until it expires, the token must be valid.
+func (j *JwtAuth) Revoke(ctx context.Context, token string) (err error) {
+	if j.revoker == nil {
+		return errors.New("jwt: no revoker")
+	}
+	claims := j.newClaims()
+	if _, err = j.parser.ParseWithClaims(token, claims, j.keyFunc); err != nil {
+		return errors.Wrap(ErrTokenInvalid, err.Error())
+	}
+	rc := claims.Registered()
+	if rc.ID == "" || rc.ExpiresAt == nil {
+		return errors.Wrap(ErrTokenInvalid, "no jti or exp")
 	}
+	return j.revoker.Revoke(ctx, rc.ID, rc.ExpiresAt.Time)
+}
+
+func (j *JwtAuth) sign(claims Claims, typ string, ttl time.Duration) (string, error) {
+	if j.signKey == nil {
+		return "", errors.New("jwt: no signing key")
+	}
+	rc := claims.Registered()
+	now := time.Now()
+	rc.IssuedAt = jwt.NewNumericDate(now)
+	rc.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	rc.ID = newID()
+	if rc.Issuer == "" {
+		rc.Issuer = j.config.Issuer
+	}
+	if len(rc.Audience) == 0 {
+		rc.Audience = j.config.Audience
+	}
+	token := jwt.NewWithClaims(j.method, claims)
+	token.Header["typ"] = typ
+	if j.config.SigningKeyID != "" {
+		token.Header["kid"] = j.config.SigningKeyID
+	}
+	return token.SignedString(j.signKey)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// bearer returns the token of the bearer authorization.
+func bearer(authorization string) (string, bool) {
+	auths := strings.SplitN(authorization, " ", 2)
+	if len(auths) != 2 || !strings.EqualFold(auths[0], bearerWord) {
+		return "", false
+	}
+	return auths[1], true
+}
+
+// ClaimsFrom returns the claims verified by the Auth middleware.
+func ClaimsFrom(c *bm.Context) (Claims, bool) {
+	v, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(Claims)
+	return claims, ok
 }
 
 type MapgooClaims struct {
-	UserTitle *auth.UserTitle `json:"title,omitempty"`
-	Claims *jwt.RegisteredClaims `json:"claims,omitempty"`
+	UserTitle *auth.UserTitle       `json:"title,omitempty"`
+	Claims    *jwt.RegisteredClaims `json:"claims,omitempty"`
 }
 
 func (claims *MapgooClaims) Valid() error {
@@ -57,62 +419,48 @@ func (claims *MapgooClaims) Valid() error {
 	return nil
 }
 
-//校验JWT Token
-func (jwtAuth *JwtAuth) Auth(c *bm.Context) {
+// Registered returns the registered claims.
+func (claims *MapgooClaims) Registered() *jwt.RegisteredClaims {
+	if claims.Claims == nil {
+		claims.Claims = &jwt.RegisteredClaims{}
+	}
+	return claims.Claims
+}
 
-	auths := strings.SplitN(c.Request.Header.Get(authorizationKey), " ", 2)
-	if len(auths) != 2 || !strings.EqualFold(auths[0], bearerWord) {
-		log.Error("Error header format: %s", auths)
-		c.AbortWithStatus(401)
-		return
+// UserName returns the user name of the user title.
+func (claims *MapgooClaims) UserName() string {
+	if claims.UserTitle == nil {
+		return ""
 	}
-	jwtToken := auths[1]
-	tokenInfo, err := jwt.ParseWithClaims(jwtToken, &MapgooClaims{}, func(token *jwt.Token) (i interface{}, e error) {
-		return []byte(jwtAuth.config.Secret), nil
-	})
-	if err != nil {
-		log.Error("ParseWithClaims failed, error:%s", err)
-		c.AbortWithStatus(401)
-		return
-	} else if !tokenInfo.Valid {
-		log.Error("tokeninfo invalid")
+	return claims.UserTitle.UserName
+}
+
+// 校验JWT Token
+func (jwtAuth *JwtAuth) Auth(c *bm.Context) {
+	token, ok := bearer(c.Request.Header.Get(authorizationKey))
+	if !ok {
+		log.Error("Error header format: %s", c.Request.Header.Get(authorizationKey))
+		c.Error = ecode.Unauthorized
 		c.AbortWithStatus(401)
 		return
-	} else if tokenInfo.Method != jwt.SigningMethodHS256 {
-		//return nil, ErrUnSupportSigningMethod
-		log.Error("signingMethod error")
+	}
+	claims := jwtAuth.newClaims()
+	if err := jwtAuth.Verify(c, token, claims); err != nil {
+		log.Error("jwt: verify token error(%v)", err)
+		c.Error = ecode.Unauthorized
 		c.AbortWithStatus(401)
 		return
-	} else {
-		if mgClaims, ok := tokenInfo.Claims.(*MapgooClaims); ok {
-			if mgClaims.Valid() != nil {
-				log.Error("mgClaims invalid")
-				c.AbortWithStatus(401)
-				return
-			} else if !mgClaims.Claims.VerifyExpiresAt(time.Now(), true) {
-				//token过期
-				log.Error("token expired")
-				c.AbortWithStatus(401)
-				return
-			} else {
-				c.Set("user_title", mgClaims.UserTitle)
-			}
-		}
+	}
+	c.Set(ClaimsKey, claims)
+	c.Context = NewContext(c.Context, claims)
+	if mgClaims, ok := claims.(*MapgooClaims); ok {
+		c.Set(userTitleKey, mgClaims.UserTitle)
 	}
 }
 
-//生成JWT Token
+// 生成JWT Token
 func (jwtAuth *JwtAuth) GetToken(userTitle *auth.UserTitle) (string, error) {
-	mgClaims := &MapgooClaims{
-		UserTitle: userTitle,
-		Claims: &jwt.RegisteredClaims{
-			//设置过期时间
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(jwtAuth.config.Expire) * 24)),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, mgClaims)
-	tokenStr, err := token.SignedString([]byte(jwtAuth.config.Secret))
+	tokenStr, err := jwtAuth.Sign(&MapgooClaims{UserTitle: userTitle})
 	if err != nil {
 		return "", err
 	}
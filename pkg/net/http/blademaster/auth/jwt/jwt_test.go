@@ -0,0 +1,247 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type memRevoker struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+func (r *memRevoker) Revoke(ctx context.Context, jti string, expire time.Time) error {
+	r.mu.Lock()
+	r.ids[jti] = expire
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *memRevoker) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.ids[jti]
+	return ok, nil
+}
+
+type userClaims struct {
+	RegisteredClaims
+	Role string `json:"role"`
+}
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	file := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestLegacyHS256(t *testing.T) {
+	j := New(&Config{Secret: "secret", Expire: 1})
+	token, err := j.GetToken(&auth.UserTitle{UserName: "atreus"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	e := bm.NewServer(&bm.ServerConfig{Timeout: xtime.Duration(time.Second)})
+	e.GET("/me", j.Auth, func(c *bm.Context) {
+		v, _ := c.Get("user_title")
+		c.JSON(v.(*auth.UserTitle).UserName, nil)
+	})
+	do := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+	w := do(token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "atreus")
+	assert.Equal(t, http.StatusUnauthorized, do("Bearer x.y.z").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("").Code)
+
+	other := New(&Config{Secret: "other"})
+	token, _ = other.GetToken(&auth.UserTitle{UserName: "atreus"})
+	assert.Equal(t, http.StatusUnauthorized, do(token).Code)
+}
+
+func TestRS256JWKS(t *testing.T) {
+	k1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwk := func(kid string, k *rsa.PrivateKey) map[string]string {
+		return map[string]string{
+			"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+			"n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes()),
+		}
+	}
+	var (
+		mu   sync.Mutex
+		keys = []map[string]string{jwk("k1", k1)}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer srv.Close()
+
+	newClaims := WithClaims(func() Claims { return &userClaims{} })
+	verifier := New(&Config{Algorithm: "RS256", JWKS: srv.URL, Issuer: "atreus", Audience: []string{"api"}}, newClaims)
+	verifier.keys.minRefresh = 0
+	signer1 := New(&Config{
+		Algorithm:    "RS256",
+		SigningKey:   writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k1)),
+		SigningKeyID: "k1",
+		Issuer:       "atreus",
+		Audience:     []string{"api"},
+	})
+	token, err := signer1.Sign(&userClaims{Role: "admin"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	claims := &userClaims{}
+	assert.NoError(t, verifier.Verify(context.TODO(), token, claims))
+	assert.Equal(t, "admin", claims.Role)
+
+	// the key is rotated, the unknown kid triggers the refresh.
+	signer2 := New(&Config{
+		Algorithm:    "RS256",
+		SigningKey:   writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k2)),
+		SigningKeyID: "k2",
+		Issuer:       "atreus",
+		Audience:     []string{"api"},
+	})
+	token, _ = signer2.Sign(&userClaims{Role: "user"})
+	assert.Equal(t, ErrKeyNotFound, verifier.Verify(context.TODO(), token, &userClaims{}))
+	mu.Lock()
+	keys = append(keys, jwk("k2", k2))
+	mu.Unlock()
+	assert.NoError(t, verifier.Verify(context.TODO(), token, &userClaims{}))
+
+	// issuer and audience.
+	wrong := New(&Config{
+		Algorithm:    "RS256",
+		SigningKey:   writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k1)),
+		SigningKeyID: "k1",
+		Issuer:       "evil",
+	})
+	token, _ = wrong.Sign(&userClaims{})
+	assert.Error(t, verifier.Verify(context.TODO(), token, &userClaims{}))
+
+	// the HS256 token signed by the public key is rejected.
+	hs := New(&Config{Secret: string(x509.MarshalPKCS1PublicKey(&k1.PublicKey)), SigningKeyID: "k1"})
+	token, _ = hs.Sign(&userClaims{})
+	assert.Error(t, verifier.Verify(context.TODO(), token, &userClaims{}))
+}
+
+func TestES256PairAndRevoke(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	revoker := &memRevoker{ids: make(map[string]time.Time)}
+	j := New(&Config{
+		Algorithm:  "ES256",
+		SigningKey: writePEM(t, "EC PRIVATE KEY", der),
+		AccessTTL:  xtime.Duration(time.Minute),
+	}, WithClaims(func() Claims { return &userClaims{} }), WithRevoker(revoker))
+
+	pair, err := j.IssuePair(&userClaims{Role: "user"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, pair.RefreshExpiresAt.After(pair.AccessExpiresAt))
+	assert.NoError(t, j.Verify(context.TODO(), pair.AccessToken, &userClaims{}))
+	// the refresh token can't be used as an access token and vice versa.
+	assert.Error(t, j.Verify(context.TODO(), pair.RefreshToken, &userClaims{}))
+	_, err = j.Refresh(context.TODO(), pair.AccessToken, &userClaims{})
+	assert.Error(t, err)
+
+	claims := &userClaims{}
+	next, err := j.Refresh(context.TODO(), pair.RefreshToken, claims)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "user", claims.Role)
+	// the refresh token is used only once.
+	_, err = j.Refresh(context.TODO(), pair.RefreshToken, &userClaims{})
+	assert.Equal(t, ErrTokenRevoked, err)
+
+	assert.NoError(t, j.Revoke(context.TODO(), next.AccessToken))
+	assert.Equal(t, ErrTokenRevoked, j.Verify(context.TODO(), next.AccessToken, &userClaims{}))
+
+	// expired.
+	expired := New(&Config{
+		Algorithm:  "ES256",
+		SigningKey: writePEM(t, "EC PRIVATE KEY", der),
+		AccessTTL:  xtime.Duration(-time.Minute),
+	})
+	token, _ := expired.sign(&userClaims{}, typAccess, -time.Minute)
+	assert.Equal(t, ErrTokenExpired, j.Verify(context.TODO(), token, &userClaims{}))
+}
+
+func TestRevokeDefaultClaims(t *testing.T) {
+	revoker := &memRevoker{ids: make(map[string]time.Time)}
+	j := New(&Config{Secret: "secret", AccessTTL: xtime.Duration(time.Minute)}, WithRevoker(revoker))
+	token, err := j.Sign(&MapgooClaims{UserTitle: &auth.UserTitle{UserName: "atreus"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, j.Verify(context.TODO(), token, &MapgooClaims{}))
+	assert.NoError(t, j.Revoke(context.TODO(), token))
+	assert.Len(t, revoker.ids, 1)
+	assert.Equal(t, ErrTokenRevoked, j.Verify(context.TODO(), token, &MapgooClaims{}))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	j := New(&Config{Secret: "secret"}, WithClaims(func() Claims { return &userClaims{} }))
+	token, _ := j.Sign(&userClaims{Role: "admin"})
+	interceptor := j.UnaryServerInterceptor("/test.Service/Public")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		claims, ok := FromContext(ctx)
+		if !ok {
+			return "", nil
+		}
+		return claims.(*userClaims).Role, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Private"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	resp, err := interceptor(ctx, nil, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", resp)
+
+	_, err = interceptor(context.Background(), nil, info, handler)
+	assert.Equal(t, ecode.Unauthorized, err)
+
+	resp, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Public"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "", resp)
+}
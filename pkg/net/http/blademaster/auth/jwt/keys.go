@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// Key is a verification key.
+type Key struct {
+	// ID is the key id matched with the kid header of the token.
+	ID string
+	// Algorithm is the signing algorithm, any algorithm of the key type is allowed if empty.
+	Algorithm string
+	// Key is *rsa.PublicKey, *ecdsa.PublicKey or []byte for HMAC.
+	Key interface{}
+}
+
+// allow reports whether the key can verify the token signed by method.
+func (k *Key) allow(method jwt.SigningMethod) bool {
+	if k.Algorithm != "" {
+		return k.Algorithm == method.Alg()
+	}
+	switch k.Key.(type) {
+	case *rsa.PublicKey:
+		_, rs := method.(*jwt.SigningMethodRSA)
+		_, ps := method.(*jwt.SigningMethodRSAPSS)
+		return rs || ps
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	}
+	return false
+}
+
+// KeySet is the verification keys by kid, it's safe for concurrent use.
+// The keys of the JWKS source are replaced on every refresh so that the keys
+// can be rotated without restart.
+type KeySet struct {
+	mu     sync.RWMutex
+	static map[string]*Key
+	jwks   map[string]*Key
+
+	source     string
+	client     *http.Client
+	lastFetch  time.Time
+	fetchMu    sync.Mutex
+	minRefresh time.Duration
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewKeySet new an empty key set.
+func NewKeySet() *KeySet {
+	return &KeySet{
+		static:     make(map[string]*Key),
+		jwks:       make(map[string]*Key),
+		client:     &http.Client{Timeout: 5 * time.Second},
+		minRefresh: time.Minute,
+		closed:     make(chan struct{}),
+	}
+}
+
+// Add adds the static key.
+func (s *KeySet) Add(k *Key) {
+	s.mu.Lock()
+	s.static[k.ID] = k
+	s.mu.Unlock()
+}
+
+// Get gets the key by kid, the only key is returned if kid is empty.
+func (s *KeySet) Get(kid string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if k, ok := s.static[kid]; ok {
+		return k, true
+	}
+	if k, ok := s.jwks[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(s.static)+len(s.jwks) == 1 {
+		for _, k := range s.static {
+			return k, true
+		}
+		for _, k := range s.jwks {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// LoadJWKS loads the keys from source which is a http(s) URL or a file path,
+// the keys are refreshed every interval if it's greater than zero.
+func (s *KeySet) LoadJWKS(source string, interval time.Duration) (err error) {
+	s.source = source
+	if err = s.refresh(); err != nil {
+		return
+	}
+	if interval > 0 {
+		go s.refreshproc(interval)
+	}
+	return
+}
+
+func (s *KeySet) refreshproc(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.refresh(); err != nil {
+				log.Error("jwt: refresh jwks(%s) error(%v)", s.source, err)
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// refreshUnknown refreshes the JWKS for an unknown kid at most once every minRefresh.
+func (s *KeySet) refreshUnknown(kid string) {
+	if s.source == "" {
+		return
+	}
+	s.fetchMu.Lock()
+	fresh := time.Since(s.lastFetch) < s.minRefresh
+	s.fetchMu.Unlock()
+	if fresh {
+		return
+	}
+	if err := s.refresh(); err != nil {
+		log.Error("jwt: refresh jwks(%s) for kid(%s) error(%v)", s.source, kid, err)
+	}
+}
+
+func (s *KeySet) refresh() (err error) {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	s.lastFetch = time.Now()
+	var data []byte
+	if strings.HasPrefix(s.source, "http://") || strings.HasPrefix(s.source, "https://") {
+		var resp *http.Response
+		if resp, err = s.client.Get(s.source); err != nil {
+			return errors.Wrapf(err, "jwt: fetch jwks(%s)", s.source)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.Errorf("jwt: fetch jwks(%s) status(%d)", s.source, resp.StatusCode)
+		}
+		data, err = ioutil.ReadAll(resp.Body)
+	} else {
+		data, err = ioutil.ReadFile(s.source)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "jwt: read jwks(%s)", s.source)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return
+	}
+	jwks := make(map[string]*Key, len(keys))
+	for _, k := range keys {
+		jwks[k.ID] = k
+	}
+	s.mu.Lock()
+	s.jwks = jwks
+	s.mu.Unlock()
+	return
+}
+
+// Close stops refreshing the JWKS.
+func (s *KeySet) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses the RSA, EC and oct keys of a JSON Web Key Set, the keys
+// for encryption and of unsupported types are skipped.
+func ParseJWKS(data []byte) (keys []*Key, err error) {
+	var set struct {
+		Keys []*jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid jwks")
+	}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key interface{}
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsa()
+		case "EC":
+			key, err = k.ecdsa()
+		case "oct":
+			key, err = decodeSegment(k.K)
+		default:
+			log.Warn("jwt: jwks key(%s) type(%s) unsupported", k.Kid, k.Kty)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "jwt: invalid jwks key(%s)", k.Kid)
+		}
+		keys = append(keys, &Key{ID: k.Kid, Algorithm: k.Alg, Key: key})
+	}
+	return
+}
+
+func (k *jwk) rsa() (*rsa.PublicKey, error) {
+	n, err := decodeSegment(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeSegment(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (k *jwk) ecdsa() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, errors.Errorf("unsupported curve %s", k.Crv)
+	}
+	x, err := decodeSegment(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeSegment(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("point is not on curve")
+	}
+	return pub, nil
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+
+	"github.com/pkg/errors"
+)
+
+// Revoker is the revocation list of the tokens by jti.
+type Revoker interface {
+	// Revoke revokes the token id until it expires.
+	Revoke(ctx context.Context, jti string, expire time.Time) error
+	// IsRevoked reports whether the token id is revoked.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type redisRevoker struct {
+	redis  *redis.Redis
+	prefix string
+}
+
+// NewRedisRevoker new a revocation list stored in redis, the revoked ids
+// expire with the tokens so that the list doesn't grow forever.
+func NewRedisRevoker(r *redis.Redis, prefix string) Revoker {
+	if prefix == "" {
+		prefix = "jwt_revoked:"
+	}
+	return &redisRevoker{redis: r, prefix: prefix}
+}
+
+func (r *redisRevoker) Revoke(ctx context.Context, jti string, expire time.Time) (err error) {
+	ttl := time.Until(expire)
+	if ttl <= 0 {
+		return
+	}
+	if _, err = r.redis.Do(ctx, "SET", r.prefix+jti, 1, "PX", int64(ttl/time.Millisecond)+1); err != nil {
+		err = errors.Wrapf(err, "jwt: revoke jti(%s)", jti)
+	}
+	return
+}
+
+func (r *redisRevoker) IsRevoked(ctx context.Context, jti string) (revoked bool, err error) {
+	if revoked, err = redis.Bool(r.redis.Do(ctx, "EXISTS", r.prefix+jti)); err != nil {
+		err = errors.Wrapf(err, "jwt: check revoked jti(%s)", jti)
+	}
+	return
+}
@@ -0,0 +1,33 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor returns a warden server interceptor which requires
+// the permissions declared in the methods of the policy, the methods not
+// declared are not checked. It must be used after the authentication, e.g.
+// jwt.UnaryServerInterceptor.
+func (e *Enforcer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, args *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		perms, ok := e.load().permissions(args.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		user := e.subject(ctx)
+		if user == nil {
+			return nil, ecode.Unauthorized
+		}
+		if !e.Allow(user, perms...) {
+			log.Warn("rbac: user(%s) denied method(%s)", user.UserName, args.FullMethod)
+			_metricDenied.Inc(args.FullMethod)
+			return nil, ecode.AccessDenied
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,271 @@
+package rbac
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// Effect is the effect of an attribute rule.
+type Effect string
+
+// effects.
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Role is a named set of permissions, the permissions of the inherited roles
+// are granted too. A permission "order:*" grants all the permissions
+// prefixed with "order:", and "*" grants everything.
+type Role struct {
+	Name        string   `toml:"name"`
+	Permissions []string `toml:"permissions"`
+	Inherits    []string `toml:"inherits"`
+}
+
+// Binding binds the roles to the users by name or by user type.
+type Binding struct {
+	Users     []string `toml:"users"`
+	UserTypes []int32  `toml:"user_types"`
+	Roles     []string `toml:"roles"`
+}
+
+// Rule is an attribute based rule. It matches a permission if all the
+// fields in When match, the field names are the json names of auth.UserTitle,
+// e.g. when = { user_type = [2], province = ["广东"] }.
+type Rule struct {
+	Permission string                   `toml:"permission"`
+	Effect     Effect                   `toml:"effect"`
+	When       map[string][]interface{} `toml:"when"`
+}
+
+// Policy is the authorization policy, it's usually loaded from a paladin
+// toml file:
+//
+//	default_roles = ["guest"]
+//
+//	[[roles]]
+//	name = "consumer"
+//	permissions = ["order:read"]
+//
+//	[[roles]]
+//	name = "dealer"
+//	permissions = ["order:write"]
+//	inherits = ["consumer"]
+//
+//	[[bindings]]
+//	user_types = [2]
+//	roles = ["dealer"]
+//
+//	[[rules]]
+//	permission = "order:export"
+//	effect = "allow"
+//	when = { user_type = [2], province = ["广东"] }
+//
+//	[methods]
+//	"/demo.service.v1.Demo/CreateOrder" = ["order:write"]
+type Policy struct {
+	DefaultRoles []string            `toml:"default_roles"`
+	Roles        []*Role             `toml:"roles"`
+	Bindings     []*Binding          `toml:"bindings"`
+	Rules        []*Rule             `toml:"rules"`
+	Methods      map[string][]string `toml:"methods"`
+}
+
+// ParsePolicy parses the toml policy.
+func ParsePolicy(text string) (*Policy, error) {
+	p := new(Policy)
+	if _, err := toml.Decode(text, p); err != nil {
+		return nil, errors.Wrap(err, "rbac: invalid policy")
+	}
+	return p, nil
+}
+
+// compiled is the policy ready to be evaluated.
+type compiled struct {
+	defaults []string
+	// perms are the permissions of each role with the inherited ones.
+	perms   map[string][]string
+	users   map[string][]string
+	types   map[int32][]string
+	rules   []*rule
+	methods map[string][]string
+}
+
+type rule struct {
+	permission string
+	effect     Effect
+	when       map[int][]string // field index of auth.UserTitle -> values
+}
+
+func compile(p *Policy) (*compiled, error) {
+	c := &compiled{
+		defaults: p.DefaultRoles,
+		perms:    make(map[string][]string, len(p.Roles)),
+		users:    make(map[string][]string),
+		types:    make(map[int32][]string),
+		methods:  p.Methods,
+	}
+	roles := make(map[string]*Role, len(p.Roles))
+	for _, r := range p.Roles {
+		if r.Name == "" {
+			return nil, errors.New("rbac: role without name")
+		}
+		if _, ok := roles[r.Name]; ok {
+			return nil, errors.Errorf("rbac: duplicate role(%s)", r.Name)
+		}
+		roles[r.Name] = r
+	}
+	for name := range roles {
+		perms, err := expand(roles, name, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		c.perms[name] = perms
+	}
+	for _, b := range p.Bindings {
+		for _, r := range b.Roles {
+			if _, ok := roles[r]; !ok {
+				return nil, errors.Errorf("rbac: binding to unknown role(%s)", r)
+			}
+		}
+		for _, u := range b.Users {
+			c.users[u] = append(c.users[u], b.Roles...)
+		}
+		for _, t := range b.UserTypes {
+			c.types[t] = append(c.types[t], b.Roles...)
+		}
+	}
+	for _, r := range p.Rules {
+		if r.Permission == "" {
+			return nil, errors.New("rbac: rule without permission")
+		}
+		if r.Effect != Allow && r.Effect != Deny {
+			return nil, errors.Errorf("rbac: rule(%s) invalid effect(%s)", r.Permission, r.Effect)
+		}
+		cr := &rule{permission: r.Permission, effect: r.Effect, when: make(map[int][]string, len(r.When))}
+		for field, values := range r.When {
+			idx, ok := _fields[field]
+			if !ok {
+				return nil, errors.Errorf("rbac: rule(%s) unknown field(%s)", r.Permission, field)
+			}
+			for _, v := range values {
+				cr.when[idx] = append(cr.when[idx], fmt.Sprint(v))
+			}
+		}
+		c.rules = append(c.rules, cr)
+	}
+	return c, nil
+}
+
+func expand(roles map[string]*Role, name string, visiting map[string]bool) ([]string, error) {
+	r, ok := roles[name]
+	if !ok {
+		return nil, errors.Errorf("rbac: unknown role(%s)", name)
+	}
+	if visiting[name] {
+		return nil, errors.Errorf("rbac: role(%s) inherits itself", name)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+	perms := append([]string(nil), r.Permissions...)
+	for _, parent := range r.Inherits {
+		inherited, err := expand(roles, parent, visiting)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, inherited...)
+	}
+	return perms, nil
+}
+
+// roles returns the roles of the user.
+func (c *compiled) roles(user *auth.UserTitle) []string {
+	roles := append([]string(nil), c.defaults...)
+	roles = append(roles, c.users[user.UserName]...)
+	return append(roles, c.types[user.UserType]...)
+}
+
+// hasRole reports whether the user has any of the roles.
+func (c *compiled) hasRole(user *auth.UserTitle, roles []string) bool {
+	for _, have := range c.roles(user) {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allow reports whether the user is granted the permission. The deny rules
+// are evaluated first, then the roles and the allow rules.
+func (c *compiled) allow(user *auth.UserTitle, perm string) bool {
+	granted := false
+	for _, r := range c.rules {
+		if !match(r.permission, perm) || !r.matchUser(user) {
+			continue
+		}
+		if r.effect == Deny {
+			return false
+		}
+		granted = true
+	}
+	if granted {
+		return true
+	}
+	for _, role := range c.roles(user) {
+		for _, p := range c.perms[role] {
+			if match(p, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *rule) matchUser(user *auth.UserTitle) bool {
+	uv := reflect.ValueOf(user).Elem()
+	for idx, values := range r.when {
+		actual := fmt.Sprint(uv.Field(idx).Interface())
+		found := false
+		for _, v := range values {
+			if v == actual {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether the granted permission pattern covers perm.
+func match(pattern, perm string) bool {
+	if pattern == "*" || pattern == perm {
+		return true
+	}
+	return strings.HasSuffix(pattern, ":*") && strings.HasPrefix(perm, pattern[:len(pattern)-1])
+}
+
+// _fields is the field index of auth.UserTitle by json name.
+var _fields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(auth.UserTitle{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = t.Field(i).Name
+		}
+		fields[name] = i
+	}
+	return fields
+}()
@@ -0,0 +1,183 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth/jwt"
+	"github.com/mapgoo-lab/atreus/pkg/stat/metric"
+)
+
+const _userTitleKey = "user_title"
+
+var _metricDenied = metric.NewCounterVec(&metric.CounterVecOpts{
+	Namespace: "rbac",
+	Subsystem: "",
+	Name:      "denied_total",
+	Help:      "rbac denied requests total.",
+	Labels:    []string{"path"},
+})
+
+// Enforcer evaluates the authorization policy, it implements paladin.Setter
+// so that the policy is reloaded when the config changes:
+//
+//	enforcer := rbac.New(nil)
+//	if err := paladin.Watch("rbac.toml", enforcer); err != nil {
+//		panic(err)
+//	}
+//	g := e.Group("/order", jwtAuth.Auth, enforcer.Require("order:read"))
+type Enforcer struct {
+	policy  atomic.Value // *compiled
+	subject func(ctx context.Context) *auth.UserTitle
+}
+
+// Option is the enforcer option.
+type Option func(*Enforcer)
+
+// WithSubject sets the function which gets the user of the warden request,
+// the user of the jwt claims is used by default.
+func WithSubject(fn func(ctx context.Context) *auth.UserTitle) Option {
+	return func(e *Enforcer) {
+		e.subject = fn
+	}
+}
+
+// New new an enforcer with the policy, the policy can be nil and set later.
+func New(p *Policy, opts ...Option) *Enforcer {
+	e := &Enforcer{subject: jwtSubject}
+	for _, o := range opts {
+		o(e)
+	}
+	if p == nil {
+		p = new(Policy)
+	}
+	if err := e.SetPolicy(p); err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// SetPolicy replaces the policy atomically.
+func (e *Enforcer) SetPolicy(p *Policy) error {
+	c, err := compile(p)
+	if err != nil {
+		return err
+	}
+	e.policy.Store(c)
+	return nil
+}
+
+// Set implements paladin.Setter, the old policy is kept if the new one is invalid.
+func (e *Enforcer) Set(text string) error {
+	p, err := ParsePolicy(text)
+	if err == nil {
+		err = e.SetPolicy(p)
+	}
+	if err != nil {
+		log.Error("rbac: reload policy error(%v)", err)
+		return err
+	}
+	log.Info("rbac: policy reloaded")
+	return nil
+}
+
+func (e *Enforcer) load() *compiled {
+	return e.policy.Load().(*compiled)
+}
+
+// Roles returns the roles of the user.
+func (e *Enforcer) Roles(user *auth.UserTitle) []string {
+	if user == nil {
+		return nil
+	}
+	return e.load().roles(user)
+}
+
+// HasRole reports whether the user has any of the roles.
+func (e *Enforcer) HasRole(user *auth.UserTitle, roles ...string) bool {
+	return user != nil && e.load().hasRole(user, roles)
+}
+
+// Allow reports whether the user is granted all the permissions.
+func (e *Enforcer) Allow(user *auth.UserTitle, perms ...string) bool {
+	if user == nil {
+		return false
+	}
+	c := e.load()
+	for _, p := range perms {
+		if !c.allow(user, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Require returns a bm middleware which requires all the permissions, it
+// must be used after the authentication which sets the user_title.
+func (e *Enforcer) Require(perms ...string) bm.HandlerFunc {
+	return e.handler(func(user *auth.UserTitle) bool {
+		return e.Allow(user, perms...)
+	})
+}
+
+// RequireRole returns a bm middleware which requires any of the roles.
+func (e *Enforcer) RequireRole(roles ...string) bm.HandlerFunc {
+	return e.handler(func(user *auth.UserTitle) bool {
+		return e.HasRole(user, roles...)
+	})
+}
+
+func (e *Enforcer) handler(allow func(*auth.UserTitle) bool) bm.HandlerFunc {
+	return func(c *bm.Context) {
+		user := userTitle(c)
+		if user == nil {
+			c.JSON(nil, ecode.Unauthorized)
+			c.Abort()
+			return
+		}
+		if !allow(user) {
+			log.Warn("rbac: user(%s) denied path(%s)", user.UserName, c.RoutePath)
+			_metricDenied.Inc(c.RoutePath)
+			c.JSON(nil, ecode.AccessDenied)
+			c.Abort()
+		}
+	}
+}
+
+func userTitle(c *bm.Context) *auth.UserTitle {
+	v, ok := c.Get(_userTitleKey)
+	if !ok {
+		return nil
+	}
+	user, _ := v.(*auth.UserTitle)
+	return user
+}
+
+func jwtSubject(ctx context.Context) *auth.UserTitle {
+	claims, ok := jwt.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if mc, ok := claims.(*jwt.MapgooClaims); ok {
+		return mc.UserTitle
+	}
+	return nil
+}
+
+// permissions returns the permissions of the grpc method, the service
+// wildcard "/pkg.Service/*" is matched if the method isn't declared.
+func (c *compiled) permissions(method string) ([]string, bool) {
+	if perms, ok := c.methods[method]; ok {
+		return perms, true
+	}
+	if i := strings.LastIndexByte(method, '/'); i > 0 {
+		perms, ok := c.methods[method[:i+1]+"*"]
+		return perms, ok
+	}
+	return nil, false
+}
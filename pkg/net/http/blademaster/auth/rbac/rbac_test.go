@@ -0,0 +1,156 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth/jwt"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+const _policy = `
+default_roles = ["guest"]
+
+[[roles]]
+name = "guest"
+permissions = ["item:read"]
+
+[[roles]]
+name = "consumer"
+permissions = ["order:read"]
+inherits = ["guest"]
+
+[[roles]]
+name = "dealer"
+permissions = ["order:*"]
+inherits = ["consumer"]
+
+[[roles]]
+name = "admin"
+permissions = ["*"]
+
+[[bindings]]
+user_types = [1]
+roles = ["consumer"]
+
+[[bindings]]
+user_types = [2]
+roles = ["dealer"]
+
+[[bindings]]
+users = ["root"]
+roles = ["admin"]
+
+[[rules]]
+permission = "order:delete"
+effect = "deny"
+when = { province = ["广东"] }
+
+[[rules]]
+permission = "report:export"
+effect = "allow"
+when = { user_type = [2], city = ["深圳", "广州"] }
+
+[methods]
+"/demo.v1.Demo/CreateOrder" = ["order:write"]
+"/demo.v1.Admin/*" = ["admin:*"]
+`
+
+var (
+	consumer = &auth.UserTitle{UserName: "c", UserType: 1}
+	dealer   = &auth.UserTitle{UserName: "d", UserType: 2, Province: "广东", City: "深圳"}
+	root     = &auth.UserTitle{UserName: "root"}
+)
+
+func TestAllow(t *testing.T) {
+	e := New(nil)
+	assert.NoError(t, e.Set(_policy))
+
+	assert.ElementsMatch(t, []string{"guest", "consumer"}, e.Roles(consumer))
+	assert.True(t, e.Allow(consumer, "order:read", "item:read"))
+	assert.False(t, e.Allow(consumer, "order:write"))
+	assert.True(t, e.Allow(dealer, "order:write"))
+	assert.False(t, e.Allow(dealer, "order:delete"))
+	assert.True(t, e.Allow(dealer, "report:export"))
+	assert.False(t, e.Allow(consumer, "report:export"))
+	assert.True(t, e.Allow(root, "anything"))
+	assert.True(t, e.HasRole(root, "admin", "dealer"))
+	assert.False(t, e.HasRole(consumer, "admin"))
+	assert.False(t, e.Allow(nil, "item:read"))
+
+	// the invalid policy is rejected and the old one is kept.
+	assert.Error(t, e.Set(`[[bindings]]
+roles = ["nobody"]`))
+	assert.Error(t, e.Set(`[[roles]]
+name = "a"
+inherits = ["a"]`))
+	assert.Error(t, e.Set(`[[rules]]
+permission = "x"
+effect = "allow"
+when = { unknown = ["1"] }`))
+	assert.True(t, e.Allow(dealer, "order:write"))
+
+	assert.NoError(t, e.Set(`default_roles = []`))
+	assert.False(t, e.Allow(dealer, "order:write"))
+}
+
+func TestMiddleware(t *testing.T) {
+	e := New(nil)
+	assert.NoError(t, e.Set(_policy))
+	engine := bm.NewServer(&bm.ServerConfig{Timeout: xtime.Duration(time.Second)})
+	engine.UseFunc(func(c *bm.Context) {
+		switch c.Request.Header.Get("X-User") {
+		case "consumer":
+			c.Set("user_title", consumer)
+		case "dealer":
+			c.Set("user_title", dealer)
+		}
+	})
+	engine.GET("/order", e.Require("order:read"), func(c *bm.Context) { c.JSON("ok", nil) })
+	admin := engine.Group("/admin", e.RequireRole("admin", "dealer"))
+	admin.GET("/stat", func(c *bm.Context) { c.JSON("ok", nil) })
+
+	do := func(path, user string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Header().Get("atreus-status-code")
+	}
+	assert.Equal(t, "0", do("/order", "consumer"))
+	assert.Equal(t, ecode.Unauthorized.Error(), do("/order", ""))
+	assert.Equal(t, ecode.AccessDenied.Error(), do("/admin/stat", "consumer"))
+	assert.Equal(t, "0", do("/admin/stat", "dealer"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	e := New(nil)
+	assert.NoError(t, e.Set(_policy))
+	interceptor := e.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(method string, user *auth.UserTitle) error {
+		ctx := context.Background()
+		if user != nil {
+			ctx = jwt.NewContext(ctx, &jwt.MapgooClaims{UserTitle: user})
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	assert.NoError(t, call("/demo.v1.Demo/CreateOrder", dealer))
+	assert.Equal(t, ecode.AccessDenied, call("/demo.v1.Demo/CreateOrder", consumer))
+	assert.Equal(t, ecode.Unauthorized, call("/demo.v1.Demo/CreateOrder", nil))
+	assert.NoError(t, call("/demo.v1.Demo/Ping", nil))
+	assert.Equal(t, ecode.AccessDenied, call("/demo.v1.Admin/Reset", dealer))
+	assert.NoError(t, call("/demo.v1.Admin/Reset", root))
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 	"strings"
 
-	"gopkg.in/go-playground/validator.v9"
+	"github.com/mapgoo-lab/atreus/pkg/validation"
 )
 
 // MIME
@@ -25,22 +25,11 @@ type Binding interface {
 }
 
 // StructValidator http validator interface.
-type StructValidator interface {
-	// ValidateStruct can receive any kind of type and it should never panic, even if the configuration is not right.
-	// If the received type is not a struct, any validation should be skipped and nil must be returned.
-	// If the received type is a struct or pointer to a struct, the validation should be performed.
-	// If the struct is not valid or the validation itself fails, a descriptive error should be returned.
-	// Otherwise nil must be returned.
-	ValidateStruct(interface{}) error
+type StructValidator = validation.StructValidator
 
-	// RegisterValidation adds a validation Func to a Validate's map of validators denoted by the key
-	// NOTE: if the key already exists, the previous validation function will be replaced.
-	// NOTE: this method is not thread-safe it is intended that these all be registered prior to any validation
-	RegisterValidation(string, validator.Func) error
-}
-
-// Validator default validator.
-var Validator StructValidator = &defaultValidator{}
+// Validator default validator, it's shared with the other validate users like
+// paladin, so the validations registered to it apply to them too.
+var Validator StructValidator = validation.Default
 
 // Binding
 var (
@@ -5,6 +5,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -206,6 +207,44 @@ func TestBindingFormMultipart(t *testing.T) {
 	assert.Equal(t, obj.Bar, "foo")
 }
 
+type FileStruct struct {
+	FooStruct
+	Avatar *multipart.FileHeader   `form:"avatar" validate:"required"`
+	Photos []*multipart.FileHeader `form:"photos"`
+}
+
+func TestBindingFormMultipartFile(t *testing.T) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	mw.WriteField("foo", "bar")
+	mw.WriteField("avatar", "not a file")
+	fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+	fw.Write([]byte("avatar"))
+	for _, name := range []string{"1.jpg", "2.jpg"} {
+		fw, _ = mw.CreateFormFile("photos", name)
+		fw.Write([]byte(name))
+	}
+	mw.Close()
+	req, _ := http.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var obj FileStruct
+	err := FormMultipart.Bind(req, &obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", obj.Foo)
+	if assert.NotNil(t, obj.Avatar) {
+		assert.Equal(t, "avatar.png", obj.Avatar.Filename)
+		assert.Equal(t, int64(6), obj.Avatar.Size)
+	}
+	if assert.Len(t, obj.Photos, 2) {
+		assert.Equal(t, "2.jpg", obj.Photos[1].Filename)
+	}
+
+	var missing FileStruct
+	err = FormMultipart.Bind(createFormMultipartRequest(), &missing)
+	assert.Error(t, err)
+}
+
 func TestValidationFails(t *testing.T) {
 	var obj FooStruct
 	req := requestWithBody("POST", "/", `{"bar": "foo"}`)
@@ -340,3 +379,78 @@ func BenchmarkBindingForm(b *testing.B) {
 		f.Bind(req, &obj)
 	}
 }
+
+type EncodeStruct struct {
+	ID      int64     `form:"id"`
+	Name    string    `form:"name"`
+	Tags    []string  `form:"tags"`
+	IDs     []int64   `form:"ids,split"`
+	Rate    float64   `form:"rate"`
+	Ignored string    `form:"-"`
+	Created time.Time `form:"created" time_format:"2006-01-02"`
+	FooStruct
+	XXX_sizecache int32
+}
+
+func TestEncodeForm(t *testing.T) {
+	obj := &EncodeStruct{
+		ID:            1,
+		Tags:          []string{"a", "b"},
+		IDs:           []int64{1, 2},
+		Rate:          0.5,
+		Ignored:       "x",
+		Created:       time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		FooStruct:     FooStruct{Foo: "bar"},
+		XXX_sizecache: 10,
+	}
+	form, err := EncodeForm(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "created=2020-01-02&foo=bar&id=1&ids=1%2C2&rate=0.5&tags=a&tags=b", form.Encode())
+
+	// round trip with the form binding.
+	decoded := new(EncodeStruct)
+	assert.NoError(t, mapForm(decoded, form))
+	assert.Equal(t, obj.ID, decoded.ID)
+	assert.Equal(t, obj.Tags, decoded.Tags)
+	assert.Equal(t, obj.IDs, decoded.IDs)
+	assert.Equal(t, obj.Foo, decoded.Foo)
+
+	_, err = EncodeForm(FooStruct{})
+	assert.Error(t, err)
+}
+
+type HeaderStruct struct {
+	ID    int64    `form:"id" json:"id"`
+	Token string   `form:"token" json:"token" header:"X-Token" validate:"required"`
+	Langs []string `json:"langs" header:"Accept-Language"`
+}
+
+func TestBindingHeader(t *testing.T) {
+	obj := &HeaderStruct{ID: 1, Token: "secret", Langs: []string{"zh", "en"}}
+	header, err := EncodeHeader(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", header.Get("X-Token"))
+	assert.Equal(t, []string{"zh", "en"}, header.Values("Accept-Language"))
+	form, err := EncodeForm(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "id=1", form.Encode())
+
+	req, _ := http.NewRequest("GET", "/?"+form.Encode(), nil)
+	req.Header = header
+	decoded := new(HeaderStruct)
+	b := WithHeader(Default("GET", ""))
+	assert.Equal(t, "form+header", b.Name())
+	assert.NoError(t, b.Bind(req, decoded))
+	assert.Equal(t, obj, decoded)
+
+	// the header takes precedence over the body.
+	req, _ = http.NewRequest("POST", "/", bytes.NewBufferString(`{"id":1,"token":"body"}`))
+	req.Header.Set("X-Token", "header")
+	decoded = new(HeaderStruct)
+	assert.NoError(t, WithHeader(JSON).Bind(req, decoded))
+	assert.Equal(t, "header", decoded.Token)
+
+	// the required header is validated after the headers are bound.
+	req, _ = http.NewRequest("GET", "/?id=1", nil)
+	assert.Error(t, WithHeader(Form).Bind(req, new(HeaderStruct)))
+}
@@ -17,13 +17,26 @@ func (f formBinding) Name() string {
 }
 
 func (f formBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := f.decode(req, obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (f formBinding) decode(req *http.Request, obj interface{}) error {
 	if err := req.ParseForm(); err != nil {
 		return errors.WithStack(err)
 	}
 	if err := mapForm(obj, req.Form); err != nil {
 		return err
 	}
-	return validate(obj)
+	// NOTE: the multipart form is parsed ahead by blademaster.
+	if req.MultipartForm != nil {
+		if err := mapFiles(obj, req.MultipartForm.File); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (f formPostBinding) Name() string {
@@ -31,25 +44,36 @@ func (f formPostBinding) Name() string {
 }
 
 func (f formPostBinding) Bind(req *http.Request, obj interface{}) error {
-	if err := req.ParseForm(); err != nil {
-		return errors.WithStack(err)
-	}
-	if err := mapForm(obj, req.PostForm); err != nil {
+	if err := f.decode(req, obj); err != nil {
 		return err
 	}
 	return validate(obj)
 }
 
+func (f formPostBinding) decode(req *http.Request, obj interface{}) error {
+	if err := req.ParseForm(); err != nil {
+		return errors.WithStack(err)
+	}
+	return mapForm(obj, req.PostForm)
+}
+
 func (f formMultipartBinding) Name() string {
 	return "multipart/form-data"
 }
 
 func (f formMultipartBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := f.decode(req, obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (f formMultipartBinding) decode(req *http.Request, obj interface{}) error {
 	if err := req.ParseMultipartForm(defaultMemory); err != nil {
 		return errors.WithStack(err)
 	}
 	if err := mapForm(obj, req.MultipartForm.Value); err != nil {
 		return err
 	}
-	return validate(obj)
+	return mapFiles(obj, req.MultipartForm.File)
 }
@@ -1,6 +1,8 @@
 package binding
 
 import (
+	"mime/multipart"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
@@ -40,6 +42,9 @@ func (c *cache) set(obj reflect.Type) (s *sinfo) {
 		fd.tp = tp.Field(i)
 		tag := fd.tp.Tag.Get("form")
 		fd.name, fd.option = parseTag(tag)
+		if fd.header, _ = parseTag(fd.tp.Tag.Get("header")); fd.header == "-" {
+			fd.header = ""
+		}
 		if defV := fd.tp.Tag.Get("default"); defV != "" {
 			dv := reflect.New(fd.tp.Type).Elem()
 			setWithProperType(fd.tp.Type.Kind(), []string{defV}, dv, fd.option)
@@ -62,6 +67,7 @@ type field struct {
 	tp     reflect.StructField
 	name   string
 	option tagOptions
+	header string // the name of the header tag
 
 	hasDefault   bool          // if field had default value
 	defaultValue reflect.Value // field default value
@@ -77,6 +83,9 @@ func mapForm(ptr interface{}, form map[string][]string) error {
 			continue
 		}
 
+		if isFileField(typeField.Type) {
+			continue
+		}
 		structFieldKind := structField.Kind()
 		inputFieldName := fd.name
 		if inputFieldName == "" {
@@ -119,6 +128,49 @@ func mapForm(ptr interface{}, form map[string][]string) error {
 	return nil
 }
 
+var (
+	_fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	_fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+func isFileField(tp reflect.Type) bool {
+	return tp == _fileHeaderType || tp == _fileHeadersType
+}
+
+// mapFiles sets the *multipart.FileHeader and []*multipart.FileHeader fields by the form tag.
+func mapFiles(ptr interface{}, files map[string][]*multipart.FileHeader) error {
+	sinfo := scache.get(reflect.TypeOf(ptr))
+	val := reflect.ValueOf(ptr).Elem()
+	for i, fd := range sinfo.field {
+		typeField := fd.tp
+		structField := val.Field(i)
+		if !structField.CanSet() {
+			continue
+		}
+		inputFieldName := fd.name
+		if inputFieldName == "" {
+			inputFieldName = typeField.Name
+			if structField.Kind() == reflect.Struct {
+				if err := mapFiles(structField.Addr().Interface(), files); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		fhs, ok := files[inputFieldName]
+		if !ok || len(fhs) == 0 {
+			continue
+		}
+		switch typeField.Type {
+		case _fileHeaderType:
+			structField.Set(reflect.ValueOf(fhs[0]))
+		case _fileHeadersType:
+			structField.Set(reflect.ValueOf(fhs))
+		}
+	}
+	return nil
+}
+
 func setWithProperType(valueKind reflect.Kind, val []string, structField reflect.Value, option tagOptions) error {
 	switch valueKind {
 	case reflect.Int:
@@ -274,3 +326,96 @@ func filterEmpty(val []string) []string {
 	}
 	return filtered
 }
+
+// EncodeForm encodes the struct ptr into form values by the form tag, it's
+// the reverse of the Form binding. The zero values are omitted so that the
+// default tag takes effect on the server, and the fields with the header tag
+// are left to EncodeHeader.
+func EncodeForm(ptr interface{}) (url.Values, error) {
+	form := make(url.Values)
+	if err := encodeForm(ptr, form); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+func encodeForm(ptr interface{}, form url.Values) error {
+	rt := reflect.TypeOf(ptr)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("binding: encode form of non struct pointer %v", rt)
+	}
+	if reflect.ValueOf(ptr).IsNil() {
+		return nil
+	}
+	sinfo := scache.get(rt)
+	val := reflect.ValueOf(ptr).Elem()
+	for i, fd := range sinfo.field {
+		typeField := fd.tp
+		structField := val.Field(i)
+		// the XXX_ fields are generated by protobuf.
+		if typeField.PkgPath != "" || fd.name == "-" || fd.header != "" || isFileField(typeField.Type) || strings.HasPrefix(typeField.Name, "XXX_") {
+			continue
+		}
+		name := fd.name
+		if name == "" {
+			name = typeField.Name
+			if structField.Kind() == reflect.Struct {
+				if err := encodeForm(structField.Addr().Interface(), form); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if structField.IsZero() {
+			continue
+		}
+		if t, ok := structField.Interface().(time.Time); ok {
+			timeFormat := typeField.Tag.Get("time_format")
+			if timeFormat == "" {
+				return errors.Errorf("binding: blank time format of field %s", typeField.Name)
+			}
+			form.Set(name, t.Format(timeFormat))
+			continue
+		}
+		if structField.Kind() == reflect.Slice {
+			values := make([]string, 0, structField.Len())
+			for j := 0; j < structField.Len(); j++ {
+				v, err := formatValue(structField.Index(j))
+				if err != nil {
+					return errors.Wrapf(err, "binding: field %s", typeField.Name)
+				}
+				values = append(values, v)
+			}
+			if fd.option.Contains("split") {
+				form.Set(name, strings.Join(values, ","))
+			} else {
+				form[name] = values
+			}
+			continue
+		}
+		v, err := formatValue(structField)
+		if err != nil {
+			return errors.Wrapf(err, "binding: field %s", typeField.Name)
+		}
+		form.Set(name, v)
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.String:
+		return v.String(), nil
+	}
+	return "", errors.Errorf("unsupported kind %s", v.Kind())
+}
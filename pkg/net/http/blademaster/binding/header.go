@@ -0,0 +1,114 @@
+package binding
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// decoder decodes the request into obj without the validation.
+type decoder interface {
+	decode(*http.Request, interface{}) error
+}
+
+type headerBinding struct {
+	b Binding
+}
+
+// WithHeader returns the binding which binds the fields with the header tag,
+// like `header:"X-Token"`, from the request headers after b. The header values
+// take precedence over the body and query of b, and obj is validated once
+// all the fields are bound.
+func WithHeader(b Binding) Binding {
+	return headerBinding{b: b}
+}
+
+func (h headerBinding) Name() string {
+	return h.b.Name() + "+header"
+}
+
+func (h headerBinding) Bind(req *http.Request, obj interface{}) (err error) {
+	if d, ok := h.b.(decoder); ok {
+		err = d.decode(req, obj)
+	} else {
+		// NOTE: the custom binding validates obj before the headers are bound.
+		err = h.b.Bind(req, obj)
+	}
+	if err != nil {
+		return
+	}
+	if err = mapHeader(obj, req.Header); err != nil {
+		return
+	}
+	return validate(obj)
+}
+
+func mapHeader(ptr interface{}, header http.Header) error {
+	sinfo := scache.get(reflect.TypeOf(ptr))
+	val := reflect.ValueOf(ptr).Elem()
+	for i, fd := range sinfo.field {
+		structField := val.Field(i)
+		if fd.header == "" || !structField.CanSet() {
+			continue
+		}
+		values := header.Values(fd.header)
+		if len(values) == 0 {
+			continue
+		}
+		if _, isTime := structField.Interface().(time.Time); isTime {
+			if err := setTimeField(values[0], fd.tp, structField); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setWithProperType(fd.tp.Type.Kind(), values, structField, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeHeader encodes the fields of the struct ptr with the header tag into
+// the request headers, it's the reverse of WithHeader. The zero values are
+// omitted, and ptr which isn't a struct pointer has no headers.
+func EncodeHeader(ptr interface{}) (http.Header, error) {
+	header := make(http.Header)
+	rt := reflect.TypeOf(ptr)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct || reflect.ValueOf(ptr).IsNil() {
+		return header, nil
+	}
+	sinfo := scache.get(rt)
+	val := reflect.ValueOf(ptr).Elem()
+	for i, fd := range sinfo.field {
+		structField := val.Field(i)
+		if fd.header == "" || fd.tp.PkgPath != "" || structField.IsZero() {
+			continue
+		}
+		if t, ok := structField.Interface().(time.Time); ok {
+			timeFormat := fd.tp.Tag.Get("time_format")
+			if timeFormat == "" {
+				return nil, errors.Errorf("binding: blank time format of field %s", fd.tp.Name)
+			}
+			header.Set(fd.header, t.Format(timeFormat))
+			continue
+		}
+		if structField.Kind() == reflect.Slice {
+			for j := 0; j < structField.Len(); j++ {
+				v, err := formatValue(structField.Index(j))
+				if err != nil {
+					return nil, errors.Wrapf(err, "binding: field %s", fd.tp.Name)
+				}
+				header.Add(fd.header, v)
+			}
+			continue
+		}
+		v, err := formatValue(structField)
+		if err != nil {
+			return nil, errors.Wrapf(err, "binding: field %s", fd.tp.Name)
+		}
+		header.Set(fd.header, v)
+	}
+	return header, nil
+}
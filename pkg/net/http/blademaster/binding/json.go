@@ -13,10 +13,17 @@ func (jsonBinding) Name() string {
 	return "json"
 }
 
-func (jsonBinding) Bind(req *http.Request, obj interface{}) error {
+func (b jsonBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := b.decode(req, obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (b jsonBinding) decode(req *http.Request, obj interface{}) error {
 	decoder := json.NewDecoder(req.Body)
 	if err := decoder.Decode(obj); err != nil {
 		return errors.WithStack(err)
 	}
-	return validate(obj)
+	return nil
 }
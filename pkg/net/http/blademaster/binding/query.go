@@ -10,10 +10,13 @@ func (queryBinding) Name() string {
 	return "query"
 }
 
-func (queryBinding) Bind(req *http.Request, obj interface{}) error {
-	values := req.URL.Query()
-	if err := mapForm(obj, values); err != nil {
+func (b queryBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := b.decode(req, obj); err != nil {
 		return err
 	}
 	return validate(obj)
 }
+
+func (b queryBinding) decode(req *http.Request, obj interface{}) error {
+	return mapForm(obj, req.URL.Query())
+}
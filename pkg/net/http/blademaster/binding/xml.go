@@ -13,10 +13,17 @@ func (xmlBinding) Name() string {
 	return "xml"
 }
 
-func (xmlBinding) Bind(req *http.Request, obj interface{}) error {
+func (b xmlBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := b.decode(req, obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (b xmlBinding) decode(req *http.Request, obj interface{}) error {
 	decoder := xml.NewDecoder(req.Body)
 	if err := decoder.Decode(obj); err != nil {
 		return errors.WithStack(err)
 	}
-	return validate(obj)
+	return nil
 }
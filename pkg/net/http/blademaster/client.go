@@ -49,6 +49,11 @@ type ClientConfig struct {
 	Breaker   *breaker.Config
 	URL       map[string]*ClientConfig
 	Host      map[string]*ClientConfig
+	// Balancer is the balancer of the uri resolved by naming, p2c or wrr, default p2c.
+	Balancer string
+	// Retry is the retry times of the idempotent request on another instance
+	// after the connection errors, default 1, negative to disable.
+	Retry int
 }
 
 // Client is http client.
@@ -62,6 +67,9 @@ type Client struct {
 	hostConf map[string]*ClientConfig
 	mutex    sync.RWMutex
 	breaker  *breaker.Group
+
+	targets   map[string]*target
+	targetsMu sync.Mutex
 }
 
 // NewClient new a http client.
@@ -118,6 +126,12 @@ func (client *Client) SetConfig(c *ClientConfig) {
 		client.dialer.Timeout = time.Duration(c.Dial)
 		client.conf.Timeout = c.Dial
 	}
+	if c.Balancer != "" {
+		client.conf.Balancer = c.Balancer
+	}
+	if c.Retry != 0 {
+		client.conf.Retry = c.Retry
+	}
 	if c.Breaker != nil {
 		client.conf.Breaker = c.Breaker
 		client.breaker.Reload(c.Breaker)
@@ -196,6 +210,13 @@ func (client *Client) RESTfulPost(c context.Context, uri, ip string, params url.
 
 // Raw sends an HTTP request and returns bytes response
 func (client *Client) Raw(c context.Context, req *xhttp.Request, v ...string) (bs []byte, err error) {
+	bs, _, err = client.raw(c, req, v...)
+	return
+}
+
+// raw sends an HTTP request and returns bytes response with the response
+// header, the header is returned even if the status is incorrect.
+func (client *Client) raw(c context.Context, req *xhttp.Request, v ...string) (bs []byte, header xhttp.Header, err error) {
 	var (
 		ok      bool
 		code    string
@@ -256,12 +277,13 @@ func (client *Client) Raw(c context.Context, req *xhttp.Request, v ...string) (b
 			setMetadata(req, key, value)
 		},
 		metadata.IsOutgoingKey)
-	if resp, err = client.client.Do(req); err != nil {
+	if resp, err = client.send(req, config.Retry); err != nil {
 		err = pkgerr.Wrapf(err, "host:%s, url:%s", req.URL.Host, realURL(req))
 		code = "failed"
 		return
 	}
 	defer resp.Body.Close()
+	header = resp.Header
 	if resp.StatusCode >= xhttp.StatusBadRequest {
 		err = pkgerr.Errorf("incorrect http status:%d host:%s, url:%s", resp.StatusCode, req.URL.Host, realURL(req))
 		code = strconv.Itoa(resp.StatusCode)
@@ -0,0 +1,101 @@
+package blademaster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	xhttp "net/http"
+	"strconv"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/env"
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/binding"
+
+	pkgerr "github.com/pkg/errors"
+)
+
+const _httpHeaderStatusCode = "atreus-status-code"
+
+// Call sends the request of the generated typed client to host+path and
+// decodes the data of the response into out. The request is encoded as the
+// query for GET and DELETE, and as the JSON body for the other methods which
+// matches binding.Default of the server, and the fields with the header tag
+// are sent as the request headers for binding.WithHeader. The ecode of the
+// response is returned as error. The path is the key of ClientConfig.URL, so
+// the timeout and breaker can be tuned per method.
+func (client *Client) Call(c context.Context, method, host, path string, in, out interface{}) (err error) {
+	var req *xhttp.Request
+	switch method {
+	case xhttp.MethodGet, xhttp.MethodDelete:
+		uri := host + path
+		if in != nil {
+			params, err := binding.EncodeForm(in)
+			if err != nil {
+				return err
+			}
+			if len(params) > 0 {
+				uri += "?" + params.Encode()
+			}
+		}
+		req, err = xhttp.NewRequest(method, uri, nil)
+	default:
+		var body []byte
+		if body, err = json.Marshal(in); err != nil {
+			return pkgerr.Wrapf(err, "method:%s,path:%s", method, path)
+		}
+		if req, err = xhttp.NewRequest(method, host+path, bytes.NewReader(body)); err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return pkgerr.Wrapf(err, "method:%s,uri:%s", method, host+path)
+	}
+	reqHeader, err := binding.EncodeHeader(in)
+	if err != nil {
+		return err
+	}
+	for k, v := range reqHeader {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", _noKickUserAgent+" "+env.AppID)
+	bs, header, err := client.raw(c, req, path)
+	// the middlewares like auth and ratelimit respond the ecode with an
+	// incorrect http status.
+	if code := header.Get(_httpHeaderStatusCode); err != nil && code != "" && code != "0" {
+		if i, perr := strconv.Atoi(code); perr == nil {
+			return ecode.Int(i)
+		}
+	}
+	if err != nil {
+		return
+	}
+	return decodeResponse(bs, header, out)
+}
+
+// decodeResponse decodes the render.JSON response or the simple json response.
+func decodeResponse(bs []byte, header xhttp.Header, out interface{}) error {
+	var res struct {
+		Code     int             `json:"code"`
+		Message  string          `json:"message"`
+		IsSimple *bool           `json:"isSimple"`
+		Data     json.RawMessage `json:"data"`
+	}
+	data := bs
+	if err := json.Unmarshal(bs, &res); err == nil && res.IsSimple != nil {
+		if res.Code != 0 {
+			return ecode.Error(ecode.Int(res.Code), res.Message)
+		}
+		data = res.Data
+	} else if code := header.Get(_httpHeaderStatusCode); code != "" && code != "0" {
+		// the simple json response carries the ecode by header only.
+		i, err := strconv.Atoi(code)
+		if err != nil {
+			return pkgerr.Errorf("invalid status code header %q", code)
+		}
+		return ecode.Int(i)
+	}
+	if out == nil || len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return pkgerr.WithStack(json.Unmarshal(data, out))
+}
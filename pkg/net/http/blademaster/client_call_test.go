@@ -0,0 +1,105 @@
+package blademaster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/net/netutil/breaker"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type callReq struct {
+	ID   int64  `form:"id" json:"id" validate:"required"`
+	Name string `form:"name" json:"name"`
+}
+
+type callReply struct {
+	Echo string `json:"echo"`
+}
+
+func TestClientCall(t *testing.T) {
+	engine := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Second)})
+	echo := func(c *Context) {
+		req := new(callReq)
+		if err := c.Bind(req); err != nil {
+			return
+		}
+		if req.ID < 0 {
+			c.JSON(nil, ecode.Error(ecode.RequestErr, "negative id"))
+			return
+		}
+		c.JSON(&callReply{Echo: c.Request.Method + " " + req.Name}, nil)
+	}
+	engine.GET("/echo", echo)
+	engine.POST("/echo", echo)
+	engine.GET("/slow", func(c *Context) {
+		time.Sleep(200 * time.Millisecond)
+		c.JSON(nil, nil)
+	})
+	engine.GET("/denied", func(c *Context) {
+		c.JSON(nil, ecode.AccessDenied)
+		c.Abort()
+	})
+	engine.SetMethodConfig("/limited", &MethodConfig{MaxBodySize: 8})
+	engine.POST("/limited", echo)
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+
+	client := NewClient(&ClientConfig{
+		Dial:    xtime.Duration(time.Second),
+		Timeout: xtime.Duration(time.Second),
+		Breaker: &breaker.Config{
+			Window:  xtime.Duration(time.Second),
+			Bucket:  10,
+			K:       1.5,
+			Request: 100,
+		},
+		URL: map[string]*ClientConfig{
+			"/slow": {Timeout: xtime.Duration(50 * time.Millisecond)},
+		},
+	})
+	ctx := context.Background()
+
+	reply := new(callReply)
+	assert.NoError(t, client.Call(ctx, "GET", srv.URL, "/echo", &callReq{ID: 1, Name: "a"}, reply))
+	assert.Equal(t, "GET a", reply.Echo)
+	assert.NoError(t, client.Call(ctx, "POST", srv.URL, "/echo", &callReq{ID: 1, Name: "b"}, reply))
+	assert.Equal(t, "POST b", reply.Echo)
+
+	err := client.Call(ctx, "POST", srv.URL, "/echo", &callReq{ID: -1}, reply)
+	assert.Equal(t, ecode.RequestErr.Code(), ecode.Cause(err).Code())
+	assert.Equal(t, "negative id", ecode.Cause(err).Message())
+	err = client.Call(ctx, "GET", srv.URL, "/echo", &callReq{Name: "a"}, reply)
+	assert.Equal(t, ecode.RequestErr.Code(), ecode.Cause(err).Code())
+	err = client.Call(ctx, "GET", srv.URL, "/denied", nil, nil)
+	assert.Equal(t, ecode.AccessDenied.Code(), ecode.Cause(err).Code())
+	// the ecode of the incorrect http status.
+	err = client.Call(ctx, "POST", srv.URL, "/limited", &callReq{ID: 1, Name: "too large"}, nil)
+	assert.Equal(t, ecode.RequestEntityTooLarge, err)
+
+	// the timeout of the method is configured by the path.
+	assert.Error(t, client.Call(ctx, "GET", srv.URL, "/slow", nil, nil))
+
+}
+
+func TestDecodeResponse(t *testing.T) {
+	reply := new(callReply)
+	assert.NoError(t, decodeResponse([]byte(`{"code":0,"message":"0","ttl":1,"isSimple":false,"data":{"echo":"a"}}`), nil, reply))
+	assert.Equal(t, "a", reply.Echo)
+	err := decodeResponse([]byte(`{"code":-404,"message":"not found","ttl":1,"isSimple":false}`), nil, reply)
+	assert.Equal(t, -404, ecode.Cause(err).Code())
+
+	// simple json carries the ecode by header.
+	header := http.Header{}
+	header.Set(_httpHeaderStatusCode, "0")
+	assert.NoError(t, decodeResponse([]byte(`{"echo":"b"}`), header, reply))
+	assert.Equal(t, "b", reply.Echo)
+	header.Set(_httpHeaderStatusCode, "-403")
+	assert.Equal(t, ecode.AccessDenied, decodeResponse([]byte(`null`), header, reply))
+}
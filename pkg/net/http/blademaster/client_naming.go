@@ -0,0 +1,410 @@
+package blademaster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	xhttp "net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/env"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/net/metadata"
+
+	pkgerr "github.com/pkg/errors"
+)
+
+// balancers of the client.
+const (
+	BalancerP2C = "p2c"
+	BalancerWRR = "wrr"
+)
+
+const (
+	// the mean lifetime of the latency and success rate of a node.
+	_nodeTau = int64(600 * time.Millisecond)
+	// the latency of the node without statistics.
+	_nodePenalty = uint64(250 * time.Millisecond)
+)
+
+// ErrNoInstance is returned if the appid has no available instance.
+var ErrNoInstance = errors.New("blademaster: no available instance")
+
+var (
+	_buildersMu sync.RWMutex
+	_builders   = make(map[string]naming.Builder)
+)
+
+// RegisterResolver registers the naming builder so that the client resolves
+// the uri with the scheme of the builder, e.g. discovery://appid/path or
+// etcd://appid/path, the builder of the same scheme is replaced.
+func RegisterResolver(b naming.Builder) {
+	_buildersMu.Lock()
+	_builders[b.Scheme()] = b
+	_buildersMu.Unlock()
+}
+
+func resolverBuilder(scheme string) (b naming.Builder, ok bool) {
+	_buildersMu.RLock()
+	b, ok = _builders[scheme]
+	_buildersMu.RUnlock()
+	return
+}
+
+// send sends the request, the host of the uri with a registered naming scheme
+// is resolved as the appid and balanced across its instances. The idempotent
+// requests are retried on another instance after the connection errors.
+func (client *Client) send(req *xhttp.Request, retry int) (resp *xhttp.Response, err error) {
+	b, ok := resolverBuilder(req.URL.Scheme)
+	if !ok {
+		return client.client.Do(req)
+	}
+	t := client.target(b, req.URL.Host)
+	ctx := req.Context()
+	color := metadata.String(ctx, metadata.Color)
+	if color == "" {
+		color = env.Color
+	}
+	if retry == 0 {
+		retry = 1
+	}
+	var tried map[string]struct{}
+	for i := 0; ; i++ {
+		n, perr := t.pick(ctx, color, tried)
+		if perr != nil {
+			if i == 0 {
+				err = pkgerr.Wrapf(perr, "appid:%s", t.appid)
+			}
+			// NOTE: return the error of the last node if no other node to retry.
+			return
+		}
+		r := new(xhttp.Request)
+		*r = *req
+		u := *req.URL
+		u.Scheme, u.Host = n.scheme, n.host
+		r.URL, r.Host = &u, n.host
+		if i > 0 && req.Body != nil && req.Body != xhttp.NoBody {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, pkgerr.WithStack(err)
+			}
+		}
+		start := time.Now()
+		resp, err = client.client.Do(r)
+		n.done(err, time.Since(start))
+		if err == nil || i >= retry || ctx.Err() != nil || !retryable(req, err) {
+			return
+		}
+		log.Warn("blademaster: appid(%s) node(%s) connection error(%v), retry on another node", t.appid, n.host, err)
+		if tried == nil {
+			tried = make(map[string]struct{})
+		}
+		tried[n.host] = struct{}{}
+	}
+}
+
+// retryable reports whether the request can be sent again after err, only the
+// idempotent requests failed before the server processed them are retried.
+func retryable(req *xhttp.Request, err error) bool {
+	switch req.Method {
+	case xhttp.MethodGet, xhttp.MethodHead, xhttp.MethodOptions, xhttp.MethodPut, xhttp.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	if req.Body != nil && req.Body != xhttp.NoBody && req.GetBody == nil {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF)
+}
+
+// target returns the resolved target of the appid, it's built on first use.
+func (client *Client) target(b naming.Builder, appid string) *target {
+	key := b.Scheme() + "://" + appid
+	client.targetsMu.Lock()
+	defer client.targetsMu.Unlock()
+	if client.targets == nil {
+		client.targets = make(map[string]*target)
+	}
+	t, ok := client.targets[key]
+	if !ok {
+		t = newTarget(b, appid, client.conf.Balancer)
+		client.targets[key] = t
+	}
+	return t
+}
+
+// Close stops resolving the appids.
+func (client *Client) Close() error {
+	client.targetsMu.Lock()
+	defer client.targetsMu.Unlock()
+	for key, t := range client.targets {
+		t.close()
+		delete(client.targets, key)
+	}
+	return nil
+}
+
+// target is the instances of an appid resolved by naming.
+type target struct {
+	appid    string
+	zone     string
+	balancer string
+	nr       naming.Resolver
+
+	picker atomic.Value // *picker
+	nodes  map[string]*endpoint
+
+	ready     chan struct{}
+	readyOnce sync.Once
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+func newTarget(b naming.Builder, appid, balancer string) *target {
+	if balancer == "" {
+		balancer = BalancerP2C
+	}
+	t := &target{
+		appid:    appid,
+		zone:     env.Zone,
+		balancer: balancer,
+		nr:       b.Build(appid),
+		nodes:    make(map[string]*endpoint),
+		ready:    make(chan struct{}),
+		quit:     make(chan struct{}),
+	}
+	go t.updateproc()
+	return t
+}
+
+func (t *target) updateproc() {
+	event := t.nr.Watch()
+	for {
+		select {
+		case <-t.quit:
+			return
+		case _, ok := <-event:
+			if !ok {
+				return
+			}
+		}
+		if ins, ok := t.nr.Fetch(context.Background()); ok {
+			t.update(ins)
+		}
+	}
+}
+
+// update rebuilds the picker with the instances of the local zone, or the
+// zones scheduled for the local zone, the statistics of the nodes are kept.
+func (t *target) update(ins *naming.InstancesInfo) {
+	p := &picker{colors: make(map[string]*pool)}
+	nodes := make(map[string]*endpoint)
+	for _, in := range ins.UseScheduler(t.zone) {
+		if in.Status == naming.StatusWaiting {
+			continue
+		}
+		u := instanceURL(in)
+		if u == nil {
+			log.Warn("blademaster: app(%s,%s) no valid http address(%v) found", in.AppID, in.Hostname, in.Addrs)
+			continue
+		}
+		n, ok := t.nodes[u.Host]
+		if !ok {
+			n = &endpoint{scheme: u.Scheme, host: u.Host, success: 1000}
+		}
+		n.weight = 10
+		if w, _ := strconv.ParseInt(in.Metadata[naming.MetaWeight], 10, 64); w > 0 {
+			n.weight = w
+		}
+		nodes[n.host] = n
+		pl := &p.pool
+		if color := in.Metadata[naming.MetaColor]; color != "" {
+			if pl = p.colors[color]; pl == nil {
+				pl = new(pool)
+				p.colors[color] = pl
+			}
+		}
+		pl.nodes = append(pl.nodes, n)
+	}
+	if len(nodes) == 0 {
+		log.Warn("blademaster: appid(%s) no instance resolved, keep the old ones", t.appid)
+		return
+	}
+	p.balancer = t.balancer
+	t.nodes = nodes
+	t.picker.Store(p)
+	t.readyOnce.Do(func() { close(t.ready) })
+}
+
+func (t *target) pick(ctx context.Context, color string, exclude map[string]struct{}) (*endpoint, error) {
+	select {
+	case <-t.ready:
+	case <-ctx.Done():
+		return nil, ErrNoInstance
+	}
+	p := t.picker.Load().(*picker)
+	pl := &p.pool
+	if color != "" {
+		if cp, ok := p.colors[color]; ok {
+			pl = cp
+		}
+	}
+	if p.balancer == BalancerWRR {
+		return pl.wrr(exclude)
+	}
+	return pl.p2c(exclude)
+}
+
+func (t *target) close() {
+	t.closeOnce.Do(func() {
+		close(t.quit)
+		t.nr.Close()
+	})
+}
+
+func instanceURL(in *naming.Instance) *url.URL {
+	for _, addr := range in.Addrs {
+		if u, err := url.Parse(addr); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			return u
+		}
+	}
+	return nil
+}
+
+// picker picks the node of the request color, the colored nodes are only
+// picked by the requests of the same color.
+type picker struct {
+	pool
+	colors   map[string]*pool
+	balancer string
+}
+
+type pool struct {
+	mu    sync.Mutex
+	nodes []*endpoint
+}
+
+// endpoint is an instance with the client statistics.
+type endpoint struct {
+	scheme string
+	host   string
+	weight int64
+
+	lag      uint64 // ewma of the latency in ns
+	success  uint64 // ewma of the success rate in 1/1000
+	inflight int64
+	stamp    int64
+
+	current int64 // the current weight of wrr, guarded by pool.mu
+}
+
+func (n *endpoint) load() float64 {
+	lag := atomic.LoadUint64(&n.lag)
+	if lag == 0 {
+		lag = _nodePenalty
+	}
+	return (math.Sqrt(float64(lag)) + 1) * float64(atomic.LoadInt64(&n.inflight)+1)
+}
+
+func (n *endpoint) health() float64 {
+	return float64(atomic.LoadUint64(&n.success) + 1)
+}
+
+// done updates the statistics with the exponentially weighted moving average.
+func (n *endpoint) done(err error, lag time.Duration) {
+	atomic.AddInt64(&n.inflight, -1)
+	now := time.Now().UnixNano()
+	td := now - atomic.SwapInt64(&n.stamp, now)
+	if td < 0 {
+		td = 0
+	}
+	w := math.Exp(float64(-td) / float64(_nodeTau))
+	var success uint64 = 1000
+	if err != nil {
+		success = 0
+	}
+	oldLag := atomic.LoadUint64(&n.lag)
+	if oldLag == 0 {
+		w = 0
+	}
+	atomic.StoreUint64(&n.lag, uint64(float64(oldLag)*w+float64(lag)*(1-w)))
+	atomic.StoreUint64(&n.success, uint64(float64(atomic.LoadUint64(&n.success))*w+float64(success)*(1-w)))
+}
+
+func (p *pool) candidates(exclude map[string]struct{}) []*endpoint {
+	if len(exclude) == 0 {
+		return p.nodes
+	}
+	nodes := make([]*endpoint, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		if _, ok := exclude[n.host]; !ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// p2c picks the node of the lower load from two random nodes, the load is
+// weighted by the health and the weight of the nodes.
+func (p *pool) p2c(exclude map[string]struct{}) (*endpoint, error) {
+	nodes := p.candidates(exclude)
+	var n *endpoint
+	switch len(nodes) {
+	case 0:
+		return nil, ErrNoInstance
+	case 1:
+		n = nodes[0]
+	default:
+		i := rand.Intn(len(nodes))
+		j := rand.Intn(len(nodes) - 1)
+		if j >= i {
+			j++
+		}
+		a, b := nodes[i], nodes[j]
+		if a.load()*b.health()*float64(b.weight) > b.load()*a.health()*float64(a.weight) {
+			a = b
+		}
+		n = a
+	}
+	atomic.AddInt64(&n.inflight, 1)
+	return n, nil
+}
+
+// wrr picks the node by the smooth weighted round-robin.
+func (p *pool) wrr(exclude map[string]struct{}) (*endpoint, error) {
+	p.mu.Lock()
+	var (
+		best  *endpoint
+		total int64
+	)
+	for _, n := range p.candidates(exclude) {
+		n.current += n.weight
+		total += n.weight
+		if best == nil || n.current > best.current {
+			best = n
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	p.mu.Unlock()
+	if best == nil {
+		return nil, ErrNoInstance
+	}
+	atomic.AddInt64(&best.inflight, 1)
+	return best, nil
+}
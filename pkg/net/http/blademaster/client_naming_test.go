@@ -0,0 +1,169 @@
+package blademaster
+
+import (
+	"context"
+	"errors"
+	"net"
+	xhttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBuilder struct {
+	ins map[string][]*naming.Instance
+}
+
+func (b *fakeBuilder) Build(appid string) naming.Resolver {
+	r := &fakeResolver{ins: b.ins, event: make(chan struct{}, 1)}
+	r.event <- struct{}{}
+	return r
+}
+
+func (b *fakeBuilder) Scheme() string { return "fake" }
+
+type fakeResolver struct {
+	ins   map[string][]*naming.Instance
+	event chan struct{}
+}
+
+func (r *fakeResolver) Fetch(context.Context) (*naming.InstancesInfo, bool) {
+	return &naming.InstancesInfo{Instances: r.ins}, true
+}
+
+func (r *fakeResolver) Watch() <-chan struct{} { return r.event }
+
+func (r *fakeResolver) Close() error { return nil }
+
+func TestClientNaming(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(xhttp.HandlerFunc(func(w xhttp.ResponseWriter, r *xhttp.Request) {
+			w.Write([]byte(`{"code":0,"data":"` + name + `"}`))
+		}))
+	}
+	s1, s2, red := newServer("s1"), newServer("s2"), newServer("red")
+	defer s1.Close()
+	defer s2.Close()
+	defer red.Close()
+	dead := httptest.NewServer(xhttp.NotFoundHandler())
+	dead.Close()
+	instance := func(addr, color string) *naming.Instance {
+		return &naming.Instance{
+			AppID:    "demo.service",
+			Addrs:    []string{addr, "grpc://127.0.0.1:9000"},
+			Metadata: map[string]string{naming.MetaColor: color},
+		}
+	}
+	RegisterResolver(&fakeBuilder{ins: map[string][]*naming.Instance{
+		"sh001": {instance(s1.URL, ""), instance(s2.URL, ""), instance(dead.URL, ""), instance(red.URL, "red")},
+	}})
+	client := NewClient(&ClientConfig{
+		Dial:    xtime.Duration(time.Second),
+		Timeout: xtime.Duration(time.Second),
+	})
+	defer client.Close()
+
+	var res struct {
+		Code int    `json:"code"`
+		Data string `json:"data"`
+	}
+	hits := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		err := client.Get(context.Background(), "fake://demo.service/ping", "", nil, &res)
+		assert.NoError(t, err)
+		hits[res.Data]++
+	}
+	assert.Zero(t, hits["red"])
+	assert.NotZero(t, hits["s1"])
+	assert.NotZero(t, hits["s2"])
+
+	ctx := metadata.NewContext(context.Background(), metadata.MD{metadata.Color: "red"})
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Get(ctx, "fake://demo.service/ping", "", nil, &res))
+		assert.Equal(t, "red", res.Data)
+	}
+}
+
+func TestClientNamingNoRetry(t *testing.T) {
+	dead := httptest.NewServer(xhttp.NotFoundHandler())
+	dead.Close()
+	RegisterResolver(&fakeNoRetryBuilder{fakeBuilder{ins: map[string][]*naming.Instance{
+		"sh001": {{AppID: "dead.service", Addrs: []string{dead.URL}}},
+	}}})
+	client := NewClient(&ClientConfig{
+		Dial:    xtime.Duration(time.Second),
+		Timeout: xtime.Duration(time.Second),
+	})
+	defer client.Close()
+	var res struct{}
+	err := client.Get(context.Background(), "dead://dead.service/ping", "", nil, &res)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	RegisterResolver(&fakeNoRetryBuilder{fakeBuilder{}})
+	client2 := NewClient(&ClientConfig{Dial: xtime.Duration(time.Second), Timeout: xtime.Duration(time.Second)})
+	defer client2.Close()
+	err = client2.Get(ctx, "dead://empty.service/ping", "", nil, &res)
+	assert.True(t, errors.Is(err, ErrNoInstance))
+}
+
+type fakeNoRetryBuilder struct {
+	fakeBuilder
+}
+
+func (b *fakeNoRetryBuilder) Scheme() string { return "dead" }
+
+func TestRetryable(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	get, _ := xhttp.NewRequest(xhttp.MethodGet, "fake://demo/ping", nil)
+	post, _ := xhttp.NewRequest(xhttp.MethodPost, "fake://demo/ping", nil)
+	assert.True(t, retryable(get, dialErr))
+	assert.False(t, retryable(get, errors.New("timeout")))
+	assert.False(t, retryable(post, dialErr))
+	post.Header.Set("Idempotency-Key", "1")
+	assert.True(t, retryable(post, dialErr))
+}
+
+func TestBalancerWRR(t *testing.T) {
+	a := &endpoint{host: "a", weight: 1}
+	b := &endpoint{host: "b", weight: 3}
+	p := &pool{nodes: []*endpoint{a, b}}
+	hits := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		n, err := p.wrr(nil)
+		assert.NoError(t, err)
+		hits[n.host]++
+	}
+	assert.Equal(t, 2, hits["a"])
+	assert.Equal(t, 6, hits["b"])
+
+	n, err := p.wrr(map[string]struct{}{"b": {}})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", n.host)
+	_, err = p.wrr(map[string]struct{}{"a": {}, "b": {}})
+	assert.Equal(t, ErrNoInstance, err)
+}
+
+func TestBalancerP2C(t *testing.T) {
+	fast := &endpoint{host: "fast", weight: 10, success: 1000}
+	slow := &endpoint{host: "slow", weight: 10, success: 1000}
+	fast.inflight, slow.inflight = 10, 10
+	for i := 0; i < 10; i++ {
+		fast.done(nil, time.Millisecond)
+		slow.done(nil, 100*time.Millisecond)
+	}
+	p := &pool{nodes: []*endpoint{fast, slow}}
+	for i := 0; i < 10; i++ {
+		n, err := p.p2c(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "fast", n.host)
+		n.done(nil, time.Millisecond)
+	}
+}
@@ -0,0 +1,262 @@
+package blademaster
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+)
+
+const (
+	_encodingGzip   = "gzip"
+	_encodingBrotli = "br"
+)
+
+var _defaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/x-protobuf",
+	"image/svg+xml",
+}
+
+// CompressConfig is the compression middleware config.
+type CompressConfig struct {
+	// Level is the compression level, the default level of each encoding is used if zero.
+	Level int
+	// MinSize is the minimum response size in bytes to compress, 1024 by default.
+	MinSize int
+	// ContentTypes is the prefixes of the content types to compress.
+	ContentTypes []string
+	// DisableBrotli only negotiates gzip.
+	DisableBrotli bool
+}
+
+// Compress returns a middleware which compresses the response by gzip or
+// brotli according to Accept-Encoding. The responses with Content-Encoding
+// set, a content type not in ContentTypes or less than MinSize are sent as they are.
+func Compress(conf *CompressConfig) HandlerFunc {
+	c := &CompressConfig{}
+	if conf != nil {
+		*c = *conf
+	}
+	if c.MinSize <= 0 {
+		c.MinSize = 1024
+	}
+	if len(c.ContentTypes) == 0 {
+		c.ContentTypes = _defaultCompressTypes
+	}
+	gzipLevel, brLevel := gzip.DefaultCompression, brotli.DefaultCompression
+	if c.Level != 0 {
+		gzipLevel, brLevel = c.Level, c.Level
+	}
+	pools := map[string]*sync.Pool{
+		_encodingGzip: {New: func() interface{} {
+			w, err := gzip.NewWriterLevel(io.Discard, gzipLevel)
+			if err != nil {
+				w = gzip.NewWriter(io.Discard)
+			}
+			return w
+		}},
+		_encodingBrotli: {New: func() interface{} {
+			return brotli.NewWriterLevel(io.Discard, brLevel)
+		}},
+	}
+	return func(ctx *Context) {
+		req := ctx.Request
+		if req.Method == http.MethodHead || req.Header.Get("Upgrade") != "" {
+			return
+		}
+		encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"), !c.DisableBrotli)
+		if encoding == "" {
+			return
+		}
+		w := &compressWriter{
+			ResponseWriter: ctx.Writer,
+			conf:           c,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			status:         http.StatusOK,
+		}
+		ctx.Writer.Header().Add("Vary", "Accept-Encoding")
+		ctx.Writer = w
+		defer func() {
+			w.close()
+			ctx.Writer = w.ResponseWriter
+		}()
+		ctx.Next()
+	}
+}
+
+// negotiateEncoding picks br or gzip by the q-values of Accept-Encoding, br is preferred.
+func negotiateEncoding(accept string, br bool) (encoding string) {
+	var best float64
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		name, q := part, 1.0
+		if idx := strings.IndexByte(part, ';'); idx >= 0 {
+			name = strings.TrimSpace(part[:idx])
+			if v := strings.TrimSpace(part[idx+1:]); strings.HasPrefix(v, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(v[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case _encodingBrotli:
+			if br && q >= best {
+				encoding, best = _encodingBrotli, q
+			}
+		case _encodingGzip, "*":
+			if q > best {
+				encoding, best = _encodingGzip, q
+			}
+		}
+	}
+	return
+}
+
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// compressWriter buffers the response until MinSize to decide whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	conf     *CompressConfig
+	encoding string
+	pool     *sync.Pool
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.conf.MinSize {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide writes the header and the buffered data, compressed if it's worth.
+func (w *compressWriter) decide(enough bool) (err error) {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if enough && w.compressible(header) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		// NOTE: the strong ETag isn't valid for the encoded representation.
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.enc = w.pool.Get().(compressor)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return
+	}
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return
+}
+
+func (w *compressWriter) compressible(header http.Header) bool {
+	if !bodyAllowedForStatus(w.status) || w.status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(w.buf)
+	}
+	for _, t := range w.conf.ContentTypes {
+		if strings.HasPrefix(ct, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush implements http.Flusher, the buffered data is sent at once.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.conf.MinSize)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("blademaster: response writer doesn't support hijack")
+}
+
+// SetWriteDeadline lifts the write deadline for streaming.
+func (w *compressWriter) SetWriteDeadline(t time.Time) error {
+	if wd, ok := w.ResponseWriter.(writeDeadliner); ok {
+		return wd.SetWriteDeadline(t)
+	}
+	return errors.New("blademaster: response writer doesn't support write deadline")
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.conf.MinSize)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
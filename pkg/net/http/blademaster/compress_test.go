@@ -0,0 +1,89 @@
+package blademaster
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		br     bool
+		want   string
+	}{
+		{"", true, ""},
+		{"gzip", true, "gzip"},
+		{"gzip, deflate, br", true, "br"},
+		{"gzip, deflate, br", false, "gzip"},
+		{"br;q=0.5, gzip;q=0.8", true, "gzip"},
+		{"gzip;q=0, identity", true, ""},
+		{"*", true, "gzip"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, negotiateEncoding(test.accept, test.br), test.accept)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("atreus ", 512)
+	e := NewServer(nil)
+	e.UseFunc(Compress(nil))
+	e.GET("/large", func(c *Context) {
+		c.String(200, large)
+	})
+	e.GET("/small", func(c *Context) {
+		c.String(200, "atreus")
+	})
+	e.GET("/png", func(c *Context) {
+		c.Bytes(200, "image/png", []byte(large))
+	})
+	e.GET("/encoded", func(c *Context) {
+		c.Writer.Header().Set("Content-Encoding", "gzip")
+		c.Bytes(200, "text/plain", []byte(large))
+	})
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/large", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	gr, err := gzip.NewReader(w.Body)
+	if assert.NoError(t, err) {
+		body, _ := ioutil.ReadAll(gr)
+		assert.Equal(t, large, string(body))
+	}
+
+	w = do("/large", "gzip, br")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	body, _ := ioutil.ReadAll(brotli.NewReader(w.Body))
+	assert.Equal(t, large, string(body))
+
+	for _, path := range []string{"/small", "/png"} {
+		w = do(path, "gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"), path)
+		assert.Equal(t, 200, w.Code, path)
+	}
+	w = do("/small", "gzip")
+	assert.Equal(t, "atreus", w.Body.String())
+
+	w = do("/encoded", "br")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	w = do("/large", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+}
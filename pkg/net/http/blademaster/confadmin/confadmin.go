@@ -0,0 +1,156 @@
+// Package confadmin provides the blademaster admin endpoints of the paladin
+// config history, they are registered only by Register.
+package confadmin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+
+	"github.com/pkg/errors"
+)
+
+type configVersion struct {
+	Version int64     `json:"version"`
+	Hash    string    `json:"hash"`
+	Time    time.Time `json:"time"`
+	Pinned  bool      `json:"pinned"`
+}
+
+type configKey struct {
+	Key     string `json:"key"`
+	Version int64  `json:"version"`
+	Pinned  int64  `json:"pinned,omitempty"`
+}
+
+// Register registers the admin endpoints of the paladin config history under
+// /debug/paladin, which list the versions, show the diffs, pin a key to a
+// previous version and release it. They respond NothingFound if the history
+// is disabled, see paladin -conf.history.
+//
+// The endpoints change the running config and aren't authenticated, the
+// handlers such as an auth middleware are run before them, and the engine
+// should listen on an internal admin port rather than the business port.
+func Register(engine *bm.Engine, handlers ...bm.HandlerFunc) {
+	group := engine.Group("/debug/paladin", handlers...)
+	{
+		group.GET("/versions", configVersions)
+		group.GET("/diff", configDiff)
+		group.POST("/pin", configPin)
+		group.POST("/release", configRelease)
+	}
+}
+
+func configHistory(c *bm.Context) (h *paladin.History, key string, ok bool) {
+	if h = paladin.DefaultHistory; h == nil {
+		c.JSON(nil, errors.Wrap(ecode.NothingFound, "paladin config history is disabled"))
+		return
+	}
+	return h, c.Request.FormValue("key"), true
+}
+
+func configVersionParam(c *bm.Context, name string) (int64, bool) {
+	s := c.Request.FormValue(name)
+	if s == "" {
+		return 0, true
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		c.JSON(nil, errors.Wrapf(ecode.RequestErr, "invalid %s(%s)", name, s))
+		return 0, false
+	}
+	return v, true
+}
+
+func configError(err error) error {
+	if errors.Cause(err) == paladin.ErrVersionNotExist {
+		return errors.Wrap(ecode.NothingFound, err.Error())
+	}
+	return err
+}
+
+// configVersions lists the keys with the latest and pinned versions, or the
+// versions of the key.
+func configVersions(c *bm.Context) {
+	h, key, ok := configHistory(c)
+	if !ok {
+		return
+	}
+	if key == "" {
+		keys := make([]*configKey, 0)
+		for _, key := range h.Keys() {
+			v, err := h.Version(key, 0)
+			if err != nil {
+				continue
+			}
+			ck := &configKey{Key: key, Version: v.Version}
+			ck.Pinned, _ = h.Pinned(key)
+			keys = append(keys, ck)
+		}
+		c.JSON(keys, nil)
+		return
+	}
+	pinned, _ := h.Pinned(key)
+	versions := make([]*configVersion, 0)
+	for _, v := range h.Versions(key) {
+		versions = append(versions, &configVersion{Version: v.Version, Hash: v.Hash, Time: v.Time, Pinned: v.Version == pinned})
+	}
+	if len(versions) == 0 {
+		c.JSON(nil, errors.Wrapf(ecode.NothingFound, "key(%s) has no versions", key))
+		return
+	}
+	c.JSON(versions, nil)
+}
+
+// configDiff shows the diff of the key between the versions from and to,
+// the latest version if to is empty and the previous version if from is empty.
+func configDiff(c *bm.Context) {
+	h, key, ok := configHistory(c)
+	if !ok {
+		return
+	}
+	from, ok := configVersionParam(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := configVersionParam(c, "to")
+	if !ok {
+		return
+	}
+	diff, err := h.Diff(key, from, to)
+	if err != nil {
+		c.JSON(nil, configError(err))
+		return
+	}
+	c.Bytes(200, "text/plain; charset=utf-8", []byte(diff))
+}
+
+// configPin pins the key to the version until it's released.
+func configPin(c *bm.Context) {
+	h, key, ok := configHistory(c)
+	if !ok {
+		return
+	}
+	version, ok := configVersionParam(c, "version")
+	if !ok {
+		return
+	}
+	if version == 0 {
+		c.JSON(nil, errors.Wrap(ecode.RequestErr, "version is required"))
+		return
+	}
+	c.JSON(nil, configError(h.Pin(key, version)))
+}
+
+// configRelease releases the pinned key to the latest version.
+func configRelease(c *bm.Context) {
+	h, key, ok := configHistory(c)
+	if !ok {
+		return
+	}
+	h.Release(key)
+	c.JSON(nil, nil)
+}
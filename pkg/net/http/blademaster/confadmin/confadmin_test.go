@@ -0,0 +1,84 @@
+package confadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigAdmin(t *testing.T) {
+	e := bm.NewServer(nil)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	code := func(w *httptest.ResponseRecorder) int {
+		var resp struct {
+			Code int `json:"code"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Code
+	}
+
+	// the endpoints aren't registered by default, and the handlers are run
+	// before them.
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/debug/paladin/versions").Code)
+	Register(e, func(c *bm.Context) {
+		if c.Request.Header.Get("Authorization") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	})
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/debug/paladin/versions").Code)
+	do = func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "admin")
+		e.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, -404, code(do(http.MethodGet, "/debug/paladin/versions")))
+
+	mock := paladin.NewMock(map[string]string{"app.toml": "a = 1"}).(*paladin.Mock)
+	h, err := paladin.NewHistory(mock, 10, "")
+	assert.Nil(t, err)
+	defer h.Close()
+	paladin.DefaultHistory = h
+	defer func() { paladin.DefaultHistory = nil }()
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "app.toml", Value: "a = 2"}
+	mock.C <- paladin.Event{Event: paladin.EventUpdate, Key: "app.toml", Value: "a = 2"}
+
+	var keys struct {
+		Data []*configKey `json:"data"`
+	}
+	w := do(http.MethodGet, "/debug/paladin/versions")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	assert.Equal(t, []*configKey{{Key: "app.toml", Version: 2}}, keys.Data)
+
+	w = do(http.MethodGet, "/debug/paladin/diff?key=app.toml")
+	assert.Equal(t, "-a = 1\n+a = 2\n", w.Body.String())
+	assert.Equal(t, -404, code(do(http.MethodGet, "/debug/paladin/diff?key=app.toml&from=5")))
+	assert.Equal(t, -400, code(do(http.MethodGet, "/debug/paladin/diff?key=app.toml&to=x")))
+
+	assert.Equal(t, -400, code(do(http.MethodPost, "/debug/paladin/pin?key=app.toml")))
+	assert.Equal(t, 0, code(do(http.MethodPost, "/debug/paladin/pin?key=app.toml&version=1")))
+	s, _ := h.Get("app.toml").String()
+	assert.Equal(t, "a = 1", s)
+	var versions struct {
+		Data []*configVersion `json:"data"`
+	}
+	w = do(http.MethodGet, "/debug/paladin/versions?key=app.toml")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &versions))
+	assert.Len(t, versions.Data, 2)
+	assert.True(t, versions.Data[0].Pinned)
+
+	assert.Equal(t, 0, code(do(http.MethodPost, "/debug/paladin/release?key=app.toml")))
+	_, ok := h.Pinned("app.toml")
+	assert.False(t, ok)
+}
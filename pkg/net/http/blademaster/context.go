@@ -302,6 +302,10 @@ func (c *Context) Bind(obj interface{}) error {
 // See the binding package.
 func (c *Context) mustBindWith(obj interface{}, b binding.Binding) (err error) {
 	if err = b.Bind(c.Request, obj); err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			c.abortBodyTooLarge()
+			return
+		}
 		c.Error = ecode.RequestErr
 		c.Render(http.StatusOK, render.JSON{
 			Code:    ecode.RequestErr.Code(),
@@ -315,5 +319,5 @@ func (c *Context) mustBindWith(obj interface{}, b binding.Binding) (err error) {
 
 func writeStatusCode(w http.ResponseWriter, ecode int) {
 	header := w.Header()
-	header.Set("atreus-status-code", strconv.FormatInt(int64(ecode), 10))
+	header.Set(_httpHeaderStatusCode, strconv.FormatInt(int64(ecode), 10))
 }
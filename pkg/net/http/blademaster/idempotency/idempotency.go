@@ -0,0 +1,238 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/render"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+)
+
+const (
+	_httpHeaderStatus = "atreus-status-code"
+	// _headerReplayed is set on the replayed responses.
+	_headerReplayed = "Idempotent-Replayed"
+	// _userTitleKey is the context key of the auth.UserTitle set by the auth middleware.
+	_userTitleKey = "user_title"
+	_maxKeyLen    = 255
+)
+
+// Config is the idempotency middleware config.
+type Config struct {
+	// Header is the header of the idempotency key, "Idempotency-Key" by default.
+	Header string
+	// Prefix is the prefix of the store key, "idempotency" by default.
+	Prefix string
+	// TTL is how long the finished response is replayed, 24h by default.
+	TTL xtime.Duration
+	// LockTTL is how long a key is claimed by the in-flight request, 1m by default.
+	LockTTL xtime.Duration
+	// Methods is the request methods applied, POST by default.
+	Methods []string
+	// ScopeRoute scopes the key by the method and route path.
+	ScopeRoute bool
+	// ScopeUser scopes the key by the authenticated user.
+	ScopeUser bool
+	// MaxBodySize is the max response body size in bytes to store, 1MB by default,
+	// the key is released if the response exceeds it.
+	MaxBodySize int
+}
+
+// Idempotency is the idempotency-key middleware.
+type Idempotency struct {
+	conf    *Config
+	store   Store
+	methods map[string]struct{}
+}
+
+// New new an idempotency middleware.
+func New(conf *Config, store Store) *Idempotency {
+	c := &Config{}
+	if conf != nil {
+		*c = *conf
+	}
+	if c.Header == "" {
+		c.Header = "Idempotency-Key"
+	}
+	if c.Prefix == "" {
+		c.Prefix = "idempotency"
+	}
+	if c.TTL <= 0 {
+		c.TTL = xtime.Duration(24 * time.Hour)
+	}
+	if c.LockTTL <= 0 {
+		c.LockTTL = xtime.Duration(time.Minute)
+	}
+	if len(c.Methods) == 0 {
+		c.Methods = []string{http.MethodPost}
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = 1 << 20
+	}
+	methods := make(map[string]struct{}, len(c.Methods))
+	for _, m := range c.Methods {
+		methods[strings.ToUpper(m)] = struct{}{}
+	}
+	return &Idempotency{conf: c, store: store, methods: methods}
+}
+
+// ServeHTTP implements bm.Handler, it must be used after the auth middleware if ScopeUser is set.
+func (i *Idempotency) ServeHTTP(c *bm.Context) {
+	if _, ok := i.methods[c.Request.Method]; !ok {
+		return
+	}
+	ikey := c.Request.Header.Get(i.conf.Header)
+	if ikey == "" {
+		return
+	}
+	if len(ikey) > _maxKeyLen {
+		c.JSON(nil, ecode.RequestErr)
+		c.Abort()
+		return
+	}
+	key := i.key(c, ikey)
+	claimed, rec, err := i.store.Claim(c, key, time.Duration(i.conf.LockTTL))
+	if err != nil {
+		// NOTE: fail open, the request is served without idempotency.
+		log.Error("idempotency: claim key(%s) error(%v)", key, err)
+		return
+	}
+	if !claimed {
+		if rec.Pending {
+			abort(c, http.StatusConflict, ecode.Conflict)
+			return
+		}
+		replay(c, rec)
+		return
+	}
+
+	w := &recorder{ResponseWriter: c.Writer, status: http.StatusOK, limit: i.conf.MaxBodySize}
+	c.Writer = w
+	defer func() {
+		c.Writer = w.ResponseWriter
+		// NOTE: the context of the request may be done already.
+		ctx := context.Background()
+		if p := recover(); p != nil {
+			i.release(ctx, key)
+			panic(p)
+		}
+		if !i.storable(c, w) {
+			i.release(ctx, key)
+			return
+		}
+		rec := &Record{Status: w.status, Header: w.Header().Clone(), Body: w.body.Bytes()}
+		if err := i.store.Save(ctx, key, rec, time.Duration(i.conf.TTL)); err != nil {
+			log.Error("idempotency: save key(%s) error(%v)", key, err)
+			i.release(ctx, key)
+		}
+	}()
+	c.Next()
+}
+
+func (i *Idempotency) key(c *bm.Context, ikey string) string {
+	parts := []string{i.conf.Prefix}
+	if i.conf.ScopeRoute {
+		parts = append(parts, c.Request.Method+c.RoutePath)
+	}
+	if i.conf.ScopeUser {
+		parts = append(parts, user(c))
+	}
+	return strings.Join(append(parts, ikey), ":")
+}
+
+// storable reports whether the response should be replayed, the server
+// errors are not stored so that the client can retry.
+func (i *Idempotency) storable(c *bm.Context, w *recorder) bool {
+	if w.overflow || w.status >= http.StatusInternalServerError {
+		return false
+	}
+	if code := ecode.Cause(c.Error).Code(); code <= ecode.ServerErr.Code() && code > -600 {
+		return false
+	}
+	return true
+}
+
+func (i *Idempotency) release(ctx context.Context, key string) {
+	if err := i.store.Release(ctx, key); err != nil {
+		log.Error("idempotency: release key(%s) error(%v)", key, err)
+	}
+}
+
+// user returns the authenticated user set by the auth middleware.
+func user(c *bm.Context) string {
+	v, ok := c.Get(_userTitleKey)
+	if !ok {
+		return ""
+	}
+	if ut, ok := v.(*auth.UserTitle); ok && ut != nil {
+		if ut.UserName != "" {
+			return ut.UserName
+		}
+		return ut.OpenId
+	}
+	return ""
+}
+
+func abort(c *bm.Context, status int, code ecode.Codes) {
+	c.Error = code
+	c.Writer.Header().Set(_httpHeaderStatus, strconv.FormatInt(int64(code.Code()), 10))
+	c.Render(status, render.JSON{
+		Code:    code.Code(),
+		Message: code.Message(),
+	})
+	c.Abort()
+}
+
+func replay(c *bm.Context, rec *Record) {
+	header := c.Writer.Header()
+	for k, vs := range rec.Header {
+		header[k] = vs
+	}
+	header.Set(_headerReplayed, "true")
+	c.Writer.WriteHeader(rec.Status)
+	if len(rec.Body) > 0 {
+		c.Writer.Write(rec.Body)
+	}
+	c.Abort()
+}
+
+// recorder records the response while writing it.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *recorder) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recorder) Write(p []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(p) > w.limit {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (w *recorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
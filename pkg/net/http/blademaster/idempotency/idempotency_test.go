@@ -0,0 +1,136 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemStore() *memStore {
+	return &memStore{records: make(map[string]*Record)}
+}
+
+func (s *memStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, *Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok {
+		return false, rec, nil
+	}
+	s.records[key] = &Record{Pending: true}
+	return true, nil, nil
+}
+
+func (s *memStore) Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	s.records[key] = rec
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.records, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	var (
+		orders  int64
+		block   = make(chan struct{})
+		store   = newMemStore()
+		idem    = New(&Config{ScopeRoute: true, ScopeUser: true}, store)
+		timeout = xtime.Duration(time.Second)
+	)
+	e := bm.NewServer(&bm.ServerConfig{Timeout: timeout})
+	e.UseFunc(func(c *bm.Context) {
+		c.Set("user_title", &auth.UserTitle{UserName: c.Request.Header.Get("X-User")})
+	})
+	e.Use(idem)
+	e.POST("/order", func(c *bm.Context) {
+		if c.Request.URL.Query().Get("block") != "" {
+			<-block
+		}
+		c.Writer.Header().Set("X-Order", "1")
+		c.JSON(atomic.AddInt64(&orders, 1), nil)
+	})
+	e.POST("/fail", func(c *bm.Context) {
+		atomic.AddInt64(&orders, 1)
+		c.JSON(nil, ecode.ServerErr)
+	})
+
+	do := func(path, key, user string) (*httptest.ResponseRecorder, int64) {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Idempotency-Key", key)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		var res struct {
+			Code int   `json:"code"`
+			Data int64 `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res.Data
+	}
+
+	w, n := do("/order", "k1", "u1")
+	assert.Equal(t, int64(1), n)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+
+	w, n = do("/order", "k1", "u1")
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "1", w.Header().Get("X-Order"))
+
+	// the key is scoped by user.
+	_, n = do("/order", "k1", "u2")
+	assert.Equal(t, int64(2), n)
+	// the request without key isn't affected.
+	_, n = do("/order", "", "u1")
+	assert.Equal(t, int64(3), n)
+
+	// the server error isn't stored.
+	do("/fail", "k2", "u1")
+	do("/fail", "k2", "u1")
+	assert.Equal(t, int64(5), atomic.LoadInt64(&orders))
+
+	// the duplicate of the in-flight request gets 409.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		do("/order?block=1", "k3", "u1")
+	}()
+	for {
+		store.mu.Lock()
+		_, ok := store.records["idempotency:POST/order:u1:k3"]
+		store.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	w, _ = do("/order", "k3", "u1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "-409", w.Header().Get("atreus-status-code"))
+	close(block)
+	<-done
+	_, n = do("/order", "k3", "u1")
+	assert.Equal(t, int64(6), n)
+}
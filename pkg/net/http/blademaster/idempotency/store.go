@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+
+	"github.com/pkg/errors"
+)
+
+// Record is the state of an idempotency key.
+type Record struct {
+	// Pending is true while the first request is in flight.
+	Pending bool        `json:"pending,omitempty"`
+	Status  int         `json:"status,omitempty"`
+	Header  http.Header `json:"header,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+// Store stores the idempotency records.
+type Store interface {
+	// Claim claims the key as pending with ttl, the current record is
+	// returned if the key has been claimed already.
+	Claim(ctx context.Context, key string, ttl time.Duration) (claimed bool, rec *Record, err error)
+	// Save saves the finished record of the key with ttl.
+	Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Release releases the key so that it can be claimed again.
+	Release(ctx context.Context, key string) error
+}
+
+var _pending = []byte(`{"pending":true}`)
+
+type redisStore struct {
+	redis *redis.Redis
+}
+
+// NewRedisStore new a store backed by redis.
+func NewRedisStore(r *redis.Redis) Store {
+	return &redisStore{redis: r}
+}
+
+func (s *redisStore) Claim(ctx context.Context, key string, ttl time.Duration) (claimed bool, rec *Record, err error) {
+	// NOTE: retry once in case the record expires between SET and GET.
+	for i := 0; i < 2; i++ {
+		if _, err = redis.String(s.redis.Do(ctx, "SET", key, _pending, "PX", int64(ttl/time.Millisecond), "NX")); err == nil {
+			return true, nil, nil
+		}
+		if err != redis.ErrNil {
+			return false, nil, errors.Wrapf(err, "idempotency: claim key(%s)", key)
+		}
+		var bs []byte
+		if bs, err = redis.Bytes(s.redis.Do(ctx, "GET", key)); err != nil {
+			if err == redis.ErrNil {
+				continue
+			}
+			return false, nil, errors.Wrapf(err, "idempotency: get key(%s)", key)
+		}
+		rec = new(Record)
+		if err = json.Unmarshal(bs, rec); err != nil {
+			return false, nil, errors.Wrapf(err, "idempotency: unmarshal key(%s)", key)
+		}
+		return false, rec, nil
+	}
+	return false, &Record{Pending: true}, nil
+}
+
+func (s *redisStore) Save(ctx context.Context, key string, rec *Record, ttl time.Duration) (err error) {
+	bs, err := json.Marshal(rec)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err = s.redis.Do(ctx, "SET", key, bs, "PX", int64(ttl/time.Millisecond)); err != nil {
+		err = errors.Wrapf(err, "idempotency: save key(%s)", key)
+	}
+	return
+}
+
+func (s *redisStore) Release(ctx context.Context, key string) (err error) {
+	if _, err = s.redis.Do(ctx, "DEL", key); err != nil {
+		err = errors.Wrapf(err, "idempotency: release key(%s)", key)
+	}
+	return
+}
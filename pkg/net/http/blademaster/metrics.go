@@ -30,6 +30,27 @@ var (
 		Help:      "http server bbr total.",
 		Labels:    []string{"url"},
 	})
+	_metricServerWSConnTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "websocket",
+		Name:      "connections_total",
+		Help:      "http server websocket connections count.",
+		Labels:    []string{"path"},
+	})
+	_metricServerWSConnCurrent = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "websocket",
+		Name:      "connections_current",
+		Help:      "http server websocket current connections.",
+		Labels:    []string{"path"},
+	})
+	_metricServerWSMsgTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "websocket",
+		Name:      "messages_total",
+		Help:      "http server websocket messages count.",
+		Labels:    []string{"path", "direction"},
+	})
 	_metricClientReqDur = metric.NewHistogramVec(&metric.HistogramVecOpts{
 		Namespace: clientNamespace,
 		Subsystem: "requests",
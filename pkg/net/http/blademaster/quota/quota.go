@@ -0,0 +1,125 @@
+// Package quota is the blademaster middleware of the distributed rate limiter by key.
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/net/claims"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/render"
+	"github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	rlquota "github.com/mapgoo-lab/atreus/pkg/ratelimit/quota"
+)
+
+const (
+	_httpHeaderStatus = "atreus-status-code"
+	// _userTitleKey is the context key of the auth.UserTitle set by the auth middleware.
+	_userTitleKey = "user_title"
+)
+
+// Limiter is the quota middleware.
+type Limiter struct {
+	keys    []string
+	limiter *rlquota.Limiter
+}
+
+// New new a quota middleware which limits the requests by the key built from
+// the sources joined by ":", the sources are:
+//
+//	ip: the remote ip.
+//	path: the route path.
+//	caller: the caller.
+//	user: the user name of auth.UserTitle, the user name or the subject of the jwt claims, or the mid.
+//	header:<name>: the request header.
+//	query:<name>: the query or form param.
+//
+// The request is not limited if all the sources are empty.
+func New(keys []string, limiter *rlquota.Limiter) *Limiter {
+	if len(keys) == 0 {
+		keys = []string{"ip"}
+	}
+	return &Limiter{keys: keys, limiter: limiter}
+}
+
+// ServeHTTP implements bm.Handler, it must be used after the auth middleware if the user source is used.
+func (l *Limiter) ServeHTTP(c *bm.Context) {
+	key, ok := l.key(c)
+	if !ok {
+		return
+	}
+	res, err := l.limiter.Allow(c, key)
+	if res == nil {
+		return
+	}
+	header := c.Writer.Header()
+	header.Set("RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	header.Set("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	header.Set("RateLimit-Reset", seconds(res.Reset))
+	if err == nil {
+		return
+	}
+	header.Set("Retry-After", seconds(res.RetryAfter))
+	c.Error = ecode.LimitExceed
+	header.Set(_httpHeaderStatus, strconv.FormatInt(int64(ecode.LimitExceed.Code()), 10))
+	c.Render(http.StatusTooManyRequests, render.JSON{
+		Code:    ecode.LimitExceed.Code(),
+		Message: ecode.LimitExceed.Message(),
+	})
+	c.Abort()
+}
+
+func (l *Limiter) key(c *bm.Context) (string, bool) {
+	var (
+		parts = make([]string, 0, len(l.keys))
+		found bool
+	)
+	for _, k := range l.keys {
+		v := source(c, k)
+		found = found || v != ""
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, ":"), found
+}
+
+func source(c *bm.Context, key string) string {
+	name := ""
+	if idx := strings.IndexByte(key, ':'); idx > 0 {
+		key, name = key[:idx], key[idx+1:]
+	}
+	switch key {
+	case "ip":
+		return metadata.String(c, metadata.RemoteIP)
+	case "path":
+		return c.RoutePath
+	case "caller":
+		return metadata.String(c, metadata.Caller)
+	case "user":
+		if v, ok := c.Get(_userTitleKey); ok {
+			if ut, ok := v.(*auth.UserTitle); ok && ut != nil && ut.UserName != "" {
+				return ut.UserName
+			}
+		}
+		if user := claims.User(c); user != "" {
+			return user
+		}
+		if mid := metadata.Value(c, metadata.Mid); mid != nil {
+			return fmt.Sprint(mid)
+		}
+	case "header":
+		return c.Request.Header.Get(name)
+	case "query":
+		return c.Request.Form.Get(name)
+	}
+	return ""
+}
+
+// seconds formats d in seconds rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
@@ -0,0 +1,82 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/net/claims"
+	bm "github.com/mapgoo-lab/atreus/pkg/net/http/blademaster"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/auth"
+	"github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	rlquota "github.com/mapgoo-lab/atreus/pkg/ratelimit/quota"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := rlquota.New(&rlquota.Config{Name: "test", Limit: 2, Period: xtime.Duration(time.Minute)}, nil)
+	e := bm.NewServer(&bm.ServerConfig{Timeout: xtime.Duration(time.Second)})
+	e.Use(New([]string{"header:X-Api-Key", "path"}, limiter))
+	e.GET("/ping", func(c *bm.Context) {
+		c.JSON("pong", nil)
+	})
+
+	do := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		if apiKey != "" {
+			req.Header.Set("X-Api-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := do("k1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	}
+	w := do("k1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "-509", w.Header().Get("atreus-status-code"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	w = do("k2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+}
+
+type testClaims struct {
+	jwt.RegisteredClaims
+}
+
+func (c *testClaims) Registered() *jwt.RegisteredClaims { return &c.RegisteredClaims }
+
+func TestUserSource(t *testing.T) {
+	l := New([]string{"user"}, nil)
+	newContext := func() *bm.Context {
+		c := &bm.Context{Context: context.Background(), Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+		c.Context = metadata.NewContext(c.Context, metadata.MD{metadata.Mid: int64(1)})
+		return c
+	}
+	c := newContext()
+	key, _ := l.key(c)
+	assert.Equal(t, "1", key)
+
+	// the subject of the custom claims takes precedence over the mid.
+	c = newContext()
+	c.Context = claims.NewContext(c.Context, &testClaims{jwt.RegisteredClaims{Subject: "42"}})
+	key, _ = l.key(c)
+	assert.Equal(t, "42", key)
+
+	// and the user title takes precedence over the claims.
+	c.Set("user_title", &auth.UserTitle{UserName: "atreus"})
+	key, _ = l.key(c)
+	assert.Equal(t, "atreus", key)
+}
@@ -20,6 +20,8 @@ var (
 	_ Render = Redirect{}
 	_ Render = Data{}
 	_ Render = PB{}
+	_ Render = SSEvent{}
+	_ Render = SSEComment("")
 )
 
 func writeContentType(w http.ResponseWriter, value []string) {
@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var sseContentType = []string{"text/event-stream"}
+
+// SSEvent is a Server-Sent Event.
+type SSEvent struct {
+	// Event is the event name, the client dispatches "message" if empty.
+	Event string
+	// ID is the event id, the client resumes by Last-Event-ID header with it.
+	ID string
+	// Retry is the reconnection time in milliseconds.
+	Retry int
+	// Data is the event data, string and []byte are written as they are,
+	// others are encoded as json.
+	Data interface{}
+}
+
+var _sseReplacer = strings.NewReplacer("\n", "\\n", "\r", "\\r")
+
+// Render (SSEvent) writes the event in the text/event-stream format.
+func (r SSEvent) Render(w http.ResponseWriter) (err error) {
+	r.WriteContentType(w)
+	var buf bytes.Buffer
+	if r.ID != "" {
+		buf.WriteString("id: " + _sseReplacer.Replace(r.ID) + "\n")
+	}
+	if r.Event != "" {
+		buf.WriteString("event: " + _sseReplacer.Replace(r.Event) + "\n")
+	}
+	if r.Retry > 0 {
+		buf.WriteString("retry: " + strconv.Itoa(r.Retry) + "\n")
+	}
+	var data []byte
+	switch d := r.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		if data, err = json.Marshal(d); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	// NOTE: a multi-line data must be split into multiple data fields.
+	for _, line := range bytes.Split(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		err = errors.WithStack(err)
+	}
+	return
+}
+
+// WriteContentType writes text/event-stream ContentType.
+func (r SSEvent) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, sseContentType)
+}
+
+// SSEComment is a Server-Sent Events comment which is ignored by clients,
+// it's usually used as a heartbeat to keep the connection alive.
+type SSEComment string
+
+// Render (SSEComment) writes the comment line.
+func (r SSEComment) Render(w http.ResponseWriter) (err error) {
+	r.WriteContentType(w)
+	if _, err = fmt.Fprintf(w, ": %s\n\n", _sseReplacer.Replace(string(r))); err != nil {
+		err = errors.WithStack(err)
+	}
+	return
+}
+
+// WriteContentType writes text/event-stream ContentType.
+func (r SSEComment) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, sseContentType)
+}
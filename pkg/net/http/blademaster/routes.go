@@ -0,0 +1,62 @@
+package blademaster
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+)
+
+// RouteInfo is the information of a registered route.
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Handler is the function name of the last handler.
+	Handler string `json:"handler"`
+	// Middlewares are the function names of the handlers before the last one.
+	Middlewares []string      `json:"middlewares,omitempty"`
+	Config      *MethodConfig `json:"config,omitempty"`
+}
+
+type route struct {
+	method   string
+	path     string
+	handlers []HandlerFunc
+}
+
+// Routes returns all the registered routes sorted by path and method, it's
+// exported via /metadata/routes for the API portals to scrape the services
+// which don't use protobuf.
+func (engine *Engine) Routes() []*RouteInfo {
+	engine.routesLock.RLock()
+	routes := make([]*RouteInfo, 0, len(engine.routes))
+	for _, r := range engine.routes {
+		info := &RouteInfo{
+			Method:  r.method,
+			Path:    r.path,
+			Handler: nameOfFunction(r.handlers[len(r.handlers)-1]),
+			Config:  engine.methodConfig(r.path),
+		}
+		for _, h := range r.handlers[:len(r.handlers)-1] {
+			info.Middlewares = append(info.Middlewares, nameOfFunction(h))
+		}
+		routes = append(routes, info)
+	}
+	engine.routesLock.RUnlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func (engine *Engine) routesHandler() HandlerFunc {
+	return func(c *Context) {
+		c.JSON(engine.Routes(), nil)
+	}
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
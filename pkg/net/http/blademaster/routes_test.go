@@ -0,0 +1,54 @@
+package blademaster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func routesOrderList(c *Context) {}
+
+func TestRoutes(t *testing.T) {
+	engine := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Second)})
+	g := engine.Group("/order", func(c *Context) {})
+	g.GET("/list", routesOrderList)
+	g.SetMethodConfig(&MethodConfig{Timeout: xtime.Duration(time.Second), MaxBodySize: 1024})
+	g.POST("/create", routesOrderList)
+
+	routes := make(map[string]*RouteInfo)
+	for _, r := range engine.Routes() {
+		routes[r.Method+" "+r.Path] = r
+	}
+	assert.Contains(t, routes, "GET /metadata/routes")
+	list := routes["GET /order/list"]
+	assert.True(t, strings.HasSuffix(list.Handler, "blademaster.routesOrderList"))
+	assert.Len(t, list.Middlewares, 1)
+	assert.Nil(t, list.Config)
+	assert.Equal(t, int64(1024), routes["POST /order/create"].Config.MaxBodySize)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metadata/routes", nil))
+	var resp struct {
+		Code int `json:"code"`
+		Data []struct {
+			Path   string
+			Config struct {
+				Timeout int64 `json:"timeout"`
+			} `json:"config"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data, len(routes))
+	for _, r := range resp.Data {
+		if r.Path == "/order/create" {
+			assert.Equal(t, int64(time.Second), r.Config.Timeout)
+		}
+	}
+}
@@ -84,11 +84,20 @@ type ServerConfig struct {
 	ReadTimeout   xtime.Duration `dsn:"query.readTimeout"`
 	WriteTimeout  xtime.Duration `dsn:"query.writeTimeout"`
 	UseSimpleJson bool           `dsn:"query.useSimpleJson"`
+	// MaxBodySize is the max request body size in bytes, zero means no limit.
+	MaxBodySize int64 `dsn:"query.maxBodySize"`
 }
 
 // MethodConfig is
 type MethodConfig struct {
-	Timeout xtime.Duration
+	Timeout xtime.Duration `json:"timeout,omitempty"`
+	// Stream exempts the path from the method timeout for long-lived responses,
+	// the context is canceled when the client disconnects instead.
+	Stream bool `json:"stream,omitempty"`
+	// MaxBodySize overrides ServerConfig.MaxBodySize if it's not zero, negative means no limit.
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+	// StreamUpload leaves the multipart body unparsed for Context.MultipartReader.
+	StreamUpload bool `json:"stream_upload,omitempty"`
 }
 
 // Start listen and serve bm engine by given DSN.
@@ -177,6 +186,9 @@ type Engine struct {
 	pcLock        sync.RWMutex
 	methodConfigs map[string]*MethodConfig
 
+	routesLock sync.RWMutex
+	routes     []route
+
 	injections []injection
 
 	// If enabled, the url.RawPath will be used to find parameters.
@@ -234,6 +246,7 @@ func NewServer(conf *ServerConfig) *Engine {
 	// NOTE add prometheus monitor location
 	engine.addRoute("GET", "/metrics", monitor())
 	engine.addRoute("GET", "/metadata", engine.metadata())
+	engine.addRoute("GET", "/metadata/routes", engine.routesHandler())
 	engine.NoRoute(func(c *Context) {
 		c.Bytes(404, "text/plain", default404Body)
 		c.Abort()
@@ -274,6 +287,9 @@ func (engine *Engine) addRoute(method, path string, handlers ...HandlerFunc) {
 		engine.metastore[path] = make(map[string]interface{})
 	}
 	engine.metastore[path]["method"] = method
+	engine.routesLock.Lock()
+	engine.routes = append(engine.routes, route{method: method, path: path, handlers: handlers})
+	engine.routesLock.Unlock()
 	root := engine.trees.get(method)
 	if root == nil {
 		root = new(node)
@@ -333,26 +349,46 @@ func (engine *Engine) prepareHandler(c *Context) {
 func (engine *Engine) handleContext(c *Context) {
 	var cancel func()
 	req := c.Request
-	ctype := req.Header.Get("Content-Type")
-	switch {
-	case strings.Contains(ctype, "multipart/form-data"):
-		req.ParseMultipartForm(defaultMaxMemory)
-	default:
-		req.ParseForm()
-	}
 	// get derived timeout from http request header,
 	// compare with the engine configured,
 	// and use the minimum one
 	engine.lock.RLock()
 	tm := time.Duration(engine.conf.Timeout)
+	maxBody := engine.conf.MaxBodySize
 	engine.lock.RUnlock()
 	// the method config is preferred
-	if pc := engine.methodConfig(c.Request.URL.Path); pc != nil {
+	pc := engine.methodConfig(c.Request.URL.Path)
+	if pc != nil {
 		tm = time.Duration(pc.Timeout)
+		if pc.MaxBodySize != 0 {
+			maxBody = pc.MaxBodySize
+		}
+	}
+	var err error
+	if maxBody > 0 {
+		if req.ContentLength > maxBody {
+			err = errBodyTooLarge
+		}
+		req.Body = newMaxBytesReader(req.Body, maxBody)
+	}
+	if err == nil {
+		ctype := req.Header.Get("Content-Type")
+		switch {
+		case strings.Contains(ctype, "multipart/form-data"):
+			// NOTE: the streaming upload is read by Context.MultipartReader in the handler.
+			if pc == nil || !pc.StreamUpload {
+				err = req.ParseMultipartForm(defaultMaxMemory)
+			}
+		default:
+			err = req.ParseForm()
+		}
 	}
 	if ctm := timeout(req); ctm > 0 && tm > ctm {
 		tm = ctm
 	}
+	if pc != nil && pc.Stream {
+		tm = 0
+	}
 	md := metadata.MD{
 		metadata.RemoteIP:    remoteIP(req),
 		metadata.RemotePort:  remotePort(req),
@@ -366,7 +402,21 @@ func (engine *Engine) handleContext(c *Context) {
 		c.Context, cancel = context.WithCancel(ctx)
 	}
 	defer cancel()
+	if pc != nil && pc.Stream {
+		// NOTE: cancel the context once the client disconnects for streaming.
+		go func(done <-chan struct{}) {
+			select {
+			case <-req.Context().Done():
+				cancel()
+			case <-done:
+			}
+		}(c.Context.Done())
+	}
 	engine.prepareHandler(c)
+	if errors.Is(err, errBodyTooLarge) {
+		// NOTE: the global middleware sees the rejected request like NoRoute.
+		c.handlers = engine.combineHandlers([]HandlerFunc{(*Context).abortBodyTooLarge})
+	}
 	c.Next()
 }
 
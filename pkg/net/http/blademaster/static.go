@@ -0,0 +1,146 @@
+package blademaster
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+const _staticIndex = "index.html"
+
+// Static serves files from the given file system root, the directory listing is disabled.
+// For example: router.Static("/static", "/var/www")
+func (group *RouterGroup) Static(relativePath, root string) IRoutes {
+	return group.StaticFS(relativePath, http.Dir(root))
+}
+
+// StaticFile registers a single route in order to serve a single file of the local filesystem.
+// For example: router.StaticFile("favicon.ico", "./resources/favicon.ico")
+func (group *RouterGroup) StaticFile(relativePath, filepath string) IRoutes {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static file")
+	}
+	server := newFileServer(http.Dir(path.Dir(filepath)), "")
+	name := path.Base(filepath)
+	handler := func(c *Context) {
+		server.serve(c, name)
+	}
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+	return group.returnObj()
+}
+
+// StaticFS works just like Static() but a custom http.FileSystem can be used instead.
+func (group *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) IRoutes {
+	return group.staticFS(relativePath, newFileServer(fs, ""))
+}
+
+// SPA serves a single page application from fsys such as an embed.FS, the
+// index is served for the paths without extension which are not found so that
+// the frontend router takes over. The index is "index.html" if empty.
+// For example:
+//	//go:embed dist
+//	var dist embed.FS
+//	sub, _ := fs.Sub(dist, "dist")
+//	router.SPA("/admin", sub, "")
+func (group *RouterGroup) SPA(relativePath string, fsys fs.FS, index string) IRoutes {
+	if index == "" {
+		index = _staticIndex
+	}
+	return group.staticFS(relativePath, newFileServer(http.FS(fsys), index))
+}
+
+func (group *RouterGroup) staticFS(relativePath string, server *fileServer) IRoutes {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static folder")
+	}
+	handler := func(c *Context) {
+		server.serve(c, c.Params.ByName("filepath"))
+	}
+	urlPattern := path.Join(relativePath, "/*filepath")
+	group.GET(urlPattern, handler)
+	group.HEAD(urlPattern, handler)
+	return group.returnObj()
+}
+
+// fileServer serves files with ETag, Last-Modified and Range supported by http.ServeContent.
+type fileServer struct {
+	fs       http.FileSystem
+	fallback string
+	// etags caches the content hash of the files without modtime, e.g. embed.FS.
+	etags sync.Map
+}
+
+func newFileServer(fs http.FileSystem, fallback string) *fileServer {
+	return &fileServer{fs: fs, fallback: fallback}
+}
+
+func (s *fileServer) serve(c *Context, name string) {
+	name = path.Clean("/" + name)
+	f, d, err := s.open(name)
+	if err != nil && s.fallback != "" && path.Ext(name) == "" {
+		name = path.Clean("/" + s.fallback)
+		f, d, err = s.open(name)
+	}
+	if err != nil {
+		c.Bytes(http.StatusNotFound, "text/plain", default404Body)
+		return
+	}
+	defer f.Close()
+	etag, err := s.etag(name, f, d)
+	if err != nil {
+		c.Bytes(http.StatusInternalServerError, "text/plain", []byte(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	c.Writer.Header().Set("ETag", etag)
+	http.ServeContent(c.Writer, c.Request, d.Name(), d.ModTime(), f)
+}
+
+// open opens the file, the index of a directory is opened instead.
+func (s *fileServer) open(name string) (f http.File, d os.FileInfo, err error) {
+	if f, err = s.fs.Open(name); err != nil {
+		return
+	}
+	if d, err = f.Stat(); err != nil {
+		f.Close()
+		return
+	}
+	if !d.IsDir() {
+		return
+	}
+	f.Close()
+	if f, err = s.fs.Open(path.Join(name, _staticIndex)); err != nil {
+		return
+	}
+	if d, err = f.Stat(); err != nil || d.IsDir() {
+		f.Close()
+		err = os.ErrNotExist
+	}
+	return
+}
+
+func (s *fileServer) etag(name string, f http.File, d os.FileInfo) (string, error) {
+	if !d.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, d.ModTime().UnixNano(), d.Size()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
@@ -0,0 +1,93 @@
+package blademaster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello atreus"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "index.html"), []byte("<html>sub</html>"), 0644)
+
+	e := NewServer(nil)
+	e.Static("/static", dir)
+	e.StaticFile("/favicon.ico", filepath.Join(dir, "hello.txt"))
+
+	do := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/static/hello.txt", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "hello atreus", w.Body.String())
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+
+	w = do("/static/hello.txt", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = do("/static/hello.txt", http.Header{"If-Modified-Since": {lastModified}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = do("/static/hello.txt", http.Header{"Range": {"bytes=6-"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "atreus", w.Body.String())
+
+	w = do("/static/sub/", nil)
+	assert.Equal(t, "<html>sub</html>", w.Body.String())
+	w = do("/static/", nil)
+	assert.Equal(t, 404, w.Code)
+	w = do("/static/../static_test.go", nil)
+	assert.Equal(t, 404, w.Code)
+
+	w = do("/favicon.ico", nil)
+	assert.Equal(t, "hello atreus", w.Body.String())
+}
+
+func TestSPA(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<html>spa</html>")},
+		"assets/app.js": {Data: []byte("console.log('atreus')")},
+	}
+	e := NewServer(nil)
+	e.SPA("/admin", fsys, "")
+
+	do := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/admin/assets/app.js", nil)
+	assert.Equal(t, "console.log('atreus')", w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	w = do("/admin/assets/app.js", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	for _, path := range []string{"/admin/", "/admin/users/1"} {
+		w = do(path, nil)
+		assert.Equal(t, 200, w.Code, path)
+		assert.Equal(t, "<html>spa</html>", w.Body.String(), path)
+	}
+	w = do("/admin/assets/missing.js", nil)
+	assert.Equal(t, 404, w.Code)
+}
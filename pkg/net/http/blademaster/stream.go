@@ -0,0 +1,123 @@
+package blademaster
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/render"
+)
+
+const _streamingKey = "_bm_streaming"
+
+// writeDeadliner is implemented by the net/http response writer since go1.20.
+// The server WriteTimeout still applies to the streams if it's built by an
+// older go or the writer is wrapped by a middleware which doesn't implement
+// it, ServerConfig.WriteTimeout should be 0 then.
+type writeDeadliner interface {
+	SetWriteDeadline(time.Time) error
+}
+
+// streaming prepares the response for streaming, the server WriteTimeout is
+// lifted and the proxy buffering is disabled.
+func (c *Context) streaming() {
+	if c.Keys != nil {
+		if _, ok := c.Keys[_streamingKey]; ok {
+			return
+		}
+	}
+	c.Set(_streamingKey, true)
+	if wd, ok := c.Writer.(writeDeadliner); ok {
+		wd.SetWriteDeadline(time.Time{})
+	}
+	header := c.Writer.Header()
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+}
+
+// Flush sends any buffered data to the client.
+func (c *Context) Flush() {
+	if f, ok := c.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ClientGone returns a channel that's closed when the client disconnects.
+func (c *Context) ClientGone() <-chan struct{} {
+	return c.Request.Context().Done()
+}
+
+// Stream sends a chunked streaming response, step is called repeatedly and
+// the written data is flushed after each call, until step returns false or
+// the client disconnects. It returns true if the client disconnected in the middle of the stream.
+// NOTE: the method timeout is still applied unless MethodConfig.Stream of the path is set.
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	c.streaming()
+	for {
+		select {
+		case <-c.ClientGone():
+			return true
+		case <-c.Done():
+			return false
+		default:
+			keepOpen := step(c.Writer)
+			c.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// SSEvent writes a Server-Sent Event into the body stream and flushes it.
+func (c *Context) SSEvent(event string, data interface{}) error {
+	return c.renderSSE(render.SSEvent{Event: event, Data: data})
+}
+
+// SSEStream sends the events of ch as Server-Sent Events until ch is closed,
+// the context is done or the client disconnects. A heartbeat comment is sent
+// if there's no event within heartbeat, zero disables the heartbeat.
+// It returns true if the client disconnected in the middle of the stream.
+func (c *Context) SSEStream(ch <-chan render.SSEvent, heartbeat time.Duration) bool {
+	c.streaming()
+	c.Writer.Header().Set("Connection", "keep-alive")
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	// NOTE: send the header at once so that the client knows the stream is open.
+	render.SSEvent{}.WriteContentType(c.Writer)
+	c.Status(http.StatusOK)
+	c.Flush()
+	for {
+		select {
+		case <-c.ClientGone():
+			return true
+		case <-c.Done():
+			return false
+		case ev, ok := <-ch:
+			if !ok {
+				return false
+			}
+			if err := c.renderSSE(ev); err != nil {
+				return true
+			}
+		case <-tick:
+			if err := c.renderSSE(render.SSEComment("heartbeat")); err != nil {
+				return true
+			}
+		}
+	}
+}
+
+func (c *Context) renderSSE(r render.Render) (err error) {
+	c.streaming()
+	if err = r.Render(c.Writer); err != nil {
+		c.Error = err
+		return
+	}
+	c.Flush()
+	return
+}
@@ -0,0 +1,31 @@
+//go:build go1.20
+// +build go1.20
+
+package blademaster
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWriteTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(newStreamEngine())
+	srv.Config.WriteTimeout = time.Millisecond * 100
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/sse")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	// the stream outlives the 100ms server write timeout.
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `data: {"seq":1}`)
+}
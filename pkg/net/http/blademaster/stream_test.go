@@ -0,0 +1,96 @@
+package blademaster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/render"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStreamEngine() *Engine {
+	e := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Millisecond * 100)})
+	e.GET("/stream", func(c *Context) {
+		i := 0
+		c.Stream(func(w io.Writer) bool {
+			fmt.Fprintf(w, "chunk%d\n", i)
+			i++
+			return i < 3
+		})
+	})
+	e.GET("/sse", func(c *Context) {
+		ch := make(chan render.SSEvent)
+		go func() {
+			defer close(ch)
+			for i := 0; i < 2; i++ {
+				time.Sleep(time.Millisecond * 150)
+				ch <- render.SSEvent{Event: "position", ID: fmt.Sprint(i), Data: map[string]int{"seq": i}}
+			}
+		}()
+		c.SSEStream(ch, time.Millisecond*100)
+	})
+	e.SetMethodConfig("/sse", &MethodConfig{Stream: true})
+	return e
+}
+
+func TestStream(t *testing.T) {
+	srv := httptest.NewServer(newStreamEngine())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/stream")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "chunk0\nchunk1\nchunk2\n", string(body))
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+}
+
+func TestSSEStream(t *testing.T) {
+	srv := httptest.NewServer(newStreamEngine())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/sse")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	var (
+		events     []string
+		heartbeats int
+	)
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, strings.TrimSpace(strings.TrimPrefix(line, "data: ")))
+		case strings.HasPrefix(line, ": heartbeat"):
+			heartbeats++
+		}
+	}
+	// the stream outlives the 100ms method timeout.
+	assert.Equal(t, []string{`{"seq":0}`, `{"seq":1}`}, events)
+	assert.True(t, heartbeats > 0)
+}
+
+func TestSSEventRender(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := render.SSEvent{Event: "msg", ID: "1", Retry: 1000, Data: "a\nb"}.Render(w)
+	assert.NoError(t, err)
+	assert.Equal(t, "id: 1\nevent: msg\nretry: 1000\ndata: a\ndata: b\n\n", w.Body.String())
+}
@@ -0,0 +1,115 @@
+package blademaster
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/render"
+
+	"github.com/pkg/errors"
+)
+
+var errBodyTooLarge = errors.New("blademaster: request body too large")
+
+// maxBytesReader is like http.MaxBytesReader but returns errBodyTooLarge.
+type maxBytesReader struct {
+	r   io.ReadCloser
+	n   int64
+	err error
+}
+
+func newMaxBytesReader(r io.ReadCloser, n int64) io.ReadCloser {
+	return &maxBytesReader{r: r, n: n}
+}
+
+func (l *maxBytesReader) Read(p []byte) (n int, err error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// NOTE: read one more byte to know whether the body exceeds the limit.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err = l.r.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		l.err = err
+		return
+	}
+	n = int(l.n)
+	l.n = 0
+	l.err = errBodyTooLarge
+	return n, l.err
+}
+
+func (l *maxBytesReader) Close() error {
+	return l.r.Close()
+}
+
+// abortBodyTooLarge aborts the request with 413 and ecode.RequestEntityTooLarge.
+func (c *Context) abortBodyTooLarge() {
+	c.Error = ecode.RequestEntityTooLarge
+	// NOTE: the rest of the body is unread so the connection can't be reused.
+	c.Writer.Header().Set("Connection", "close")
+	writeStatusCode(c.Writer, ecode.RequestEntityTooLarge.Code())
+	c.Render(http.StatusRequestEntityTooLarge, render.JSON{
+		Code:    ecode.RequestEntityTooLarge.Code(),
+		Message: ecode.RequestEntityTooLarge.Message(),
+	})
+	c.Abort()
+}
+
+// MultipartForm returns the parsed multipart form, including file uploads.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if err := c.Request.ParseMultipartForm(defaultMaxMemory); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.Request.MultipartForm, nil
+}
+
+// FormFile returns the first file for the provided form key.
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if fhs := form.File[name]; len(fhs) > 0 {
+		return fhs[0], nil
+	}
+	return nil, errors.WithStack(http.ErrMissingFile)
+}
+
+// MultipartReader returns a reader of the multipart body for streaming
+// uploads, the path must be configured with MethodConfig.StreamUpload so
+// that the body isn't parsed ahead.
+func (c *Context) MultipartReader() (*multipart.Reader, error) {
+	r, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r, nil
+}
+
+// SaveUploadedFile uploads the form file to the specific dst.
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) (err error) {
+	src, err := file.Open()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+	if _, err = io.Copy(out, src); err != nil {
+		return errors.WithStack(err)
+	}
+	return
+}
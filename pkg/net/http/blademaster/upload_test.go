@@ -0,0 +1,153 @@
+package blademaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/binding"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func multipartBody(t *testing.T, files map[string]string) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "atreus")
+	for name, content := range files {
+		fw, err := mw.CreateFormFile(name, name+".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	e := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Second), MaxBodySize: 1024})
+	e.POST("/upload", func(c *Context) {
+		var arg struct {
+			Name string                `form:"name"`
+			File *multipart.FileHeader `form:"file" validate:"required"`
+		}
+		if err := c.Bind(&arg); err != nil {
+			return
+		}
+		dst := filepath.Join(dir, arg.File.Filename)
+		if err := c.SaveUploadedFile(arg.File, dst); err != nil {
+			c.JSON(nil, err)
+			return
+		}
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(nil, err)
+			return
+		}
+		c.JSON(arg.Name+":"+fh.Filename, nil)
+	})
+	e.POST("/stream", func(c *Context) {
+		r, err := c.MultipartReader()
+		if err != nil {
+			c.JSON(nil, ecode.RequestErr)
+			return
+		}
+		var size int64
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				c.JSON(nil, ecode.RequestErr)
+				return
+			}
+			n, _ := io.Copy(ioutil.Discard, p)
+			size += n
+		}
+		c.JSON(size, nil)
+	})
+	e.SetMethodConfig("/stream", &MethodConfig{MaxBodySize: 1 << 20, StreamUpload: true})
+	e.POST("/json", func(c *Context) {
+		var arg map[string]string
+		if err := c.BindWith(&arg, binding.JSON); err != nil {
+			return
+		}
+	})
+
+	do := func(path string, body io.Reader, ctype string, chunked bool) (*httptest.ResponseRecorder, int) {
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", ctype)
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		var res struct {
+			Code int `json:"code"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res.Code
+	}
+
+	body, ctype := multipartBody(t, map[string]string{"file": "hello atreus"})
+	w, code := do("/upload", body, ctype, false)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, w.Body.String(), "atreus:file.txt")
+	saved, _ := ioutil.ReadFile(filepath.Join(dir, "file.txt"))
+	assert.Equal(t, "hello atreus", string(saved))
+
+	for _, chunked := range []bool{false, true} {
+		body, ctype = multipartBody(t, map[string]string{"file": strings.Repeat("x", 2048)})
+		w, code = do("/upload", body, ctype, chunked)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, ecode.RequestEntityTooLarge.Code(), code)
+		assert.Equal(t, "-413", w.Header().Get("atreus-status-code"))
+	}
+
+	body, ctype = multipartBody(t, map[string]string{"a": strings.Repeat("x", 4096), "b": "b"})
+	w, code = do("/stream", body, ctype, true)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, w.Body.String(), "4103")
+
+	w, code = do("/json", strings.NewReader(`{"a":"`+strings.Repeat("x", 2048)+`"}`), "application/json", true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, ecode.RequestEntityTooLarge.Code(), code)
+}
+
+func TestBodyTooLargeMiddleware(t *testing.T) {
+	e := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Second), MaxBodySize: 16})
+	var seen []error
+	e.UseFunc(func(c *Context) {
+		c.Next()
+		seen = append(seen, c.Error)
+	})
+	e.POST("/form", func(c *Context) {
+		c.JSON(c.Request.Form.Get("a"), nil)
+	})
+
+	for _, chunked := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader("a="+strings.Repeat("x", 32)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	}
+	// the middleware sees the rejected requests.
+	assert.Equal(t, []error{ecode.RequestEntityTooLarge, ecode.RequestEntityTooLarge}, seen)
+}
@@ -0,0 +1,289 @@
+package blademaster
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// The message types are defined in RFC 6455, section 11.8.
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+
+	CloseNormalClosure   = websocket.CloseNormalClosure
+	CloseGoingAway       = websocket.CloseGoingAway
+	CloseMessageTooBig   = websocket.CloseMessageTooBig
+	ClosePolicyViolation = websocket.ClosePolicyViolation
+)
+
+const (
+	_defaultWSReadTimeout    = 60 * time.Second
+	_defaultWSWriteTimeout   = 10 * time.Second
+	_defaultWSMaxMessageSize = 1 << 20
+)
+
+// WebSocketConfig is the websocket route config.
+type WebSocketConfig struct {
+	// ReadTimeout is the read deadline, it's extended on every received message and pong.
+	ReadTimeout xtime.Duration
+	// WriteTimeout is the deadline of every write.
+	WriteTimeout xtime.Duration
+	// PingInterval is the keepalive ping interval, 9/10 of ReadTimeout by default.
+	PingInterval xtime.Duration
+	// MaxMessageSize is the max size in bytes of a received message, the
+	// connection is closed with 1009 if a message exceeds it.
+	MaxMessageSize   int64
+	HandshakeTimeout xtime.Duration
+	ReadBufferSize   int
+	WriteBufferSize  int
+	// AllowOrigins is the allowed origins, "*" allows any origin.
+	// The origin must be the same as the Host if it's empty.
+	AllowOrigins      []string
+	Subprotocols      []string
+	EnableCompression bool
+}
+
+func (conf *WebSocketConfig) fix() *WebSocketConfig {
+	c := &WebSocketConfig{}
+	if conf != nil {
+		*c = *conf
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = xtime.Duration(_defaultWSReadTimeout)
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = xtime.Duration(_defaultWSWriteTimeout)
+	}
+	if c.PingInterval <= 0 || c.PingInterval >= c.ReadTimeout {
+		c.PingInterval = c.ReadTimeout * 9 / 10
+	}
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = _defaultWSMaxMessageSize
+	}
+	return c
+}
+
+func (conf *WebSocketConfig) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if len(conf.AllowOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	for _, o := range conf.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) || strings.EqualFold(o, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketHandler handles an upgraded websocket connection, the connection
+// is closed once it returns.
+type WebSocketHandler func(conn *WebSocketConn)
+
+// WebSocket registers a websocket route on GET, the middleware of the group
+// and handlers run before the upgrade, aborting in them rejects the handshake.
+// The connection is exempted from the method timeout and lives until the handler returns.
+func (group *RouterGroup) WebSocket(relativePath string, conf *WebSocketConfig, handler WebSocketHandler, handlers ...HandlerFunc) IRoutes {
+	conf = conf.fix()
+	upgrader := &websocket.Upgrader{
+		HandshakeTimeout:  time.Duration(conf.HandshakeTimeout),
+		ReadBufferSize:    conf.ReadBufferSize,
+		WriteBufferSize:   conf.WriteBufferSize,
+		Subprotocols:      conf.Subprotocols,
+		EnableCompression: conf.EnableCompression,
+		CheckOrigin:       conf.checkOrigin,
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			w.Header().Set("Sec-Websocket-Version", "13")
+			http.Error(w, http.StatusText(status), status)
+		},
+	}
+	upgrade := func(c *Context) {
+		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Warn("blademaster: websocket upgrade %s error(%v)", c.Request.URL.Path, err)
+			c.Error = ecode.RequestErr
+			c.Abort()
+			return
+		}
+		// NOTE: the connection outlives the method timeout, the context keeps
+		// the values but not the deadline, it's canceled when the connection is closed.
+		var cancel func()
+		c.Context, cancel = context.WithCancel(detachedContext{c.Context})
+		conn := newWebSocketConn(c, ws, conf)
+		conn.cancel = cancel
+		defer conn.Close()
+		handler(conn)
+	}
+	return group.handle("GET", relativePath, append(handlers[:len(handlers):len(handlers)], upgrade)...)
+}
+
+// detachedContext keeps the values of the parent without the deadline and
+// the cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+func (c detachedContext) Value(key interface{}) interface{}     { return c.parent.Value(key) }
+
+// WebSocketConn is an upgraded websocket connection with keepalive, it's
+// safe to write concurrently with one reader.
+type WebSocketConn struct {
+	ctx  *Context
+	conn *websocket.Conn
+	conf *WebSocketConfig
+	path string
+	// cancel cancels the context once the connection is closed.
+	cancel func()
+
+	wmu       sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWebSocketConn(c *Context, ws *websocket.Conn, conf *WebSocketConfig) *WebSocketConn {
+	conn := &WebSocketConn{
+		ctx:  c,
+		conn: ws,
+		conf: conf,
+		path: strings.TrimPrefix(c.RoutePath, "/"),
+		done: make(chan struct{}),
+	}
+	readTimeout := time.Duration(conf.ReadTimeout)
+	ws.SetReadLimit(conf.MaxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(readTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	_metricServerWSConnTotal.Inc(conn.path)
+	_metricServerWSConnCurrent.Inc(conn.path)
+	go conn.keepalive()
+	return conn
+}
+
+func (c *WebSocketConn) keepalive() {
+	ticker := time.NewTicker(time.Duration(c.conf.PingInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deadline := time.Now().Add(time.Duration(c.conf.WriteTimeout))
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				log.Warn("blademaster: websocket %s ping error(%v)", c.path, err)
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		case <-c.ctx.Done():
+			c.Close()
+			return
+		}
+	}
+}
+
+// Context returns the request context, it carries the metadata set by the
+// middleware and is canceled when the connection is closed.
+func (c *WebSocketConn) Context() *Context {
+	return c.ctx
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+// Done returns a channel that's closed when the connection is closed.
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// ReadMessage reads a message, the read deadline is extended after it.
+func (c *WebSocketConn) ReadMessage() (messageType int, p []byte, err error) {
+	if messageType, p, err = c.conn.ReadMessage(); err != nil {
+		return
+	}
+	c.conn.SetReadDeadline(time.Now().Add(time.Duration(c.conf.ReadTimeout)))
+	_metricServerWSMsgTotal.Inc(c.path, "read")
+	return
+}
+
+// ReadJSON reads a text message and decodes it as json into v.
+func (c *WebSocketConn) ReadJSON(v interface{}) (err error) {
+	if err = c.conn.ReadJSON(v); err != nil {
+		return
+	}
+	c.conn.SetReadDeadline(time.Now().Add(time.Duration(c.conf.ReadTimeout)))
+	_metricServerWSMsgTotal.Inc(c.path, "read")
+	return
+}
+
+// WriteMessage writes a message with the write timeout.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	return c.write(func() error {
+		return c.conn.WriteMessage(messageType, data)
+	})
+}
+
+// WriteJSON writes v as a json text message with the write timeout.
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	return c.write(func() error {
+		return c.conn.WriteJSON(v)
+	})
+}
+
+func (c *WebSocketConn) write(fn func() error) (err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(time.Duration(c.conf.WriteTimeout)))
+	if err = fn(); err != nil {
+		return errors.WithStack(err)
+	}
+	_metricServerWSMsgTotal.Inc(c.path, "write")
+	return
+}
+
+// CloseWithReason sends a close message with the code and reason then closes the connection.
+func (c *WebSocketConn) CloseWithReason(code int, reason string) error {
+	deadline := time.Now().Add(time.Duration(c.conf.WriteTimeout))
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	return c.Close()
+}
+
+// Close closes the underlying connection, it's safe to call multiple times.
+func (c *WebSocketConn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.cancel != nil {
+			c.cancel()
+		}
+		err = c.conn.Close()
+		_metricServerWSConnCurrent.Add(-1, c.path)
+	})
+	return
+}
+
+// IsCloseError reports whether err is a close error with any of the codes.
+func IsCloseError(err error, codes ...int) bool {
+	return websocket.IsCloseError(errors.Cause(err), codes...)
+}
@@ -0,0 +1,134 @@
+package blademaster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebSocketEngine() *Engine {
+	e := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Millisecond * 100)})
+	auth := func(c *Context) {
+		if c.Request.Header.Get("Authorization") == "" {
+			c.JSON(nil, ecode.Unauthorized)
+			c.Abort()
+			return
+		}
+		c.Set("user", c.Request.Header.Get("Authorization"))
+	}
+	conf := &WebSocketConfig{
+		ReadTimeout:    xtime.Duration(time.Millisecond * 200),
+		MaxMessageSize: 16,
+	}
+	e.WebSocket("/ws", conf, func(conn *WebSocketConn) {
+		user, _ := conn.Context().Get("user")
+		for {
+			mt, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(mt, append([]byte(user.(string)+":"), p...)); err != nil {
+				return
+			}
+		}
+	}, auth)
+	return e
+}
+
+func dialWebSocket(t *testing.T, url string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws", header)
+}
+
+func TestWebSocket(t *testing.T) {
+	srv := httptest.NewServer(newWebSocketEngine())
+	defer srv.Close()
+
+	t.Run("rejected by middleware", func(t *testing.T) {
+		_, resp, err := dialWebSocket(t, srv.URL, nil)
+		assert.Error(t, err)
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, "-401", resp.Header.Get("atreus-status-code"))
+		}
+	})
+	t.Run("echo beyond timeout", func(t *testing.T) {
+		conn, _, err := dialWebSocket(t, srv.URL, http.Header{"Authorization": {"atreus"}})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		for i := 0; i < 3; i++ {
+			// the connection outlives the 100ms method timeout and the 200ms read timeout by keepalive.
+			time.Sleep(time.Millisecond * 150)
+			assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hi")))
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, p, err := conn.ReadMessage()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "atreus:hi", string(p))
+		}
+	})
+	t.Run("message too big", func(t *testing.T) {
+		conn, _, err := dialWebSocket(t, srv.URL, http.Header{"Authorization": {"atreus"}})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 32))))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "%v", err)
+	})
+	t.Run("origin", func(t *testing.T) {
+		_, resp, err := dialWebSocket(t, srv.URL, http.Header{"Authorization": {"atreus"}, "Origin": {"http://evil.com"}})
+		assert.Error(t, err)
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		}
+	})
+}
+
+func TestWebSocketParam(t *testing.T) {
+	e := NewServer(&ServerConfig{Timeout: xtime.Duration(time.Millisecond * 100)})
+	g := e.Group("/room").SetMethodConfig(&MethodConfig{MaxBodySize: 1024})
+	g.WebSocket("/:id", nil, func(conn *WebSocketConn) {
+		id, _ := conn.Context().Params.Get("id")
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			if err := conn.Context().Err(); err != nil {
+				conn.WriteMessage(TextMessage, []byte(err.Error()))
+				return
+			}
+			if err := conn.WriteMessage(TextMessage, []byte(id)); err != nil {
+				return
+			}
+		}
+	})
+	// the method config of the group is kept.
+	assert.Equal(t, int64(1024), e.methodConfig("/room/:id").MaxBodySize)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/room/1", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	// the connection of the param route outlives the 100ms method timeout.
+	time.Sleep(time.Millisecond * 150)
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hi")))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, p, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(p))
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/mapgoo-lab/atreus/pkg/log"
 	nmd "github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/locality"
 	wmd "github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/metadata"
 
 	"google.golang.org/grpc/balancer"
@@ -141,6 +142,10 @@ func (*p2cPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
 		}
 		cp.subConns = append(cp.subConns, subc)
 	}
+	p.localize()
+	for _, cp := range p.colors {
+		cp.localize()
+	}
 	return p
 }
 
@@ -150,6 +155,8 @@ type p2cPicker struct {
 	// selection from it and return the selected SubConn.
 	subConns []*subConn
 	colors   map[string]*p2cPicker
+	zones    map[string]*p2cPicker
+	locality *locality.Picker
 	logTs    int64
 	r        *rand.Rand
 	lk       sync.Mutex
@@ -169,6 +176,31 @@ func (p *p2cPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 	return p.pick(info.Ctx, info)
 }
 
+// localize splits subConns into zone pickers if the resolver enabled locality.
+func (p *p2cPicker) localize() {
+	var (
+		zones  = make(map[string]*p2cPicker)
+		shares = make(map[string]uint64)
+	)
+	for _, sc := range p.subConns {
+		if sc.meta.Share == 0 {
+			return
+		}
+		zp, ok := zones[sc.meta.Zone]
+		if !ok {
+			zp = &p2cPicker{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+			zones[sc.meta.Zone] = zp
+		}
+		zp.subConns = append(zp.subConns, sc)
+		shares[sc.meta.Zone] = sc.meta.Share
+	}
+	if len(zones) == 0 {
+		return
+	}
+	p.zones = zones
+	p.locality = locality.NewPicker(p.subConns[0].addr.ServerName, shares)
+}
+
 // choose two distinct nodes
 func (p *p2cPicker) prePick() (nodeA *subConn, nodeB *subConn) {
 	for i := 0; i < 3; i++ {
@@ -188,6 +220,11 @@ func (p *p2cPicker) prePick() (nodeA *subConn, nodeB *subConn) {
 }
 
 func (p *p2cPicker) pick(ctx context.Context, opts balancer.PickInfo) (balancer.PickResult, error) {
+	if p.locality != nil {
+		if zp, ok := p.zones[p.locality.Pick()]; ok {
+			return zp.pick(ctx, opts)
+		}
+	}
 	var pc, upc *subConn
 	start := time.Now().UnixNano()
 
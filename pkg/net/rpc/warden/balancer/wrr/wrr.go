@@ -11,6 +11,7 @@ import (
 	"github.com/mapgoo-lab/atreus/pkg/conf/env"
 	"github.com/mapgoo-lab/atreus/pkg/log"
 	nmd "github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/locality"
 	wmeta "github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/metadata"
 	"github.com/mapgoo-lab/atreus/pkg/stat/metric"
 	"google.golang.org/grpc"
@@ -178,6 +179,10 @@ func (*wrrPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
 		}
 		cp.subConns = append(cp.subConns, subc)
 	}
+	p.localize()
+	for _, cp := range p.colors {
+		cp.localize()
+	}
 	return p
 }
 
@@ -187,6 +192,8 @@ type wrrPicker struct {
 	// selection from it and return the selected SubConn.
 	subConns []*subConn
 	colors   map[string]*wrrPicker
+	zones    map[string]*wrrPicker
+	locality *locality.Picker
 	updateAt int64
 
 	mu sync.Mutex
@@ -206,7 +213,37 @@ func (p *wrrPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 	return p.pick(info.Ctx, info)
 }
 
+// localize splits subConns into zone pickers if the resolver enabled locality.
+func (p *wrrPicker) localize() {
+	var (
+		zones  = make(map[string]*wrrPicker)
+		shares = make(map[string]uint64)
+	)
+	for _, sc := range p.subConns {
+		if sc.meta.Share == 0 {
+			return
+		}
+		zp, ok := zones[sc.meta.Zone]
+		if !ok {
+			zp = &wrrPicker{}
+			zones[sc.meta.Zone] = zp
+		}
+		zp.subConns = append(zp.subConns, sc)
+		shares[sc.meta.Zone] = sc.meta.Share
+	}
+	if len(zones) == 0 {
+		return
+	}
+	p.zones = zones
+	p.locality = locality.NewPicker(p.subConns[0].addr.ServerName, shares)
+}
+
 func (p *wrrPicker) pick(ctx context.Context, info balancer.PickInfo) (balancer.PickResult, error) {
+	if p.locality != nil {
+		if zp, ok := p.zones[p.locality.Pick()]; ok {
+			return zp.pick(ctx, info)
+		}
+	}
 	var (
 		conn        *subConn
 		totalWeight int64
@@ -68,6 +68,7 @@ type ClientConfig struct {
 	Clusters               []string
 	Zone                   string
 	Subset                 int
+	Spillover              float64
 	NonBlock               bool
 	KeepAliveInterval      xtime.Duration
 	KeepAliveTimeout       xtime.Duration
@@ -312,6 +313,9 @@ func (c *Client) dial(ctx context.Context, target string, opts ...grpc.DialOptio
 		if v.Get("subset") == "" && c.conf.Subset > 0 {
 			v.Add("subset", strconv.FormatInt(int64(c.conf.Subset), 10))
 		}
+		if v.Get("spillover") == "" && c.conf.Spillover > 0 {
+			v.Add("spillover", strconv.FormatFloat(c.conf.Spillover, 'f', -1, 64))
+		}
 		u.RawQuery = v.Encode()
 		// 比较_grpcTarget中的appid是否等于u.path中的appid，并替换成mock的地址
 		for _, t := range _grpcTarget {
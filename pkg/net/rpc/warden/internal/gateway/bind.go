@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/runtime/protoimpl"
+)
+
+// _maxBodySize is the max size of request body.
+const _maxBodySize = 32 << 20
+
+// bind binds the http request into the request message of rt.
+// Fields are bound by the query parameters first, then the body and the path variables.
+func bind(r *http.Request, rt *route, in interface{}, vars map[string]string) (err error) {
+	m := protoimpl.X.ProtoMessageV2Of(in).ProtoReflect()
+	if rt.body != "*" {
+		for key, values := range r.URL.Query() {
+			if _, ok := vars[key]; ok || key == rt.body {
+				continue
+			}
+			// NOTE: unknown query parameters are ignored like blademaster binding.
+			if err = setField(m, key, values); err != nil && !errors.Is(err, errUnknownField) {
+				return
+			}
+		}
+	}
+	if rt.body != "" {
+		var body []byte
+		if body, err = io.ReadAll(io.LimitReader(r.Body, _maxBodySize)); err != nil {
+			return errors.WithStack(err)
+		}
+		if len(body) > 0 {
+			var dst interface{}
+			if rt.body == "*" {
+				dst = in
+			} else {
+				field, ok := structField(reflect.ValueOf(in), rt.body)
+				if !ok {
+					return errors.Errorf("gateway: body field(%s) not found", rt.body)
+				}
+				dst = field.Addr().Interface()
+			}
+			if err = json.Unmarshal(body, dst); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	for path, value := range vars {
+		if err = setField(m, path, []string{value}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+var errUnknownField = errors.New("gateway: unknown field")
+
+// setField sets the field of m by a dot separated field path.
+func setField(m protoreflect.Message, path string, values []string) error {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := fieldByName(m.Descriptor(), name)
+		if fd == nil {
+			return errors.WithMessage(errUnknownField, path)
+		}
+		if i < len(names)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return errors.Errorf("gateway: field(%s) is not a message", path)
+			}
+			m = m.Mutable(fd).Message()
+			continue
+		}
+		if fd.IsMap() || (fd.Message() != nil && !fd.IsList()) {
+			return errors.Errorf("gateway: field(%s) is not a scalar", path)
+		}
+		if fd.IsList() {
+			list := m.Mutable(fd).List()
+			for _, v := range values {
+				pv, err := parseValue(fd, v)
+				if err != nil {
+					return errors.WithMessage(err, path)
+				}
+				list.Append(pv)
+			}
+			return nil
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		pv, err := parseValue(fd, values[len(values)-1])
+		if err != nil {
+			return errors.WithMessage(err, path)
+		}
+		m.Set(fd, pv)
+	}
+	return nil
+}
+
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+func parseValue(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), errors.WithStack(err)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), errors.WithStack(err)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), errors.WithStack(err)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), errors.WithStack(err)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), errors.WithStack(err)
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), errors.WithStack(err)
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), errors.WithStack(err)
+	case protoreflect.BytesKind:
+		v, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			v, err = base64.URLEncoding.DecodeString(s)
+		}
+		return protoreflect.ValueOfBytes(v), errors.WithStack(err)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), errors.WithStack(err)
+	}
+	return protoreflect.Value{}, errors.Errorf("gateway: unsupported field kind(%s)", fd.Kind())
+}
+
+// structField finds the go struct field of a generated message by proto field name.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		for _, opt := range strings.Split(t.Field(i).Tag.Get("protobuf"), ",") {
+			if opt == "name="+name {
+				return v.Field(i), true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"strings"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/runtime/protoimpl"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// serviceDescriptor resolves the descriptor of the grpc service, file is the
+// grpc.ServiceInfo Metadata which is the proto file of the generated code.
+func serviceDescriptor(name string, file interface{}) (*descriptorpb.ServiceDescriptorProto, error) {
+	path, _ := file.(string)
+	if path == "" {
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "gateway: service(%s) has no proto file", name)
+		}
+		path = d.ParentFile().Path()
+	}
+	fd, err := fileDescriptor(path)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "gateway: service(%s)", name)
+	}
+	for _, sd := range fd.GetService() {
+		if fullName(fd.GetPackage(), sd.GetName()) == name {
+			return sd, nil
+		}
+	}
+	return nil, errors.Errorf("gateway: service(%s) not found in file(%s)", name, path)
+}
+
+// fileDescriptor returns the file descriptor registered by the generated code.
+// The gogo registry is looked up before the golang one, both the options of
+// the file are parsed by the registered extensions like google.api.http.
+func fileDescriptor(path string) (*descriptorpb.FileDescriptorProto, error) {
+	if gz := gogoproto.FileDescriptor(path); len(gz) > 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			return nil, errors.Wrapf(err, "file(%s)", path)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "file(%s)", path)
+		}
+		fd := new(descriptorpb.FileDescriptorProto)
+		if err = proto.Unmarshal(b, fd); err != nil {
+			return nil, errors.Wrapf(err, "file(%s)", path)
+		}
+		return fd, nil
+	}
+	fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "file(%s)", path)
+	}
+	return protodesc.ToFileDescriptorProto(fd), nil
+}
+
+// messageType returns the go type of the generated message, e.g. *pb.HelloRequest.
+func messageType(name string) (reflect.Type, error) {
+	name = strings.TrimPrefix(name, ".")
+	if t := gogoproto.MessageType(name); t != nil {
+		return t, nil
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "message(%s)", name)
+	}
+	return reflect.TypeOf(protoimpl.X.ProtoMessageV1Of(mt.New().Interface())), nil
+}
+
+func fullName(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
@@ -0,0 +1,262 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/net/http/blademaster/render"
+	nmd "github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/status"
+	"github.com/mapgoo-lab/atreus/pkg/net/trace"
+
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	gstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// http head, keep the same with blademaster.
+	_httpHeaderUser     = "x1-bmspy-user"
+	_httpHeaderTimeout  = "x1-bmspy-timeout"
+	_httpHeaderRemoteIP = "x-backend-bm-real-ip"
+	_httpHeaderMetadata = "x-bm-metadata-"
+	_httpHeaderStatus   = "atreus-status-code"
+)
+
+type route struct {
+	method     string
+	pattern    *pattern
+	body       string
+	respBody   string
+	fullMethod string
+	input      reflect.Type
+	output     reflect.Type
+}
+
+// Gateway transcodes HTTP/JSON requests to the unary grpc methods annotated by google.api.http.
+type Gateway struct {
+	conn   grpc.ClientConnInterface
+	routes []*route
+}
+
+// New new a gateway which invokes grpc methods through conn.
+func New(conn grpc.ClientConnInterface) *Gateway {
+	return &Gateway{conn: conn}
+}
+
+// Register registers routes of the grpc services by their google.api.http annotations.
+// The services are resolved by the proto file in grpc.ServiceInfo Metadata, it
+// fails if any of the services can't be resolved.
+func (g *Gateway) Register(services map[string]grpc.ServiceInfo) (err error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var sd *descriptorpb.ServiceDescriptorProto
+		if sd, err = serviceDescriptor(name, services[name].Metadata); err != nil {
+			return
+		}
+		for _, md := range sd.GetMethod() {
+			if err = g.registerMethod(name, md); err != nil {
+				return
+			}
+		}
+	}
+	// NOTE: the pattern with more literal segments takes precedence.
+	sort.SliceStable(g.routes, func(i, j int) bool {
+		return g.routes[i].pattern.literals > g.routes[j].pattern.literals
+	})
+	return
+}
+
+func (g *Gateway) registerMethod(service string, md *descriptorpb.MethodDescriptorProto) (err error) {
+	if md.GetClientStreaming() || md.GetServerStreaming() || md.GetOptions() == nil {
+		return
+	}
+	rule, ok := proto.GetExtension(md.GetOptions(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return
+	}
+	fullMethod := fmt.Sprintf("/%s/%s", service, md.GetName())
+	var in, out reflect.Type
+	if in, err = messageType(md.GetInputType()); err != nil {
+		return errors.WithMessagef(err, "gateway: method(%s) input", fullMethod)
+	}
+	if out, err = messageType(md.GetOutputType()); err != nil {
+		return errors.WithMessagef(err, "gateway: method(%s) output", fullMethod)
+	}
+	rules := append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...)
+	for _, r := range rules {
+		var method, tmpl string
+		switch p := r.Pattern.(type) {
+		case *annotations.HttpRule_Get:
+			method, tmpl = http.MethodGet, p.Get
+		case *annotations.HttpRule_Put:
+			method, tmpl = http.MethodPut, p.Put
+		case *annotations.HttpRule_Post:
+			method, tmpl = http.MethodPost, p.Post
+		case *annotations.HttpRule_Delete:
+			method, tmpl = http.MethodDelete, p.Delete
+		case *annotations.HttpRule_Patch:
+			method, tmpl = http.MethodPatch, p.Patch
+		case *annotations.HttpRule_Custom:
+			method, tmpl = strings.ToUpper(p.Custom.Kind), p.Custom.Path
+		default:
+			continue
+		}
+		var pt *pattern
+		if pt, err = parsePattern(tmpl); err != nil {
+			return
+		}
+		g.routes = append(g.routes, &route{
+			method:     method,
+			pattern:    pt,
+			body:       r.Body,
+			respBody:   r.ResponseBody,
+			fullMethod: fullMethod,
+			input:      in,
+			output:     out,
+		})
+		log.Info("gateway: register %s %s => %s", method, tmpl, fullMethod)
+	}
+	return
+}
+
+// ServeHTTP implements http.Handler.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		writeJSON(w, http.StatusUnsupportedMediaType, nil, ecode.RequestErr)
+		return
+	}
+	var allowed bool
+	path := r.URL.EscapedPath()
+	for _, rt := range g.routes {
+		vars, ok := rt.pattern.match(path)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			allowed = true
+			continue
+		}
+		g.serve(w, r, rt, vars)
+		return
+	}
+	if allowed {
+		writeJSON(w, http.StatusMethodNotAllowed, nil, ecode.MethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusNotFound, nil, ecode.NothingFound)
+}
+
+func (g *Gateway) serve(w http.ResponseWriter, r *http.Request, rt *route, vars map[string]string) {
+	in, out := reflect.New(rt.input.Elem()).Interface(), reflect.New(rt.output.Elem()).Interface()
+	if err := bind(r, rt, in, vars); err != nil {
+		log.Warn("gateway: bind %s request error(%v)", rt.fullMethod, err)
+		writeJSON(w, http.StatusOK, nil, ecode.RequestErr)
+		return
+	}
+	ctx := r.Context()
+	if to := timeout(r); to > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, to)
+		defer cancel()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, outgoingMD(r))
+	if err := g.conn.Invoke(ctx, rt.fullMethod, in, out); err != nil {
+		gst, _ := gstatus.FromError(err)
+		writeJSON(w, http.StatusOK, nil, status.ToEcode(gst))
+		return
+	}
+	var data = out
+	if rt.respBody != "" {
+		if field, ok := structField(reflect.ValueOf(data), rt.respBody); ok {
+			data = field.Interface()
+		}
+	}
+	writeJSON(w, http.StatusOK, data, nil)
+}
+
+// outgoingMD converts the http headers into grpc metadata.
+func outgoingMD(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	if t, err := trace.Extract(trace.HTTPFormat, r.Header); err == nil {
+		trace.Inject(t, trace.GRPCFormat, md)
+	}
+	for key := range r.Header {
+		lk := strings.ToLower(key)
+		if !strings.HasPrefix(lk, _httpHeaderMetadata) {
+			continue
+		}
+		if mk := strings.ReplaceAll(strings.TrimPrefix(lk, _httpHeaderMetadata), "-", "_"); nmd.IsIncomingKey(mk) {
+			md.Set(mk, r.Header.Get(key))
+		}
+	}
+	if caller := r.Header.Get(_httpHeaderUser); caller != "" {
+		md.Set(nmd.Caller, caller)
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		md.Set("authorization", auth)
+	}
+	md.Set(nmd.RemoteIP, remoteIP(r))
+	return md
+}
+
+// timeout get timeout from http request.
+func timeout(r *http.Request) time.Duration {
+	to, err := strconv.ParseInt(r.Header.Get(_httpHeaderTimeout), 10, 64)
+	if err == nil && to > 20 {
+		to -= 20 // reduce 20ms every time.
+	}
+	return time.Duration(to) * time.Millisecond
+}
+
+// remoteIP returns the real client IP like blademaster.
+func remoteIP(r *http.Request) (remote string) {
+	if remote = r.Header.Get(_httpHeaderRemoteIP); remote != "" && remote != "null" {
+		return
+	}
+	var xff = r.Header.Get("X-Forwarded-For")
+	if idx := strings.IndexByte(xff, ','); idx > -1 {
+		if remote = strings.TrimSpace(xff[:idx]); remote != "" {
+			return
+		}
+	}
+	if remote = r.Header.Get("X-Real-IP"); remote != "" {
+		return
+	}
+	if idx := strings.LastIndexByte(r.RemoteAddr, ':'); idx > 0 {
+		return r.RemoteAddr[:idx]
+	}
+	return r.RemoteAddr
+}
+
+// writeJSON writes the response the same as blademaster Context.JSON.
+func writeJSON(w http.ResponseWriter, code int, data interface{}, err error) {
+	bcode := ecode.Cause(err)
+	resp := render.JSON{
+		Code:    bcode.Code(),
+		Message: bcode.Message(),
+		Data:    data,
+	}
+	w.Header().Set(_httpHeaderStatus, strconv.FormatInt(int64(bcode.Code()), 10))
+	resp.WriteContentType(w)
+	w.WriteHeader(code)
+	if err = resp.Render(w); err != nil {
+		log.Error("gateway: render response error(%v)", err)
+	}
+}
@@ -0,0 +1,208 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	pb "github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/proto/testgw"
+	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/status"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		path string
+		ok   bool
+		vars map[string]string
+	}{
+		{"/v1/hello", "/v1/hello", true, map[string]string{}},
+		{"/v1/hello", "/v1/hello/world", false, nil},
+		{"/v1/hello/{name}", "/v1/hello/world", true, map[string]string{"name": "world"}},
+		{"/v1/hello/{name}", "/v1/hello/w%2Fd", true, map[string]string{"name": "w/d"}},
+		{"/v1/hello/{name}", "/v1/hello", false, nil},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books/2", true, map[string]string{"name": "shelves/1/books/2"}},
+		{"/v1/{name=files/**}", "/v1/files/a/b/c", true, map[string]string{"name": "files/a/b/c"}},
+		{"/v1/hello/{name}:greet", "/v1/hello/world:greet", true, map[string]string{"name": "world"}},
+		{"/v1/hello/{name}:greet", "/v1/hello/world", false, nil},
+		{"/v1/*/age/{age}", "/v1/x/age/10", true, map[string]string{"age": "10"}},
+	}
+	for _, test := range tests {
+		p, err := parsePattern(test.tmpl)
+		if !assert.NoError(t, err, test.tmpl) {
+			continue
+		}
+		vars, ok := p.match(test.path)
+		assert.Equal(t, test.ok, ok, test.tmpl+" "+test.path)
+		assert.Equal(t, test.vars, vars, test.tmpl+" "+test.path)
+	}
+	for _, tmpl := range []string{"v1/hello", "/v1/{name", "/v1/**/hello", "/v1//hello"} {
+		_, err := parsePattern(tmpl)
+		assert.Error(t, err, tmpl)
+	}
+}
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+	// delay delays the reply to test the shutdown.
+	delay time.Duration
+}
+
+func (g *greeter) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
+	if in.Age < 0 {
+		return nil, status.FromError(ecode.AccessDenied).Err()
+	}
+	time.Sleep(g.delay)
+	return &pb.HelloReply{Message: "hello " + in.Name, Success: in.Age > 0}, nil
+}
+
+func TestRegister(t *testing.T) {
+	gs := grpc.NewServer()
+	pb.RegisterGreeterServer(gs, &greeter{})
+	gw := New(nil)
+	if !assert.NoError(t, gw.Register(gs.GetServiceInfo())) {
+		return
+	}
+	// the streaming method is not transcoded.
+	assert.Len(t, gw.routes, 2)
+	for _, rt := range gw.routes {
+		assert.Equal(t, "/testgw.Greeter/SayHello", rt.fullMethod)
+	}
+
+	gs.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "testgw.Unknown",
+		HandlerType: (*interface{})(nil),
+		Metadata:    "unknown.proto",
+	}, struct{}{})
+	assert.Error(t, New(nil).Register(gs.GetServiceInfo()))
+}
+
+func TestGateway(t *testing.T) {
+	gs := grpc.NewServer()
+	pb.RegisterGreeterServer(gs, &greeter{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(gs)
+	go s.Serve(lis)
+	defer func() {
+		s.Shutdown(context.Background())
+		gs.Stop()
+	}()
+	time.Sleep(time.Millisecond * 100)
+	addr := "http://" + lis.Addr().String()
+
+	decode := func(resp *http.Response) (code int, data map[string]interface{}) {
+		defer resp.Body.Close()
+		var res struct {
+			Code int                    `json:"code"`
+			Data map[string]interface{} `json:"data"`
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatalf("invalid response %s", b)
+		}
+		return res.Code, res.Data
+	}
+
+	t.Run("get", func(t *testing.T) {
+		resp, err := http.Get(addr + "/v1/hello/atreus?age=18")
+		if !assert.NoError(t, err) {
+			return
+		}
+		code, data := decode(resp)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "hello atreus", data["message"])
+		assert.Equal(t, true, data["success"])
+	})
+	t.Run("post", func(t *testing.T) {
+		resp, err := http.Post(addr+"/v1/hello", "application/json", strings.NewReader(`{"name":"bm","age":0}`))
+		if !assert.NoError(t, err) {
+			return
+		}
+		code, data := decode(resp)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "hello bm", data["message"])
+	})
+	t.Run("ecode", func(t *testing.T) {
+		resp, err := http.Get(addr + "/v1/hello/atreus?age=-1")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "-403", resp.Header.Get(_httpHeaderStatus))
+		code, _ := decode(resp)
+		assert.Equal(t, ecode.AccessDenied.Code(), code)
+	})
+	t.Run("bad request", func(t *testing.T) {
+		resp, err := http.Get(addr + "/v1/hello/atreus?age=x")
+		if !assert.NoError(t, err) {
+			return
+		}
+		code, _ := decode(resp)
+		assert.Equal(t, ecode.RequestErr.Code(), code)
+	})
+	t.Run("not found", func(t *testing.T) {
+		resp, err := http.Get(addr + "/v2/hello")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp.Body.Close()
+		resp, err = http.Post(addr+"/v1/hello/atreus", "application/json", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		resp.Body.Close()
+	})
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		reply := new(pb.HelloReply)
+		err = conn.Invoke(context.Background(), "/testgw.Greeter/SayHello", &pb.HelloRequest{Name: "grpc", Age: 1}, reply)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello grpc", reply.Message)
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	gs := grpc.NewServer()
+	pb.RegisterGreeterServer(gs, &greeter{delay: time.Millisecond * 200})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(gs)
+	go s.Serve(lis)
+	time.Sleep(time.Millisecond * 100)
+
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + lis.Addr().String() + "/v1/hello/atreus")
+		assert.NoError(t, err)
+		done <- resp
+	}()
+	time.Sleep(time.Millisecond * 50)
+	// the in-flight HTTP request is finished before the grpc server stops.
+	assert.NoError(t, s.Shutdown(context.Background()))
+	gs.GracefulStop()
+	resp := <-done
+	if !assert.NotNil(t, resp) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "0", resp.Header.Get(_httpHeaderStatus))
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// _sniffTimeout is the max duration to read the connection preface.
+const _sniffTimeout = time.Second * 10
+
+// _h2Preface is the first bytes of the HTTP/2 client connection preface "PRI * HTTP/2.0".
+var _h2Preface = []byte("PRI ")
+
+var errListenerClosed = errors.New("gateway: listener closed")
+
+// Split splits lis by protocol, HTTP/2 connections (grpc) go to the first
+// listener and the others (HTTP/1.x) go to the second one.
+func Split(lis net.Listener) (h2 net.Listener, h1 net.Listener) {
+	m := &mux{
+		root: lis,
+		done: make(chan struct{}),
+	}
+	m.h2 = &muxListener{m: m, conns: make(chan net.Conn), done: make(chan struct{})}
+	m.h1 = &muxListener{m: m, conns: make(chan net.Conn), done: make(chan struct{})}
+	go m.serve()
+	return m.h2, m.h1
+}
+
+type mux struct {
+	root   net.Listener
+	h2, h1 *muxListener
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func (m *mux) serve() {
+	for {
+		conn, err := m.root.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(time.Millisecond * 5)
+				continue
+			}
+			m.close(err)
+			return
+		}
+		go m.dispatch(conn)
+	}
+}
+
+func (m *mux) dispatch(conn net.Conn) {
+	pc := &peekConn{Conn: conn, r: bufio.NewReader(conn)}
+	conn.SetReadDeadline(time.Now().Add(_sniffTimeout))
+	head, err := pc.r.Peek(len(_h2Preface))
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+	l := m.h1
+	if string(head) == string(_h2Preface) {
+		l = m.h2
+	}
+	select {
+	case l.conns <- pc:
+	case <-l.done:
+		conn.Close()
+	case <-m.done:
+		conn.Close()
+	}
+}
+
+// closed reports whether both the splitted listeners are closed.
+func (m *mux) closed() bool {
+	for _, l := range []*muxListener{m.h2, m.h1} {
+		select {
+		case <-l.done:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (m *mux) close(err error) {
+	m.once.Do(func() {
+		m.err = err
+		close(m.done)
+		m.root.Close()
+	})
+}
+
+type muxListener struct {
+	m     *mux
+	conns chan net.Conn
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, errListenerClosed
+	case <-l.m.done:
+		if l.m.err != nil {
+			return nil, l.m.err
+		}
+		return nil, errListenerClosed
+	}
+}
+
+// Close closes the listener, the root listener is closed after both the
+// splitted listeners are closed, so the HTTP server can be shut down before
+// the grpc server.
+func (l *muxListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+		if l.m.closed() {
+			l.m.close(nil)
+		}
+	})
+	return nil
+}
+
+// Addr returns the root listener's network address.
+func (l *muxListener) Addr() net.Addr {
+	return l.m.root.Addr()
+}
+
+// peekConn is a net.Conn which replays the sniffed bytes.
+type peekConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,178 @@
+package gateway
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	opLiteral = iota
+	opWildcard
+	opDeepWildcard
+)
+
+type op struct {
+	kind int
+	lit  string
+}
+
+type variable struct {
+	path       string
+	start, end int // end is -1 when the variable ends with a deep wildcard
+}
+
+// pattern is a compiled google.api.http path template.
+// Template = "/" Segments [ Verb ] ;
+// Segments = Segment { "/" Segment } ;
+// Segment  = "*" | "**" | LITERAL | Variable ;
+// Variable = "{" FieldPath [ "=" Segments ] "}" ;
+// Verb     = ":" LITERAL ;
+type pattern struct {
+	ops  []op
+	vars []variable
+	verb string
+	// literals is the number of literal segments, used to order patterns.
+	literals int
+}
+
+func parsePattern(tmpl string) (p *pattern, err error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, errors.Errorf("gateway: template(%s) must start with /", tmpl)
+	}
+	p = new(pattern)
+	rest := tmpl[1:]
+	if idx := strings.LastIndex(rest, ":"); idx >= 0 && !strings.Contains(rest[idx:], "}") {
+		p.verb, rest = rest[idx+1:], rest[:idx]
+	}
+	for len(rest) > 0 {
+		var seg string
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, errors.Errorf("gateway: template(%s) has unclosed variable", tmpl)
+			}
+			seg, rest = rest[:end+1], rest[end+1:]
+			if err = p.parseVariable(seg[1 : len(seg)-1]); err != nil {
+				return nil, errors.WithMessage(err, tmpl)
+			}
+		} else {
+			end := strings.IndexByte(rest, '/')
+			if end < 0 {
+				end = len(rest)
+			}
+			seg, rest = rest[:end], rest[end:]
+			if err = p.parseSegment(seg); err != nil {
+				return nil, errors.WithMessage(err, tmpl)
+			}
+		}
+		if len(rest) > 0 {
+			if rest[0] != '/' {
+				return nil, errors.Errorf("gateway: template(%s) has invalid segment", tmpl)
+			}
+			rest = rest[1:]
+		}
+	}
+	for i, o := range p.ops {
+		if o.kind == opDeepWildcard && i != len(p.ops)-1 {
+			return nil, errors.Errorf("gateway: template(%s) ** must be the last segment", tmpl)
+		}
+	}
+	return p, nil
+}
+
+func (p *pattern) parseSegment(seg string) error {
+	switch seg {
+	case "":
+		return errors.New("gateway: empty segment")
+	case "*":
+		p.ops = append(p.ops, op{kind: opWildcard})
+	case "**":
+		p.ops = append(p.ops, op{kind: opDeepWildcard})
+	default:
+		p.ops = append(p.ops, op{kind: opLiteral, lit: seg})
+		p.literals++
+	}
+	return nil
+}
+
+func (p *pattern) parseVariable(v string) (err error) {
+	path, segs := v, "*"
+	if idx := strings.IndexByte(v, '='); idx >= 0 {
+		path, segs = v[:idx], v[idx+1:]
+	}
+	if path == "" {
+		return errors.New("gateway: empty variable")
+	}
+	vr := variable{path: path, start: len(p.ops)}
+	for _, seg := range strings.Split(segs, "/") {
+		if err = p.parseSegment(seg); err != nil {
+			return
+		}
+	}
+	vr.end = len(p.ops)
+	if p.ops[len(p.ops)-1].kind == opDeepWildcard {
+		vr.end = -1
+	}
+	p.vars = append(p.vars, vr)
+	return
+}
+
+// match matches the escaped url path and returns the variables.
+func (p *pattern) match(path string) (vars map[string]string, ok bool) {
+	if !strings.HasPrefix(path, "/") {
+		return
+	}
+	path = path[1:]
+	if p.verb != "" {
+		if !strings.HasSuffix(path, ":"+p.verb) {
+			return
+		}
+		path = path[:len(path)-len(p.verb)-1]
+	}
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	for i, o := range p.ops {
+		switch o.kind {
+		case opDeepWildcard:
+			if i > len(parts) {
+				return
+			}
+		case opWildcard:
+			if i >= len(parts) || parts[i] == "" {
+				return
+			}
+		case opLiteral:
+			if i >= len(parts) || parts[i] != o.lit {
+				return
+			}
+		}
+		if i == len(p.ops)-1 && o.kind != opDeepWildcard && len(parts) != len(p.ops) {
+			return
+		}
+	}
+	if len(p.ops) == 0 && len(parts) != 0 {
+		return
+	}
+	vars = make(map[string]string, len(p.vars))
+	for _, v := range p.vars {
+		end := v.end
+		if end < 0 {
+			end = len(parts)
+		}
+		var value string
+		if end-v.start == 1 {
+			var err error
+			if value, err = url.PathUnescape(parts[v.start]); err != nil {
+				return nil, false
+			}
+		} else {
+			value = strings.Join(parts[v.start:end], "/")
+		}
+		vars[v.path] = value
+	}
+	return vars, true
+}
@@ -0,0 +1,98 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/log"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	_bufSize = 1 << 20
+	// _readHeaderTimeout and _readTimeout limit the slow HTTP clients, the
+	// body is read by the timeout of the request (_maxBodySize at most).
+	_readHeaderTimeout = time.Second * 10
+	_readTimeout       = time.Second * 30
+)
+
+// Server serves grpc and HTTP/JSON transcoding on the same listener.
+type Server struct {
+	server *grpc.Server
+
+	mu   sync.Mutex
+	http *http.Server
+	conn *grpc.ClientConn
+}
+
+// NewServer new a gateway server for the grpc server.
+func NewServer(s *grpc.Server) *Server {
+	return &Server{server: s}
+}
+
+// Serve serves lis, grpc services must be registered before Serve.
+// The HTTP/JSON requests are transcoded and invoked through an in-memory
+// grpc connection, so they go through all the server interceptors.
+func (s *Server) Serve(lis net.Listener) (err error) {
+	h2, h1 := Split(lis)
+	bl := bufconn.Listen(_bufSize)
+	go func() {
+		if err := s.server.Serve(bl); err != nil {
+			log.Error("gateway: serve in-memory listener error(%v)", err)
+		}
+	}()
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return bl.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		h2.Close()
+		h1.Close()
+		return errors.WithStack(err)
+	}
+	gw := New(conn)
+	if err = gw.Register(s.server.GetServiceInfo()); err != nil {
+		h2.Close()
+		h1.Close()
+		conn.Close()
+		return
+	}
+	hs := &http.Server{
+		Handler:           gw,
+		ReadHeaderTimeout: _readHeaderTimeout,
+		ReadTimeout:       _readTimeout,
+	}
+	s.mu.Lock()
+	s.http, s.conn = hs, conn
+	s.mu.Unlock()
+	go func() {
+		if err := hs.Serve(h1); err != nil && err != http.ErrServerClosed && err != errListenerClosed {
+			log.Error("gateway: serve http error(%v)", err)
+		}
+	}()
+	return s.server.Serve(h2)
+}
+
+// Shutdown shuts down the HTTP server and closes the in-memory connection,
+// it should be called before the grpc server stops so that the in-flight
+// HTTP requests are finished by the grpc server.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.mu.Lock()
+	hs, conn := s.http, s.conn
+	s.mu.Unlock()
+	if hs != nil {
+		err = hs.Shutdown(ctx)
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	return
+}
@@ -0,0 +1,167 @@
+package locality
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/stat/metric"
+)
+
+// Total is the sum of all zone shares in basis points.
+const Total = 10000
+
+const namespace = "grpc_client"
+
+var (
+	_metricZoneReqTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "zone",
+		Name:      "requests_total",
+		Help:      "grpc client requests count by destination zone.",
+		Labels:    []string{"app", "zone"},
+	})
+	_metricZoneShare = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "zone",
+		Name:      "share",
+		Help:      "grpc client traffic share(basis points) by destination zone.",
+		Labels:    []string{"app", "zone"},
+	})
+)
+
+// Capacity is the instance weight of a zone.
+type Capacity struct {
+	Healthy int64
+	Total   int64
+}
+
+// Priorities returns zones ordered by priority for the local zone.
+// The local zone always comes first, followed by the scheduler destinations
+// of the local zone ordered by scheduler weight, and then all the other zones.
+func Priorities(local string, insInf *naming.InstancesInfo) (zones []string) {
+	seen := map[string]struct{}{local: {}}
+	zones = append(zones, local)
+	for _, sch := range insInf.Scheduler {
+		if sch.Src != local {
+			continue
+		}
+		dst := make([]string, 0, len(sch.Dst))
+		for zone, weight := range sch.Dst {
+			if weight > 0 {
+				dst = append(dst, zone)
+			}
+		}
+		sort.Slice(dst, func(i, j int) bool {
+			if sch.Dst[dst[i]] != sch.Dst[dst[j]] {
+				return sch.Dst[dst[i]] > sch.Dst[dst[j]]
+			}
+			return dst[i] < dst[j]
+		})
+		for _, zone := range dst {
+			if _, ok := seen[zone]; !ok {
+				seen[zone] = struct{}{}
+				zones = append(zones, zone)
+			}
+		}
+	}
+	var rest []string
+	for zone := range insInf.Instances {
+		if _, ok := seen[zone]; !ok {
+			rest = append(rest, zone)
+		}
+	}
+	sort.Strings(rest)
+	return append(zones, rest...)
+}
+
+// Shares computes the traffic share of zones in basis points.
+// Zones are visited in priority order, a zone keeps all the remaining traffic
+// while its healthy capacity ratio is not below threshold, otherwise it keeps
+// a part proportional to ratio/threshold and the rest spills over to the next zone.
+// If all zones are degraded, the shares are scaled up to Total.
+func Shares(zones []string, caps map[string]Capacity, threshold float64) map[string]uint64 {
+	if threshold <= 0 || threshold > 1 {
+		threshold = 1
+	}
+	var (
+		remaining = 1.0
+		fractions = make(map[string]float64, len(zones))
+	)
+	for _, zone := range zones {
+		c := caps[zone]
+		if remaining <= 0 || c.Total <= 0 || c.Healthy <= 0 {
+			continue
+		}
+		h := float64(c.Healthy) / float64(c.Total) / threshold
+		if h > 1 {
+			h = 1
+		}
+		fractions[zone] = remaining * h
+		remaining -= fractions[zone]
+	}
+	shares := make(map[string]uint64, len(zones))
+	if remaining >= 1 {
+		return shares
+	}
+	for zone, f := range fractions {
+		if s := uint64(f/(1-remaining)*Total + 0.5); s > 0 {
+			shares[zone] = s
+		}
+	}
+	return shares
+}
+
+// Report reports the traffic shares of app.
+func Report(app string, zones []string, shares map[string]uint64) {
+	for _, zone := range zones {
+		_metricZoneShare.Set(float64(shares[zone]), app, zone)
+	}
+}
+
+// Picker picks a zone by traffic share.
+type Picker struct {
+	app   string
+	zones []string
+	// bound is the cumulative shares of zones.
+	bound []uint64
+
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// NewPicker new a zone picker for app.
+func NewPicker(app string, shares map[string]uint64) *Picker {
+	p := &Picker{
+		app: app,
+		r:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for zone := range shares {
+		p.zones = append(p.zones, zone)
+	}
+	sort.Strings(p.zones)
+	var sum uint64
+	for _, zone := range p.zones {
+		sum += shares[zone]
+		p.bound = append(p.bound, sum)
+	}
+	return p
+}
+
+// Pick picks a zone and counts it.
+func (p *Picker) Pick() string {
+	if len(p.zones) == 0 {
+		return ""
+	}
+	zone := p.zones[0]
+	if total := p.bound[len(p.bound)-1]; len(p.zones) > 1 && total > 0 {
+		p.mu.Lock()
+		n := uint64(p.r.Int63n(int64(total)))
+		p.mu.Unlock()
+		zone = p.zones[sort.Search(len(p.bound), func(i int) bool { return p.bound[i] > n })]
+	}
+	_metricZoneReqTotal.Inc(p.app, zone)
+	return zone
+}
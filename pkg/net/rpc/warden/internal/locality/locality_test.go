@@ -0,0 +1,72 @@
+package locality
+
+import (
+	"testing"
+
+	"github.com/mapgoo-lab/atreus/pkg/naming"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorities(t *testing.T) {
+	insInf := &naming.InstancesInfo{
+		Instances: map[string][]*naming.Instance{
+			"sh001": nil,
+			"sh002": nil,
+			"sh003": nil,
+			"sh004": nil,
+		},
+		Scheduler: []naming.Zone{
+			{Src: "sh001", Dst: map[string]int64{"sh003": 10, "sh002": 1, "sh004": 0}},
+			{Src: "sh002", Dst: map[string]int64{"sh004": 10}},
+		},
+	}
+	assert.Equal(t, []string{"sh001", "sh003", "sh002", "sh004"}, Priorities("sh001", insInf))
+	assert.Equal(t, []string{"sh002", "sh004", "sh001", "sh003"}, Priorities("sh002", insInf))
+	assert.Equal(t, []string{"sh005", "sh001", "sh002", "sh003", "sh004"}, Priorities("sh005", insInf))
+}
+
+func TestShares(t *testing.T) {
+	zones := []string{"sh001", "sh002", "sh003"}
+	// local zone is healthy
+	shares := Shares(zones, map[string]Capacity{
+		"sh001": {Healthy: 80, Total: 100},
+		"sh002": {Healthy: 100, Total: 100},
+	}, 0.8)
+	assert.Equal(t, map[string]uint64{"sh001": Total}, shares)
+	// local zone spills over
+	shares = Shares(zones, map[string]Capacity{
+		"sh001": {Healthy: 40, Total: 100},
+		"sh002": {Healthy: 100, Total: 100},
+	}, 0.8)
+	assert.Equal(t, map[string]uint64{"sh001": 5000, "sh002": 5000}, shares)
+	// local zone is down
+	shares = Shares(zones, map[string]Capacity{
+		"sh001": {Healthy: 0, Total: 100},
+		"sh002": {Healthy: 40, Total: 100},
+		"sh003": {Healthy: 100, Total: 100},
+	}, 0.8)
+	assert.Equal(t, map[string]uint64{"sh002": 5000, "sh003": 5000}, shares)
+	// all zones are degraded
+	shares = Shares(zones, map[string]Capacity{
+		"sh001": {Healthy: 40, Total: 100},
+		"sh002": {Healthy: 40, Total: 100},
+	}, 0.8)
+	assert.Equal(t, map[string]uint64{"sh001": 6667, "sh002": 3333}, shares)
+	// nothing healthy
+	assert.Empty(t, Shares(zones, map[string]Capacity{"sh001": {Total: 100}}, 0.8))
+}
+
+func TestPicker(t *testing.T) {
+	p := NewPicker("test", map[string]uint64{"sh001": 7500, "sh002": 2500})
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[p.Pick()]++
+	}
+	assert.InDelta(t, 7500, counts["sh001"], 300)
+	assert.InDelta(t, 2500, counts["sh002"], 300)
+
+	p = NewPicker("test", map[string]uint64{"sh001": Total})
+	assert.Equal(t, "sh001", p.Pick())
+	assert.Equal(t, "", NewPicker("test", nil).Pick())
+}
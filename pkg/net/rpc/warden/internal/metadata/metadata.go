@@ -8,4 +8,9 @@ const (
 type MD struct {
 	Weight uint64
 	Color  string
+	// Zone is the zone of the instance.
+	Zone string
+	// Share is the traffic share of the instance's zone in basis points,
+	// zero means locality-aware routing is disabled.
+	Share uint64
 }
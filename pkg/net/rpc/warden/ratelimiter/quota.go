@@ -0,0 +1,113 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/net/claims"
+	nmd "github.com/mapgoo-lab/atreus/pkg/net/metadata"
+	"github.com/mapgoo-lab/atreus/pkg/ratelimit/quota"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// QuotaLimiter is the distributed rate limiter middleware by key.
+type QuotaLimiter struct {
+	keys    []string
+	limiter *quota.Limiter
+}
+
+// NewQuota return a quota middleware which limits the requests by the key
+// built from the sources joined by ":", the sources are:
+//
+//	ip: the remote ip.
+//	method: the full method.
+//	caller: the caller.
+//	user: the user name or the subject of the jwt claims, or the mid if it's not authenticated.
+//	metadata:<key>: the incoming grpc metadata.
+//
+// The request is not limited if all the sources are empty.
+func NewQuota(keys []string, limiter *quota.Limiter) *QuotaLimiter {
+	if len(keys) == 0 {
+		keys = []string{"ip"}
+	}
+	return &QuotaLimiter{keys: keys, limiter: limiter}
+}
+
+// Limit is a server interceptor that rejects the requests exceeding the quota
+// with ecode.LimitExceed, the RateLimit-* headers are sent by the grpc header.
+func (q *QuotaLimiter) Limit() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, args *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		key, ok := q.key(ctx, args.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		res, err := q.limiter.Allow(ctx, key)
+		if res != nil {
+			md := metadata.Pairs(
+				"ratelimit-limit", strconv.FormatInt(res.Limit, 10),
+				"ratelimit-remaining", strconv.FormatInt(res.Remaining, 10),
+				"ratelimit-reset", seconds(res.Reset),
+			)
+			if err != nil {
+				md.Set("retry-after", seconds(res.RetryAfter))
+			}
+			grpc.SetHeader(ctx, md)
+		}
+		if err != nil {
+			return
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (q *QuotaLimiter) key(ctx context.Context, fullMethod string) (string, bool) {
+	var (
+		parts = make([]string, 0, len(q.keys))
+		found bool
+	)
+	for _, k := range q.keys {
+		v := source(ctx, fullMethod, k)
+		found = found || v != ""
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, ":"), found
+}
+
+func source(ctx context.Context, fullMethod, key string) string {
+	name := ""
+	if idx := strings.IndexByte(key, ':'); idx > 0 {
+		key, name = key[:idx], key[idx+1:]
+	}
+	switch key {
+	case "ip":
+		return nmd.String(ctx, nmd.RemoteIP)
+	case "method":
+		return fullMethod
+	case "caller":
+		return nmd.String(ctx, nmd.Caller)
+	case "user":
+		if user := claims.User(ctx); user != "" {
+			return user
+		}
+		if mid := nmd.Value(ctx, nmd.Mid); mid != nil {
+			return fmt.Sprint(mid)
+		}
+	case "metadata":
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if vs := md.Get(name); len(vs) > 0 {
+				return vs[0]
+			}
+		}
+	}
+	return ""
+}
+
+// seconds formats d in seconds rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
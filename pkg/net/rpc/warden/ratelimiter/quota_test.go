@@ -0,0 +1,45 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mapgoo-lab/atreus/pkg/net/claims"
+	nmd "github.com/mapgoo-lab/atreus/pkg/net/metadata"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClaims struct {
+	jwt.RegisteredClaims
+	Name string
+}
+
+func (c *testClaims) Registered() *jwt.RegisteredClaims { return &c.RegisteredClaims }
+
+func (c *testClaims) UserName() string { return c.Name }
+
+func TestQuotaUserSource(t *testing.T) {
+	q := NewQuota([]string{"user", "method"}, nil)
+	ctx := nmd.NewContext(context.Background(), nmd.MD{nmd.Mid: int64(1)})
+	key, ok := q.key(ctx, "/test.Service/Echo")
+	assert.True(t, ok)
+	assert.Equal(t, "1:/test.Service/Echo", key)
+
+	// the authenticated user takes precedence over the mid.
+	tc := &testClaims{Name: "atreus", RegisteredClaims: jwt.RegisteredClaims{Subject: "42"}}
+	key, _ = q.key(claims.NewContext(ctx, tc), "/test.Service/Echo")
+	assert.Equal(t, "atreus:/test.Service/Echo", key)
+
+	tc = &testClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "42"}}
+	key, _ = q.key(claims.NewContext(ctx, tc), "/test.Service/Echo")
+	assert.Equal(t, "42:/test.Service/Echo", key)
+
+	// the claims without the user fall back to the mid.
+	key, _ = q.key(claims.NewContext(ctx, &testClaims{}), "/test.Service/Echo")
+	assert.Equal(t, "1:/test.Service/Echo", key)
+
+	_, ok = NewQuota([]string{"user"}, nil).key(context.Background(), "")
+	assert.False(t, ok)
+}
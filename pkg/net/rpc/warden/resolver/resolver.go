@@ -14,6 +14,7 @@ import (
 	"github.com/mapgoo-lab/atreus/pkg/conf/env"
 	"github.com/mapgoo-lab/atreus/pkg/log"
 	"github.com/mapgoo-lab/atreus/pkg/naming"
+	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/locality"
 	wmeta "github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/metadata"
 
 	farm "github.com/dgryski/go-farm"
@@ -58,6 +59,7 @@ type Builder struct {
 func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
 	var zone = env.Zone
 	ss := int64(50)
+	spillover := float64(0)
 	clusters := map[string]struct{}{}
 	str := strings.SplitN(target.Endpoint, "?", 2)
 	if len(str) == 0 {
@@ -78,6 +80,11 @@ func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts res
 				}
 
 			}
+			if sp, ok := m["spillover"]; ok {
+				if t, err := strconv.ParseFloat(sp[0], 64); err == nil {
+					spillover = t
+				}
+			}
 		}
 	}
 	r := &Resolver{
@@ -87,6 +94,7 @@ func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts res
 		clusters:   clusters,
 		zone:       zone,
 		subsetSize: ss,
+		spillover:  spillover,
 	}
 	go r.updateproc()
 	return r, nil
@@ -102,6 +110,9 @@ type Resolver struct {
 	clusters   map[string]struct{}
 	zone       string
 	subsetSize int64
+	// spillover is the healthy capacity ratio of a zone below which
+	// its traffic spills over to other zones, zero means disabled.
+	spillover float64
 }
 
 // Close is a noop for Resolver.
@@ -129,6 +140,12 @@ func (r *Resolver) updateproc() {
 			}
 		}
 		if ins, ok := r.nr.Fetch(context.Background()); ok {
+			if r.spillover > 0 {
+				if res, shares := r.localize(ins); len(res) > 0 {
+					r.newAddress(res, shares)
+					continue
+				}
+			}
 			instances, _ := ins.Instances[r.zone]
 			res := r.filter(instances)
 			if len(res) == 0 {
@@ -137,7 +154,7 @@ func (r *Resolver) updateproc() {
 				}
 				res = r.filter(instances)
 			}
-			r.newAddress(res)
+			r.newAddress(res, nil)
 		}
 	}
 }
@@ -147,23 +164,10 @@ func (r *Resolver) filter(backends []*naming.Instance) (instances []*naming.Inst
 		return
 	}
 	for _, ins := range backends {
-		//如果r.clusters的长度大于0说明需要进行集群选择
-		if _, ok := r.clusters[ins.Metadata[naming.MetaCluster]]; !ok && len(r.clusters) > 0 {
-			continue
-		}
-		var addr string
-		for _, a := range ins.Addrs {
-			u, err := url.Parse(a)
-			if err == nil && u.Scheme == Scheme {
-				addr = u.Host
-			}
-		}
-		if addr == "" {
-			fmt.Fprintf(os.Stderr, "resolver: app(%s,%s) no valid grpc address(%v) found!", ins.AppID, ins.Hostname, ins.Addrs)
-			log.Warn("resolver: invalid rpc address(%s,%s,%v) found!", ins.AppID, ins.Hostname, ins.Addrs)
-			continue
+		// NOTE: the waiting instances are registered but not ready for traffic.
+		if ins.Status != naming.StatusWaiting && r.valid(ins) {
+			instances = append(instances, ins)
 		}
-		instances = append(instances, ins)
 	}
 	if len(instances) == 0 {
 		for _, bkend := range backends {
@@ -177,6 +181,70 @@ func (r *Resolver) filter(backends []*naming.Instance) (instances []*naming.Inst
 	return
 }
 
+func (r *Resolver) valid(ins *naming.Instance) bool {
+	//如果r.clusters的长度大于0说明需要进行集群选择
+	if _, ok := r.clusters[ins.Metadata[naming.MetaCluster]]; !ok && len(r.clusters) > 0 {
+		return false
+	}
+	var addr string
+	for _, a := range ins.Addrs {
+		u, err := url.Parse(a)
+		if err == nil && u.Scheme == Scheme {
+			addr = u.Host
+		}
+	}
+	if addr == "" {
+		fmt.Fprintf(os.Stderr, "resolver: app(%s,%s) no valid grpc address(%v) found!", ins.AppID, ins.Hostname, ins.Addrs)
+		log.Warn("resolver: invalid rpc address(%s,%s,%v) found!", ins.AppID, ins.Hostname, ins.Addrs)
+		return false
+	}
+	return true
+}
+
+// localize selects the healthy instances of zones by locality, the local zone
+// is preferred and traffic spills over to other zones by priority when the
+// healthy capacity of the local zone drops below r.spillover.
+func (r *Resolver) localize(insInf *naming.InstancesInfo) (instances []*naming.Instance, shares map[string]uint64) {
+	var (
+		app     string
+		zones   = locality.Priorities(r.zone, insInf)
+		caps    = make(map[string]locality.Capacity, len(zones))
+		healthy = make(map[string][]*naming.Instance, len(zones))
+	)
+	for _, zone := range zones {
+		var c locality.Capacity
+		for _, ins := range insInf.Instances[zone] {
+			if !r.valid(ins) {
+				continue
+			}
+			app = ins.AppID
+			weight := instanceWeight(ins)
+			c.Total += weight
+			if ins.Status != naming.StatusWaiting {
+				c.Healthy += weight
+				healthy[zone] = append(healthy[zone], ins)
+			}
+		}
+		caps[zone] = c
+	}
+	shares = locality.Shares(zones, caps, r.spillover)
+	for _, zone := range zones {
+		if shares[zone] == 0 {
+			continue
+		}
+		inss := healthy[zone]
+		if r.subsetSize > 0 {
+			inss = r.subset(inss, env.Hostname, r.subsetSize)
+		}
+		instances = append(instances, inss...)
+	}
+	if len(instances) > 0 {
+		locality.Report(app, zones, shares)
+		log.Info("resolver: app(%s) zone(%s) locality shares(%v)", app, r.zone, shares)
+	}
+	return
+}
+
 func (r *Resolver) subset(backends []*naming.Instance, clientID string, size int64) []*naming.Instance {
 	if len(backends) <= int(size) {
 		return backends
@@ -198,16 +266,20 @@ func (r *Resolver) subset(backends []*naming.Instance, clientID string, size int
 	return backends[int(start) : int(start)+int(size)]
 }
 
-func (r *Resolver) newAddress(instances []*naming.Instance) {
+func instanceWeight(ins *naming.Instance) (weight int64) {
+	if weight, _ = strconv.ParseInt(ins.Metadata[naming.MetaWeight], 10, 64); weight <= 0 {
+		weight = 10
+	}
+	return
+}
+
+func (r *Resolver) newAddress(instances []*naming.Instance, shares map[string]uint64) {
 	if len(instances) <= 0 {
 		return
 	}
 	addrs := make([]resolver.Address, 0, len(instances))
 	for _, ins := range instances {
-		var weight int64
-		if weight, _ = strconv.ParseInt(ins.Metadata[naming.MetaWeight], 10, 64); weight <= 0 {
-			weight = 10
-		}
+		weight := instanceWeight(ins)
 		var rpc string
 		for _, a := range ins.Addrs {
 			u, err := url.Parse(a)
@@ -219,7 +291,12 @@ func (r *Resolver) newAddress(instances []*naming.Instance) {
 			Addr:       rpc,
 			Type:       resolver.Backend,
 			ServerName: ins.AppID,
-			Metadata:   wmeta.MD{Weight: uint64(weight), Color: ins.Metadata[naming.MetaColor]},
+			Metadata: wmeta.MD{
+				Weight: uint64(weight),
+				Color:  ins.Metadata[naming.MetaColor],
+				Zone:   ins.Zone,
+				Share:  shares[ins.Zone],
+			},
 		}
 		addrs = append(addrs, addr)
 	}
@@ -31,6 +31,30 @@ func Test_FilterLittle(t *testing.T) {
 	}
 }
 
+func Test_FilterWaiting(t *testing.T) {
+	var backs []*naming.Instance
+	for i := 0; i < 3; i++ {
+		backs = append(backs, &naming.Instance{
+			Zone:     "sh1",
+			Env:      "prod",
+			AppID:    "2233",
+			Hostname: fmt.Sprintf("linux-%d", i),
+			Addrs:    []string{fmt.Sprintf("grpc://127.0.0.%d:9000", i)},
+			LastTs:   time.Now().Unix(),
+			Status:   naming.StatusUP,
+		})
+	}
+	backs[1].Status = naming.StatusWaiting
+	r := &Resolver{
+		quit: make(chan struct{}, 1),
+		zone: "sh1",
+	}
+
+	if len(r.filter(backs)) != 2 {
+		t.Fatalf("backends length must be 2")
+	}
+}
+
 func Test_FilterBig(t *testing.T) {
 	var backs []*naming.Instance
 	for i := 0; i < 100; i++ {
@@ -123,3 +147,36 @@ func Test_FilterSome(t *testing.T) {
 		t.Fatalf("backends length must be 0")
 	}
 }
+
+func Test_Localize(t *testing.T) {
+	insInf := &naming.InstancesInfo{Instances: make(map[string][]*naming.Instance)}
+	for i, zone := range []string{"sh1", "sh1", "sh1", "sh1", "sh2", "sh2"} {
+		ins := &naming.Instance{
+			Zone:     zone,
+			Env:      "prod",
+			AppID:    "2233",
+			Hostname: fmt.Sprintf("linux-%d", i),
+			Addrs:    []string{fmt.Sprintf("grpc://127.0.0.%d:9000", i)},
+			LastTs:   time.Now().Unix(),
+		}
+		insInf.Instances[zone] = append(insInf.Instances[zone], ins)
+	}
+	r := &Resolver{
+		quit:       make(chan struct{}, 1),
+		zone:       "sh1",
+		subsetSize: 50,
+		spillover:  0.5,
+	}
+	res, shares := r.localize(insInf)
+	if len(res) != 4 || shares["sh1"] != 10000 {
+		t.Fatalf("all traffic must stay in local zone, got %d instances shares %v", len(res), shares)
+	}
+
+	insInf.Instances["sh1"][0].Status = naming.StatusWaiting
+	insInf.Instances["sh1"][1].Status = naming.StatusWaiting
+	insInf.Instances["sh1"][2].Status = naming.StatusWaiting
+	res, shares = r.localize(insInf)
+	if len(res) != 3 || shares["sh1"] != 5000 || shares["sh2"] != 5000 {
+		t.Fatalf("half of traffic must spill over, got %d instances shares %v", len(res), shares)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/mapgoo-lab/atreus/pkg/naming"
 	"github.com/mapgoo-lab/atreus/pkg/net/ip"
 	_ "github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/encoding/json"
+	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/gateway"
 	"github.com/mapgoo-lab/atreus/pkg/net/rpc/warden/internal/status"
 
 	"github.com/pkg/errors"
@@ -72,6 +73,9 @@ type ServerConfig struct {
 	// LogFlag to control log behaviour. e.g. LogFlag: warden.LogFlagDisableLog.
 	// Disable: 1 DisableArgs: 2 DisableInfo: 4
 	LogFlag int8 `dsn:"query.logFlag"`
+	// Gateway enables serving HTTP/1.1 JSON on the same port, requests are transcoded
+	// to the registered grpc services by their google.api.http annotations.
+	Gateway bool `dsn:"query.gateway"`
 }
 
 // Server is the framework's server side instance, it contains the GrpcServer, interceptor and interceptors.
@@ -81,6 +85,7 @@ type Server struct {
 	mutex sync.RWMutex
 
 	server   *grpc.Server
+	gateway  *gateway.Server
 	handlers []grpc.UnaryServerInterceptor
 }
 
@@ -190,6 +195,9 @@ func NewServer(conf *ServerConfig, opt ...grpc.ServerOption) (s *Server) {
 	})
 	opt = append(opt, keepParam, grpc.UnaryInterceptor(s.interceptor))
 	s.server = grpc.NewServer(opt...)
+	if s.conf.Gateway {
+		s.gateway = gateway.NewServer(s.server)
+	}
 	s.Use(s.recovery(), s.handle(), serverLogging(conf.LogFlag), s.stats(), s.validate())
 	//s.Use(ratelimiter.New(nil).Limit())
 	return
@@ -385,6 +393,9 @@ func (s *Server) startWithAddr() (net.Addr, error) {
 // ServerTransport and service goroutine for each.
 // Serve will return a non-nil error unless Stop or GracefulStop is called.
 func (s *Server) Serve(lis net.Listener) error {
+	if s.gateway != nil {
+		return s.gateway.Serve(lis)
+	}
 	return s.server.Serve(lis)
 }
 
@@ -392,6 +403,11 @@ func (s *Server) Serve(lis net.Listener) error {
 // accepting new connections and RPCs and blocks until all the pending RPCs are
 // finished or the context deadline is reached.
 func (s *Server) Shutdown(ctx context.Context) (err error) {
+	// NOTE: the HTTP requests of the gateway are invoked by the grpc server,
+	// so the gateway is shut down first.
+	if s.gateway != nil {
+		err = s.gateway.Shutdown(ctx)
+	}
 	ch := make(chan struct{})
 	go func() {
 		s.server.GracefulStop()
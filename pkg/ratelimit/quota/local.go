@@ -0,0 +1,148 @@
+package quota
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// localBucket is the in-process token bucket with the same algorithm as _tokenBucketScript.
+type localBucket struct {
+	conf *Config
+	rate float64 // tokens per ms
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+func newLocalBucket(c *Config) *localBucket {
+	return &localBucket{
+		conf:    c,
+		rate:    float64(c.Limit) / float64(time.Duration(c.Period)/time.Millisecond),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *localBucket) allow(_ context.Context, key string, now time.Time, cost int64) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	burst := float64(l.conf.Burst)
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, ts: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.ts); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(elapsed)/float64(time.Millisecond)*l.rate)
+	}
+	b.ts = now
+	res := &Result{Limit: l.conf.Burst}
+	if b.tokens >= float64(cost) {
+		b.tokens -= float64(cost)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((float64(cost)-b.tokens)/l.rate)) * time.Millisecond
+	}
+	res.Remaining = int64(b.tokens)
+	res.Reset = time.Duration(math.Ceil((burst-b.tokens)/l.rate)) * time.Millisecond
+	return res, nil
+}
+
+// sweep removes the full buckets every period.
+func (l *localBucket) sweep(now time.Time) {
+	period := time.Duration(l.conf.Period)
+	if now.Sub(l.lastSweep) < period {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(float64(l.conf.Burst)/l.rate) * time.Millisecond
+	for key, b := range l.buckets {
+		if now.Sub(b.ts) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// localWindow is the in-process sliding window with the same algorithm as _slidingWindowScript.
+type localWindow struct {
+	conf   *Config
+	window int64 // ms
+
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+type counter struct {
+	idx  int64
+	curr int64
+	prev int64
+}
+
+func newLocalWindow(c *Config) *localWindow {
+	return &localWindow{
+		conf:     c,
+		window:   int64(time.Duration(c.Period) / time.Millisecond),
+		counters: make(map[string]*counter),
+	}
+}
+
+func (l *localWindow) allow(_ context.Context, key string, now time.Time, cost int64) (*Result, error) {
+	ms := now.UnixNano() / int64(time.Millisecond)
+	idx := ms / l.window
+	elapsed := ms - idx*l.window
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now, idx)
+	c, ok := l.counters[key]
+	if !ok {
+		c = &counter{idx: idx}
+		l.counters[key] = c
+	}
+	switch {
+	case c.idx == idx-1:
+		c.idx, c.prev, c.curr = idx, c.curr, 0
+	case c.idx < idx-1:
+		c.idx, c.prev, c.curr = idx, 0, 0
+	}
+	limit, window := float64(l.conf.Limit), float64(l.window)
+	count := float64(c.prev)*(window-float64(elapsed))/window + float64(c.curr)
+	res := &Result{Limit: l.conf.Limit, Reset: time.Duration(l.window-elapsed) * time.Millisecond}
+	if count+float64(cost) > limit {
+		retry := l.window - elapsed
+		if c.curr+cost <= l.conf.Limit && c.prev > 0 {
+			retry = int64(math.Ceil(window*(1-(limit-float64(c.curr+cost))/float64(c.prev)) - float64(elapsed)))
+		}
+		if retry < 1 {
+			retry = 1
+		}
+		res.RetryAfter = time.Duration(retry) * time.Millisecond
+		res.Remaining = int64(math.Max(0, math.Floor(limit-count)))
+		return res, nil
+	}
+	c.curr += cost
+	res.Allowed = true
+	res.Remaining = int64(math.Max(0, math.Floor(limit-count-float64(cost))))
+	return res, nil
+}
+
+// sweep removes the counters older than the previous window every period.
+func (l *localWindow) sweep(now time.Time, idx int64) {
+	if now.Sub(l.lastSweep) < time.Duration(l.conf.Period) {
+		return
+	}
+	l.lastSweep = now
+	for key, c := range l.counters {
+		if c.idx < idx-1 {
+			delete(l.counters, key)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package quota implements the distributed rate limiter by key for business
+// quotas, e.g. 100 requests per minute per user, which is shared by all the
+// replicas through redis. A local limiter takes over when redis is unavailable.
+package quota
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	"github.com/mapgoo-lab/atreus/pkg/log"
+	"github.com/mapgoo-lab/atreus/pkg/stat/metric"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+)
+
+// The algorithms of the limiter.
+const (
+	TokenBucket   = "token_bucket"
+	SlidingWindow = "sliding_window"
+)
+
+var _metricQuota = metric.NewCounterVec(&metric.CounterVecOpts{
+	Namespace: "ratelimit",
+	Subsystem: "quota",
+	Name:      "total",
+	Help:      "ratelimit quota requests total.",
+	Labels:    []string{"name", "result"},
+})
+
+// Config is the quota limiter config.
+type Config struct {
+	// Name is the name of the quota, it's used as the redis key prefix and the metric label.
+	Name string
+	// Algorithm is TokenBucket or SlidingWindow, TokenBucket by default.
+	Algorithm string
+	// Limit is the requests allowed in every Period.
+	Limit int64
+	// Period is the period of the Limit, 1s by default.
+	Period xtime.Duration
+	// Burst is the capacity of the token bucket, Limit by default.
+	Burst int64
+}
+
+// Result is the result of Allow.
+type Result struct {
+	Allowed bool
+	// Limit is the request quota of the period.
+	Limit int64
+	// Remaining is the remaining quota.
+	Remaining int64
+	// Reset is the time until the quota is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed if it's denied.
+	RetryAfter time.Duration
+}
+
+type algorithm interface {
+	allow(ctx context.Context, key string, now time.Time, cost int64) (*Result, error)
+}
+
+// Limiter is the rate limiter by key.
+type Limiter struct {
+	conf    *Config
+	remote  algorithm
+	local   algorithm
+	logTime int64
+}
+
+// New new a quota limiter, only the local limiter is used if r is nil.
+func New(conf *Config, r *redis.Redis) *Limiter {
+	c := *conf
+	if c.Algorithm == "" {
+		c.Algorithm = TokenBucket
+	}
+	if c.Period <= 0 {
+		c.Period = xtime.Duration(time.Second)
+	}
+	if c.Burst <= 0 {
+		c.Burst = c.Limit
+	}
+	if c.Limit <= 0 {
+		panic("quota: limit must be greater than 0")
+	}
+	l := &Limiter{conf: &c}
+	switch c.Algorithm {
+	case TokenBucket:
+		l.local = newLocalBucket(&c)
+		if r != nil {
+			l.remote = &redisBucket{conf: &c, redis: r}
+		}
+	case SlidingWindow:
+		l.local = newLocalWindow(&c)
+		if r != nil {
+			l.remote = &redisWindow{conf: &c, redis: r}
+		}
+	default:
+		panic("quota: unknown algorithm " + c.Algorithm)
+	}
+	return l
+}
+
+// Allow takes one request from the quota of key, ecode.LimitExceed is
+// returned if the quota is exhausted.
+func (l *Limiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN takes n requests from the quota of key, ecode.LimitExceed is
+// returned if the quota is exhausted.
+func (l *Limiter) AllowN(ctx context.Context, key string, n int64) (res *Result, err error) {
+	now := time.Now()
+	if l.remote != nil {
+		if res, err = l.remote.allow(ctx, key, now, n); err != nil {
+			l.logFallback(key, err)
+		}
+	}
+	if res == nil {
+		_metricQuota.Inc(l.conf.Name, "fallback")
+		if res, err = l.local.allow(ctx, key, now, n); err != nil {
+			return
+		}
+	}
+	if !res.Allowed {
+		_metricQuota.Inc(l.conf.Name, "deny")
+		return res, ecode.LimitExceed
+	}
+	_metricQuota.Inc(l.conf.Name, "allow")
+	return
+}
+
+// logFallback logs the redis error at most once every 3 seconds.
+func (l *Limiter) logFallback(key string, err error) {
+	now := time.Now().UnixNano()
+	if last := atomic.LoadInt64(&l.logTime); now-last > int64(time.Second*3) && atomic.CompareAndSwapInt64(&l.logTime, last, now) {
+		log.Error("quota: name(%s) key(%s) redis error(%v), fallback to local limiter", l.conf.Name, key, err)
+	}
+}
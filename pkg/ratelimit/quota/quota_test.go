@@ -0,0 +1,83 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+	"github.com/mapgoo-lab/atreus/pkg/container/pool"
+	"github.com/mapgoo-lab/atreus/pkg/ecode"
+	xtime "github.com/mapgoo-lab/atreus/pkg/time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBucket(t *testing.T) {
+	l := newLocalBucket(&Config{Limit: 10, Burst: 5, Period: xtime.Duration(time.Second)})
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		res, _ := l.allow(context.TODO(), "a", now, 1)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, int64(4-i), res.Remaining)
+	}
+	res, _ := l.allow(context.TODO(), "a", now, 1)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 100*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 500*time.Millisecond, res.Reset)
+	// another key has its own bucket.
+	res, _ = l.allow(context.TODO(), "b", now, 1)
+	assert.True(t, res.Allowed)
+	// refilled 2 tokens after 200ms.
+	res, _ = l.allow(context.TODO(), "a", now.Add(200*time.Millisecond), 2)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, int64(0), res.Remaining)
+}
+
+func TestLocalWindow(t *testing.T) {
+	l := newLocalWindow(&Config{Limit: 10, Period: xtime.Duration(time.Second)})
+	start := time.Unix(100, 0)
+	for i := 0; i < 10; i++ {
+		res, _ := l.allow(context.TODO(), "a", start, 1)
+		assert.True(t, res.Allowed)
+	}
+	res, _ := l.allow(context.TODO(), "a", start.Add(500*time.Millisecond), 1)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	// the previous window weighs 70% at 300ms of the next window.
+	now := start.Add(1300 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		res, _ = l.allow(context.TODO(), "a", now, 1)
+		assert.True(t, res.Allowed)
+	}
+	res, _ = l.allow(context.TODO(), "a", now, 1)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 100*time.Millisecond, res.RetryAfter)
+	// the previous window is expired.
+	res, _ = l.allow(context.TODO(), "a", start.Add(3*time.Second), 1)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, int64(9), res.Remaining)
+}
+
+func TestFallback(t *testing.T) {
+	r := redis.NewRedis(&redis.Config{
+		Config:       &pool.Config{Active: 1, Idle: 1, IdleTimeout: xtime.Duration(time.Second)},
+		Name:         "quota",
+		Proto:        "tcp",
+		Addr:         "127.0.0.1:1",
+		DialTimeout:  xtime.Duration(100 * time.Millisecond),
+		ReadTimeout:  xtime.Duration(100 * time.Millisecond),
+		WriteTimeout: xtime.Duration(100 * time.Millisecond),
+	})
+	defer r.Close()
+	for _, alg := range []string{TokenBucket, SlidingWindow} {
+		l := New(&Config{Name: "test", Algorithm: alg, Limit: 2, Period: xtime.Duration(time.Minute)}, r)
+		for i := 0; i < 2; i++ {
+			_, err := l.Allow(context.TODO(), "user")
+			assert.NoError(t, err, alg)
+		}
+		res, err := l.Allow(context.TODO(), "user")
+		assert.Equal(t, ecode.LimitExceed, err, alg)
+		assert.False(t, res.Allowed, alg)
+	}
+}
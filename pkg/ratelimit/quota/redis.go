@@ -0,0 +1,117 @@
+package quota
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mapgoo-lab/atreus/pkg/cache/redis"
+
+	"github.com/pkg/errors"
+)
+
+// _tokenBucketScript refills the bucket by the elapsed time and takes the cost.
+// KEYS[1]: bucket key.
+// ARGV: rate(tokens per ms), burst, now(ms), cost.
+// returns: {allowed, remaining, retry_after(ms), reset(ms)}.
+var _tokenBucketScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((cost - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+local reset = math.ceil((burst - tokens) / rate)
+redis.call("PEXPIRE", KEYS[1], reset + 1000)
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// _slidingWindowScript counts the current window and the weighted previous window.
+// KEYS[1]: current window key, KEYS[2]: previous window key.
+// ARGV: limit, window(ms), elapsed(ms) in the current window, cost.
+// returns: {allowed, remaining, retry_after(ms), reset(ms)}.
+var _slidingWindowScript = redis.NewScript(2, `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local curr = tonumber(redis.call("GET", KEYS[1]) or "0")
+local prev = tonumber(redis.call("GET", KEYS[2]) or "0")
+local count = prev * (window - elapsed) / window + curr
+if count + cost > limit then
+	local retry = window - elapsed
+	if curr + cost <= limit and prev > 0 then
+		retry = math.ceil(window * (1 - (limit - curr - cost) / prev) - elapsed)
+	end
+	return {0, math.max(0, math.floor(limit - count)), math.max(1, retry), window - elapsed}
+end
+redis.call("INCRBY", KEYS[1], cost)
+redis.call("PEXPIRE", KEYS[1], window * 2)
+return {1, math.max(0, math.floor(limit - count - cost)), 0, window - elapsed}
+`)
+
+type redisBucket struct {
+	conf  *Config
+	redis *redis.Redis
+}
+
+func (b *redisBucket) allow(ctx context.Context, key string, now time.Time, cost int64) (*Result, error) {
+	rate := float64(b.conf.Limit) / float64(time.Duration(b.conf.Period)/time.Millisecond)
+	conn := b.redis.Conn(ctx)
+	defer conn.Close()
+	reply, err := redis.Int64s(_tokenBucketScript.Do(conn, b.conf.Name+":"+key,
+		strconv.FormatFloat(rate, 'f', -1, 64), b.conf.Burst, now.UnixNano()/int64(time.Millisecond), cost))
+	if err != nil {
+		return nil, errors.Wrapf(err, "quota: token bucket key(%s)", key)
+	}
+	return toResult(reply, b.conf.Burst)
+}
+
+type redisWindow struct {
+	conf  *Config
+	redis *redis.Redis
+}
+
+func (w *redisWindow) allow(ctx context.Context, key string, now time.Time, cost int64) (*Result, error) {
+	window := int64(time.Duration(w.conf.Period) / time.Millisecond)
+	ms := now.UnixNano() / int64(time.Millisecond)
+	idx := ms / window
+	prefix := w.conf.Name + ":" + key + ":"
+	conn := w.redis.Conn(ctx)
+	defer conn.Close()
+	reply, err := redis.Int64s(_slidingWindowScript.Do(conn,
+		prefix+strconv.FormatInt(idx, 10), prefix+strconv.FormatInt(idx-1, 10),
+		w.conf.Limit, window, ms-idx*window, cost))
+	if err != nil {
+		return nil, errors.Wrapf(err, "quota: sliding window key(%s)", key)
+	}
+	return toResult(reply, w.conf.Limit)
+}
+
+func toResult(reply []int64, limit int64) (*Result, error) {
+	if len(reply) != 4 {
+		return nil, errors.Errorf("quota: unexpected script reply %v", reply)
+	}
+	return &Result{
+		Allowed:    reply[0] == 1,
+		Limit:      limit,
+		Remaining:  reply[1],
+		RetryAfter: time.Duration(reply[2]) * time.Millisecond,
+		Reset:      time.Duration(reply[3]) * time.Millisecond,
+	}, nil
+}
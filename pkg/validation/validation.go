@@ -0,0 +1,34 @@
+// Package validation validates the structs by the validate tags of
+// go-playground/validator, it's shared by the blademaster binding and the
+// paladin typed config, so the registered validations apply to both.
+package validation
+
+import (
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// StructValidator is the struct validator interface.
+type StructValidator interface {
+	// ValidateStruct can receive any kind of type and it should never panic, even if the configuration is not right.
+	// If the received type is not a struct, any validation should be skipped and nil must be returned.
+	// If the received type is a struct or pointer to a struct, the validation should be performed.
+	// If the struct is not valid or the validation itself fails, a descriptive error should be returned.
+	// Otherwise nil must be returned.
+	ValidateStruct(interface{}) error
+
+	// RegisterValidation adds a validation Func to a Validate's map of validators denoted by the key
+	// NOTE: if the key already exists, the previous validation function will be replaced.
+	// NOTE: this method is not thread-safe it is intended that these all be registered prior to any validation
+	RegisterValidation(string, validator.Func) error
+}
+
+// Default is the default validator.
+var Default StructValidator = &defaultValidator{}
+
+// Struct validates obj by the default validator.
+func Struct(obj interface{}) error {
+	if Default == nil {
+		return nil
+	}
+	return Default.ValidateStruct(obj)
+}
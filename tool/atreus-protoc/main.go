@@ -27,6 +27,11 @@ func main() {
 			Usage:       "whether to use swagger for generation",
 			Destination: &withSwagger,
 		},
+		cli.BoolFlag{
+			Name:        "openapi",
+			Usage:       "whether to generate OpenAPI 3.0 instead of swagger 2.0",
+			Destination: &withOpenAPI,
+		},
 		cli.BoolFlag{
 			Name:        "ecode",
 			Usage:       "whether to use ecode for generation",
@@ -20,6 +20,7 @@ var (
 	withBM      bool
 	withGRPC    bool
 	withSwagger bool
+	withOpenAPI bool
 	withEcode   bool
 )
 
@@ -31,6 +32,9 @@ func protocAction(ctx *cli.Context) (err error) {
 	if len(files) == 0 {
 		files, _ = filepath.Glob("*.proto")
 	}
+	if withOpenAPI {
+		withSwagger = true
+	}
 	if !withGRPC && !withBM && !withSwagger && !withEcode {
 		withBM = true
 		withGRPC = true
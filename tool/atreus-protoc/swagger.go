@@ -7,6 +7,7 @@ import (
 const (
 	_getSwaggerGen = "go get -u github.com/mapgoo-lab/atreus/tool/protobuf/protoc-gen-bswagger"
 	_swaggerProtoc = "protoc --proto_path=%s --proto_path=%s --proto_path=%s --bswagger_out=:."
+	_openapiProtoc = "protoc --proto_path=%s --proto_path=%s --proto_path=%s --bswagger_out=openapi=3:."
 )
 
 func installSwaggerGen() error {
@@ -19,5 +20,8 @@ func installSwaggerGen() error {
 }
 
 func genSwagger(files []string) error {
+	if withOpenAPI {
+		return generate(_openapiProtoc, files)
+	}
 	return generate(_swaggerProtoc, files)
 }
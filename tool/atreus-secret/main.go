@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+var (
+	keyFile string
+	output  string
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "secret"
+	app.Usage = "paladin配置密文加解密工具"
+	keyFlag := cli.StringFlag{
+		Name:        "key-file, k",
+		Usage:       "base64编码的AES密钥文件, 未指定时使用PALADIN_SECRET_KEY环境变量",
+		EnvVar:      "PALADIN_SECRET_KEY_FILE",
+		Destination: &keyFile,
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:  "genkey",
+			Usage: "生成AES-256密钥",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "output, o",
+					Usage:       "密钥文件, 未指定时输出到stdout",
+					Destination: &output,
+				},
+			},
+			Action: genkeyAction,
+		},
+		{
+			Name:      "encrypt",
+			Aliases:   []string{"e"},
+			Usage:     "加密明文, 输出ENC(...)",
+			ArgsUsage: "[plaintext], 未指定时从stdin读取",
+			Flags:     []cli.Flag{keyFlag},
+			Action:    encryptAction,
+		},
+		{
+			Name:      "decrypt",
+			Aliases:   []string{"d"},
+			Usage:     "解密文本中所有的ENC(...)",
+			ArgsUsage: "[text], 未指定时从stdin读取",
+			Flags:     []cli.Flag{keyFlag},
+			Action:    decryptAction,
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
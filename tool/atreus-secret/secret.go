@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mapgoo-lab/atreus/pkg/conf/paladin"
+
+	"github.com/urfave/cli"
+)
+
+func genkeyAction(ctx *cli.Context) error {
+	key, err := paladin.GenerateAESKey()
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		fmt.Println(key)
+		return nil
+	}
+	if err = ioutil.WriteFile(output, []byte(key+"\n"), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("AES密钥已写入: %s\n", output)
+	return nil
+}
+
+func encryptAction(ctx *cli.Context) error {
+	if err := registerProvider(); err != nil {
+		return err
+	}
+	text, err := input(ctx)
+	if err != nil {
+		return err
+	}
+	enc, err := paladin.EncryptSecret("", strings.TrimRight(text, "\r\n"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(enc)
+	return nil
+}
+
+func decryptAction(ctx *cli.Context) error {
+	if err := registerProvider(); err != nil {
+		return err
+	}
+	text, err := input(ctx)
+	if err != nil {
+		return err
+	}
+	if text, err = paladin.DecryptSecrets(text); err != nil {
+		return err
+	}
+	fmt.Print(text)
+	if !strings.HasSuffix(text, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
+
+// registerProvider registers the AES provider of the key file or the
+// PALADIN_SECRET_KEY env var as the default provider.
+func registerProvider() (err error) {
+	var key []byte
+	if keyFile != "" {
+		if key, err = paladin.LoadAESKey(keyFile); err != nil {
+			return
+		}
+	} else if env := os.Getenv("PALADIN_SECRET_KEY"); env != "" {
+		if key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(env)); err != nil {
+			return
+		}
+	} else {
+		return errors.New("请通过--key-file或者PALADIN_SECRET_KEY环境变量指定AES密钥")
+	}
+	p, err := paladin.NewAESProvider(key)
+	if err != nil {
+		return
+	}
+	paladin.RegisterSecretProvider("", p)
+	return
+}
+
+func input(ctx *cli.Context) (string, error) {
+	if ctx.NArg() > 0 {
+		return strings.Join(ctx.Args(), " "), nil
+	}
+	b, err := ioutil.ReadAll(os.Stdin)
+	return string(b), err
+}
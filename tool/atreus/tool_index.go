@@ -65,6 +65,15 @@ var toolIndexs = []*Tool{
 		Hidden:       true,
 		Requirements: []string{"wire"},
 	},
+	{
+		Name:      "secret",
+		Alias:     "atreus-secret",
+		BuildTime: time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local),
+		Install:   "go get -u github.com/mapgoo-lab/atreus/tool/atreus-secret",
+		Summary:   "paladin配置密文加解密",
+		Platform:  []string{"darwin", "linux", "windows"},
+		Author:    "atreus",
+	},
 	{
 		Name:      "testcli",
 		Alias:     "testcli",
@@ -105,6 +105,22 @@ func GetFieldRequired(
 	reg *typemap.Registry,
 	md *typemap.MessageDefinition,
 ) bool {
+	for _, rule := range GetFieldValidateRules(f, reg, md) {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFieldValidateRules returns the validate rules of the field from
+// gogoproto.moretags or the tags in comment, eg. validate="required,min=1"
+// returns [required min=1].
+func GetFieldValidateRules(
+	f *descriptor.FieldDescriptorProto,
+	reg *typemap.Registry,
+	md *typemap.MessageDefinition,
+) []string {
 	fComment, _ := reg.FieldComments(md, f)
 	var tags []reflect.StructTag
 	{
@@ -118,17 +134,10 @@ func GetFieldRequired(
 		tags = tag.GetTagsInComment(fComment.Leading)
 	}
 	validateTag := tag.GetTagValue("validate", tags)
-	var validateRules []string
-	if validateTag != "" {
-		validateRules = strings.Split(validateTag, ",")
-	}
-	required := false
-	for _, rule := range validateRules {
-		if rule == "required" {
-			required = true
-		}
+	if validateTag == "" {
+		return nil
 	}
-	return required
+	return strings.Split(validateTag, ",")
 }
 
 func MakeIndentStr(i int) string {
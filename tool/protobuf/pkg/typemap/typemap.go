@@ -99,6 +99,21 @@ func (r *Registry) MethodComments(file *descriptor.FileDescriptorProto, svc *des
 	return DefinitionComments{}, errors.Errorf("service not found in file")
 }
 
+// EnumValueComments comment of the value of a top level enum
+func (r *Registry) EnumValueComments(file *descriptor.FileDescriptorProto, enum *descriptor.EnumDescriptorProto, value *descriptor.EnumValueDescriptorProto) (DefinitionComments, error) {
+	for i, e := range file.EnumType {
+		if e == enum {
+			for j, v := range e.Value {
+				if v == value {
+					path := []int32{enumPath, int32(i), enumValuePath, int32(j)}
+					return commentsAtPath(path, file), nil
+				}
+			}
+		}
+	}
+	return DefinitionComments{}, errors.Errorf("enum value not found in file")
+}
+
 // MethodInputDefinition returns MethodInputDefinition
 func (r *Registry) MethodInputDefinition(method *descriptor.MethodDescriptorProto) *MessageDefinition {
 	return r.messagesByProtoName[method.GetInputType()]
@@ -268,10 +283,13 @@ const (
 	// tag numbers in FileDescriptorProto
 	packagePath = 2 // package
 	messagePath = 4 // message_type
+	enumPath    = 5 // enum_type
 	servicePath = 6 // service
 	// tag numbers in DescriptorProto
 	messageFieldPath   = 2 // field
 	messageMessagePath = 3 // nested_type
 	// tag numbers in ServiceDescriptorProto
 	serviceMethodPath = 2 // method
+	// tag numbers in EnumDescriptorProto
+	enumValuePath = 2 // value
 )
@@ -52,6 +52,7 @@ func (t *bm) generateForFile(file *descriptor.FileDescriptorProto) *plugin.CodeG
 	for i, service := range file.Service {
 		count += t.generateBMInterface(file, service)
 		t.generateBMRoute(file, service, i)
+		t.generateBMClient(file, service)
 	}
 
 	resp.Name = proto.String(naming.GenFileName(file, ".bm.go"))
@@ -206,12 +207,12 @@ func (t *bm) generateBMRoute(
 
 		t.P(fmt.Sprintf("func %s (c *bm.Context) {", routeName))
 		t.P(`	p := new(`, inputType, `)`)
-		requestBinding := ""
+		requestBinding := `binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type"))`
 		if t.hasHeaderTag(t.Reg.MessageDefinition(method.GetInputType())) {
-			requestBinding = ", binding.Request"
+			// the client of Call sends the header fields as the request headers.
+			requestBinding = `binding.WithHeader(` + requestBinding + `)`
 		}
-		t.P(`	if err := c.BindWith(p, binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type"))` +
-			requestBinding + `); err != nil {`)
+		t.P(`	if err := c.BindWith(p, `, requestBinding, `); err != nil {`)
 		t.P(`		return`)
 		t.P(`	}`)
 		t.P(`	resp, err := `, svcName, `.`, methName, `(c, p)`)
@@ -268,6 +269,92 @@ func (t *bm) generateBMRoute(
 	}
 }
 
+// generateBMClient generates the typed client of the service which calls the
+// routes registered by generateBMRoute with bm.Client.
+func (t *bm) generateBMClient(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	servName := naming.ServiceName(service)
+	clientName := servName + "BMClient"
+	implName := utils.LcFirst(servName) + "BMClient"
+
+	type methodInfo struct {
+		methName   string
+		httpMethod string
+		path       string
+		inputType  string
+		outputType string
+		dynamic    bool
+		comments   typemap.DefinitionComments
+	}
+	var methList []methodInfo
+	for _, method := range service.Method {
+		if !t.ShouldGenForMethod(file, service, method) {
+			continue
+		}
+		comments, _ := t.Reg.MethodComments(file, service, method)
+		tags := tag.GetTagsInComment(comments.Leading)
+		if tag.GetTagValue("dynamic", tags) == "true" {
+			continue
+		}
+		apiInfo := t.GetHttpInfoCached(file, service, method)
+		path := apiInfo.NewPath
+		if apiInfo.IsLegacyPath {
+			path = apiInfo.LegacyPath
+		}
+		methList = append(methList, methodInfo{
+			methName:   naming.MethodName(method),
+			httpMethod: apiInfo.HttpMethod,
+			path:       path,
+			inputType:  t.GoTypeName(method.GetInputType()),
+			outputType: t.GoTypeName(method.GetOutputType()),
+			dynamic:    tag.GetTagValue("dynamic_resp", tags) == "true",
+			comments:   comments,
+		})
+	}
+
+	t.P()
+	t.P(`// `, clientName, ` is the blademaster client API for `, servName, ` service.`)
+	t.P(`type `, clientName, ` interface {`)
+	for _, m := range methList {
+		t.PrintComments(m.comments)
+		if m.dynamic {
+			t.P(`	`, m.methName, `(ctx context.Context, req *`, m.inputType, `) (resp interface{}, err error)`)
+		} else {
+			t.P(`	`, m.methName, `(ctx context.Context, req *`, m.inputType, `) (resp *`, m.outputType, `, err error)`)
+		}
+		t.P()
+	}
+	t.P(`}`)
+	t.P()
+	t.P(`type `, implName, ` struct {`)
+	t.P(`	client *bm.Client`)
+	t.P(`	host   string`)
+	t.P(`}`)
+	t.P()
+	t.P(`// New`, clientName, ` new a blademaster client of `, servName, ` service, host is like http://127.0.0.1:8000.`)
+	t.P(`// The path of each method is the key of bm.ClientConfig.URL to tune the timeout and breaker.`)
+	t.P(`func New`, clientName, `(client *bm.Client, host string) `, clientName, ` {`)
+	t.P(`	return &`, implName, `{client: client, host: host}`)
+	t.P(`}`)
+	for _, m := range methList {
+		t.P()
+		if m.dynamic {
+			t.P(`func (c *`, implName, `) `, m.methName, `(ctx context.Context, req *`, m.inputType, `) (resp interface{}, err error) {`)
+			t.P(`	err = c.client.Call(ctx, "`, m.httpMethod, `", c.host, "`, m.path, `", req, &resp)`)
+		} else {
+			t.P(`func (c *`, implName, `) `, m.methName, `(ctx context.Context, req *`, m.inputType, `) (resp *`, m.outputType, `, err error) {`)
+			t.P(`	out := new(`, m.outputType, `)`)
+			t.P(`	if err = c.client.Call(ctx, "`, m.httpMethod, `", c.host, "`, m.path, `", req, out); err != nil {`)
+			t.P(`		return nil, err`)
+			t.P(`	}`)
+			t.P(`	return out, nil`)
+			t.P(`}`)
+			continue
+		}
+		t.P(`	return`)
+		t.P(`}`)
+	}
+}
+
 func (t *bm) hasHeaderTag(md *typemap.MessageDefinition) bool {
 	if md.Descriptor.Field == nil {
 		return false
@@ -276,9 +363,6 @@ func (t *bm) hasHeaderTag(md *typemap.MessageDefinition) bool {
 		t := tag.GetMoreTags(f)
 		if t != nil {
 			st := reflect.StructTag(*t)
-			if st.Get("request") != "" {
-				return true
-			}
 			if st.Get("header") != "" {
 				return true
 			}
@@ -19,6 +19,7 @@ import (
 type SwaggerParams struct {
 	generator.ParamsBase
 	isSimpleJson bool
+	openapi      string
 }
 
 func (b *SwaggerParams) GetBase() *generator.ParamsBase {
@@ -33,6 +34,9 @@ func (b *SwaggerParams) SetParam(key string, value string) error {
 		}
 
 	}
+	if key == "openapi" {
+		b.openapi = value
+	}
 	return nil
 }
 
@@ -42,6 +46,8 @@ type swaggerGen struct {
 	// key is full qualified proto name
 	defsMap      map[string]*typemap.MessageDefinition
 	isSimpleJson bool
+	// openapi is the version of OpenAPI to generate, swagger 2.0 is generated if empty.
+	openapi string
 }
 
 // NewSwaggerGenerator a swagger generator
@@ -53,12 +59,21 @@ func (t *swaggerGen) Generate(in *plugin.CodeGeneratorRequest) *plugin.CodeGener
 	params := &SwaggerParams{}
 	t.Setup(in, params)
 	t.isSimpleJson = params.isSimpleJson
+	t.openapi = params.openapi
+	if t.openapi != "" && t.openapi != "3" {
+		gen.Fail("unsupported openapi version " + t.openapi + ", only openapi=3 is supported")
+	}
 	resp := &plugin.CodeGeneratorResponse{}
 	for _, f := range t.GenFiles {
 		if len(f.Service) == 0 {
 			continue
 		}
-		respFile := t.generateSwagger(f)
+		var respFile *plugin.CodeGeneratorResponse_File
+		if t.openapi != "" {
+			respFile = t.generateOpenAPI(f)
+		} else {
+			respFile = t.generateSwagger(f)
+		}
 		if respFile != nil {
 			resp.File = append(resp.File, respFile)
 		}
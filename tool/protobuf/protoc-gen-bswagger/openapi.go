@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/mapgoo-lab/atreus/tool/protobuf/pkg/generator"
+	"github.com/mapgoo-lab/atreus/tool/protobuf/pkg/naming"
+	"github.com/mapgoo-lab/atreus/tool/protobuf/pkg/tag"
+	"github.com/mapgoo-lab/atreus/tool/protobuf/pkg/typemap"
+)
+
+const (
+	_statusSchema  = "ecode.Status"
+	_errorResponse = "Error"
+)
+
+// https://spec.openapis.org/oas/v3.0.3#openapi-object
+type openapiObject struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       swaggerInfoObject    `json:"info"`
+	Paths      openapiPathsObject   `json:"paths"`
+	Components openapiComponents    `json:"components"`
+	Tags       []openapiTagObject   `json:"tags,omitempty"`
+	Ecodes     []openapiEcodeObject `json:"x-ecodes,omitempty"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#tag-object
+type openapiTagObject struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// openapiEcodeObject is the business error code declared by the *ErrCode enums.
+type openapiEcodeObject struct {
+	Code        int32  `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#components-object
+type openapiComponents struct {
+	Schemas   map[string]*openapiSchemaObject   `json:"schemas"`
+	Responses map[string]*openapiResponseObject `json:"responses,omitempty"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#paths-object
+type openapiPathsObject map[string]*openapiPathItemObject
+
+// https://spec.openapis.org/oas/v3.0.3#path-item-object
+type openapiPathItemObject struct {
+	Get    *openapiOperationObject `json:"get,omitempty"`
+	Put    *openapiOperationObject `json:"put,omitempty"`
+	Post   *openapiOperationObject `json:"post,omitempty"`
+	Delete *openapiOperationObject `json:"delete,omitempty"`
+	Patch  *openapiOperationObject `json:"patch,omitempty"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#operation-object
+type openapiOperationObject struct {
+	Tags        []string                          `json:"tags,omitempty"`
+	Summary     string                            `json:"summary,omitempty"`
+	Description string                            `json:"description,omitempty"`
+	OperationID string                            `json:"operationId,omitempty"`
+	Parameters  []*openapiParameterObject         `json:"parameters,omitempty"`
+	RequestBody *openapiRequestBodyObject         `json:"requestBody,omitempty"`
+	Responses   map[string]*openapiResponseObject `json:"responses"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#parameter-object
+type openapiParameterObject struct {
+	Name        string               `json:"name"`
+	In          string               `json:"in"`
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Schema      *openapiSchemaObject `json:"schema"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#request-body-object
+type openapiRequestBodyObject struct {
+	Required bool                           `json:"required,omitempty"`
+	Content  map[string]*openapiMediaObject `json:"content"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#media-type-object
+type openapiMediaObject struct {
+	Schema *openapiSchemaObject `json:"schema"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#response-object
+type openapiResponseObject struct {
+	Ref         string                         `json:"$ref,omitempty"`
+	Description string                         `json:"description,omitempty"`
+	Content     map[string]*openapiMediaObject `json:"content,omitempty"`
+}
+
+// https://spec.openapis.org/oas/v3.0.3#schema-object
+type openapiSchemaObject struct {
+	Ref                  string                         `json:"$ref,omitempty"`
+	Type                 string                         `json:"type,omitempty"`
+	Format               string                         `json:"format,omitempty"`
+	Description          string                         `json:"description,omitempty"`
+	Items                *openapiSchemaObject           `json:"items,omitempty"`
+	Properties           *swaggerSchemaObjectProperties `json:"properties,omitempty"`
+	AdditionalProperties *openapiSchemaObject           `json:"additionalProperties,omitempty"`
+	Required             []string                       `json:"required,omitempty"`
+	Enum                 []interface{}                  `json:"enum,omitempty"`
+	Minimum              *float64                       `json:"minimum,omitempty"`
+	ExclusiveMinimum     bool                           `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64                       `json:"maximum,omitempty"`
+	ExclusiveMaximum     bool                           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *uint64                        `json:"minLength,omitempty"`
+	MaxLength            *uint64                        `json:"maxLength,omitempty"`
+	MinItems             *uint64                        `json:"minItems,omitempty"`
+	MaxItems             *uint64                        `json:"maxItems,omitempty"`
+}
+
+func (t *swaggerGen) generateOpenAPI(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	var vStr string
+	if strs := regexp.MustCompile(`v(\d+)$`).FindStringSubmatch(file.GetPackage()); len(strs) >= 2 {
+		vStr = strs[1]
+	}
+	doc := &openapiObject{
+		OpenAPI: "3.0.3",
+		Info: swaggerInfoObject{
+			Title:   file.GetName(),
+			Version: vStr,
+		},
+		Paths: openapiPathsObject{},
+		Components: openapiComponents{
+			Schemas:   map[string]*openapiSchemaObject{},
+			Responses: map[string]*openapiResponseObject{},
+		},
+	}
+	fileComment, _ := t.Reg.FileComments(file)
+	if len(fileComment.Leading) > 0 {
+		split := strings.Split(fileComment.Leading, "\n")
+		doc.Info.Title = split[0]
+		doc.Info.Description = strings.Trim(strings.Join(split[1:], "\n"), "\r\n ")
+	}
+	doc.Ecodes = t.fileEcodes(file)
+	doc.Components.Schemas[_statusSchema] = t.statusSchema(doc.Ecodes)
+	doc.Components.Responses[_errorResponse] = &openapiResponseObject{
+		Description: "The error response, the body is the ecode of the error.",
+		Content:     jsonContent(&openapiSchemaObject{Ref: schemaRef(_statusSchema)}),
+	}
+
+	t.defsMap = map[string]*typemap.MessageDefinition{}
+	for _, svc := range file.Service {
+		svcComment, _ := t.Reg.ServiceComments(file, svc)
+		svcTag := svc.GetName()
+		if len(svcComment.Leading) > 0 {
+			svcTag = svcComment.Leading
+		}
+		doc.Tags = append(doc.Tags, openapiTagObject{Name: svcTag})
+		for _, meth := range svc.Method {
+			if !t.ShouldGenForMethod(file, svc, meth) {
+				continue
+			}
+			apiInfo := t.GetHttpInfoCached(file, svc, meth)
+			pathItem, ok := doc.Paths[apiInfo.Path]
+			if !ok {
+				pathItem = &openapiPathItemObject{}
+				doc.Paths[apiInfo.Path] = pathItem
+			}
+			op := &openapiOperationObject{
+				Tags:        []string{svcTag},
+				Summary:     apiInfo.Title,
+				Description: apiInfo.Description,
+				OperationID: svc.GetName() + "_" + meth.GetName(),
+			}
+			setOpenAPIOperation(apiInfo.HttpMethod, pathItem, op)
+
+			// request
+			request := t.Reg.MessageDefinition(meth.GetInputType())
+			isComplexRequest := false
+			for _, field := range request.Descriptor.Field {
+				if !generator.IsScalar(field) {
+					isComplexRequest = true
+					break
+				}
+			}
+			if !isComplexRequest && (apiInfo.HttpMethod == http.MethodGet || apiInfo.HttpMethod == http.MethodDelete) {
+				for _, field := range request.Descriptor.Field {
+					op.Parameters = append(op.Parameters, t.openapiQueryParameter(request, field))
+				}
+			} else {
+				op.RequestBody = &openapiRequestBodyObject{
+					Required: true,
+					Content:  jsonContent(&openapiSchemaObject{Ref: schemaRef(meth.GetInputType())}),
+				}
+				if !isComplexRequest {
+					op.RequestBody.Content["application/x-www-form-urlencoded"] = &openapiMediaObject{
+						Schema: &openapiSchemaObject{Ref: schemaRef(meth.GetInputType())},
+					}
+				}
+			}
+
+			// response
+			// proto 里面的response只定义data里面的
+			// 所以需要把code msg data 这一级加上
+			data := &openapiSchemaObject{Ref: schemaRef(meth.GetOutputType())}
+			schema := data
+			if !t.isSimpleJson {
+				schema = &openapiSchemaObject{
+					Type: "object",
+					Properties: &swaggerSchemaObjectProperties{
+						{Key: "code", Value: &openapiSchemaObject{Type: "integer", Description: "0 is success, the others are ecodes, see x-ecodes."}},
+						{Key: "message", Value: &openapiSchemaObject{Type: "string"}},
+						{Key: "ttl", Value: &openapiSchemaObject{Type: "integer"}},
+						{Key: "data", Value: data},
+					},
+				}
+			}
+			op.Responses = map[string]*openapiResponseObject{
+				"200": {
+					Description: "A successful response.",
+					Content:     jsonContent(schema),
+				},
+				"default": {Ref: "#/components/responses/" + _errorResponse},
+			}
+		}
+	}
+
+	t.walkThroughFileDefinition(file)
+	for typ, msg := range t.defsMap {
+		def := &openapiSchemaObject{
+			Type:        "object",
+			Description: strings.Trim(msg.Comments.Leading, "\n\r "),
+			Properties:  new(swaggerSchemaObjectProperties),
+		}
+		for _, field := range msg.Descriptor.Field {
+			name := generator.GetFormOrJSONName(field)
+			if generator.GetFieldRequired(field, t.Reg, msg) {
+				def.Required = append(def.Required, name)
+			}
+			*def.Properties = append(*def.Properties, keyVal{Key: name, Value: t.openapiSchemaForField(msg, field)})
+		}
+		doc.Components.Schemas[schemaName(typ)] = def
+	}
+
+	b, _ := json.MarshalIndent(doc, "", "    ")
+	str := string(b)
+	name := naming.GenFileName(file, ".openapi.json")
+	return &plugin.CodeGeneratorResponse_File{Name: &name, Content: &str}
+}
+
+// fileEcodes returns the ecodes of the *ErrCode enums like protoc-gen-ecode.
+func (t *swaggerGen) fileEcodes(file *descriptor.FileDescriptorProto) (ecodes []openapiEcodeObject) {
+	for _, enum := range file.EnumType {
+		if !strings.HasSuffix(enum.GetName(), "ErrCode") {
+			continue
+		}
+		for _, v := range enum.Value {
+			if v.GetNumber() == 0 {
+				continue
+			}
+			comment, _ := t.Reg.EnumValueComments(file, enum, v)
+			desc := comment.Trailing
+			if desc == "" {
+				desc = comment.Leading
+			}
+			ecodes = append(ecodes, openapiEcodeObject{
+				Code:        v.GetNumber(),
+				Name:        v.GetName(),
+				Description: strings.Trim(desc, "\n\r "),
+			})
+		}
+	}
+	sort.Slice(ecodes, func(i, j int) bool { return ecodes[i].Code < ecodes[j].Code })
+	return
+}
+
+// statusSchema is the schema of the ecode response.
+func (t *swaggerGen) statusSchema(ecodes []openapiEcodeObject) *openapiSchemaObject {
+	code := &openapiSchemaObject{Type: "integer", Description: "the ecode, see x-ecodes."}
+	for _, e := range ecodes {
+		code.Enum = append(code.Enum, e.Code)
+	}
+	return &openapiSchemaObject{
+		Type:        "object",
+		Description: "The business error of ecode.",
+		Properties: &swaggerSchemaObjectProperties{
+			{Key: "code", Value: code},
+			{Key: "message", Value: &openapiSchemaObject{Type: "string"}},
+		},
+		Required: []string{"code", "message"},
+	}
+}
+
+func (t *swaggerGen) openapiQueryParameter(msg *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto) *openapiParameterObject {
+	fComment, _ := t.Reg.FieldComments(msg, field)
+	schema := t.openapiSchemaForField(msg, field)
+	schema.Description = ""
+	return &openapiParameterObject{
+		Name:        generator.GetFormOrJSONName(field),
+		In:          "query",
+		Description: strings.Trim(strings.Join(tag.GetCommentWithoutTag(fComment.Leading), "\n"), "\n\r "),
+		Required:    generator.GetFieldRequired(field, t.Reg, msg),
+		Schema:      schema,
+	}
+}
+
+func (t *swaggerGen) openapiSchemaForField(msg *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto) *openapiSchemaObject {
+	fComment, _ := t.Reg.FieldComments(msg, field)
+	schema := &openapiSchemaObject{
+		Description: strings.Trim(strings.Join(tag.GetCommentWithoutTag(fComment.Leading), "\n"), "\n\r "),
+	}
+	typ, isArray, format := getFieldSwaggerType(field)
+	item := schema
+	if !generator.IsScalar(field) {
+		if generator.IsMap(field, t.Reg) {
+			mapMsg := t.Reg.MessageDefinition(field.GetTypeName())
+			schema.Type = "object"
+			schema.AdditionalProperties = t.openapiSchemaForField(mapMsg, mapMsg.Descriptor.Field[1])
+			schema.AdditionalProperties.Description = ""
+			// only the rules of the values after dive apply to the map.
+			rules := generator.GetFieldValidateRules(field, t.Reg, msg)
+			for i, rule := range rules {
+				if rule == "dive" {
+					applyValidateRules(schema, rules[i:])
+					break
+				}
+			}
+			return schema
+		}
+		if isArray {
+			schema.Type = "array"
+			schema.Items = &openapiSchemaObject{Ref: schemaRef(field.GetTypeName())}
+		} else {
+			schema.Ref = schemaRef(field.GetTypeName())
+		}
+		return schema
+	}
+	if isArray {
+		schema.Type = "array"
+		item = &openapiSchemaObject{}
+		schema.Items = item
+	}
+	item.Type = typ
+	item.Format = format
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES {
+		item.Format = "byte"
+	}
+	applyValidateRules(schema, generator.GetFieldValidateRules(field, t.Reg, msg))
+	return schema
+}
+
+// applyValidateRules maps the validator rules of binding to the schema
+// constraints. The rules after dive are applied to the items of the array,
+// the keys of the map between keys and endkeys are skipped.
+func applyValidateRules(schema *openapiSchemaObject, rules []string) {
+	for i, rule := range rules {
+		name, param := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		switch name {
+		case "dive":
+			items := schema.Items
+			if items == nil {
+				items = schema.AdditionalProperties
+			}
+			if items != nil && items.Ref == "" {
+				applyValidateRules(items, skipKeys(rules[i+1:]))
+			}
+			return
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			setLimit(schema, name, n)
+		case "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			if schema.Type == "string" || schema.Type == "array" {
+				// the exclusive limit of the length
+				switch name {
+				case "gt":
+					setLimit(schema, "min", n+1)
+				case "gte":
+					setLimit(schema, "min", n)
+				case "lt":
+					setLimit(schema, "max", n-1)
+				case "lte":
+					setLimit(schema, "max", n)
+				}
+				continue
+			}
+			if name[0] == 'g' {
+				schema.Minimum = &n
+				schema.ExclusiveMinimum = name == "gt"
+			} else {
+				schema.Maximum = &n
+				schema.ExclusiveMaximum = name == "lt"
+			}
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if schema.Type == "integer" || schema.Type == "number" {
+					if n, err := strconv.ParseFloat(v, 64); err == nil {
+						schema.Enum = append(schema.Enum, n)
+					}
+					continue
+				}
+				schema.Enum = append(schema.Enum, v)
+			}
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid":
+			schema.Format = "uuid"
+		case "ip", "ipv4":
+			schema.Format = "ipv4"
+		case "ipv6":
+			schema.Format = "ipv6"
+		}
+	}
+}
+
+// skipKeys skips the rules of the map keys after dive.
+func skipKeys(rules []string) []string {
+	if len(rules) == 0 || rules[0] != "keys" {
+		return rules
+	}
+	for i, rule := range rules {
+		if rule == "endkeys" {
+			return rules[i+1:]
+		}
+	}
+	return nil
+}
+
+// setLimit sets min, max or len which means the length for strings and
+// arrays, or the value for numbers like the validator does.
+func setLimit(schema *openapiSchemaObject, name string, n float64) {
+	if n < 0 {
+		n = 0
+	}
+	u := uint64(n)
+	switch schema.Type {
+	case "string":
+		if name != "max" {
+			schema.MinLength = &u
+		}
+		if name != "min" {
+			schema.MaxLength = &u
+		}
+	case "array":
+		if name != "max" {
+			schema.MinItems = &u
+		}
+		if name != "min" {
+			schema.MaxItems = &u
+		}
+	default:
+		if name != "max" {
+			schema.Minimum = &n
+		}
+		if name != "min" {
+			schema.Maximum = &n
+		}
+	}
+}
+
+func setOpenAPIOperation(httpMethod string, pathItem *openapiPathItemObject, op *openapiOperationObject) {
+	switch httpMethod {
+	case http.MethodPost:
+		pathItem.Post = op
+	case http.MethodPut:
+		pathItem.Put = op
+	case http.MethodDelete:
+		pathItem.Delete = op
+	case http.MethodPatch:
+		pathItem.Patch = op
+	default:
+		pathItem.Get = op
+	}
+}
+
+func jsonContent(schema *openapiSchemaObject) map[string]*openapiMediaObject {
+	return map[string]*openapiMediaObject{"application/json": {Schema: schema}}
+}
+
+// schemaName trims the leading dot of the full qualified proto name.
+func schemaName(protoName string) string {
+	return strings.TrimPrefix(protoName, ".")
+}
+
+func schemaRef(protoName string) string {
+	return "#/components/schemas/" + schemaName(protoName)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/mapgoo-lab/atreus/tool/protobuf/pkg/extensions/gogoproto"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func fieldWithTags(name string, number int32, typ descriptor.FieldDescriptorProto_Type, tags string) *descriptor.FieldDescriptorProto {
+	f := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+	if tags != "" {
+		f.Options = &descriptor.FieldOptions{}
+		if err := proto.SetExtension(f.Options, gogoproto.E_Moretags, proto.String(tags)); err != nil {
+			panic(err)
+		}
+	}
+	return f
+}
+
+// demoFile is the descriptor of:
+//
+//	syntax = "proto3";
+//	package demo.v1;
+//	option go_package = "v1";
+//
+//	enum DemoErrCode {
+//	  OK = 0;
+//	  // the user is not found.
+//	  UserNotFound = 10404;
+//	}
+//	// the hello request.
+//	message HelloReq {
+//	  string name = 1 [(gogoproto.moretags) = 'validate:"required,min=2,max=10"'];
+//	  repeated string tags = 2 [(gogoproto.moretags) = 'validate:"max=3,dive,max=5"'];
+//	  map<string, int32> scores = 3 [(gogoproto.moretags) = 'validate:"dive,keys,max=5,endkeys,gte=0"'];
+//	}
+//	message PingReq {
+//	  int64 id = 1 [(gogoproto.moretags) = 'validate:"gt=0"'];
+//	}
+//	message HelloReply {
+//	  string content = 1;
+//	}
+//	service Demo {
+//	  // say hello
+//	  rpc SayHello(HelloReq) returns (HelloReply) {
+//	    option (google.api.http) = { post: "/demo/hello" };
+//	  }
+//	  rpc Ping(PingReq) returns (HelloReply);
+//	}
+func demoFile() *descriptor.FileDescriptorProto {
+	tags := fieldWithTags("tags", 2, descriptor.FieldDescriptorProto_TYPE_STRING, `validate:"max=3,dive,max=5"`)
+	tags.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	scores := fieldWithTags("scores", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, `validate:"dive,keys,max=5,endkeys,gte=0"`)
+	scores.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	scores.TypeName = proto.String(".demo.v1.HelloReq.ScoresEntry")
+	sayHello := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("SayHello"),
+		InputType:  proto.String(".demo.v1.HelloReq"),
+		OutputType: proto.String(".demo.v1.HelloReply"),
+		Options:    &descriptor.MethodOptions{},
+	}
+	if err := proto.SetExtension(sayHello.Options, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Post{Post: "/demo/hello"},
+	}); err != nil {
+		panic(err)
+	}
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("demo/v1/demo.proto"),
+		Package: proto.String("demo.v1"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("v1")},
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name: proto.String("DemoErrCode"),
+			Value: []*descriptor.EnumValueDescriptorProto{
+				{Name: proto.String("OK"), Number: proto.Int32(0)},
+				{Name: proto.String("UserNotFound"), Number: proto.Int32(10404)},
+			},
+		}},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("HelloReq"),
+				Field: []*descriptor.FieldDescriptorProto{
+					fieldWithTags("name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, `validate:"required,min=2,max=10"`),
+					tags,
+					scores,
+				},
+				NestedType: []*descriptor.DescriptorProto{{
+					Name: proto.String("ScoresEntry"),
+					Field: []*descriptor.FieldDescriptorProto{
+						fieldWithTags("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+						fieldWithTags("value", 2, descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+					},
+					Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			},
+			{
+				Name:  proto.String("PingReq"),
+				Field: []*descriptor.FieldDescriptorProto{fieldWithTags("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, `validate:"gt=0"`)},
+			},
+			{
+				Name:  proto.String("HelloReply"),
+				Field: []*descriptor.FieldDescriptorProto{fieldWithTags("content", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "")},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Demo"),
+			Method: []*descriptor.MethodDescriptorProto{
+				sayHello,
+				{
+					Name:       proto.String("Ping"),
+					InputType:  proto.String(".demo.v1.PingReq"),
+					OutputType: proto.String(".demo.v1.HelloReply"),
+				},
+			},
+		}},
+		SourceCodeInfo: &descriptor.SourceCodeInfo{
+			Location: []*descriptor.SourceCodeInfo_Location{
+				{Path: []int32{5, 0, 2, 1}, LeadingComments: proto.String(" the user is not found.\n")},
+				{Path: []int32{4, 0}, LeadingComments: proto.String(" the hello request.\n")},
+				{Path: []int32{6, 0, 2, 0}, LeadingComments: proto.String(" say hello\n")},
+			},
+		},
+	}
+}
+
+func TestGenerateOpenAPIGolden(t *testing.T) {
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"demo/v1/demo.proto"},
+		Parameter:      proto.String("openapi=3"),
+		ProtoFile:      []*descriptor.FileDescriptorProto{demoFile()},
+	}
+	resp := NewSwaggerGenerator().Generate(req)
+	if len(resp.File) != 1 {
+		t.Fatalf("got %d files, want 1", len(resp.File))
+	}
+	if got := resp.File[0].GetName(); got != "demo/v1/demo.openapi.json" {
+		t.Fatalf("got file name %s", got)
+	}
+	golden := filepath.Join("testdata", "demo.openapi.json")
+	got := resp.File[0].GetContent()
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Fatalf("the generated openapi differs from %s, run go test -update to update it:\n%s", golden, got)
+	}
+}
+
+func TestApplyValidateRules(t *testing.T) {
+	s := &openapiSchemaObject{Type: "string"}
+	applyValidateRules(s, []string{"required", "min=2", "max=10", "email"})
+	if *s.MinLength != 2 || *s.MaxLength != 10 || s.Format != "email" {
+		t.Fatalf("unexpected string schema %+v", s)
+	}
+
+	n := &openapiSchemaObject{Type: "integer"}
+	applyValidateRules(n, []string{"gt=0", "lte=100", "oneof=1 2 3"})
+	if *n.Minimum != 0 || !n.ExclusiveMinimum || *n.Maximum != 100 || n.ExclusiveMaximum || len(n.Enum) != 3 {
+		t.Fatalf("unexpected integer schema %+v", n)
+	}
+
+	a := &openapiSchemaObject{Type: "array", Items: &openapiSchemaObject{Type: "string"}}
+	applyValidateRules(a, []string{"len=3", "dive", "max=5"})
+	if *a.MinItems != 3 || *a.MaxItems != 3 || a.Items.MinLength != nil || *a.Items.MaxLength != 5 {
+		t.Fatalf("unexpected array schema %+v", a)
+	}
+
+	m := &openapiSchemaObject{Type: "object", AdditionalProperties: &openapiSchemaObject{Type: "integer"}}
+	applyValidateRules(m, []string{"dive", "keys", "max=5", "endkeys", "gte=1"})
+	if m.Minimum != nil || *m.AdditionalProperties.Minimum != 1 || m.AdditionalProperties.MaxLength != nil {
+		t.Fatalf("unexpected map schema %+v", m.AdditionalProperties)
+	}
+}